@@ -0,0 +1,247 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambient
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/exp/maps"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/istio/cni/pkg/ambient/ambientpod"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/pkg/env"
+)
+
+var (
+	consistencyCheckInterval = env.Register("AMBIENT_CONSISTENCY_CHECK_INTERVAL", time.Minute,
+		"The interval at which the node redirection state is compared against the pods enrolled in the mesh. "+
+			"Set to 0 to disable the consistency checks.").Get()
+	consistencyRepair = env.Register("AMBIENT_CONSISTENCY_REPAIR", true,
+		"If enabled, the drift found by the consistency checks is repaired, otherwise it is only reported.").Get()
+)
+
+// ConsistencyDebugPath is the debug endpoint serving the last consistency report.
+const ConsistencyDebugPath = "/debug/ambient/consistency"
+
+const (
+	// DriftMissing means an enrolled pod is not redirected by the host state.
+	DriftMissing = "missing"
+	// DriftStale means the host state redirects an IP that does not belong to an enrolled pod.
+	DriftStale = "stale"
+)
+
+// Host state kinds holding the redirection of pods to ztunnel.
+const (
+	IpsetKind = "ipset"
+	RouteKind = "route"
+	EbpfKind  = "ebpf"
+)
+
+// hostRedirection reads and mutates the redirection of pods to ztunnel programmed on the node.
+type hostRedirection interface {
+	// List returns the IPs redirected to ztunnel, for each kind of host state.
+	List() (map[string]sets.String, error)
+	// Add redirects the traffic of the pod to ztunnel.
+	Add(pod *corev1.Pod) error
+	// Remove deletes the redirection of the IP from the given kind of host state.
+	Remove(kind, ip string) error
+}
+
+// Drift is an inconsistency between the pods enrolled in the mesh and the node redirection state.
+type Drift struct {
+	Kind string `json:"kind"`
+	Type string `json:"type"`
+	IP   string `json:"ip"`
+	// Pod is the namespace/name of the enrolled pod, only set for missing entries.
+	Pod string `json:"pod,omitempty"`
+	// Repaired is set when the repair was applied successfully. The next check verifies it.
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ConsistencyReport is the outcome of a consistency check.
+type ConsistencyReport struct {
+	Time         time.Time `json:"time"`
+	RedirectMode string    `json:"redirectMode"`
+	EnrolledPods int       `json:"enrolledPods"`
+	Drift        []Drift   `json:"drift"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// consistencyCheck is enqueued to run the check in order with the pod events.
+type consistencyCheck struct{}
+
+func (s *Server) runConsistencyChecks(stop <-chan struct{}) {
+	if consistencyCheckInterval <= 0 {
+		log.Infof("ambient consistency checks are disabled")
+		return
+	}
+	ticker := time.NewTicker(consistencyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if s.isZTunnelRunning() {
+				s.queue.Add(consistencyCheck{})
+			}
+		}
+	}
+}
+
+// CheckConsistency compares the pods enrolled in the mesh against the node redirection state.
+func (s *Server) CheckConsistency() *ConsistencyReport {
+	var enrolled, enabled []*corev1.Pod
+	for _, pod := range s.pods.List(metav1.NamespaceAll, klabels.Everything()) {
+		if ztunnelPod(pod) {
+			continue
+		}
+		if pod.Annotations[constants.AmbientRedirection] == constants.AmbientRedirectionEnabled {
+			enrolled = append(enrolled, pod)
+		}
+		if ns := s.namespaces.Get(pod.Namespace, ""); ns != nil && ambientpod.PodZtunnelEnabled(ns, pod) {
+			enabled = append(enabled, pod)
+		}
+	}
+	report := checkConsistency(s.redirection, enrolled, enabled, consistencyRepair)
+	report.RedirectMode = s.redirectMode.String()
+
+	s.mu.Lock()
+	s.consistencyReport = report
+	s.mu.Unlock()
+	return report
+}
+
+// checkConsistency reports the enrolled pods missing from the host state, and the host state entries not
+// belonging to an enrolled pod. Entries of pods about to be enrolled are not stale, to not race with their
+// enrollment. If repair is set, the drift is repaired.
+func checkConsistency(r hostRedirection, enrolled, enabled []*corev1.Pod, repair bool) *ConsistencyReport {
+	report := &ConsistencyReport{Time: time.Now(), EnrolledPods: len(enrolled), Drift: []Drift{}}
+	observed, err := r.List()
+	if err != nil {
+		report.Error = err.Error()
+		consistencyChecks.With(resultLabel.Value(resultError)).Increment()
+		return report
+	}
+
+	expected := map[string]*corev1.Pod{}
+	for _, pod := range enrolled {
+		if ip := pod.Status.PodIP; ip != "" && !isTerminal(pod) {
+			expected[ip] = pod
+		}
+	}
+	allowed := sets.New[string]()
+	for _, pod := range enabled {
+		if ip := pod.Status.PodIP; ip != "" && !isTerminal(pod) {
+			allowed.Insert(ip)
+		}
+	}
+
+	kinds := make([]string, 0, len(observed))
+	for kind := range observed {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	missingPods := map[string]*corev1.Pod{}
+	for _, kind := range kinds {
+		for _, ip := range sets.SortedList(sets.New(maps.Keys(expected)...).Difference(observed[kind])) {
+			pod := expected[ip]
+			missingPods[ip] = pod
+			report.Drift = append(report.Drift, Drift{Kind: kind, Type: DriftMissing, IP: ip, Pod: pod.Namespace + "/" + pod.Name})
+		}
+		for _, ip := range sets.SortedList(observed[kind]) {
+			if _, f := expected[ip]; !f && !allowed.Contains(ip) {
+				report.Drift = append(report.Drift, Drift{Kind: kind, Type: DriftStale, IP: ip})
+			}
+		}
+	}
+
+	if repair {
+		// A pod is added to every kind of host state at once, only add it once.
+		addErrs := map[string]error{}
+		for ip, pod := range missingPods {
+			addErrs[ip] = r.Add(pod)
+		}
+		for i := range report.Drift {
+			d := &report.Drift[i]
+			if d.Type == DriftMissing {
+				err = addErrs[d.IP]
+			} else {
+				err = r.Remove(d.Kind, d.IP)
+			}
+			if err != nil {
+				d.Error = err.Error()
+			} else {
+				d.Repaired = true
+			}
+		}
+	}
+
+	current := map[[2]string]int{}
+	for _, kind := range kinds {
+		current[[2]string{kind, DriftMissing}] = 0
+		current[[2]string{kind, DriftStale}] = 0
+	}
+	for _, d := range report.Drift {
+		result := resultReported
+		if d.Repaired {
+			result = resultRepaired
+		} else if d.Error != "" {
+			result = resultFailed
+		}
+		log.Warnf("ambient redirection drift: %s %s entry for %s (%s), %s", d.Type, d.Kind, d.IP, d.Pod, result)
+		redirectionDrift.With(kindLabel.Value(d.Kind), driftLabel.Value(d.Type), resultLabel.Value(result)).Increment()
+		current[[2]string{d.Kind, d.Type}]++
+	}
+	for k, v := range current {
+		redirectionDriftCurrent.With(kindLabel.Value(k[0]), driftLabel.Value(k[1])).Record(float64(v))
+	}
+	if len(report.Drift) > 0 {
+		consistencyChecks.With(resultLabel.Value(resultDrift)).Increment()
+	} else {
+		consistencyChecks.With(resultLabel.Value(resultOK)).Increment()
+	}
+	return report
+}
+
+// consistencyHandler serves the last consistency report.
+func (s *Server) consistencyHandler(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	report := s.consistencyReport
+	s.mu.Unlock()
+	if report == nil {
+		http.Error(w, "no consistency check has run yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(b)
+}
+
+func isTerminal(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambient
+
+import (
+	"fmt"
+	"net/netip"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/cni/pkg/ambient/constants"
+	"istio.io/istio/cni/pkg/hostnet"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// iptablesRedirection is the host state of the iptables redirect mode: the ipset matched by the
+// iptables rules, and the routes of the inbound route table.
+type iptablesRedirection struct{}
+
+var _ hostRedirection = iptablesRedirection{}
+
+func (iptablesRedirection) List() (map[string]sets.String, error) {
+	entries, err := Ipset.List()
+	if err != nil {
+		return nil, err
+	}
+	ipset := sets.New[string]()
+	for _, entry := range entries {
+		ipset.Insert(entry.IP.String())
+	}
+
+	routes, err := hostNet.RouteList(constants.RouteTableInbound)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes of table %d: %v", constants.RouteTableInbound, err)
+	}
+	gw := netip.MustParseAddr(constants.ZTunnelInboundTunIP)
+	podRoutes := sets.New[string]()
+	for _, route := range routes {
+		// Only the pod routes go through the inbound tunnel, the route to ztunnel itself is a link route.
+		if route.Gw != gw || !route.Dst.IsSingleIP() {
+			continue
+		}
+		podRoutes.Insert(route.Dst.Addr().String())
+	}
+	return map[string]sets.String{IpsetKind: ipset, RouteKind: podRoutes}, nil
+}
+
+func (iptablesRedirection) Add(pod *corev1.Pod) error {
+	return addPodToMeshWithIptables(pod, "")
+}
+
+func (iptablesRedirection) Remove(kind, ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case IpsetKind:
+		return Ipset.DeleteIP(addr)
+	case RouteKind:
+		return hostNet.RouteDel(&hostnet.Route{
+			Table: constants.RouteTableInbound,
+			Dst:   netip.PrefixFrom(addr, addr.BitLen()),
+		})
+	}
+	return fmt.Errorf("unknown redirection kind %q", kind)
+}
+
+// ebpfRedirection is the host state of the eBPF redirect mode: the workloads of the app info map.
+type ebpfRedirection struct {
+	s *Server
+}
+
+var _ hostRedirection = ebpfRedirection{}
+
+func (r ebpfRedirection) List() (map[string]sets.String, error) {
+	ips, err := r.s.ebpfServer.AppIPs()
+	if err != nil {
+		return nil, err
+	}
+	res := sets.New[string]()
+	for _, ip := range ips {
+		res.Insert(ip.String())
+	}
+	return map[string]sets.String{EbpfKind: res}, nil
+}
+
+func (r ebpfRedirection) Add(pod *corev1.Pod) error {
+	return r.s.updatePodEbpfOnNode(pod)
+}
+
+func (r ebpfRedirection) Remove(kind, ip string) error {
+	if kind != EbpfKind {
+		return fmt.Errorf("unknown redirection kind %q", kind)
+	}
+	return r.s.delPodEbpfOnNode(ip)
+}
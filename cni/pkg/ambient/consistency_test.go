@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambient
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+type fakeRedirection struct {
+	state   map[string]sets.String
+	listErr error
+	added   []string
+	removed []string
+}
+
+func (f *fakeRedirection) List() (map[string]sets.String, error) {
+	return f.state, f.listErr
+}
+
+func (f *fakeRedirection) Add(pod *corev1.Pod) error {
+	f.added = append(f.added, pod.Name)
+	if pod.Name == "broken" {
+		return errors.New("no veth")
+	}
+	return nil
+}
+
+func (f *fakeRedirection) Remove(kind, ip string) error {
+	f.removed = append(f.removed, kind+"/"+ip)
+	return nil
+}
+
+func makeConsistencyPod(name, ip string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: ip, Phase: phase},
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	a := makeConsistencyPod("a", "10.0.0.1", corev1.PodRunning)
+	b := makeConsistencyPod("b", "10.0.0.2", corev1.PodRunning)
+	broken := makeConsistencyPod("broken", "10.0.0.3", corev1.PodRunning)
+	completed := makeConsistencyPod("completed", "10.0.0.4", corev1.PodSucceeded)
+	pending := makeConsistencyPod("pending", "", corev1.PodPending)
+	cases := []struct {
+		name        string
+		state       map[string]sets.String
+		listErr     error
+		enrolled    []*corev1.Pod
+		enabled     []*corev1.Pod
+		repair      bool
+		wantDrift   []Drift
+		wantAdded   []string
+		wantRemoved []string
+		wantErr     bool
+	}{
+		{
+			name:      "in sync",
+			state:     map[string]sets.String{IpsetKind: sets.New("10.0.0.1", "10.0.0.2"), RouteKind: sets.New("10.0.0.1", "10.0.0.2")},
+			enrolled:  []*corev1.Pod{a, b, pending},
+			repair:    true,
+			wantDrift: []Drift{},
+		},
+		{
+			name:     "missing pod repaired once",
+			state:    map[string]sets.String{IpsetKind: sets.New("10.0.0.1"), RouteKind: sets.New("10.0.0.1")},
+			enrolled: []*corev1.Pod{a, b},
+			repair:   true,
+			wantDrift: []Drift{
+				{Kind: IpsetKind, Type: DriftMissing, IP: "10.0.0.2", Pod: "default/b", Repaired: true},
+				{Kind: RouteKind, Type: DriftMissing, IP: "10.0.0.2", Pod: "default/b", Repaired: true},
+			},
+			wantAdded: []string{"b"},
+		},
+		{
+			name:     "stale entries removed",
+			state:    map[string]sets.String{EbpfKind: sets.New("10.0.0.1", "10.0.0.4", "10.0.0.9")},
+			enrolled: []*corev1.Pod{a, completed},
+			repair:   true,
+			wantDrift: []Drift{
+				{Kind: EbpfKind, Type: DriftStale, IP: "10.0.0.4", Repaired: true},
+				{Kind: EbpfKind, Type: DriftStale, IP: "10.0.0.9", Repaired: true},
+			},
+			wantRemoved: []string{"ebpf/10.0.0.4", "ebpf/10.0.0.9"},
+		},
+		{
+			name:      "pod being enrolled is not stale",
+			state:     map[string]sets.String{IpsetKind: sets.New("10.0.0.1", "10.0.0.2")},
+			enrolled:  []*corev1.Pod{a},
+			enabled:   []*corev1.Pod{a, b},
+			repair:    true,
+			wantDrift: []Drift{},
+		},
+		{
+			name:     "failed repair",
+			state:    map[string]sets.String{EbpfKind: sets.New[string]()},
+			enrolled: []*corev1.Pod{broken},
+			repair:   true,
+			wantDrift: []Drift{
+				{Kind: EbpfKind, Type: DriftMissing, IP: "10.0.0.3", Pod: "default/broken", Error: "no veth"},
+			},
+			wantAdded: []string{"broken"},
+		},
+		{
+			name:     "report only",
+			state:    map[string]sets.String{IpsetKind: sets.New("10.0.0.9")},
+			enrolled: []*corev1.Pod{a},
+			wantDrift: []Drift{
+				{Kind: IpsetKind, Type: DriftMissing, IP: "10.0.0.1", Pod: "default/a"},
+				{Kind: IpsetKind, Type: DriftStale, IP: "10.0.0.9"},
+			},
+		},
+		{
+			name:      "list error",
+			listErr:   errors.New("ipset not found"),
+			enrolled:  []*corev1.Pod{a},
+			repair:    true,
+			wantDrift: []Drift{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRedirection{state: tt.state, listErr: tt.listErr}
+			report := checkConsistency(r, tt.enrolled, tt.enabled, tt.repair)
+			assert.Equal(t, report.Drift, tt.wantDrift)
+			assert.Equal(t, report.Error != "", tt.wantErr)
+			assert.Equal(t, r.added, tt.wantAdded)
+			assert.Equal(t, r.removed, tt.wantRemoved)
+		})
+	}
+}
@@ -89,6 +89,10 @@ func (s *Server) EnqueueNamespace(o controllers.Object) {
 }
 
 func (s *Server) Reconcile(input any) error {
+	if _, ok := input.(consistencyCheck); ok {
+		s.CheckConsistency()
+		return nil
+	}
 	event := input.(controllers.Event)
 	log := log.WithLabels("type", event.Event)
 	pod := event.Latest().(*corev1.Pod)
@@ -0,0 +1,54 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambient
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	kindLabel  = monitoring.MustCreateLabel("kind")
+	driftLabel = monitoring.MustCreateLabel("drift")
+
+	resultLabel    = monitoring.MustCreateLabel("result")
+	resultOK       = "ok"
+	resultDrift    = "drift"
+	resultError    = "error"
+	resultRepaired = "repaired"
+	resultFailed   = "failed"
+	resultReported = "reported"
+
+	consistencyChecks = monitoring.NewSum(
+		"istio_cni_ambient_consistency_checks_total",
+		"Total number of ambient node redirection consistency checks",
+		monitoring.WithLabels(resultLabel),
+	)
+
+	redirectionDrift = monitoring.NewSum(
+		"istio_cni_ambient_redirection_drift_total",
+		"Total number of drifted ambient node redirection entries found by the consistency checks",
+		monitoring.WithLabels(kindLabel, driftLabel, resultLabel),
+	)
+
+	redirectionDriftCurrent = monitoring.NewGauge(
+		"istio_cni_ambient_redirection_drift",
+		"Number of drifted ambient node redirection entries found by the last consistency check",
+		monitoring.WithLabels(kindLabel, driftLabel),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(consistencyChecks, redirectionDrift, redirectionDriftCurrent)
+}
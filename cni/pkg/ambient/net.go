@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
-	"os"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -28,6 +27,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 
+	"istio.io/istio/cni/pkg/ambient/constants"
+	"istio.io/istio/cni/pkg/hostnet"
 	pconstants "istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
@@ -36,73 +37,72 @@ import (
 
 var log = istiolog.RegisterScope("ambient", "ambient controller")
 
-func RouteExists(rte []string) bool {
-	output, err := executeOutput(
-		"bash", "-c",
-		fmt.Sprintf("ip route show %s | wc -l", strings.Join(rte, " ")),
-	)
+// RouteExists returns true if a route with the same table, destination and type exists.
+func RouteExists(route *hostnet.Route) bool {
+	routes, err := hostNet.RouteList(route.Table)
 	if err != nil {
+		log.Warnf("failed to check route %v: %v", route, err)
 		return false
 	}
-
-	log.Debugf("RouteExists(%s): %s", strings.Join(rte, " "), output)
-
-	return output == "1"
+	for i := range routes {
+		if routes[i].Matches(route) {
+			return true
+		}
+	}
+	return false
 }
 
-func AddPodToMesh(client kubernetes.Interface, pod *corev1.Pod, ip string) {
-	addPodToMeshWithIptables(pod, ip)
+func AddPodToMesh(client kubernetes.Interface, pod *corev1.Pod, ip string) error {
+	return addPodToMeshWithIptables(pod, ip)
 }
 
-func addPodToMeshWithIptables(pod *corev1.Pod, ip string) {
+// addPodToMeshWithIptables adds the pod to the ipset and the inbound route table. The rp_filter of the pod
+// device is disabled on a best effort basis, as some platforms do not allow it.
+func addPodToMeshWithIptables(pod *corev1.Pod, ip string) error {
 	if ip == "" {
 		ip = pod.Status.PodIP
 	}
 	if ip == "" {
 		log.Debugf("skip adding pod %s/%s, IP not yet allocated", pod.Name, pod.Namespace)
-		return
+		return nil
+	}
+	podIP, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("failed to parse IP %q of pod %s: %v", ip, pod.Name, err)
 	}
 
 	if !IsPodInIpset(pod) {
 		log.Infof("Adding pod '%s/%s' (%s) to ipset", pod.Name, pod.Namespace, string(pod.UID))
-		err := Ipset.AddIP(net.ParseIP(ip).To4(), string(pod.UID))
+		err := Ipset.AddIP(podIP, string(pod.UID))
 		if err != nil {
-			log.Errorf("Failed to add pod %s to ipset list: %v", pod.Name, err)
+			return fmt.Errorf("failed to add pod %s to ipset list: %v", pod.Name, err)
 		}
 	} else {
 		log.Infof("Pod '%s/%s' (%s) is in ipset", pod.Name, pod.Namespace, string(pod.UID))
 	}
 
-	rte, err := buildRouteFromPod(pod, ip)
-	if err != nil {
-		log.Errorf("Failed to build route for pod %s: %v", pod.Name, err)
-	}
-
+	rte := buildRouteFromPod(podIP)
 	if !RouteExists(rte) {
-		log.Infof("Adding route for %s/%s: %+v", pod.Name, pod.Namespace, rte)
-		// @TODO Try and figure out why buildRouteFromPod doesn't return a good route that we can
-		// use err = netlink.RouteAdd(rte):
-		// Error: {"level":"error","time":"2022-06-24T16:30:59.083809Z","msg":"Failed to add route ({Ifindex: 4 Dst: 10.244.2.7/32
-		// Via: Family: 2, Address: 192.168.126.2 Src: 10.244.2.1 Gw: <nil> Flags: [] Table: 100 Realm: 0}) for pod
-		// helloworld-v2-same-node-67b6b764bf-zhmp4: invalid argument"}
-		err = execute("ip", append([]string{"route", "add"}, rte...)...)
+		log.Infof("Adding route for %s/%s: %v", pod.Name, pod.Namespace, rte)
+		err = hostNet.RouteAdd(rte)
 		if err != nil {
-			log.Warnf("Failed to add route (%s) for pod %s: %v", rte, pod.Name, err)
+			return fmt.Errorf("failed to add route for pod %s: %v", pod.Name, err)
 		}
 	} else {
-		log.Infof("Route already exists for %s/%s: %+v", pod.Name, pod.Namespace, rte)
+		log.Infof("Route already exists for %s/%s: %v", pod.Name, pod.Namespace, rte)
 	}
 
-	dev, err := getDeviceWithDestinationOf(ip)
+	dev, err := getDeviceWithDestinationOf(podIP)
 	if err != nil {
-		log.Warnf("Failed to get device for destination %s", ip)
-		return
+		log.Warnf("Failed to get device for destination %s: %v", ip, err)
+		return nil
 	}
 
 	err = disableRPFiltersForLink(dev)
 	if err != nil {
 		log.Warnf("failed to disable procfs rp_filter for device %s: %v", dev, err)
 	}
+	return nil
 }
 
 var annotationPatch = []byte(fmt.Sprintf(
@@ -151,29 +151,94 @@ func AnnotateUnenrollPod(client kubernetes.Interface, pod *corev1.Pod) error {
 
 func DelPodFromMesh(client kubernetes.Interface, pod *corev1.Pod) {
 	log.Debugf("Removing pod '%s/%s' (%s) from mesh", pod.Name, pod.Namespace, string(pod.UID))
+	podIP, err := netip.ParseAddr(pod.Status.PodIP)
+	if err != nil {
+		log.Errorf("Failed to parse IP %q of pod %s: %v", pod.Status.PodIP, pod.Name, err)
+		return
+	}
 	if IsPodInIpset(pod) {
 		log.Infof("Removing pod '%s' (%s) from ipset", pod.Name, string(pod.UID))
-		err := Ipset.DeleteIP(net.ParseIP(pod.Status.PodIP).To4())
+		err := Ipset.DeleteIP(podIP)
 		if err != nil {
 			log.Errorf("Failed to delete pod %s from ipset list: %v", pod.Name, err)
 		}
 	} else {
 		log.Infof("Pod '%s/%s' (%s) is not in ipset", pod.Name, pod.Namespace, string(pod.UID))
 	}
-	rte, err := buildRouteFromPod(pod, "")
-	if err != nil {
-		log.Errorf("Failed to build route for pod %s: %v", pod.Name, err)
-	}
+	rte := buildRouteFromPod(podIP)
 	if RouteExists(rte) {
-		log.Infof("Removing route: %+v", rte)
-		// @TODO Try and figure out why buildRouteFromPod doesn't return a good route that we can
-		// use this:
-		// err = netlink.RouteDel(rte)
-		err = execute("ip", append([]string{"route", "del"}, rte...)...)
+		log.Infof("Removing route: %v", rte)
+		err = hostNet.RouteDel(rte)
 		if err != nil {
-			log.Warnf("Failed to delete route (%s) for pod %s: %v", rte, pod.Name, err)
+			log.Warnf("Failed to delete route for pod %s: %v", pod.Name, err)
+		}
+	}
+}
+
+// IsPodInIpset returns true if the pod UID or IP is a member of the ipset.
+func IsPodInIpset(pod *corev1.Pod) bool {
+	ipset, err := Ipset.List()
+	if err != nil {
+		log.Errorf("Failed to list ipset entries: %v", err)
+		return false
+	}
+
+	// Since not all kernels support comments in ipset, we should also try and
+	// match against the IP
+	for _, ip := range ipset {
+		if ip.Comment == string(pod.UID) {
+			return true
+		}
+		if ip.IP.String() == pod.Status.PodIP {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildRouteFromPod returns the route sending the traffic to the pod through ztunnel.
+func buildRouteFromPod(podIP netip.Addr) *hostnet.Route {
+	route := &hostnet.Route{
+		Table: constants.RouteTableInbound,
+		Dst:   netip.PrefixFrom(podIP, podIP.BitLen()),
+		Gw:    netip.MustParseAddr(constants.ZTunnelInboundTunIP),
+		Dev:   constants.InboundTun,
+	}
+	if hostIP, err := netip.ParseAddr(HostIP); err == nil {
+		route.Src = hostIP
+	}
+	return route
+}
+
+// getDeviceWithDestinationOf returns the device of the main table route to the IP.
+func getDeviceWithDestinationOf(ip netip.Addr) (string, error) {
+	routes, err := hostNet.RouteList(hostnet.MainTable)
+	if err != nil {
+		return "", err
+	}
+	dst := netip.PrefixFrom(ip, ip.BitLen())
+	for _, route := range routes {
+		if route.Dst == dst && route.Dev != "" {
+			return route.Dev, nil
 		}
 	}
+	return "", fmt.Errorf("no routes found for %s", ip)
+}
+
+func disableRPFiltersForLink(ifaceName string) error {
+	// Need to do some work in procfs
+	// @TODO: This needs to be cleaned up, there are a lot of martians in AWS
+	// that seem to necessitate this work and in theory we shouldn't *need* to disable
+	// `rp_filter` with eBPF.
+	for _, iface := range []string{"default", "all", ifaceName} {
+		if err := SetSysctl("net/ipv4/conf/"+iface+"/rp_filter", "0"); err != nil {
+			log.Errorf("failed to disable rp_filter: %v", err)
+			return err
+		}
+	}
+
+	return nil
 }
 
 // GetHostIPByRoute get the automatically chosen host ip to the Pod's CIDR
@@ -261,7 +326,9 @@ func GetHostIP(kubeClient kubernetes.Interface) (string, error) {
 func (s *Server) AddPodToMesh(pod *corev1.Pod) {
 	switch s.redirectMode {
 	case IptablesMode:
-		AddPodToMesh(s.kubeClient.Kube(), pod, "")
+		if err := AddPodToMesh(s.kubeClient.Kube(), pod, ""); err != nil {
+			log.Errorf("failed to add pod to mesh: %v", err)
+		}
 	case EbpfMode:
 		if err := s.updatePodEbpfOnNode(pod); err != nil {
 			log.Errorf("failed to update POD ebpf: %v", err)
@@ -294,6 +361,7 @@ func (s *Server) DelPodFromMesh(pod *corev1.Pod, event controllers.Event) {
 	}
 }
 
-func SetProc(path string, value string) error {
-	return os.WriteFile(path, []byte(value), 0o644)
+// SetSysctl writes the value of the sysctl, given as its path relative to /proc/sys.
+func SetSysctl(key, value string) error {
+	return hostNet.SetSysctl(key, value)
 }
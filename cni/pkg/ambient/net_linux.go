@@ -24,7 +24,6 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
-	"strings"
 
 	netns "github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
@@ -33,29 +32,9 @@ import (
 
 	"istio.io/istio/cni/pkg/ambient/constants"
 	ebpf "istio.io/istio/cni/pkg/ebpf/server"
+	"istio.io/istio/cni/pkg/hostnet"
 )
 
-func IsPodInIpset(pod *corev1.Pod) bool {
-	ipset, err := Ipset.List()
-	if err != nil {
-		log.Errorf("Failed to list ipset entries: %v", err)
-		return false
-	}
-
-	// Since not all kernels support comments in ipset, we should also try and
-	// match against the IP
-	for _, ip := range ipset {
-		if ip.Comment == string(pod.UID) {
-			return true
-		}
-		if ip.IP.String() == pod.Status.PodIP {
-			return true
-		}
-	}
-
-	return false
-}
-
 func buildEbpfArgsByIP(ip string, isZtunnel, isRemove bool) (*ebpf.RedirectArgs, error) {
 	ipAddr, err := netip.ParseAddr(ip)
 	if err != nil {
@@ -232,14 +211,6 @@ func getVethWithDestinationOf(ip string) (*netlink.Veth, error) {
 	return veth, nil
 }
 
-func getDeviceWithDestinationOf(ip string) (string, error) {
-	link, err := getLinkWithDestinationOf(ip)
-	if err != nil {
-		return "", err
-	}
-	return link.Attrs().Name, nil
-}
-
 func GetIndexAndPeerMac(podIfName, ns string) (int, net.HardwareAddr, error) {
 	var hostIfIndex int
 	var hwAddr net.HardwareAddr
@@ -592,18 +563,13 @@ func (s *Server) CreateRulesOnNode(ztunnelVeth, ztunnelIP string, captureDNS boo
 	// Need to do some work in procfs
 	// @TODO: This likely needs to be cleaned up, there are a lot of martians in AWS
 	// that seem to necessitate this work.
-	procs := map[string]int{
-		"/proc/sys/net/ipv4/conf/default/rp_filter":                0,
-		"/proc/sys/net/ipv4/conf/all/rp_filter":                    0,
-		"/proc/sys/net/ipv4/conf/" + ztunnelVeth + "/rp_filter":    0,
-		"/proc/sys/net/ipv4/conf/" + ztunnelVeth + "/accept_local": 1,
-	}
-	for proc, val := range procs {
-		err = SetProc(proc, fmt.Sprint(val))
-		if err != nil {
-			log.Errorf("failed to write to proc file %s: %v", proc, err)
-		}
+	sysctls := map[string]int{
+		"net/ipv4/conf/default/rp_filter":                0,
+		"net/ipv4/conf/all/rp_filter":                    0,
+		"net/ipv4/conf/" + ztunnelVeth + "/rp_filter":    0,
+		"net/ipv4/conf/" + ztunnelVeth + "/accept_local": 1,
 	}
+	setSysctls(sysctls)
 
 	// Create tunnels
 	inbnd := &netlink.Geneve{
@@ -659,18 +625,13 @@ func (s *Server) CreateRulesOnNode(ztunnelVeth, ztunnelIP string, captureDNS boo
 		log.Errorf("failed to set outbound tunnel up: %v", err)
 	}
 
-	procs = map[string]int{
-		"/proc/sys/net/ipv4/conf/" + constants.InboundTun + "/rp_filter":     0,
-		"/proc/sys/net/ipv4/conf/" + constants.InboundTun + "/accept_local":  1,
-		"/proc/sys/net/ipv4/conf/" + constants.OutboundTun + "/rp_filter":    0,
-		"/proc/sys/net/ipv4/conf/" + constants.OutboundTun + "/accept_local": 1,
-	}
-	for proc, val := range procs {
-		err = SetProc(proc, fmt.Sprint(val))
-		if err != nil {
-			log.Errorf("failed to write to proc file %s: %v", proc, err)
-		}
+	sysctls = map[string]int{
+		"net/ipv4/conf/" + constants.InboundTun + "/rp_filter":     0,
+		"net/ipv4/conf/" + constants.InboundTun + "/accept_local":  1,
+		"net/ipv4/conf/" + constants.OutboundTun + "/rp_filter":    0,
+		"net/ipv4/conf/" + constants.OutboundTun + "/accept_local": 1,
 	}
+	setSysctls(sysctls)
 
 	dirEntries, err := os.ReadDir("/proc/sys/net/ipv4/conf")
 	if err != nil {
@@ -679,88 +640,18 @@ func (s *Server) CreateRulesOnNode(ztunnelVeth, ztunnelIP string, captureDNS boo
 	for _, dirEntry := range dirEntries {
 		if dirEntry.IsDir() {
 			if _, err := os.Stat("/proc/sys/net/ipv4/conf/" + dirEntry.Name() + "/rp_filter"); err != nil {
-				err := SetProc("/proc/sys/net/ipv4/conf/"+dirEntry.Name()+"/rp_filter", "0")
+				err := SetSysctl("net/ipv4/conf/"+dirEntry.Name()+"/rp_filter", "0")
 				if err != nil {
-					log.Errorf("failed to set /proc/sys/net/ipv4/conf/%s/rp_filter: %v", dirEntry.Name(), err)
+					log.Errorf("failed to disable rp_filter: %v", err)
 				}
 			}
 		}
 	}
 
-	routes := []*ExecList{
-		newExec("ip",
-			[]string{
-				"route", "add", "table", fmt.Sprint(constants.RouteTableOutbound), ztunnelIP,
-				"dev", ztunnelVeth, "scope", "link",
-			},
-		),
-		newExec("ip",
-			[]string{
-				"route", "add", "table", fmt.Sprint(constants.RouteTableOutbound), "0.0.0.0/0",
-				"via", constants.ZTunnelOutboundTunIP, "dev", constants.OutboundTun,
-			},
-		),
-		newExec("ip",
-			[]string{
-				"route", "add", "table", fmt.Sprint(constants.RouteTableProxy), ztunnelIP,
-				"dev", ztunnelVeth, "scope", "link",
-			},
-		),
-		newExec("ip",
-			[]string{
-				"route", "add", "table", fmt.Sprint(constants.RouteTableProxy), "0.0.0.0/0",
-				"via", ztunnelIP, "dev", ztunnelVeth, "onlink",
-			},
-		),
-		newExec("ip",
-			[]string{
-				"route", "add", "table", fmt.Sprint(constants.RouteTableInbound), ztunnelIP,
-				"dev", ztunnelVeth, "scope", "link",
-			},
-		),
-		// Everything with the skip mark goes directly to the main table
-		newExec("ip",
-			[]string{
-				"rule", "add", "priority", "100",
-				"fwmark", fmt.Sprint(constants.SkipMark),
-				"goto", "32766",
-			},
-		),
-		// Everything with the outbound mark goes to the tunnel out device
-		// using the outbound route table
-		newExec("ip",
-			[]string{
-				"rule", "add", "priority", "101",
-				"fwmark", fmt.Sprint(constants.OutboundMark),
-				"lookup", fmt.Sprint(constants.RouteTableOutbound),
-			},
-		),
-		// Things with the proxy return mark go directly to the proxy veth using the proxy
-		// route table (useful for original src)
-		newExec("ip",
-			[]string{
-				"rule", "add", "priority", "102",
-				"fwmark", fmt.Sprint(constants.ProxyRetMark),
-				"lookup", fmt.Sprint(constants.RouteTableProxy),
-			},
-		),
-		// Send all traffic to the inbound table. This table has routes only to pods in the mesh.
-		// It does not have a catch-all route, so if a route is missing, the search will continue
-		// allowing us to override routing just for member pods.
-		newExec("ip",
-			[]string{
-				"rule", "add", "priority", "103",
-				"table", fmt.Sprint(constants.RouteTableInbound),
-			},
-		),
-	}
-
-	for _, route := range routes {
-		err = execute(route.Cmd, route.Args...)
-		if err != nil {
-			log.Errorf(fmt.Errorf("failed to add route (%+v): %v", route, err))
-		}
+	if err := addNodeRoutes(ztunnelVeth, ztunnelIP); err != nil {
+		return err
 	}
+	addNodeRules()
 
 	return nil
 }
@@ -777,12 +668,12 @@ func (s *Server) CreateEBPFRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP,
 	err := netns.WithNetNSPath(fmt.Sprintf("/var/run/netns/%s", ns), func(netns.NetNS) error {
 		// Make sure we flush table 100 before continuing - it should be empty in a new namespace
 		// but better to ensure that.
-		if err := routeFlushTable(constants.RouteTableInbound); err != nil {
+		if err := hostNet.RouteFlush(constants.RouteTableInbound); err != nil {
 			log.Error(err)
 		}
 
 		// Flush rules before initializing within 'addTProxyMarks'
-		deleteIPRules([]string{strconv.Itoa(constants.TProxyMarkPriority), strconv.Itoa(constants.OrgSrcPriority)}, false)
+		deleteIPRules([]int{constants.TProxyMarkPriority, constants.OrgSrcPriority}, false)
 
 		// Set up tproxy marks
 		err := addTProxyMarkRule()
@@ -790,27 +681,23 @@ func (s *Server) CreateEBPFRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP,
 			return fmt.Errorf("failed to add TPROXY mark rules: %v", err)
 		}
 
-		loopbackLink, err := netlink.LinkByName("lo")
-		if err != nil {
-			return fmt.Errorf("failed to find 'lo' link: %v", err)
-		}
 		// In routing table ${INBOUND_TPROXY_ROUTE_TABLE}, create a single default rule to route all traffic to
 		// the loopback interface.
 		// Equiv: "ip route add local 0.0.0.0/0 dev lo table 100"
 		// TODO IPv6, append "0::0/0"
 		cidrs := []string{"0.0.0.0/0"}
 		for _, fullCIDR := range cidrs {
-			_, dst, err := net.ParseCIDR(fullCIDR)
+			dst, err := netip.ParsePrefix(fullCIDR)
 			if err != nil {
 				return fmt.Errorf("parse CIDR: %v", err)
 			}
 
-			if err := netlink.RouteAdd(&netlink.Route{
-				Dst:       dst,
-				Scope:     netlink.SCOPE_HOST,
-				Type:      unix.RTN_LOCAL,
-				Table:     constants.RouteTableInbound,
-				LinkIndex: loopbackLink.Attrs().Index,
+			if err := hostNet.RouteAdd(&hostnet.Route{
+				Table: constants.RouteTableInbound,
+				Dst:   dst,
+				Dev:   "lo",
+				Scope: hostnet.ScopeHost,
+				Local: true,
 			}); err != nil {
 				// TODO clear this route every time
 				// Would not expect this if we have properly cleared routes
@@ -932,7 +819,7 @@ func (s *Server) CreateRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP, ztu
 		// TODO not strictly necessary? A harmless correctness check, at least.
 		flushAllRouteTables()
 
-		deleteIPRules([]string{"20000", "20001", "20002", "20003"}, false)
+		deleteIPRules([]int{20000, 20001, 20002, 20003}, false)
 
 		deleteTunnelLinks(inboundGeneveLinkName, outboundGeneveLinkName, false)
 
@@ -997,16 +884,10 @@ func (s *Server) CreateRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP, ztu
 		// Turn OFF  reverse packet filtering for the tunnels
 		// This is required for iptables impl, but not for eBPF impl
 		log.Debugf("Disabling '/rp_filter' for inbound and outbound tunnels")
-		procs := map[string]int{
-			"/proc/sys/net/ipv4/conf/" + outbndTunLink.Name + "/rp_filter": 0,
-			"/proc/sys/net/ipv4/conf/" + inbndTunLink.Name + "/rp_filter":  0,
-		}
-		for proc, val := range procs {
-			err = SetProc(proc, fmt.Sprint(val))
-			if err != nil {
-				log.Errorf("failed to write to proc file %s: %v", proc, err)
-			}
-		}
+		setSysctls(map[string]int{
+			"net/ipv4/conf/" + outbndTunLink.Name + "/rp_filter": 0,
+			"net/ipv4/conf/" + inbndTunLink.Name + "/rp_filter":  0,
+		})
 
 		// Set up tproxy marks
 		err = addTProxyMarkRule()
@@ -1018,64 +899,57 @@ func (s *Server) CreateRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP, ztu
 			return fmt.Errorf("failed to add OrgSrc mark rules: %v", err)
 		}
 
-		loopbackLink, err := netlink.LinkByName("lo")
-		if err != nil {
-			return fmt.Errorf("failed to find 'lo' link: %v", err)
-		}
-
 		// Set up netlink routes for localhost
 		// TODO IPv6, append "0::0/0"
 		cidrs := []string{"0.0.0.0/0"}
 		for _, fullCIDR := range cidrs {
-			_, localhostDst, err := net.ParseCIDR(fullCIDR)
+			localhostDst, err := netip.ParsePrefix(fullCIDR)
 			if err != nil {
 				return fmt.Errorf("parse CIDR: %v", err)
 			}
 
-			netlinkRoutes := []*netlink.Route{
+			routes := []*hostnet.Route{
 				// In routing table ${INBOUND_TPROXY_ROUTE_TABLE}, create a single default rule to route all traffic to
 				// the loopback interface.
 				// Equiv: "ip route add local 0.0.0.0/0 dev lo table 100"
 				{
-					Dst:       localhostDst,
-					Scope:     netlink.SCOPE_HOST,
-					Type:      unix.RTN_LOCAL,
-					Table:     constants.RouteTableInbound,
-					LinkIndex: loopbackLink.Attrs().Index,
+					Table: constants.RouteTableInbound,
+					Dst:   localhostDst,
+					Dev:   "lo",
+					Scope: hostnet.ScopeHost,
+					Local: true,
 				},
 				// Send to localhost, if it came via OutboundTunIP
 				// Equiv: "ip route add table 101 0.0.0.0/0 via $OUTBOUND_TUN_IP dev p$OUTBOUND_TUN"
 				{
-					Dst:       localhostDst,
-					Gw:        net.ParseIP(constants.OutboundTunIP),
-					Type:      unix.RTN_UNICAST,
-					Table:     constants.RouteTableOutbound,
-					LinkIndex: outbndTunLink.Attrs().Index,
+					Table: constants.RouteTableOutbound,
+					Dst:   localhostDst,
+					Gw:    netip.MustParseAddr(constants.OutboundTunIP),
+					Dev:   outbndTunLink.Name,
 				},
 				// Send to localhost, if it came via InboundTunIP
 				// Equiv: "ip route add table 102 0.0.0.0/0 via $INBOUND_TUN_IP dev p$INBOUND_TUN"
 				{
-					Dst:       localhostDst,
-					Gw:        net.ParseIP(constants.InboundTunIP),
-					Type:      unix.RTN_UNICAST,
-					Table:     constants.RouteTableProxy,
-					LinkIndex: inbndTunLink.Attrs().Index,
+					Table: constants.RouteTableProxy,
+					Dst:   localhostDst,
+					Gw:    netip.MustParseAddr(constants.InboundTunIP),
+					Dev:   inbndTunLink.Name,
 				},
 			}
 
-			for _, route := range netlinkRoutes {
-				log.Debugf("Adding netlink route : %+v", route)
-				if err := netlink.RouteAdd(route); err != nil {
+			for _, route := range routes {
+				log.Debugf("Adding route: %v", route)
+				if err := hostNet.RouteAdd(route); err != nil {
 					// TODO clear this route every time
 					// Would not expect this if we have properly cleared routes
-					log.Errorf("Failed to add netlink route : %+v", route)
+					log.Errorf("Failed to add route: %v", route)
 					return fmt.Errorf("failed to add route: %v", err)
 				}
 			}
 		}
 
 		log.Debugf("Finding link and parsing host IP")
-		_, parsedHostIPNet, err := net.ParseCIDR(hostIP + "/32")
+		parsedHostIPNet, err := netip.ParsePrefix(hostIP + "/32")
 		if err != nil {
 			return fmt.Errorf("could not parse host IP %s: %v", hostIP, err)
 		}
@@ -1084,30 +958,28 @@ func (s *Server) CreateRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP, ztu
 		if err != nil {
 			return fmt.Errorf("failed to find veth with index '%d' within namespace %s: %v", proxyNsVethIdx, ztunnelNetNS, err)
 		}
-		netlinkHostRoutes := []*netlink.Route{
+		hostRoutes := []*hostnet.Route{
 			// Send to localhost, if it came via InboundTunIP
 			// Equiv: "ip route add table 101 $HOST_IP dev eth0 scope link"
 			{
-				Dst:       parsedHostIPNet,
-				Scope:     netlink.SCOPE_LINK,
-				Type:      unix.RTN_UNICAST,
-				Table:     constants.RouteTableOutbound,
-				LinkIndex: vethLink.Attrs().Index,
+				Table: constants.RouteTableOutbound,
+				Dst:   parsedHostIPNet,
+				Dev:   vethLink.Attrs().Name,
+				Scope: hostnet.ScopeLink,
 			},
 			// Send to localhost, if it came via InboundTunIP
 			// Equiv: "ip route add table 102 $HOST_IP dev eth0 scope link"
 			{
-				Dst:       parsedHostIPNet,
-				Scope:     netlink.SCOPE_LINK,
-				Type:      unix.RTN_UNICAST,
-				Table:     constants.RouteTableProxy,
-				LinkIndex: vethLink.Attrs().Index,
+				Table: constants.RouteTableProxy,
+				Dst:   parsedHostIPNet,
+				Dev:   vethLink.Attrs().Name,
+				Scope: hostnet.ScopeLink,
 			},
 		}
 
-		for _, route := range netlinkHostRoutes {
-			log.Debugf("Adding netlink HOST_IP routes : %+v", route)
-			if err := netlink.RouteAdd(route); err != nil {
+		for _, route := range hostRoutes {
+			log.Debugf("Adding HOST_IP route: %v", route)
+			if err := hostNet.RouteAdd(route); err != nil {
 				// TODO clear this route every time
 				// Would not expect this if we have properly cleared routes
 				return fmt.Errorf("failed to add host route: %v", err)
@@ -1195,6 +1067,73 @@ func (s *Server) CreateRulesWithinNodeProxyNS(proxyNsVethIdx int, ztunnelIP, ztu
 	return nil
 }
 
+// addNodeRoutes adds the routes sending the traffic of the mesh pods through ztunnel.
+func addNodeRoutes(ztunnelVeth, ztunnelIP string) error {
+	ztunnelAddr, err := netip.ParseAddr(ztunnelIP)
+	if err != nil {
+		return fmt.Errorf("failed to parse ztunnel IP: %v", err)
+	}
+	ztunnelDst := netip.PrefixFrom(ztunnelAddr, ztunnelAddr.BitLen())
+	defaultDst := netip.MustParsePrefix("0.0.0.0/0")
+	routes := []*hostnet.Route{
+		{Table: constants.RouteTableOutbound, Dst: ztunnelDst, Dev: ztunnelVeth, Scope: hostnet.ScopeLink},
+		{
+			Table: constants.RouteTableOutbound, Dst: defaultDst,
+			Gw: netip.MustParseAddr(constants.ZTunnelOutboundTunIP), Dev: constants.OutboundTun,
+		},
+		{Table: constants.RouteTableProxy, Dst: ztunnelDst, Dev: ztunnelVeth, Scope: hostnet.ScopeLink},
+		{Table: constants.RouteTableProxy, Dst: defaultDst, Gw: ztunnelAddr, Dev: ztunnelVeth, OnLink: true},
+		{Table: constants.RouteTableInbound, Dst: ztunnelDst, Dev: ztunnelVeth, Scope: hostnet.ScopeLink},
+	}
+	for _, route := range routes {
+		if err := hostNet.RouteAdd(route); err != nil {
+			log.Errorf("failed to add route: %v", err)
+		}
+	}
+	return nil
+}
+
+// addNodeRules adds the policy routing rules selecting the route tables of the mesh traffic.
+func addNodeRules() {
+	rules := []*hostnet.Rule{
+		// Everything with the skip mark goes directly to the main table
+		{Priority: 100, Mark: mark(constants.SkipMask), Mask: mark(constants.SkipMask), Goto: 32766},
+		// Everything with the outbound mark goes to the tunnel out device
+		// using the outbound route table
+		{Priority: 101, Mark: mark(constants.OutboundMask), Mask: mark(constants.OutboundMask), Table: constants.RouteTableOutbound},
+		// Things with the proxy return mark go directly to the proxy veth using the proxy
+		// route table (useful for original src)
+		{Priority: 102, Mark: mark(constants.ProxyRetMask), Mask: mark(constants.ProxyRetMask), Table: constants.RouteTableProxy},
+		// Send all traffic to the inbound table. This table has routes only to pods in the mesh.
+		// It does not have a catch-all route, so if a route is missing, the search will continue
+		// allowing us to override routing just for member pods.
+		{Priority: 103, Table: constants.RouteTableInbound},
+	}
+	for _, rule := range rules {
+		if err := hostNet.RuleAdd(rule); err != nil {
+			log.Errorf("failed to add rule: %v", err)
+		}
+	}
+}
+
+// mark parses a firewall mark constant.
+func mark(s string) uint32 {
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		panic(fmt.Sprintf("invalid mark %q: %v", s, err))
+	}
+	return uint32(v)
+}
+
+// setSysctls writes the sysctls, logging the failures.
+func setSysctls(sysctls map[string]int) {
+	for key, val := range sysctls {
+		if err := SetSysctl(key, fmt.Sprint(val)); err != nil {
+			log.Errorf("failed to write sysctl: %v", err)
+		}
+	}
+}
+
 func (s *Server) cleanupNode() {
 	log.Infof("Node-level network rule cleanup started")
 	if s.redirectMode == EbpfMode {
@@ -1207,7 +1146,7 @@ func (s *Server) cleanupNode() {
 
 	flushAllRouteTables()
 
-	deleteIPRules([]string{"100", "101", "102", "103"}, true)
+	deleteIPRules([]int{100, 101, 102, 103}, true)
 
 	deleteTunnelLinks(constants.InboundTun, constants.OutboundTun, true)
 
@@ -1218,148 +1157,53 @@ func (s *Server) cleanupNode() {
 }
 
 func addTProxyMarkRule() error {
-	// Set up tproxy marks
-	var rules []*netlink.Rule
-	// TODO IPv6, append  unix.AF_INET6
-	families := []int{unix.AF_INET}
-	for _, family := range families {
-		// Equiv: "ip rule add priority 20000 fwmark 0x400/0xfff lookup 100"
-		tproxMarkRule := netlink.NewRule()
-		tproxMarkRule.Family = family
-		tproxMarkRule.Table = constants.RouteTableInbound
-		tproxMarkRule.Mark = constants.TProxyMark
-		tproxMarkRule.Mask = constants.TProxyMask
-		tproxMarkRule.Priority = constants.TProxyMarkPriority
-		rules = append(rules, tproxMarkRule)
+	// Equiv: "ip rule add priority 20000 fwmark 0x400/0xfff lookup 100"
+	// TODO IPv6
+	rule := &hostnet.Rule{
+		Priority: constants.TProxyMarkPriority,
+		Mark:     constants.TProxyMark,
+		Mask:     constants.TProxyMask,
+		Table:    constants.RouteTableInbound,
+	}
+	log.Debugf("Adding rule: %v", rule)
+	if err := hostNet.RuleAdd(rule); err != nil {
+		return fmt.Errorf("failed to configure rule: %v", err)
 	}
-
-	for _, rule := range rules {
-		log.Debugf("Adding netlink rule : %+v", rule)
-		if err := netlink.RuleAdd(rule); err != nil {
-			return fmt.Errorf("failed to configure netlink rule: %v", err)
-		}
-	}
-
 	return nil
 }
 
 func addOrgSrcMarkRule() error {
-	// Set up tproxy marks
-	var rules []*netlink.Rule
-	// TODO IPv6, append  unix.AF_INET6
-	families := []int{unix.AF_INET}
-	for _, family := range families {
-		// Equiv: "ip rule add priority 20003 fwmark 0x4d3/0xfff lookup 100"
-		orgSrcRule := netlink.NewRule()
-		orgSrcRule.Family = family
-		orgSrcRule.Table = constants.RouteTableInbound
-		orgSrcRule.Mark = constants.OrgSrcRetMark
-		orgSrcRule.Mask = constants.OrgSrcRetMask
-		orgSrcRule.Priority = constants.OrgSrcPriority
-		rules = append(rules, orgSrcRule)
-	}
-
-	for _, rule := range rules {
-		log.Debugf("Adding netlink rule : %+v", rule)
-		if err := netlink.RuleAdd(rule); err != nil {
-			return fmt.Errorf("failed to configure netlink rule: %v", err)
-		}
-	}
-
-	return nil
-}
-
-func disableRPFiltersForLink(ifaceName string) error {
-	// Need to do some work in procfs
-	// @TODO: This needs to be cleaned up, there are a lot of martians in AWS
-	// that seem to necessitate this work and in theory we shouldn't *need* to disable
-	// `rp_filter` with eBPF.
-	procs := map[string]int{
-		"/proc/sys/net/ipv4/conf/default/rp_filter":           0,
-		"/proc/sys/net/ipv4/conf/all/rp_filter":               0,
-		"/proc/sys/net/ipv4/conf/" + ifaceName + "/rp_filter": 0,
-	}
-	for proc, val := range procs {
-		err := setProc(proc, fmt.Sprint(val))
-		if err != nil {
-			log.Errorf("failed to write to proc file %s: %v", proc, err)
-			return err
-		}
+	// Equiv: "ip rule add priority 20003 fwmark 0x4d3/0xfff lookup 100"
+	// TODO IPv6
+	rule := &hostnet.Rule{
+		Priority: constants.OrgSrcPriority,
+		Mark:     constants.OrgSrcRetMark,
+		Mask:     constants.OrgSrcRetMask,
+		Table:    constants.RouteTableInbound,
+	}
+	log.Debugf("Adding rule: %v", rule)
+	if err := hostNet.RuleAdd(rule); err != nil {
+		return fmt.Errorf("failed to configure rule: %v", err)
 	}
-
 	return nil
 }
 
-func setProc(path string, value string) error {
-	return os.WriteFile(path, []byte(value), 0o644)
-}
-
-func buildRouteFromPod(pod *corev1.Pod, ip string) ([]string, error) {
-	if ip == "" {
-		ip = pod.Status.PodIP
-	}
-
-	if ip == "" {
-		return nil, errors.New("no ip found")
-	}
-
-	return []string{
-		"table",
-		fmt.Sprintf("%d", constants.RouteTableInbound),
-		fmt.Sprintf("%s/32", ip),
-		"via",
-		constants.ZTunnelInboundTunIP,
-		"dev",
-		constants.InboundTun,
-		"src",
-		HostIP,
-	}, nil
-}
-
 // This can be called on the node, as part of termination/cleanup,
 // or it can be called from within a pod netns, as a "clean slate" prep.
 func flushAllRouteTables() {
 	// Clean up ip route tables
-	_ = routeFlushTable(constants.RouteTableInbound)
-	_ = routeFlushTable(constants.RouteTableOutbound)
-	_ = routeFlushTable(constants.RouteTableProxy)
-}
-
-func routeFlushTable(table int) error {
-	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
-	if err != nil {
-		return err
-	}
-	// default route is not handled proper in netlink
-	// https://github.com/vishvananda/netlink/issues/670
-	// https://github.com/vishvananda/netlink/issues/611
-	for i, route := range routes {
-		if (route.Dst == nil || route.Dst.IP == nil) && route.Src == nil && route.Gw == nil && route.MPLSDst == nil {
-			_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
-			routes[i].Dst = defaultDst
-		}
-	}
-	err = routesDelete(routes)
-	if err != nil {
-		return err
-	}
-	return nil
+	_ = hostNet.RouteFlush(constants.RouteTableInbound)
+	_ = hostNet.RouteFlush(constants.RouteTableOutbound)
+	_ = hostNet.RouteFlush(constants.RouteTableProxy)
 }
 
 // This can be called on the node, as part of termination/cleanup,
 // or it can be called from within a pod netns, as a "clean slate" prep.
-//
-// TODO `netlink.RuleDel` SHOULD work here - but it does not. Unsure why.
-// So, for time being, rely on `ip`
-func deleteIPRules(prioritiesToDelete []string, warnOnFail bool) {
-	var exec []*ExecList
+func deleteIPRules(prioritiesToDelete []int, warnOnFail bool) {
 	for _, pri := range prioritiesToDelete {
-		exec = append(exec, newExec("ip", []string{"rule", "del", "priority", pri}))
-	}
-	for _, e := range exec {
-		err := execute(e.Cmd, e.Args...)
+		err := hostNet.RuleDel(pri)
 		if err != nil && warnOnFail {
-			log.Warnf("Error running command %v %v: %v", e.Cmd, strings.Join(e.Args, " "), err)
+			log.Warnf("Error deleting rule with priority %d: %v", pri, err)
 		}
 	}
 }
@@ -1392,13 +1236,3 @@ func deleteTunnelLinks(inboundName, outboundName string, warnOnFail bool) {
 		}
 	}
 }
-
-func routesDelete(routes []netlink.Route) error {
-	for _, r := range routes {
-		err := netlink.RouteDel(&r)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
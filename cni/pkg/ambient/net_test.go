@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambient
+
+import (
+	"net/netip"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/cni/pkg/ambient/constants"
+	"istio.io/istio/cni/pkg/hostnet"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func setupFakeHostNet(t *testing.T) *hostnet.Fake {
+	fake := hostnet.NewFake()
+	oldNet, oldIpsetNet := hostNet, Ipset.Net
+	hostNet, Ipset.Net = fake, fake
+	t.Cleanup(func() {
+		hostNet, Ipset.Net = oldNet, oldIpsetNet
+	})
+	assert.NoError(t, Ipset.CreateSet())
+	return fake
+}
+
+func TestPodMeshMembership(t *testing.T) {
+	fake := setupFakeHostNet(t)
+	podIP := netip.MustParseAddr("10.0.0.5")
+	// The route of the pod veth, added by the primary CNI
+	assert.NoError(t, fake.RouteAdd(&hostnet.Route{
+		Table: hostnet.MainTable,
+		Dst:   netip.PrefixFrom(podIP, 32),
+		Dev:   "veth1234",
+		Scope: hostnet.ScopeLink,
+	}))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "uid"},
+		Status:     corev1.PodStatus{PodIP: podIP.String()},
+	}
+	inbound := buildRouteFromPod(podIP)
+
+	assert.NoError(t, addPodToMeshWithIptables(pod, ""))
+	assert.Equal(t, IsPodInIpset(pod), true)
+	entries, err := Ipset.List()
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].IP == podIP, true)
+	assert.Equal(t, entries[0].Comment, "uid")
+	assert.Equal(t, RouteExists(inbound), true)
+	routes, err := fake.RouteList(constants.RouteTableInbound)
+	assert.NoError(t, err)
+	assert.Equal(t, len(routes), 1)
+	assert.Equal(t, routes[0].Gw == netip.MustParseAddr(constants.ZTunnelInboundTunIP), true)
+	assert.Equal(t, routes[0].Dev, constants.InboundTun)
+	for _, iface := range []string{"default", "all", "veth1234"} {
+		assert.Equal(t, fake.Sysctl("net/ipv4/conf/"+iface+"/rp_filter"), "0")
+	}
+
+	// Adding the pod again is a no-op
+	assert.NoError(t, addPodToMeshWithIptables(pod, ""))
+	entries, err = Ipset.List()
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 1)
+	routes, err = fake.RouteList(constants.RouteTableInbound)
+	assert.NoError(t, err)
+	assert.Equal(t, len(routes), 1)
+
+	DelPodFromMesh(nil, pod)
+	assert.Equal(t, IsPodInIpset(pod), false)
+	assert.Equal(t, RouteExists(inbound), false)
+	// The routes of the other tables are untouched
+	routes, err = fake.RouteList(hostnet.MainTable)
+	assert.NoError(t, err)
+	assert.Equal(t, len(routes), 1)
+}
+
+func TestAddPodToMeshWithoutIP(t *testing.T) {
+	fake := setupFakeHostNet(t)
+	assert.NoError(t, addPodToMeshWithIptables(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}, ""))
+	entries, err := Ipset.List()
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 0)
+	assert.Equal(t, len(fake.Routes()), 0)
+}
+
+func TestAddPodToMeshFailure(t *testing.T) {
+	fake := hostnet.NewFake()
+	oldNet, oldIpsetNet := hostNet, Ipset.Net
+	hostNet, Ipset.Net = fake, fake
+	t.Cleanup(func() {
+		hostNet, Ipset.Net = oldNet, oldIpsetNet
+	})
+	// The ipset does not exist, so the pod cannot be added to it.
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "uid"},
+		Status:     corev1.PodStatus{PodIP: "10.0.0.5"},
+	}
+	assert.Error(t, addPodToMeshWithIptables(pod, ""))
+	assert.Error(t, iptablesRedirection{}.Add(pod))
+	assert.Equal(t, len(fake.Routes()), 0)
+}
@@ -15,6 +15,7 @@
 package ambient
 
 import (
+	"istio.io/istio/cni/pkg/hostnet"
 	ipsetlib "istio.io/istio/cni/pkg/ipset"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/pkg/env"
@@ -28,8 +29,12 @@ var (
 	HostIP       = env.RegisterStringVar("HOST_IP", "", "").Get()
 )
 
+// hostNet manages the routes, rules, ipsets and sysctls of the current network namespace.
+var hostNet = hostnet.New()
+
 var Ipset = &ipsetlib.IPSet{
 	Name: "ztunnel-pods-ips",
+	Net:  hostNet,
 }
 
 type RedirectMode int
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"sync"
 
@@ -29,6 +30,7 @@ import (
 
 	"istio.io/istio/cni/pkg/ambient/constants"
 	ebpf "istio.io/istio/cni/pkg/ebpf/server"
+	"istio.io/istio/cni/pkg/monitoring"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
@@ -49,6 +51,9 @@ type Server struct {
 	iptablesCommand lazy.Lazy[string]
 	redirectMode    RedirectMode
 	ebpfServer      *ebpf.RedirectServer
+
+	redirection       hostRedirection
+	consistencyReport *ConsistencyReport
 }
 
 type AmbientConfigFile struct {
@@ -81,14 +86,17 @@ func NewServer(ctx context.Context, args AmbientArgs) (*Server, error) {
 		}
 		HostIP = h
 		log.Infof("HostIP=%v", HostIP)
+		s.redirection = iptablesRedirection{}
 	case EbpfMode:
 		s.redirectMode = EbpfMode
 		s.ebpfServer = ebpf.NewRedirectServer()
 		s.ebpfServer.SetLogLevel(args.LogLevel)
 		s.ebpfServer.Start(ctx.Done())
+		s.redirection = ebpfRedirection{s}
 	}
 
 	s.setupHandlers()
+	monitoring.RegisterDebugHandler(ConsistencyDebugPath, http.HandlerFunc(s.consistencyHandler))
 
 	s.UpdateConfig()
 
@@ -126,6 +134,7 @@ func (s *Server) Start() {
 	go func() {
 		s.queue.Run(s.ctx.Done())
 	}()
+	go s.runConsistencyChecks(s.ctx.Done())
 }
 
 func (s *Server) Stop() {
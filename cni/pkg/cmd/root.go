@@ -31,8 +31,12 @@ import (
 	"istio.io/istio/cni/pkg/install"
 	udsLog "istio.io/istio/cni/pkg/log"
 	"istio.io/istio/cni/pkg/monitoring"
+	"istio.io/istio/cni/pkg/plugin"
 	"istio.io/istio/cni/pkg/repair"
+	"istio.io/istio/cni/pkg/wasmcache"
 	"istio.io/istio/pkg/cmd"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/wasm"
 	iptables "istio.io/istio/tools/istio-iptables/pkg/constants"
 	"istio.io/pkg/collateral"
 	"istio.io/pkg/ctrlz"
@@ -80,6 +84,23 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
+		// Start the node level Wasm module cache
+		if cfg.InstallConfig.WasmCacheUDSAddress != "" {
+			wasmCache := wasm.NewNodeCacheServer(cfg.InstallConfig.WasmCacheDir, wasm.Options{
+				InsecureRegistries: sets.New(cfg.InstallConfig.WasmCacheInsecureRegistries...),
+				MaxCacheSize:       int64(cfg.InstallConfig.WasmCacheMaxSizeMB) * 1024 * 1024,
+			})
+			if err = wasmCache.Start(cfg.InstallConfig.WasmCacheUDSAddress, ctx.Done()); err != nil {
+				log.Errorf("Failed to start up the Wasm node cache server: %v", err)
+				return
+			}
+			if cfg.InstallConfig.WasmCachePrewarm {
+				if err := wasmcache.StartPrewarm(ctx, wasmCache, cfg.InstallConfig.K8sNodeName); err != nil {
+					log.Errorf("Failed to start pre-fetching the Wasm modules: %v", err)
+				}
+			}
+		}
+
 		if cfg.InstallConfig.AmbientEnabled {
 			// Start ambient controller
 			redirectMode := ambient.IptablesMode
@@ -152,6 +173,8 @@ func init() {
 	registerStringParameter(constants.CNINetworkConfig, "", "CNI configuration template as a string")
 	registerBooleanParameter(constants.CNIEnableInstall, true, "Whether to install CNI configuration and binary files")
 	registerBooleanParameter(constants.CNIEnableReinstall, true, "Whether to reinstall CNI configuration and binary files")
+	registerBooleanParameter(constants.CNIStrictReadiness, false,
+		"Whether to report not ready while pods scheduled without traffic capture, when the CNI configuration was invalid, are running on the node")
 	registerStringParameter(constants.LogLevel, "warn", "Fallback value for log level in CNI config file, if not specified in helm template")
 
 	// Not configurable in CNI helm charts
@@ -169,10 +192,27 @@ func init() {
 	registerStringParameter(constants.LogUDSAddress, "/var/run/istio-cni/log.sock", "The UDS server address which CNI plugin will copy log ouptut to")
 	registerBooleanParameter(constants.AmbientEnabled, false, "Whether ambient controller is enabled")
 	registerBooleanParameter(constants.EbpfEnabled, false, "Whether ebpf redirection is enabled")
+	registerStringParameter(constants.WasmCacheUDSAddress, "",
+		"The UDS server address of the node level Wasm module cache queried by the istio-agents. The cache is disabled if empty")
+	registerStringParameter(constants.WasmCacheDir, "/var/lib/istio-cni/wasm", "Directory where the node level Wasm module cache stores the modules")
+	registerIntegerParameter(constants.WasmCacheMaxSize, 1024,
+		"Disk budget of the node level Wasm module cache in MiB, beyond which the least recently used modules are removed. No limit if 0")
+	registerStringArrayParameter(constants.WasmCacheInsecureRegistries, []string{},
+		"Registries from which the node level Wasm module cache pulls modules insecurely, or '*' for all of them")
+	registerBooleanParameter(constants.WasmCachePrewarm, false,
+		"Whether the node level Wasm module cache pre-fetches the modules of the WasmPlugins selecting the pods of the node")
 	// Repair
 	registerBooleanParameter(constants.RepairEnabled, true, "Whether to enable race condition repair or not")
 	registerBooleanParameter(constants.RepairDeletePods, false, "Controller will delete pods when detecting pod broken by race condition")
 	registerBooleanParameter(constants.RepairLabelPods, false, "Controller will label pods when detecting pod broken by race condition")
+	registerBooleanParameter(constants.RepairRepairPods, false,
+		"Controller will re-apply the traffic redirection of pods in place when detecting pod broken by race condition")
+	registerStringParameter(constants.RepairInterceptType, "iptables",
+		"The intercept type of the CNI plugin, iptables or ebpf, used to re-apply the traffic redirection of pods in place")
+	registerBooleanParameter(constants.RepairDNSCapture, false,
+		"Whether the CNI plugin captures the DNS traffic, used to re-apply the traffic redirection of pods in place")
+	registerStringArrayParameter(constants.RepairDNSServers, []string{},
+		"The DNS servers whose traffic is captured by the CNI plugin, used to re-apply the traffic redirection of pods in place")
 	registerStringParameter(constants.RepairLabelKey, "cni.istio.io/uninitialized",
 		"The key portion of the label which will be set by the ace repair if label pods is true")
 	registerStringParameter(constants.RepairLabelValue, "true",
@@ -239,6 +279,7 @@ func constructConfig() (*config.Config, error) {
 		CNINetworkConfig:     viper.GetString(constants.CNINetworkConfig),
 		CNIEnableInstall:     viper.GetBool(constants.CNIEnableInstall),
 		CNIEnableReinstall:   viper.GetBool(constants.CNIEnableReinstall),
+		CNIStrictReadiness:   viper.GetBool(constants.CNIStrictReadiness),
 
 		LogLevel:           viper.GetString(constants.LogLevel),
 		KubeconfigFilename: viper.GetString(constants.KubeconfigFilename),
@@ -259,6 +300,12 @@ func constructConfig() (*config.Config, error) {
 
 		AmbientEnabled: viper.GetBool(constants.AmbientEnabled),
 		EbpfEnabled:    viper.GetBool(constants.EbpfEnabled),
+
+		WasmCacheUDSAddress:         viper.GetString(constants.WasmCacheUDSAddress),
+		WasmCacheDir:                viper.GetString(constants.WasmCacheDir),
+		WasmCacheMaxSizeMB:          viper.GetInt(constants.WasmCacheMaxSize),
+		WasmCacheInsecureRegistries: viper.GetStringSlice(constants.WasmCacheInsecureRegistries),
+		WasmCachePrewarm:            viper.GetBool(constants.WasmCachePrewarm),
 	}
 
 	if len(installCfg.K8sNodeName) == 0 {
@@ -273,6 +320,10 @@ func constructConfig() (*config.Config, error) {
 		Enabled:            viper.GetBool(constants.RepairEnabled),
 		DeletePods:         viper.GetBool(constants.RepairDeletePods),
 		LabelPods:          viper.GetBool(constants.RepairLabelPods),
+		RepairPods:         viper.GetBool(constants.RepairRepairPods),
+		InterceptType:      viper.GetString(constants.RepairInterceptType),
+		DNSCapture:         viper.GetBool(constants.RepairDNSCapture),
+		DNSServers:         viper.GetStringSlice(constants.RepairDNSServers),
 		LabelKey:           viper.GetString(constants.RepairLabelKey),
 		LabelValue:         viper.GetString(constants.RepairLabelValue),
 		NodeName:           viper.GetString(constants.RepairNodeName),
@@ -284,5 +335,9 @@ func constructConfig() (*config.Config, error) {
 		FieldSelectors:     viper.GetString(constants.RepairFieldSelectors),
 	}
 
+	if repairCfg.DNSCapture && !plugin.DNSCaptureInterceptTypes[repairCfg.InterceptType] {
+		return nil, fmt.Errorf("the DNS capture is not supported by the %s intercept type", repairCfg.InterceptType)
+	}
+
 	return &config.Config{InstallConfig: installCfg, RepairConfig: repairCfg}, nil
 }
@@ -43,6 +43,9 @@ type InstallConfig struct {
 	CNIEnableInstall bool
 	// Whether to reinstall CNI configuration and binary files
 	CNIEnableReinstall bool
+	// Whether to report not ready while pods scheduled without traffic capture, when the CNI configuration was
+	// invalid, are running on the node
+	CNIStrictReadiness bool
 
 	// Logging level
 	LogLevel string
@@ -88,6 +91,17 @@ type InstallConfig struct {
 
 	// Use the external nsenter command for network namespace switching
 	HostNSEnterExec bool
+
+	// The UDS address of the node level Wasm module cache. The cache is disabled if empty.
+	WasmCacheUDSAddress string
+	// Directory where the node level Wasm module cache stores the modules
+	WasmCacheDir string
+	// Disk budget of the node level Wasm module cache, in MiB
+	WasmCacheMaxSizeMB int
+	// Registries from which the node level Wasm module cache pulls modules insecurely
+	WasmCacheInsecureRegistries []string
+	// Whether the node level Wasm module cache pre-fetches the modules of the WasmPlugins selecting the pods of the node
+	WasmCachePrewarm bool
 }
 
 // RepairConfig struct defines the Istio CNI race repair configuration
@@ -108,6 +122,16 @@ type RepairConfig struct {
 	// Whether to label broken pods
 	LabelPods bool
 
+	// Whether to fix race condition by re-applying the traffic redirection of broken pods in place
+	RepairPods bool
+
+	// The intercept type of the CNI plugin, used to repair the traffic redirection in place
+	InterceptType string
+
+	// The DNS capture of the CNI plugin, used to repair the traffic redirection in place
+	DNSCapture bool
+	DNSServers []string
+
 	// Filters for race repair, including name of sidecar annotation, name of init container,
 	// init container termination message and exit code.
 	SidecarAnnotation  string
@@ -130,6 +154,7 @@ func (c InstallConfig) String() string {
 	b.WriteString("CNINetworkConfig: " + c.CNINetworkConfig + "\n")
 	b.WriteString("CNIEnableInstall: " + fmt.Sprint(c.CNIEnableInstall) + "\n")
 	b.WriteString("CNIEnableReinstall: " + fmt.Sprint(c.CNIEnableReinstall) + "\n")
+	b.WriteString("CNIStrictReadiness: " + fmt.Sprint(c.CNIStrictReadiness) + "\n")
 
 	b.WriteString("LogLevel: " + c.LogLevel + "\n")
 	b.WriteString("KubeconfigFilename: " + c.KubeconfigFilename + "\n")
@@ -160,6 +185,10 @@ func (c RepairConfig) String() string {
 	b.WriteString("LabelValue: " + c.LabelValue + "\n")
 	b.WriteString("DeletePods: " + fmt.Sprint(c.DeletePods) + "\n")
 	b.WriteString("LabelPods: " + fmt.Sprint(c.LabelPods) + "\n")
+	b.WriteString("RepairPods: " + fmt.Sprint(c.RepairPods) + "\n")
+	b.WriteString("InterceptType: " + c.InterceptType + "\n")
+	b.WriteString("DNSCapture: " + fmt.Sprint(c.DNSCapture) + "\n")
+	b.WriteString("DNSServers: " + strings.Join(c.DNSServers, ",") + "\n")
 	b.WriteString("SidecarAnnotation: " + c.SidecarAnnotation + "\n")
 	b.WriteString("InitContainerName: " + c.InitContainerName + "\n")
 	b.WriteString("InitTerminationMsg: " + c.InitTerminationMsg + "\n")
@@ -25,6 +25,7 @@ const (
 	CNINetworkConfig     = "cni-network-config"
 	CNIEnableInstall     = "cni-enable-install"
 	CNIEnableReinstall   = "cni-enable-reinstall"
+	CNIStrictReadiness   = "cni-strict-readiness"
 	LogLevel             = "log-level"
 	KubeconfigFilename   = "kubecfg-file-name"
 	KubeconfigMode       = "kubeconfig-mode"
@@ -37,10 +38,21 @@ const (
 	AmbientEnabled       = "ambient-enabled"
 	EbpfEnabled          = "ebpf-enabled"
 
+	// Wasm node cache
+	WasmCacheUDSAddress         = "wasm-cache-uds-address"
+	WasmCacheDir                = "wasm-cache-dir"
+	WasmCacheMaxSize            = "wasm-cache-max-size-mb"
+	WasmCacheInsecureRegistries = "wasm-cache-insecure-registries"
+	WasmCachePrewarm            = "wasm-cache-prewarm"
+
 	// Repair
 	RepairEnabled            = "repair-enabled"
 	RepairDeletePods         = "repair-delete-pods"
 	RepairLabelPods          = "repair-label-pods"
+	RepairRepairPods         = "repair-repair-pods"
+	RepairInterceptType      = "repair-intercept-type"
+	RepairDNSCapture         = "repair-dns-capture"
+	RepairDNSServers         = "repair-dns-servers"
 	RepairLabelKey           = "repair-broken-pod-label-key"
 	RepairLabelValue         = "repair-broken-pod-label-value"
 	RepairNodeName           = "repair-node-name"
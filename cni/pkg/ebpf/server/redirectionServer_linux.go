@@ -665,6 +665,24 @@ func (r *RedirectServer) dumpAppInfo() ([]uint32, []mapInfo) {
 	return keys, values
 }
 
+// AppIPs returns the IPs of the workloads redirected by the eBPF programs.
+func (r *RedirectServer) AppIPs() ([]netip.Addr, error) {
+	if r.obj.AppInfo == nil {
+		return nil, fmt.Errorf("eBPF objects are not loaded")
+	}
+	var keyOut [4]byte
+	var valueOut mapInfo
+	var ips []netip.Addr
+	mapIter := r.obj.AppInfo.Iterate()
+	for mapIter.Next(&keyOut, &valueOut) {
+		ips = append(ips, netip.AddrFrom4(keyOut))
+	}
+	if err := mapIter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate app info: %w", err)
+	}
+	return ips, nil
+}
+
 func htons(a uint16) uint16 {
 	if isBigEndian {
 		return a
@@ -0,0 +1,235 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sidecar redirects the traffic of sidecar pods to the proxy with eBPF programs instead of iptables.
+//
+// Outbound TCP connections are redirected by cgroup programs attached once to the root cgroup of the node:
+// connect4 rewrites the destination to the outbound proxy port, and getsockopt answers SO_ORIGINAL_DST for the
+// proxy, like the iptables REDIRECT target does. The configuration of each pod is stored in a pinned map keyed
+// by the cookie of its network namespace. Inbound TCP connections are assigned to the inbound proxy listener by
+// a tc program attached to the interfaces of the pod.
+package sidecar
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+const (
+	// MaxProxyIDs is the maximum number of proxy UIDs, and of proxy GIDs, bypassing the redirection.
+	MaxProxyIDs = 4
+	// MaxPorts is the maximum number of ports of each port list.
+	MaxPorts = 16
+	// MaxCIDRs is the maximum number of IPv4 ranges of each range list.
+	MaxCIDRs = 16
+)
+
+// ErrNotImplemented is returned on platforms without eBPF redirection.
+var ErrNotImplemented = errors.New("not implemented")
+
+// Config is the redirection of a pod, the eBPF equivalent of the iptables rules built from config.Config.
+type Config struct {
+	// ProxyPort is the port of the outbound proxy listener.
+	ProxyPort uint16
+	// InboundCapturePort is the port of the inbound proxy listener.
+	InboundCapturePort uint16
+	// InboundTunnelPort is never redirected, the proxy listens on it directly.
+	InboundTunnelPort uint16
+	// ProxyUIDs and ProxyGIDs own the sockets of the proxy, which are never redirected.
+	ProxyUIDs []uint32
+	ProxyGIDs []uint32
+
+	// InboundAllPorts redirects the inbound connections to all ports but InboundPortsExclude.
+	InboundAllPorts     bool
+	InboundPortsInclude []uint16
+	InboundPortsExclude []uint16
+
+	OutboundPortsExclude []uint16
+	// OutboundAllIPRanges redirects the outbound connections to all IPs but OutboundIPRangesExclude.
+	OutboundAllIPRanges     bool
+	OutboundIPRangesInclude []netip.Prefix
+	OutboundIPRangesExclude []netip.Prefix
+
+	// ExcludeInterfaces are the interfaces of the pod on which inbound connections are not redirected.
+	ExcludeInterfaces []string
+}
+
+// NewConfig converts the istio-iptables configuration of a pod. The connections of the pod to its own IPs are
+// not redirected, like the traffic on the loopback interface with iptables. Settings the eBPF redirection does
+// not implement are rejected instead of being ignored.
+func NewConfig(cfg *config.Config, podIPs []netip.Addr) (*Config, error) {
+	var unsupported []string
+	if cfg.OutboundPortsInclude != "" {
+		unsupported = append(unsupported, "OUTBOUND_PORTS_INCLUDE")
+	}
+	if cfg.KubeVirtInterfaces != "" {
+		unsupported = append(unsupported, "KUBE_VIRT_INTERFACES")
+	}
+	if cfg.RedirectDNS || cfg.CaptureAllDNS {
+		unsupported = append(unsupported, "REDIRECT_DNS")
+	}
+	if cfg.DropInvalid {
+		unsupported = append(unsupported, "DROP_INVALID")
+	}
+	if cfg.EnableInboundIPv6 {
+		unsupported = append(unsupported, "ENABLE_INBOUND_IPV6")
+	}
+	if cfg.OwnerGroupsInclude != constants.OwnerGroupsInclude.DefaultValue || cfg.OwnerGroupsExclude != "" {
+		unsupported = append(unsupported, "OUTBOUND_OWNER_GROUPS")
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("the eBPF redirection does not support %s", strings.Join(unsupported, ", "))
+	}
+
+	c := &Config{}
+	var err error
+	if c.ProxyPort, err = parsePort(cfg.ProxyPort); err != nil {
+		return nil, fmt.Errorf("invalid proxy port: %v", err)
+	}
+	if c.InboundCapturePort, err = parsePort(cfg.InboundCapturePort); err != nil {
+		return nil, fmt.Errorf("invalid inbound capture port: %v", err)
+	}
+	if c.InboundTunnelPort, err = parsePort(cfg.InboundTunnelPort); err != nil {
+		return nil, fmt.Errorf("invalid inbound tunnel port: %v", err)
+	}
+	if c.ProxyUIDs, err = parseIDs(cfg.ProxyUID); err != nil {
+		return nil, fmt.Errorf("invalid proxy UID: %v", err)
+	}
+	if c.ProxyGIDs, err = parseIDs(cfg.ProxyGID); err != nil {
+		return nil, fmt.Errorf("invalid proxy GID: %v", err)
+	}
+
+	if strings.TrimSpace(cfg.InboundPortsInclude) == "*" {
+		c.InboundAllPorts = true
+	} else if c.InboundPortsInclude, err = parsePorts(cfg.InboundPortsInclude); err != nil {
+		return nil, fmt.Errorf("invalid inbound ports: %v", err)
+	}
+	if c.InboundPortsExclude, err = parsePorts(cfg.InboundPortsExclude); err != nil {
+		return nil, fmt.Errorf("invalid excluded inbound ports: %v", err)
+	}
+	if c.OutboundPortsExclude, err = parsePorts(cfg.OutboundPortsExclude); err != nil {
+		return nil, fmt.Errorf("invalid excluded outbound ports: %v", err)
+	}
+
+	if strings.TrimSpace(cfg.OutboundIPRangesInclude) == "*" {
+		c.OutboundAllIPRanges = true
+	} else if c.OutboundIPRangesInclude, err = parseIPRanges(cfg.OutboundIPRangesInclude); err != nil {
+		return nil, fmt.Errorf("invalid outbound IP ranges: %v", err)
+	}
+	if c.OutboundIPRangesExclude, err = parseIPRanges(cfg.OutboundIPRangesExclude); err != nil {
+		return nil, fmt.Errorf("invalid excluded outbound IP ranges: %v", err)
+	}
+	for _, ip := range podIPs {
+		if ip.Is4() {
+			c.OutboundIPRangesExclude = append(c.OutboundIPRangesExclude, netip.PrefixFrom(ip, 32))
+		}
+	}
+
+	for _, iface := range strings.Split(cfg.ExcludeInterfaces, ",") {
+		if iface = strings.TrimSpace(iface); iface != "" {
+			c.ExcludeInterfaces = append(c.ExcludeInterfaces, iface)
+		}
+	}
+	return c, c.validate()
+}
+
+// validate checks the lists fit in the fixed size eBPF map value.
+func (c *Config) validate() error {
+	if len(c.ProxyUIDs) > MaxProxyIDs || len(c.ProxyGIDs) > MaxProxyIDs {
+		return fmt.Errorf("at most %d proxy UIDs and GIDs are supported", MaxProxyIDs)
+	}
+	if len(c.OutboundPortsExclude) > MaxPorts {
+		return fmt.Errorf("at most %d excluded outbound ports are supported, got %d", MaxPorts, len(c.OutboundPortsExclude))
+	}
+	if len(c.OutboundIPRangesInclude) > MaxCIDRs {
+		return fmt.Errorf("at most %d outbound IP ranges are supported, got %d", MaxCIDRs, len(c.OutboundIPRangesInclude))
+	}
+	if len(c.OutboundIPRangesExclude) > MaxCIDRs {
+		return fmt.Errorf("at most %d excluded outbound IP ranges, including the pod IPs, are supported, got %d",
+			MaxCIDRs, len(c.OutboundIPRangesExclude))
+	}
+	return nil
+}
+
+func parsePort(s string) (uint16, error) {
+	p, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(p), nil
+}
+
+func parsePorts(s string) ([]uint16, error) {
+	var ports []uint16
+	for _, p := range strings.Split(s, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		port, err := parsePort(p)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func parseIDs(s string) ([]uint32, error) {
+	var ids []uint32
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint32(v))
+	}
+	return ids, nil
+}
+
+// parseIPRanges parses a list of IPs and CIDRs, skipping the IPv6 ones which are not redirected.
+func parseIPRanges(s string) ([]netip.Prefix, error) {
+	var ranges []netip.Prefix
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		var prefix netip.Prefix
+		if strings.Contains(r, "/") {
+			p, err := netip.ParsePrefix(r)
+			if err != nil {
+				return nil, err
+			}
+			prefix = p.Masked()
+		} else {
+			ip, err := netip.ParseAddr(r)
+			if err != nil {
+				return nil, err
+			}
+			prefix = netip.PrefixFrom(ip, ip.BitLen())
+		}
+		if prefix.Addr().Is4() {
+			ranges = append(ranges, prefix)
+		}
+	}
+	return ranges, nil
+}
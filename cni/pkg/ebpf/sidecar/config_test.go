@@ -0,0 +1,141 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sidecar
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+)
+
+func defaultCaptureConfig() *config.Config {
+	return &config.Config{
+		ProxyPort:               "15001",
+		InboundCapturePort:      "15006",
+		InboundTunnelPort:       "15008",
+		ProxyUID:                "1337",
+		ProxyGID:                "1337",
+		InboundPortsInclude:     "*",
+		InboundPortsExclude:     "15020,15021,15090",
+		OutboundIPRangesInclude: "*",
+		OwnerGroupsInclude:      "*",
+	}
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	var s []string
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return s
+}
+
+func TestNewConfig(t *testing.T) {
+	podIPs := []netip.Addr{netip.MustParseAddr("10.244.0.5"), netip.MustParseAddr("fd00::5")}
+
+	cfg, err := NewConfig(defaultCaptureConfig(), podIPs)
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.ProxyPort, uint16(15001))
+	assert.Equal(t, cfg.InboundCapturePort, uint16(15006))
+	assert.Equal(t, cfg.InboundTunnelPort, uint16(15008))
+	assert.Equal(t, cfg.ProxyUIDs, []uint32{1337})
+	assert.Equal(t, cfg.ProxyGIDs, []uint32{1337})
+	assert.Equal(t, cfg.InboundAllPorts, true)
+	assert.Equal(t, cfg.InboundPortsExclude, []uint16{15020, 15021, 15090})
+	assert.Equal(t, cfg.OutboundAllIPRanges, true)
+	assert.Equal(t, prefixStrings(cfg.OutboundIPRangesExclude), []string{"10.244.0.5/32"})
+
+	c := defaultCaptureConfig()
+	c.InboundPortsInclude = "80, 8080"
+	c.OutboundPortsExclude = "3306"
+	c.OutboundIPRangesInclude = "10.96.0.0/12,fd00:10:96::/112"
+	c.OutboundIPRangesExclude = "10.96.0.10,10.100.1.1/16"
+	c.ExcludeInterfaces = "eth1, eth2"
+	cfg, err = NewConfig(c, podIPs)
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.InboundAllPorts, false)
+	assert.Equal(t, cfg.InboundPortsInclude, []uint16{80, 8080})
+	assert.Equal(t, cfg.OutboundPortsExclude, []uint16{3306})
+	assert.Equal(t, cfg.OutboundAllIPRanges, false)
+	assert.Equal(t, prefixStrings(cfg.OutboundIPRangesInclude), []string{"10.96.0.0/12"})
+	assert.Equal(t, prefixStrings(cfg.OutboundIPRangesExclude), []string{"10.96.0.10/32", "10.100.0.0/16", "10.244.0.5/32"})
+	assert.Equal(t, cfg.ExcludeInterfaces, []string{"eth1", "eth2"})
+}
+
+func TestNewConfigInvalid(t *testing.T) {
+	ports := make([]string, MaxPorts+1)
+	for i := range ports {
+		ports[i] = "80"
+	}
+	cidrs := make([]string, MaxCIDRs)
+	for i := range cidrs {
+		cidrs[i] = "10.0.0.1"
+	}
+	cases := []struct {
+		name   string
+		modify func(c *config.Config)
+		err    string
+	}{
+		{
+			name:   "outbound ports include",
+			modify: func(c *config.Config) { c.OutboundPortsInclude = "80" },
+			err:    "OUTBOUND_PORTS_INCLUDE",
+		},
+		{
+			name:   "dns capture",
+			modify: func(c *config.Config) { c.RedirectDNS = true },
+			err:    "REDIRECT_DNS",
+		},
+		{
+			name:   "owner groups",
+			modify: func(c *config.Config) { c.OwnerGroupsExclude = "1000" },
+			err:    "OUTBOUND_OWNER_GROUPS",
+		},
+		{
+			name:   "invalid port",
+			modify: func(c *config.Config) { c.InboundPortsExclude = "http" },
+			err:    "invalid excluded inbound ports",
+		},
+		{
+			name:   "invalid range",
+			modify: func(c *config.Config) { c.OutboundIPRangesExclude = "10.0.0.0/33" },
+			err:    "invalid excluded outbound IP ranges",
+		},
+		{
+			name:   "too many ports",
+			modify: func(c *config.Config) { c.OutboundPortsExclude = strings.Join(ports, ",") },
+			err:    "excluded outbound ports",
+		},
+		{
+			name:   "too many ranges with the pod IP",
+			modify: func(c *config.Config) { c.OutboundIPRangesExclude = strings.Join(cidrs, ",") },
+			err:    "excluded outbound IP ranges",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := defaultCaptureConfig()
+			tc.modify(c)
+			_, err := NewConfig(c, []netip.Addr{netip.MustParseAddr("10.244.0.5")})
+			assert.Error(t, err)
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
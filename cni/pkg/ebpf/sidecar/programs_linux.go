@@ -0,0 +1,481 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sidecar
+
+import (
+	"fmt"
+	"net/netip"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/josharian/native"
+	"golang.org/x/sys/unix"
+)
+
+// The programs are assembled at runtime rather than compiled from C, so that the per pod inbound program can
+// embed the ports of the pod, and the CNI plugin does not depend on a prebuilt object for each architecture.
+
+const (
+	license = "Apache-2.0"
+
+	// Offsets of the fields of the context structures, see include/uapi/linux/bpf.h.
+	sockAddrUserFamily = 0
+	sockAddrUserIP4    = 4
+	sockAddrUserPort   = 24
+	sockAddrProtocol   = 36
+
+	sockOpsOp        = 0
+	sockOpsFamily    = 20
+	sockOpsLocalPort = 68
+
+	sockoptSk        = 0
+	sockoptOptval    = 8
+	sockoptOptvalEnd = 16
+	sockoptLevel     = 24
+	sockoptOptname   = 28
+	sockoptOptlen    = 32
+	sockoptRetval    = 36
+
+	sockSrcIP4   = 24
+	sockSrcPort  = 44
+	sockDstPort  = 48
+	sockState    = 72
+	skbData      = 76
+	skbDataEnd   = 80
+	tcpListen    = 10
+	tcActOK      = 0
+	tcActShot    = 2
+	soOrigDst    = 80
+	sockaddrSize = 16
+
+	// sockOpsActiveEstablished is BPF_SOCK_OPS_ACTIVE_ESTABLISHED_CB.
+	sockOpsActiveEstablished = 4
+	// currentNetns is BPF_F_CURRENT_NETNS.
+	currentNetns = -1
+
+	flagOutboundAllIPRanges = 1 << 0
+)
+
+// cidr is an IPv4 range, both fields holding the bytes in network order.
+type cidr struct {
+	Addr uint32
+	Mask uint32
+}
+
+// podConfig is the value of the pod config map read by the connect4 program.
+// Note: it is encoded byte by byte into the map, the offsets are used by the program.
+type podConfig struct {
+	// ProxyPort holds the port in network order in its first two bytes, like bpf_sock_addr.user_port.
+	ProxyPort       uint32
+	Flags           uint32
+	NumUIDs         uint32
+	UIDs            [MaxProxyIDs]uint32
+	NumGIDs         uint32
+	GIDs            [MaxProxyIDs]uint32
+	NumExcludePorts uint32
+	ExcludePorts    [MaxPorts]uint32
+	NumInclude      uint32
+	Include         [MaxCIDRs]cidr
+	NumExclude      uint32
+	Exclude         [MaxCIDRs]cidr
+}
+
+// origDst is the original destination of an outbound connection, in network order.
+type origDst struct {
+	Addr uint32
+	Port uint32
+}
+
+// pairKey identifies a redirected connection by the local port of the application socket.
+type pairKey struct {
+	NetnsCookie uint64
+	Port        uint32
+	Pad         uint32
+}
+
+func newPodConfig(c *Config) podConfig {
+	pc := podConfig{
+		ProxyPort: portValue(c.ProxyPort),
+		NumUIDs:   uint32(len(c.ProxyUIDs)),
+		NumGIDs:   uint32(len(c.ProxyGIDs)),
+	}
+	copy(pc.UIDs[:], c.ProxyUIDs)
+	copy(pc.GIDs[:], c.ProxyGIDs)
+	pc.NumExcludePorts = uint32(len(c.OutboundPortsExclude))
+	for i, p := range c.OutboundPortsExclude {
+		pc.ExcludePorts[i] = portValue(p)
+	}
+	if c.OutboundAllIPRanges {
+		pc.Flags |= flagOutboundAllIPRanges
+	}
+	pc.NumInclude = uint32(len(c.OutboundIPRangesInclude))
+	for i, p := range c.OutboundIPRangesInclude {
+		pc.Include[i] = newCIDR(p)
+	}
+	pc.NumExclude = uint32(len(c.OutboundIPRangesExclude))
+	for i, p := range c.OutboundIPRangesExclude {
+		pc.Exclude[i] = newCIDR(p)
+	}
+	return pc
+}
+
+func newCIDR(p netip.Prefix) cidr {
+	addr := p.Masked().Addr().As4()
+	var m [4]byte
+	for i := 0; i < p.Bits(); i++ {
+		m[i/8] |= 0x80 >> (i % 8)
+	}
+	return cidr{Addr: native.Endian.Uint32(addr[:]), Mask: native.Endian.Uint32(m[:])}
+}
+
+// portValue returns the value of a 32 bits load of a port stored in network order, followed by two zero bytes.
+func portValue(port uint16) uint32 {
+	return native.Endian.Uint32([]byte{byte(port >> 8), byte(port), 0, 0})
+}
+
+// port16Value returns the value of a 16 bits load of a port stored in network order.
+func port16Value(port uint16) int32 {
+	return int32(native.Endian.Uint16([]byte{byte(port >> 8), byte(port)}))
+}
+
+func ip4Value(ip netip.Addr) int32 {
+	b := ip.As4()
+	return int32(native.Endian.Uint32(b[:]))
+}
+
+func offsetOf(field uintptr) int16 {
+	return int16(field)
+}
+
+// loopIDs jumps to label if the 32 bits register matches one of the n IDs stored at offset in the map value
+// pointed to by R7. It clobbers R2 and R3.
+func loopIDs(reg asm.Register, numOffset, offset int16, n int, name, label string) asm.Instructions {
+	done := name + "_done"
+	insns := asm.Instructions{asm.LoadMem(asm.R2, asm.R7, numOffset, asm.Word)}
+	for i := 0; i < n; i++ {
+		insns = append(insns,
+			asm.JLE.Imm(asm.R2, int32(i), done),
+			asm.LoadMem(asm.R3, asm.R7, offset+int16(4*i), asm.Word),
+			asm.JEq.Reg32(asm.R3, reg, label),
+		)
+	}
+	return append(insns, asm.Mov.Imm(asm.R2, 0).WithSymbol(done))
+}
+
+// loopCIDRs jumps to label if the IPv4 address in R8 is in one of the ranges stored at offset in the map value
+// pointed to by R7. It clobbers R1 to R4.
+func loopCIDRs(numOffset, offset int16, name, label string) asm.Instructions {
+	done := name + "_done"
+	insns := asm.Instructions{asm.LoadMem(asm.R2, asm.R7, numOffset, asm.Word)}
+	for i := 0; i < MaxCIDRs; i++ {
+		o := offset + int16(8*i)
+		insns = append(insns,
+			asm.JLE.Imm(asm.R2, int32(i), done),
+			asm.LoadMem(asm.R3, asm.R7, o, asm.Word),
+			asm.LoadMem(asm.R4, asm.R7, o+4, asm.Word),
+			asm.Mov.Reg32(asm.R1, asm.R8),
+			asm.And.Reg32(asm.R1, asm.R4),
+			asm.JEq.Reg32(asm.R1, asm.R3, label),
+		)
+	}
+	return append(insns, asm.Mov.Imm(asm.R2, 0).WithSymbol(done))
+}
+
+// connect4Instructions redirects the outbound TCP connections of the pods in the config map to the proxy,
+// recording their original destination by socket cookie.
+func connect4Instructions(configMap, cookieMap *ebpf.Map) asm.Instructions {
+	var pc podConfig
+	loopback := netip.MustParseAddr("127.0.0.1")
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R1, asm.R6, sockAddrProtocol, asm.Word),
+		asm.JNE.Imm(asm.R1, unix.IPPROTO_TCP, "allow"),
+		asm.LoadMem(asm.R1, asm.R6, sockAddrUserFamily, asm.Word),
+		asm.JNE.Imm(asm.R1, unix.AF_INET, "allow"),
+
+		// Look up the config of the pod
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.FnGetNetnsCookie.Call(),
+		asm.StoreMem(asm.RFP, -8, asm.R0, asm.DWord),
+		asm.LoadMapPtr(asm.R1, configMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "allow"),
+		asm.Mov.Reg(asm.R7, asm.R0),
+
+		// The proxy owns the sockets it connects with
+		asm.FnGetCurrentUidGid.Call(),
+		asm.Mov.Reg(asm.R8, asm.R0),
+		asm.Mov.Reg(asm.R9, asm.R0),
+		asm.RSh.Imm(asm.R9, 32),
+	}
+	insns = append(insns, loopIDs(asm.R8, offsetOf(unsafe.Offsetof(pc.NumUIDs)), offsetOf(unsafe.Offsetof(pc.UIDs)),
+		MaxProxyIDs, "uid", "allow")...)
+	insns = append(insns, loopIDs(asm.R9, offsetOf(unsafe.Offsetof(pc.NumGIDs)), offsetOf(unsafe.Offsetof(pc.GIDs)),
+		MaxProxyIDs, "gid", "allow")...)
+
+	insns = append(insns,
+		asm.LoadMem(asm.R8, asm.R6, sockAddrUserIP4, asm.Word),
+		asm.LoadMem(asm.R9, asm.R6, sockAddrUserPort, asm.Word),
+		// Connections to the loopback addresses are not redirected
+		asm.Mov.Reg32(asm.R1, asm.R8),
+		asm.And.Imm32(asm.R1, ip4Value(netip.MustParseAddr("255.0.0.0"))),
+		asm.JEq.Imm32(asm.R1, ip4Value(netip.MustParseAddr("127.0.0.0")), "allow"),
+	)
+	insns = append(insns, loopIDs(asm.R9, offsetOf(unsafe.Offsetof(pc.NumExcludePorts)),
+		offsetOf(unsafe.Offsetof(pc.ExcludePorts)), MaxPorts, "port", "allow")...)
+	insns = append(insns, loopCIDRs(offsetOf(unsafe.Offsetof(pc.NumExclude)), offsetOf(unsafe.Offsetof(pc.Exclude)),
+		"exclude", "allow")...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.R7, offsetOf(unsafe.Offsetof(pc.Flags)), asm.Word),
+		asm.And.Imm(asm.R1, flagOutboundAllIPRanges),
+		asm.JNE.Imm(asm.R1, 0, "redirect"),
+	)
+	insns = append(insns, loopCIDRs(offsetOf(unsafe.Offsetof(pc.NumInclude)), offsetOf(unsafe.Offsetof(pc.Include)),
+		"include", "redirect")...)
+	insns = append(insns,
+		asm.Ja.Label("allow"),
+
+		// Record the original destination, the connection is not redirected if it cannot be recorded
+		asm.StoreMem(asm.RFP, -16, asm.R8, asm.Word).WithSymbol("redirect"),
+		asm.StoreMem(asm.RFP, -12, asm.R9, asm.Word),
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.FnGetSocketCookie.Call(),
+		asm.StoreMem(asm.RFP, -8, asm.R0, asm.DWord),
+		asm.LoadMapPtr(asm.R1, cookieMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, -16),
+		asm.Mov.Imm(asm.R4, int32(ebpf.UpdateAny)),
+		asm.FnMapUpdateElem.Call(),
+		asm.JNE.Imm(asm.R0, 0, "allow"),
+
+		asm.Mov.Imm32(asm.R1, ip4Value(loopback)),
+		asm.StoreMem(asm.R6, sockAddrUserIP4, asm.R1, asm.Word),
+		asm.LoadMem(asm.R1, asm.R7, offsetOf(unsafe.Offsetof(pc.ProxyPort)), asm.Word),
+		asm.StoreMem(asm.R6, sockAddrUserPort, asm.R1, asm.Word),
+
+		asm.Mov.Imm(asm.R0, 1).WithSymbol("allow"),
+		asm.Return(),
+	)
+	return insns
+}
+
+// sockOpsInstructions moves the original destination of the redirected connections from the socket cookie to
+// the local port of the application socket once it is connected, which the proxy sees as the peer port.
+func sockOpsInstructions(cookieMap, pairMap *ebpf.Map) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R1, asm.R6, sockOpsOp, asm.Word),
+		asm.JNE.Imm(asm.R1, sockOpsActiveEstablished, "out"),
+		asm.LoadMem(asm.R1, asm.R6, sockOpsFamily, asm.Word),
+		asm.JNE.Imm(asm.R1, unix.AF_INET, "out"),
+
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.FnGetSocketCookie.Call(),
+		asm.StoreMem(asm.RFP, -8, asm.R0, asm.DWord),
+		asm.LoadMapPtr(asm.R1, cookieMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "out"),
+		asm.LoadMem(asm.R1, asm.R0, 0, asm.DWord),
+		asm.StoreMem(asm.RFP, -24, asm.R1, asm.DWord),
+
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.FnGetNetnsCookie.Call(),
+		asm.StoreMem(asm.RFP, -40, asm.R0, asm.DWord),
+		asm.LoadMem(asm.R1, asm.R6, sockOpsLocalPort, asm.Word),
+		asm.StoreMem(asm.RFP, -32, asm.R1, asm.Word),
+		asm.StoreImm(asm.RFP, -28, 0, asm.Word),
+		asm.LoadMapPtr(asm.R1, pairMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -40),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, -24),
+		asm.Mov.Imm(asm.R4, int32(ebpf.UpdateAny)),
+		asm.FnMapUpdateElem.Call(),
+
+		asm.LoadMapPtr(asm.R1, cookieMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.FnMapDeleteElem.Call(),
+
+		asm.Mov.Imm(asm.R0, 1).WithSymbol("out"),
+		asm.Return(),
+	}
+}
+
+// getsockoptInstructions answers SO_ORIGINAL_DST on the sockets accepted by the proxy in the pods of the config
+// map: with the recorded destination for the outbound connections, and with the local address of the socket
+// for the inbound connections assigned to the proxy listener, which keep their destination.
+func getsockoptInstructions(configMap, pairMap *ebpf.Map) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R1, asm.R6, sockoptLevel, asm.Word),
+		asm.JNE.Imm(asm.R1, unix.SOL_IP, "out"),
+		asm.LoadMem(asm.R1, asm.R6, sockoptOptname, asm.Word),
+		asm.JNE.Imm(asm.R1, soOrigDst, "out"),
+		asm.LoadMem(asm.R7, asm.R6, sockoptSk, asm.DWord),
+		asm.JEq.Imm(asm.R7, 0, "out"),
+
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.FnGetNetnsCookie.Call(),
+		asm.StoreMem(asm.RFP, -16, asm.R0, asm.DWord),
+		asm.LoadMem(asm.R1, asm.R7, sockDstPort, asm.Half),
+		asm.HostTo(asm.BE, asm.R1, asm.Half),
+		asm.StoreMem(asm.RFP, -8, asm.R1, asm.Word),
+		asm.StoreImm(asm.RFP, -4, 0, asm.Word),
+		asm.LoadMapPtr(asm.R1, pairMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -16),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "local"),
+		asm.LoadMem(asm.R8, asm.R0, 0, asm.Word),
+		asm.LoadMem(asm.R9, asm.R0, 4, asm.Word),
+		asm.LoadMapPtr(asm.R1, pairMap.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -16),
+		asm.FnMapDeleteElem.Call(),
+		asm.Ja.Label("write"),
+
+		// Only answer for the pods redirected with eBPF, when the kernel could not answer
+		asm.LoadMapPtr(asm.R1, configMap.FD()).WithSymbol("local"),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -16),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "out"),
+		asm.LoadMem(asm.R1, asm.R6, sockoptRetval, asm.Word),
+		asm.JEq.Imm(asm.R1, 0, "out"),
+		asm.LoadMem(asm.R8, asm.R7, sockSrcIP4, asm.Word),
+		asm.LoadMem(asm.R9, asm.R7, sockSrcPort, asm.Word),
+		asm.HostTo(asm.BE, asm.R9, asm.Half),
+
+		// Write the struct sockaddr_in
+		asm.LoadMem(asm.R2, asm.R6, sockoptOptval, asm.DWord).WithSymbol("write"),
+		asm.LoadMem(asm.R3, asm.R6, sockoptOptvalEnd, asm.DWord),
+		asm.Mov.Reg(asm.R4, asm.R2),
+		asm.Add.Imm(asm.R4, sockaddrSize),
+		asm.JGT.Reg(asm.R4, asm.R3, "out"),
+		asm.Mov.Imm(asm.R1, unix.AF_INET),
+		asm.StoreMem(asm.R2, 0, asm.R1, asm.Half),
+		asm.StoreMem(asm.R2, 2, asm.R9, asm.Half),
+		asm.StoreMem(asm.R2, 4, asm.R8, asm.Word),
+		asm.Mov.Imm(asm.R1, 0),
+		asm.StoreMem(asm.R2, 8, asm.R1, asm.DWord),
+		asm.Mov.Imm(asm.R1, sockaddrSize),
+		asm.StoreMem(asm.R6, sockoptOptlen, asm.R1, asm.Word),
+		asm.Mov.Imm(asm.R1, 0),
+		asm.StoreMem(asm.R6, sockoptRetval, asm.R1, asm.Word),
+
+		asm.Mov.Imm(asm.R0, 1).WithSymbol("out"),
+		asm.Return(),
+	}
+}
+
+// inboundInstructions assigns the new inbound TCP connections of the pod to the inbound proxy listener,
+// unless their port is excluded. Only IPv4 packets without IP options are redirected.
+func inboundInstructions(c *Config) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R2, asm.R6, skbData, asm.Word),
+		asm.LoadMem(asm.R3, asm.R6, skbDataEnd, asm.Word),
+		asm.Mov.Reg(asm.R4, asm.R2),
+		// Ethernet, IPv4 and TCP headers
+		asm.Add.Imm(asm.R4, 14+20+20),
+		asm.JGT.Reg(asm.R4, asm.R3, "ok"),
+		asm.LoadMem(asm.R4, asm.R2, 12, asm.Half),
+		asm.JNE.Imm(asm.R4, port16Value(unix.ETH_P_IP), "ok"),
+		asm.LoadMem(asm.R4, asm.R2, 14, asm.Byte),
+		asm.JNE.Imm(asm.R4, 0x45, "ok"),
+		asm.LoadMem(asm.R4, asm.R2, 14+9, asm.Byte),
+		asm.JNE.Imm(asm.R4, unix.IPPROTO_TCP, "ok"),
+		asm.LoadMem(asm.R5, asm.R2, 14+20+2, asm.Half),
+	}
+	excluded := append([]uint16{c.InboundTunnelPort, c.InboundCapturePort}, c.InboundPortsExclude...)
+	for _, p := range excluded {
+		insns = append(insns, asm.JEq.Imm(asm.R5, port16Value(p), "ok"))
+	}
+	if !c.InboundAllPorts {
+		for _, p := range c.InboundPortsInclude {
+			insns = append(insns, asm.JEq.Imm(asm.R5, port16Value(p), "capture"))
+		}
+		insns = append(insns, asm.Ja.Label("ok"))
+	}
+	insns = append(insns,
+		// Existing connections are delivered as usual, the tuple is the IPv4 addresses followed by the ports
+		asm.Mov.Reg(asm.R1, asm.R6).WithSymbol("capture"),
+		asm.Add.Imm(asm.R2, 14+12),
+		asm.Mov.Imm(asm.R3, 12),
+		asm.Mov.Imm(asm.R4, currentNetns),
+		asm.Mov.Imm(asm.R5, 0),
+		asm.FnSkcLookupTcp.Call(),
+		asm.JEq.Imm(asm.R0, 0, "listener"),
+		asm.LoadMem(asm.R7, asm.R0, sockState, asm.Word),
+		asm.Mov.Reg(asm.R1, asm.R0),
+		asm.FnSkRelease.Call(),
+		asm.JNE.Imm(asm.R7, tcpListen, "ok"),
+
+		// Look up the proxy listener
+		asm.StoreImm(asm.RFP, -16, 0, asm.DWord).WithSymbol("listener"),
+		asm.StoreImm(asm.RFP, -8, 0, asm.DWord),
+		asm.StoreImm(asm.RFP, -6, int64(port16Value(c.InboundCapturePort)), asm.Half),
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -16),
+		asm.Mov.Imm(asm.R3, 12),
+		asm.Mov.Imm(asm.R4, currentNetns),
+		asm.Mov.Imm(asm.R5, 0),
+		asm.FnSkcLookupTcp.Call(),
+		asm.JEq.Imm(asm.R0, 0, "ok"),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		asm.LoadMem(asm.R1, asm.R7, sockState, asm.Word),
+		asm.JNE.Imm(asm.R1, tcpListen, "release"),
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.Mov.Reg(asm.R2, asm.R7),
+		asm.Mov.Imm(asm.R3, 0),
+		asm.FnSkAssign.Call(),
+		asm.Mov.Reg(asm.R8, asm.R0),
+		asm.Mov.Reg(asm.R1, asm.R7),
+		asm.FnSkRelease.Call(),
+		asm.JNE.Imm(asm.R8, 0, "shot"),
+		asm.Ja.Label("ok"),
+
+		asm.Mov.Reg(asm.R1, asm.R7).WithSymbol("release"),
+		asm.FnSkRelease.Call(),
+		asm.Mov.Imm(asm.R0, tcActOK).WithSymbol("ok"),
+		asm.Return(),
+		asm.Mov.Imm(asm.R0, tcActShot).WithSymbol("shot"),
+		asm.Return(),
+	)
+	return insns
+}
+
+func newProgram(name string, typ ebpf.ProgramType, attach ebpf.AttachType, insns asm.Instructions) (*ebpf.Program, error) {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         name,
+		Type:         typ,
+		AttachType:   attach,
+		Instructions: insns,
+		License:      license,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load program %s: %w", name, err)
+	}
+	return prog, nil
+}
@@ -0,0 +1,377 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sidecar
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	istiolog "istio.io/pkg/log"
+)
+
+var log = istiolog.RegisterScope("ebpf", "ebpf redirection")
+
+const (
+	// PinPath holds the maps and the cgroup links of the sidecar redirection.
+	PinPath = "/sys/fs/bpf/istio-sidecar"
+
+	// MaxPods is the maximum number of pods redirected on a node. The config map is sized for more pods than a
+	// node runs, as it also holds the configs of the deleted pods which could not be removed, until they are
+	// evicted.
+	MaxPods = 1024
+	// maxConnections is the maximum number of outbound connections being established at once on a node.
+	maxConnections = 65536
+
+	configMapName = "sidecar_config"
+	cookieMapName = "sidecar_cookie"
+	pairMapName   = "sidecar_pair"
+
+	inboundProgName = "sidecar_inbound"
+	// tcHandle identifies the inbound filter, so that programming a pod again replaces it.
+	tcHandle = 0x1570
+
+	// soNetnsCookie is SO_NETNS_COOKIE, Linux 5.14 or later. The programs also need bpf_get_netns_cookie in the
+	// sock_ops and getsockopt programs, so the redirection requires Linux 5.15 or later.
+	soNetnsCookie = 71
+)
+
+// cgroupRoots are the mount points of the cgroup v2 hierarchy, unified and hybrid.
+var cgroupRoots = []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"}
+
+// configMapSpec is the spec of the map of the pod configs, keyed by network namespace cookie. The CNI DEL of a pod
+// often runs once its network namespace is gone, and the cookie of the namespace can no longer be computed to
+// remove its config. The cookies are never reused, so the map is a LRU map: the programs look up the config of
+// the running pods on every connection, and the configs of the deleted pods are evicted first when it is full.
+func configMapSpec() *ebpf.MapSpec {
+	return &ebpf.MapSpec{
+		Name:       configMapName,
+		Type:       ebpf.LRUHash,
+		KeySize:    8,
+		ValueSize:  uint32(unsafe.Sizeof(podConfig{})),
+		MaxEntries: MaxPods,
+		Pinning:    ebpf.PinByName,
+	}
+}
+
+func cookieMapSpec() *ebpf.MapSpec {
+	return &ebpf.MapSpec{
+		Name:       cookieMapName,
+		Type:       ebpf.LRUHash,
+		KeySize:    8,
+		ValueSize:  uint32(unsafe.Sizeof(origDst{})),
+		MaxEntries: maxConnections,
+		Pinning:    ebpf.PinByName,
+	}
+}
+
+func pairMapSpec() *ebpf.MapSpec {
+	return &ebpf.MapSpec{
+		Name:       pairMapName,
+		Type:       ebpf.LRUHash,
+		KeySize:    uint32(unsafe.Sizeof(pairKey{})),
+		ValueSize:  uint32(unsafe.Sizeof(origDst{})),
+		MaxEntries: maxConnections,
+		Pinning:    ebpf.PinByName,
+	}
+}
+
+type maps struct {
+	config *ebpf.Map
+	cookie *ebpf.Map
+	pair   *ebpf.Map
+}
+
+func (m *maps) Close() {
+	for _, mp := range []*ebpf.Map{m.config, m.cookie, m.pair} {
+		if mp != nil {
+			mp.Close()
+		}
+	}
+}
+
+// loadMaps creates the pinned maps, or opens them if they exist.
+func loadMaps() (*maps, error) {
+	if err := os.MkdirAll(PinPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", PinPath, err)
+	}
+	opts := ebpf.MapOptions{PinPath: PinPath}
+	m := &maps{}
+	var err error
+	if m.config, err = ebpf.NewMapWithOptions(configMapSpec(), opts); err != nil {
+		return nil, fmt.Errorf("failed to load map %s: %w", configMapName, err)
+	}
+	if m.cookie, err = ebpf.NewMapWithOptions(cookieMapSpec(), opts); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to load map %s: %w", cookieMapName, err)
+	}
+	if m.pair, err = ebpf.NewMapWithOptions(pairMapSpec(), opts); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to load map %s: %w", pairMapName, err)
+	}
+	return m, nil
+}
+
+// lock serializes the CNI plugins updating the cgroup programs.
+func lock() (func(), error) {
+	f, err := os.Open(PinPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %v", PinPath, err)
+	}
+	return func() {
+		_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+func cgroupRoot() (string, error) {
+	for _, root := range cgroupRoots {
+		var st unix.Statfs_t
+		if err := unix.Statfs(root, &st); err == nil && st.Type == unix.CGROUP2_SUPER_MAGIC {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 hierarchy found in %v", cgroupRoots)
+}
+
+// attachCgroupPrograms attaches the outbound programs to the root cgroup, or updates the programs of the pinned
+// links, so that upgrading the CNI plugin replaces them.
+func attachCgroupPrograms(m *maps) error {
+	root, err := cgroupRoot()
+	if err != nil {
+		return err
+	}
+	unlock, err := lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	programs := []struct {
+		name   string
+		typ    ebpf.ProgramType
+		attach ebpf.AttachType
+		insns  asm.Instructions
+	}{
+		{"sidecar_connect", ebpf.CGroupSockAddr, ebpf.AttachCGroupInet4Connect, connect4Instructions(m.config, m.cookie)},
+		{"sidecar_sockops", ebpf.SockOps, ebpf.AttachCGroupSockOps, sockOpsInstructions(m.cookie, m.pair)},
+		{"sidecar_getsock", ebpf.CGroupSockopt, ebpf.AttachCGroupGetsockopt, getsockoptInstructions(m.config, m.pair)},
+	}
+	for _, p := range programs {
+		prog, err := newProgram(p.name, p.typ, p.attach, p.insns)
+		if err != nil {
+			return err
+		}
+		err = attachCgroupProgram(root, p.name, p.attach, prog)
+		prog.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func attachCgroupProgram(root, name string, attach ebpf.AttachType, prog *ebpf.Program) error {
+	pin := filepath.Join(PinPath, name)
+	if l, err := link.LoadPinnedLink(pin, nil); err == nil {
+		defer l.Close()
+		if err := l.Update(prog); err != nil {
+			return fmt.Errorf("failed to update program %s: %v", name, err)
+		}
+		return nil
+	}
+	l, err := link.AttachCgroup(link.CgroupOptions{Path: root, Attach: attach, Program: prog})
+	if err != nil {
+		return fmt.Errorf("failed to attach program %s to %s: %v", name, root, err)
+	}
+	defer l.Close()
+	if err := l.Pin(pin); err != nil {
+		_ = l.Unpin()
+		return fmt.Errorf("failed to pin program %s: %v", name, err)
+	}
+	log.Infof("attached program %s to cgroup %s", name, root)
+	return nil
+}
+
+// netnsCookie returns the cookie of the current network namespace.
+func netnsCookie() (uint64, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	cookie, err := unix.GetsockoptUint64(fd, unix.SOL_SOCKET, soNetnsCookie)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the network namespace cookie: %v", err)
+	}
+	return cookie, nil
+}
+
+// Program redirects the traffic of the pod running in the network namespace to the proxy. It returns true if the
+// redirection was missing or differed, and had to be programmed.
+func Program(netnsPath string, cfg *Config) (bool, error) {
+	m, err := loadMaps()
+	if err != nil {
+		return false, err
+	}
+	defer m.Close()
+	if err := attachCgroupPrograms(m); err != nil {
+		return false, err
+	}
+	inbound, err := newProgram(inboundProgName, ebpf.SchedCLS, ebpf.AttachNone, inboundInstructions(cfg))
+	if err != nil {
+		return false, err
+	}
+	defer inbound.Close()
+
+	netNs, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open netns %q: %v", netnsPath, err)
+	}
+	defer netNs.Close()
+
+	changed := false
+	err = netNs.Do(func(ns.NetNS) error {
+		cookie, err := netnsCookie()
+		if err != nil {
+			return err
+		}
+		want := newPodConfig(cfg)
+		var got podConfig
+		if err := m.config.Lookup(cookie, &got); err != nil || got != want {
+			changed = true
+			if err := m.config.Put(cookie, want); err != nil {
+				return fmt.Errorf("failed to update the config of the pod: %w", err)
+			}
+		}
+		attached, err := attachInbound(inbound, cfg.ExcludeInterfaces)
+		changed = changed || attached
+		return err
+	})
+	return changed, err
+}
+
+// attachInbound attaches the inbound program to the ingress of the interfaces of the current network namespace,
+// but the loopback and the excluded ones. It returns true if it was missing on any interface.
+func attachInbound(prog *ebpf.Program, exclude []string) (bool, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return false, fmt.Errorf("failed to list the interfaces: %v", err)
+	}
+	info, err := prog.Info()
+	if err != nil {
+		return false, err
+	}
+	progID, _ := info.ID()
+	attached := false
+	for _, l := range links {
+		attrs := l.Attrs()
+		if attrs.Flags&unix.IFF_LOOPBACK != 0 || contains(exclude, attrs.Name) {
+			continue
+		}
+		qdisc := &netlink.GenericQdisc{
+			QdiscAttrs: netlink.QdiscAttrs{
+				LinkIndex: attrs.Index,
+				Handle:    netlink.MakeHandle(0xffff, 0),
+				Parent:    netlink.HANDLE_CLSACT,
+			},
+			QdiscType: "clsact",
+		}
+		if err := netlink.QdiscReplace(qdisc); err != nil {
+			return attached, fmt.Errorf("failed to add the clsact qdisc to %s: %v", attrs.Name, err)
+		}
+		parent := uint32(netlink.HANDLE_MIN_INGRESS)
+		filters, err := netlink.FilterList(l, parent)
+		if err != nil {
+			return attached, fmt.Errorf("failed to list the filters of %s: %v", attrs.Name, err)
+		}
+		found := false
+		for _, f := range filters {
+			if bpf, ok := f.(*netlink.BpfFilter); ok && bpf.Handle == tcHandle && bpf.Name == inboundProgName {
+				found = true
+			}
+		}
+		attached = attached || !found
+		filter := &netlink.BpfFilter{
+			FilterAttrs: netlink.FilterAttrs{
+				LinkIndex: attrs.Index,
+				Parent:    parent,
+				Handle:    tcHandle,
+				Protocol:  unix.ETH_P_ALL,
+				Priority:  1,
+			},
+			Fd:           prog.FD(),
+			Name:         inboundProgName,
+			DirectAction: true,
+		}
+		if err := netlink.FilterReplace(filter); err != nil {
+			return attached, fmt.Errorf("failed to attach program %s to %s: %v", inboundProgName, attrs.Name, err)
+		}
+		log.Debugf("attached program %s (%d) to %s", inboundProgName, progID, attrs.Name)
+	}
+	return attached, nil
+}
+
+// Remove deletes the config of the pod running in the network namespace. The programs attached to the pod
+// interfaces are removed with the namespace. If the namespace is already gone, the config is left to be evicted
+// from the config map.
+func Remove(netnsPath string) error {
+	if _, err := os.Stat(filepath.Join(PinPath, configMapName)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	m, err := loadMaps()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	netNs, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return fmt.Errorf("failed to open netns %q: %v", netnsPath, err)
+	}
+	defer netNs.Close()
+	return netNs.Do(func(ns.NetNS) error {
+		cookie, err := netnsCookie()
+		if err != nil {
+			return err
+		}
+		if err := m.config.Delete(cookie); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("failed to delete the config of the pod: %w", err)
+		}
+		return nil
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sidecar
+
+// Program redirects the traffic of the pod running in the network namespace to the proxy. It returns true if the
+// redirection was missing or differed, and had to be programmed.
+func Program(netnsPath string, cfg *Config) (bool, error) {
+	return false, ErrNotImplemented
+}
+
+// Remove deletes the config of the pod running in the network namespace.
+func Remove(netnsPath string) error {
+	return ErrNotImplemented
+}
@@ -0,0 +1,215 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostnet
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// Fake is an in-memory Interface, for unit tests that cannot change the host network.
+type Fake struct {
+	mu      sync.Mutex
+	routes  []Route
+	rules   []Rule
+	ipsets  map[string][]IPSetEntry
+	sysctls map[string]string
+}
+
+var _ Interface = &Fake{}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{
+		ipsets:  map[string][]IPSetEntry{},
+		sysctls: map[string]string{},
+	}
+}
+
+func (f *Fake) RouteAdd(route *Route) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.routes {
+		if f.routes[i].Matches(route) {
+			return newError("route add", route.String(), ErrExist)
+		}
+	}
+	f.routes = append(f.routes, *route)
+	return nil
+}
+
+func (f *Fake) RouteDel(route *Route) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.routes {
+		if f.routes[i].Matches(route) {
+			f.routes = slices.Delete(f.routes, i, i+1)
+			return nil
+		}
+	}
+	return newError("route del", route.String(), ErrNotFound)
+}
+
+func (f *Fake) RouteList(table int) ([]Route, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []Route
+	for _, r := range f.routes {
+		if r.Table == table {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func (f *Fake) RouteFlush(table int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	routes := f.routes[:0]
+	for _, r := range f.routes {
+		if r.Table != table {
+			routes = append(routes, r)
+		}
+	}
+	f.routes = routes
+	return nil
+}
+
+func (f *Fake) RuleAdd(rule *Rule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slices.Contains(f.rules, *rule) {
+		return newError("rule add", rule.String(), ErrExist)
+	}
+	f.rules = append(f.rules, *rule)
+	return nil
+}
+
+func (f *Fake) RuleDel(priority int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.rules {
+		if f.rules[i].Priority == priority {
+			f.rules = slices.Delete(f.rules, i, i+1)
+			return nil
+		}
+	}
+	return newError("rule del", fmt.Sprintf("priority %d", priority), ErrNotFound)
+}
+
+func (f *Fake) IPSetCreate(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.ipsets[name]; ok {
+		return newError("ipset create", name, ErrExist)
+	}
+	f.ipsets[name] = []IPSetEntry{}
+	return nil
+}
+
+func (f *Fake) IPSetDestroy(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.ipsets[name]; !ok {
+		return newError("ipset destroy", name, ErrNotFound)
+	}
+	delete(f.ipsets, name)
+	return nil
+}
+
+func (f *Fake) IPSetFlush(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.ipsets[name]; !ok {
+		return newError("ipset flush", name, ErrNotFound)
+	}
+	f.ipsets[name] = []IPSetEntry{}
+	return nil
+}
+
+func (f *Fake) IPSetAdd(name string, entry IPSetEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object := name + " " + entry.IP.String()
+	entries, ok := f.ipsets[name]
+	if !ok {
+		return newError("ipset add", object, ErrNotFound)
+	}
+	for _, e := range entries {
+		if e.IP == entry.IP {
+			return newError("ipset add", object, ErrExist)
+		}
+	}
+	f.ipsets[name] = append(entries, entry)
+	return nil
+}
+
+func (f *Fake) IPSetDel(name string, ip netip.Addr) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object := name + " " + ip.String()
+	entries, ok := f.ipsets[name]
+	if !ok {
+		return newError("ipset del", object, ErrNotFound)
+	}
+	for i, e := range entries {
+		if e.IP == ip {
+			f.ipsets[name] = slices.Delete(entries, i, i+1)
+			return nil
+		}
+	}
+	return newError("ipset del", object, ErrNotFound)
+}
+
+func (f *Fake) IPSetList(name string) ([]IPSetEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, ok := f.ipsets[name]
+	if !ok {
+		return nil, newError("ipset list", name, ErrNotFound)
+	}
+	return slices.Clone(entries), nil
+}
+
+func (f *Fake) SetSysctl(key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sysctls[key] = value
+	return nil
+}
+
+// Routes returns all the routes.
+func (f *Fake) Routes() []Route {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.routes)
+}
+
+// Rules returns all the rules.
+func (f *Fake) Rules() []Rule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.rules)
+}
+
+// Sysctl returns the value written to the sysctl.
+func (f *Fake) Sysctl(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sysctls[key]
+}
@@ -0,0 +1,213 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hostnet manages the routes, policy routing rules, ipsets and sysctls of a network namespace.
+// The operations are typed and return an *Error describing the failed operation.
+package hostnet
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// MainTable is the main routing table.
+const MainTable = 254
+
+// Scope is the scope of a route.
+type Scope uint8
+
+const (
+	ScopeUniverse Scope = 0
+	ScopeLink     Scope = 253
+	ScopeHost     Scope = 254
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeUniverse:
+		return "global"
+	case ScopeLink:
+		return "link"
+	case ScopeHost:
+		return "host"
+	}
+	return fmt.Sprint(uint8(s))
+}
+
+// Route is an IPv4 route.
+type Route struct {
+	Table int
+	Dst   netip.Prefix
+	// Gw is the optional gateway.
+	Gw netip.Addr
+	// Dev is the optional name of the output device.
+	Dev string
+	// Src is the optional preferred source address.
+	Src   netip.Addr
+	Scope Scope
+	// Local routes deliver the packets to the host, like `ip route add local`.
+	Local bool
+	// OnLink assumes the gateway is directly reachable on the device.
+	OnLink bool
+}
+
+// String returns the route in the `ip route` syntax.
+func (r *Route) String() string {
+	var b strings.Builder
+	if r.Local {
+		b.WriteString("local ")
+	}
+	b.WriteString(r.Dst.String())
+	if r.Gw.IsValid() {
+		b.WriteString(" via " + r.Gw.String())
+	}
+	if r.Dev != "" {
+		b.WriteString(" dev " + r.Dev)
+	}
+	if r.Src.IsValid() {
+		b.WriteString(" src " + r.Src.String())
+	}
+	if r.Scope != ScopeUniverse {
+		b.WriteString(" scope " + r.Scope.String())
+	}
+	if r.OnLink {
+		b.WriteString(" onlink")
+	}
+	b.WriteString(fmt.Sprintf(" table %d", r.Table))
+	return b.String()
+}
+
+// Matches returns true if both routes have the same key: the table, destination and type.
+func (r *Route) Matches(o *Route) bool {
+	return r.Table == o.Table && r.Dst == o.Dst && r.Local == o.Local
+}
+
+// Rule is an IPv4 policy routing rule.
+type Rule struct {
+	Priority int
+	// Mark and Mask match the firewall mark of the packets. All packets match if Mask is 0.
+	Mark uint32
+	Mask uint32
+	// Table is looked up when the rule matches, unless Goto is set.
+	Table int
+	// Goto jumps to the rule with the given priority.
+	Goto int
+}
+
+// String returns the rule in the `ip rule` syntax.
+func (r *Rule) String() string {
+	s := fmt.Sprintf("priority %d", r.Priority)
+	if r.Mask != 0 {
+		s += fmt.Sprintf(" fwmark %#x/%#x", r.Mark, r.Mask)
+	}
+	if r.Goto != 0 {
+		return s + fmt.Sprintf(" goto %d", r.Goto)
+	}
+	return s + fmt.Sprintf(" lookup %d", r.Table)
+}
+
+// IPSetEntry is a member of a hash:ip ipset.
+type IPSetEntry struct {
+	IP      netip.Addr
+	Comment string
+}
+
+// Interface manages the host networking state of the current network namespace.
+type Interface interface {
+	RouteAdd(route *Route) error
+	RouteDel(route *Route) error
+	// RouteList returns the IPv4 routes of the table.
+	RouteList(table int) ([]Route, error)
+	// RouteFlush deletes all IPv4 routes of the table.
+	RouteFlush(table int) error
+
+	RuleAdd(rule *Rule) error
+	// RuleDel deletes the IPv4 rule with the given priority.
+	RuleDel(priority int) error
+
+	// IPSetCreate creates a hash:ip ipset supporting comments.
+	IPSetCreate(name string) error
+	IPSetDestroy(name string) error
+	IPSetFlush(name string) error
+	IPSetAdd(name string, entry IPSetEntry) error
+	IPSetDel(name string, ip netip.Addr) error
+	IPSetList(name string) ([]IPSetEntry, error)
+
+	// SetSysctl writes the value of the sysctl, given as its path relative to /proc/sys,
+	// like net/ipv4/conf/all/rp_filter.
+	SetSysctl(key, value string) error
+}
+
+var (
+	// ErrExist is matched by errors.Is when the object of the operation already exists.
+	ErrExist = errors.New("already exists")
+	// ErrNotFound is matched by errors.Is when the object of the operation does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrNotImplemented is returned on platforms without netlink.
+	ErrNotImplemented = errors.New("not implemented")
+)
+
+// Error is returned by the operations of Interface.
+type Error struct {
+	// Op is the operation that failed, like "route add".
+	Op string
+	// Object is the route, rule, ipset or sysctl the operation applied to.
+	Object string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Object, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is maps the errno returned by the kernel to ErrExist and ErrNotFound.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrExist:
+		return errors.Is(e.Err, syscall.EEXIST)
+	case ErrNotFound:
+		return errors.Is(e.Err, syscall.ESRCH) || errors.Is(e.Err, syscall.ENOENT)
+	}
+	return false
+}
+
+func newError(op, object string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, Object: object, Err: err}
+}
+
+// IgnoreExist returns nil if the error is ErrExist.
+func IgnoreExist(err error) error {
+	if errors.Is(err, ErrExist) {
+		return nil
+	}
+	return err
+}
+
+// IgnoreNotFound returns nil if the error is ErrNotFound.
+func IgnoreNotFound(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
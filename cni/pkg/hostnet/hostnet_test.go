@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostnet
+
+import (
+	"errors"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestRouteString(t *testing.T) {
+	cases := []struct {
+		name  string
+		route Route
+		want  string
+	}{
+		{
+			name: "via",
+			route: Route{
+				Table: 100,
+				Dst:   netip.MustParsePrefix("10.0.0.5/32"),
+				Gw:    netip.MustParseAddr("192.168.126.2"),
+				Dev:   "istioin",
+				Src:   netip.MustParseAddr("172.18.0.2"),
+			},
+			want: "10.0.0.5/32 via 192.168.126.2 dev istioin src 172.18.0.2 table 100",
+		},
+		{
+			name: "local",
+			route: Route{
+				Table: 100,
+				Dst:   netip.MustParsePrefix("0.0.0.0/0"),
+				Dev:   "lo",
+				Scope: ScopeHost,
+				Local: true,
+			},
+			want: "local 0.0.0.0/0 dev lo scope host table 100",
+		},
+		{
+			name: "onlink",
+			route: Route{
+				Table:  102,
+				Dst:    netip.MustParsePrefix("0.0.0.0/0"),
+				Gw:     netip.MustParseAddr("10.0.0.2"),
+				Dev:    "veth1",
+				OnLink: true,
+			},
+			want: "0.0.0.0/0 via 10.0.0.2 dev veth1 onlink table 102",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.route.String(), tt.want)
+		})
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	assert.Equal(t, (&Rule{Priority: 100, Mark: 0x200, Mask: 0x200, Goto: 32766}).String(),
+		"priority 100 fwmark 0x200/0x200 goto 32766")
+	assert.Equal(t, (&Rule{Priority: 103, Table: 100}).String(), "priority 103 lookup 100")
+}
+
+func TestErrorIs(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		exist    bool
+		notFound bool
+	}{
+		{name: "eexist", err: newError("route add", "r", syscall.EEXIST), exist: true},
+		{name: "esrch", err: newError("route del", "r", syscall.ESRCH), notFound: true},
+		{name: "enoent", err: newError("rule del", "r", syscall.ENOENT), notFound: true},
+		{name: "wrapped", err: newError("ipset add", "s", ErrExist), exist: true},
+		{name: "other", err: newError("route add", "r", syscall.EINVAL)},
+		{name: "nil", err: newError("route add", "r", nil)},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, errors.Is(tt.err, ErrExist), tt.exist)
+			assert.Equal(t, errors.Is(tt.err, ErrNotFound), tt.notFound)
+			assert.Equal(t, IgnoreExist(tt.err) == nil, tt.exist || tt.err == nil)
+			assert.Equal(t, IgnoreNotFound(tt.err) == nil, tt.notFound || tt.err == nil)
+		})
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	route := &Route{Table: 100, Dst: netip.MustParsePrefix("10.0.0.5/32"), Dev: "istioin"}
+	assert.NoError(t, f.RouteAdd(route))
+	assert.Equal(t, errors.Is(f.RouteAdd(route), ErrExist), true)
+	assert.NoError(t, f.RouteAdd(&Route{Table: 101, Dst: netip.MustParsePrefix("0.0.0.0/0"), Dev: "istioout"}))
+	assert.NoError(t, f.RouteFlush(100))
+	routes, err := f.RouteList(101)
+	assert.NoError(t, err)
+	assert.Equal(t, len(routes), 1)
+	assert.Equal(t, errors.Is(f.RouteDel(route), ErrNotFound), true)
+
+	assert.NoError(t, f.RuleAdd(&Rule{Priority: 103, Table: 100}))
+	assert.NoError(t, f.RuleDel(103))
+	assert.Equal(t, errors.Is(f.RuleDel(103), ErrNotFound), true)
+
+	ip := netip.MustParseAddr("10.0.0.5")
+	assert.Equal(t, errors.Is(f.IPSetAdd("pods", IPSetEntry{IP: ip}), ErrNotFound), true)
+	assert.NoError(t, f.IPSetCreate("pods"))
+	assert.Equal(t, errors.Is(f.IPSetCreate("pods"), ErrExist), true)
+	assert.NoError(t, f.IPSetAdd("pods", IPSetEntry{IP: ip, Comment: "uid"}))
+	assert.Equal(t, errors.Is(f.IPSetAdd("pods", IPSetEntry{IP: ip}), ErrExist), true)
+	assert.NoError(t, f.IPSetDel("pods", ip))
+	entries, err := f.IPSetList("pods")
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 0)
+
+	assert.NoError(t, f.SetSysctl("net/ipv4/conf/all/rp_filter", "0"))
+	assert.Equal(t, f.Sysctl("net/ipv4/conf/all/rp_filter"), "0")
+}
@@ -0,0 +1,233 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostnet
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// procSys is where the sysctls are written.
+const procSys = "/proc/sys"
+
+type netlinkHost struct{}
+
+// New returns the netlink implementation of Interface, for the network namespace of the calling thread.
+func New() Interface {
+	return netlinkHost{}
+}
+
+func (netlinkHost) RouteAdd(route *Route) error {
+	r, err := toNetlinkRoute(route)
+	if err == nil {
+		err = netlink.RouteAdd(r)
+	}
+	return newError("route add", route.String(), err)
+}
+
+func (netlinkHost) RouteDel(route *Route) error {
+	r, err := toNetlinkRoute(route)
+	if err == nil {
+		err = netlink.RouteDel(r)
+	}
+	return newError("route del", route.String(), err)
+}
+
+func (netlinkHost) RouteList(table int) ([]Route, error) {
+	routes, err := listRoutes(table)
+	if err != nil {
+		return nil, newError("route list", fmt.Sprintf("table %d", table), err)
+	}
+	links := map[int]string{}
+	res := make([]Route, 0, len(routes))
+	for _, r := range routes {
+		route := Route{
+			Table:  r.Table,
+			Scope:  Scope(r.Scope),
+			Local:  r.Type == unix.RTN_LOCAL,
+			OnLink: r.Flags&int(netlink.FLAG_ONLINK) != 0,
+		}
+		if ip, ok := netip.AddrFromSlice(r.Dst.IP); ok {
+			ones, _ := r.Dst.Mask.Size()
+			route.Dst = netip.PrefixFrom(ip.Unmap(), ones)
+		}
+		if ip, ok := netip.AddrFromSlice(r.Gw); ok {
+			route.Gw = ip.Unmap()
+		}
+		if ip, ok := netip.AddrFromSlice(r.Src); ok {
+			route.Src = ip.Unmap()
+		}
+		if r.LinkIndex > 0 {
+			name, f := links[r.LinkIndex]
+			if !f {
+				if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+					name = link.Attrs().Name
+				}
+				links[r.LinkIndex] = name
+			}
+			route.Dev = name
+		}
+		res = append(res, route)
+	}
+	return res, nil
+}
+
+func (netlinkHost) RouteFlush(table int) error {
+	routes, err := listRoutes(table)
+	if err != nil {
+		return newError("route flush", fmt.Sprintf("table %d", table), err)
+	}
+	for i := range routes {
+		if err := netlink.RouteDel(&routes[i]); err != nil {
+			return newError("route flush", fmt.Sprintf("table %d", table), err)
+		}
+	}
+	return nil
+}
+
+// listRoutes returns the IPv4 routes of the table, with the destination of the default routes set.
+func listRoutes(table int) ([]netlink.Route, error) {
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	// default route is not handled proper in netlink
+	// https://github.com/vishvananda/netlink/issues/670
+	// https://github.com/vishvananda/netlink/issues/611
+	for i, route := range routes {
+		if route.Dst == nil || route.Dst.IP == nil {
+			routes[i].Dst = &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}
+		}
+	}
+	return routes, nil
+}
+
+func toNetlinkRoute(route *Route) (*netlink.Route, error) {
+	r := &netlink.Route{
+		Family: netlink.FAMILY_V4,
+		Table:  route.Table,
+		Scope:  netlink.Scope(route.Scope),
+		Type:   unix.RTN_UNICAST,
+		Dst:    &net.IPNet{IP: route.Dst.Addr().AsSlice(), Mask: net.CIDRMask(route.Dst.Bits(), route.Dst.Addr().BitLen())},
+	}
+	if route.Local {
+		r.Type = unix.RTN_LOCAL
+	}
+	if route.Gw.IsValid() {
+		r.Gw = route.Gw.AsSlice()
+	}
+	if route.Src.IsValid() {
+		r.Src = route.Src.AsSlice()
+	}
+	if route.OnLink {
+		r.SetFlag(netlink.FLAG_ONLINK)
+	}
+	if route.Dev != "" {
+		link, err := netlink.LinkByName(route.Dev)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find device %s: %w", route.Dev, err)
+		}
+		r.LinkIndex = link.Attrs().Index
+	}
+	return r, nil
+}
+
+func (netlinkHost) RuleAdd(rule *Rule) error {
+	r := netlink.NewRule()
+	r.Family = unix.AF_INET
+	r.Priority = rule.Priority
+	if rule.Mask != 0 {
+		r.Mark = int(rule.Mark)
+		r.Mask = int(rule.Mask)
+	}
+	if rule.Goto != 0 {
+		r.Goto = rule.Goto
+	} else {
+		r.Table = rule.Table
+	}
+	return newError("rule add", rule.String(), netlink.RuleAdd(r))
+}
+
+func (netlinkHost) RuleDel(priority int) error {
+	object := fmt.Sprintf("priority %d", priority)
+	rules, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		return newError("rule del", object, err)
+	}
+	// The kernel only deletes a rule matching all the given attributes, so delete the listed rule.
+	for i := range rules {
+		if rules[i].Priority == priority {
+			return newError("rule del", object, netlink.RuleDel(&rules[i]))
+		}
+	}
+	return newError("rule del", object, ErrNotFound)
+}
+
+func (netlinkHost) IPSetCreate(name string) error {
+	return newError("ipset create", name, ipsetError(netlink.IpsetCreate(name, "hash:ip", netlink.IpsetCreateOptions{Comments: true})))
+}
+
+func (netlinkHost) IPSetDestroy(name string) error {
+	return newError("ipset destroy", name, ipsetError(netlink.IpsetDestroy(name)))
+}
+
+func (netlinkHost) IPSetFlush(name string) error {
+	return newError("ipset flush", name, ipsetError(netlink.IpsetFlush(name)))
+}
+
+func (netlinkHost) IPSetAdd(name string, entry IPSetEntry) error {
+	err := netlink.IpsetAdd(name, &netlink.IPSetEntry{Comment: entry.Comment, IP: entry.IP.AsSlice()})
+	return newError("ipset add", name+" "+entry.IP.String(), ipsetError(err))
+}
+
+func (netlinkHost) IPSetDel(name string, ip netip.Addr) error {
+	err := netlink.IpsetDel(name, &netlink.IPSetEntry{IP: ip.AsSlice()})
+	return newError("ipset del", name+" "+ip.String(), ipsetError(err))
+}
+
+func (netlinkHost) IPSetList(name string) ([]IPSetEntry, error) {
+	res, err := netlink.IpsetList(name)
+	if err != nil {
+		return nil, newError("ipset list", name, ipsetError(err))
+	}
+	entries := make([]IPSetEntry, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		ip, ok := netip.AddrFromSlice(e.IP)
+		if !ok {
+			continue
+		}
+		entries = append(entries, IPSetEntry{IP: ip.Unmap(), Comment: e.Comment})
+	}
+	return entries, nil
+}
+
+// ipsetError maps the ipset protocol error of existing objects to ErrExist.
+func ipsetError(err error) error {
+	if ipsetErr, ok := err.(nl.IPSetError); ok && ipsetErr == nl.IPSET_ERR_EXIST {
+		return fmt.Errorf("%w: %v", ErrExist, err)
+	}
+	return err
+}
+
+func (netlinkHost) SetSysctl(key, value string) error {
+	return newError("sysctl", key+"="+value, os.WriteFile(filepath.Join(procSys, key), []byte(value), 0o644))
+}
@@ -0,0 +1,82 @@
+//go:build !linux
+// +build !linux
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostnet
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+type unsupported struct{}
+
+// New returns the netlink implementation of Interface, for the network namespace of the calling thread.
+func New() Interface {
+	return unsupported{}
+}
+
+func (unsupported) RouteAdd(route *Route) error {
+	return newError("route add", route.String(), ErrNotImplemented)
+}
+
+func (unsupported) RouteDel(route *Route) error {
+	return newError("route del", route.String(), ErrNotImplemented)
+}
+
+func (unsupported) RouteList(table int) ([]Route, error) {
+	return nil, newError("route list", fmt.Sprintf("table %d", table), ErrNotImplemented)
+}
+
+func (unsupported) RouteFlush(table int) error {
+	return newError("route flush", fmt.Sprintf("table %d", table), ErrNotImplemented)
+}
+
+func (unsupported) RuleAdd(rule *Rule) error {
+	return newError("rule add", rule.String(), ErrNotImplemented)
+}
+
+func (unsupported) RuleDel(priority int) error {
+	return newError("rule del", fmt.Sprintf("priority %d", priority), ErrNotImplemented)
+}
+
+func (unsupported) IPSetCreate(name string) error {
+	return newError("ipset create", name, ErrNotImplemented)
+}
+
+func (unsupported) IPSetDestroy(name string) error {
+	return newError("ipset destroy", name, ErrNotImplemented)
+}
+
+func (unsupported) IPSetFlush(name string) error {
+	return newError("ipset flush", name, ErrNotImplemented)
+}
+
+func (unsupported) IPSetAdd(name string, entry IPSetEntry) error {
+	return newError("ipset add", name+" "+entry.IP.String(), ErrNotImplemented)
+}
+
+func (unsupported) IPSetDel(name string, ip netip.Addr) error {
+	return newError("ipset del", name+" "+ip.String(), ErrNotImplemented)
+}
+
+func (unsupported) IPSetList(name string) ([]IPSetEntry, error) {
+	return nil, newError("ipset list", name, ErrNotImplemented)
+}
+
+func (unsupported) SetSysctl(key, value string) error {
+	return newError("sysctl", key+"="+value, ErrNotImplemented)
+}
@@ -15,6 +15,7 @@
 package install
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -47,9 +48,14 @@ func copyBinaries(srcDir string, targetDirs []string, updateBinaries bool, skipB
 			}
 
 			targetFilepath := filepath.Join(targetDir, filename)
-			if _, err := os.Stat(targetFilepath); err == nil && !updateBinaries {
-				installLog.Infof("%s is already here and UPDATE_CNI_BINARIES isn't true, skipping", targetFilepath)
-				continue
+			if info, err := os.Stat(targetFilepath); err == nil && !updateBinaries {
+				// checkBinaries fails on a binary which is no longer executable, so it is rewritten.
+				if executable(f) && info.Mode()&0o111 == 0 {
+					installLog.Infof("%s is not executable, rewriting it", targetFilepath)
+				} else {
+					installLog.Infof("%s is already here and UPDATE_CNI_BINARIES isn't true, skipping", targetFilepath)
+					continue
+				}
 			}
 
 			srcFilepath := filepath.Join(srcDir, filename)
@@ -63,3 +69,45 @@ func copyBinaries(srcDir string, targetDirs []string, updateBinaries bool, skipB
 
 	return nil
 }
+
+// checkBinaries returns an error if a binary copied by copyBinaries is missing from a target directory, or is no
+// longer executable.
+func checkBinaries(srcDir string, targetDirs []string, skipBinaries []string) error {
+	skipBinariesSet := sets.New(skipBinaries...)
+	var files []os.DirEntry
+	for _, targetDir := range targetDirs {
+		if err := file.IsDirWriteable(targetDir); err != nil {
+			continue
+		}
+
+		if files == nil {
+			var err error
+			if files, err = os.ReadDir(srcDir); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range files {
+			if f.IsDir() || skipBinariesSet.Contains(f.Name()) {
+				continue
+			}
+
+			targetFilepath := filepath.Join(targetDir, f.Name())
+			info, err := os.Stat(targetFilepath)
+			if err != nil {
+				return fmt.Errorf("CNI binary removed: %s", targetFilepath)
+			}
+			if executable(f) && info.Mode()&0o111 == 0 {
+				return fmt.Errorf("CNI binary is not executable: %s", targetFilepath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// executable returns true if the source binary is executable.
+func executable(f os.DirEntry) bool {
+	info, err := f.Info()
+	return err == nil && info.Mode()&0o111 != 0
+}
@@ -28,6 +28,7 @@ func TestCopyBinaries(t *testing.T) {
 		expectedFiles  map[string]string // {filename: contents. ...}
 		updateBinaries bool
 		skipBinaries   []string
+		nonExecutable  []string // existing files which are not executable
 	}{
 		{
 			name:          "basic",
@@ -48,6 +49,14 @@ func TestCopyBinaries(t *testing.T) {
 			existingFiles:  map[string]string{"istio-cni": "cni000", "istio-iptables": "iptables111"},
 			expectedFiles:  map[string]string{"istio-cni": "cni000", "istio-iptables": "iptables111"},
 		},
+		{
+			name:           "rewrite binaries which are not executable",
+			updateBinaries: false,
+			srcFiles:       map[string]string{"istio-cni": "cni111", "istio-iptables": "iptables111"},
+			existingFiles:  map[string]string{"istio-cni": "cni000", "istio-iptables": "iptables000"},
+			nonExecutable:  []string{"istio-cni"},
+			expectedFiles:  map[string]string{"istio-cni": "cni111", "istio-iptables": "iptables000"},
+		},
 		{
 			name:          "skip binaries",
 			skipBinaries:  []string{"istio-iptables"},
@@ -73,6 +82,11 @@ func TestCopyBinaries(t *testing.T) {
 					t.Fatal(err)
 				}
 			}
+			for _, filename := range c.nonExecutable {
+				if err := os.Chmod(filepath.Join(targetDir, filename), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
 
 			err := copyBinaries(srcDir, []string{targetDir}, c.updateBinaries, c.skipBinaries)
 			if err != nil {
@@ -88,6 +102,61 @@ func TestCopyBinaries(t *testing.T) {
 					t.Fatalf("target file contents don't match source file; actual: %s", string(contents))
 				}
 			}
+			if err := checkBinaries(srcDir, []string{targetDir}, c.skipBinaries); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCheckBinaries(t *testing.T) {
+	cases := []struct {
+		name            string
+		existingFiles   map[string]os.FileMode // {filename: mode, ...}
+		skipBinaries    []string
+		expectedFailure bool
+	}{
+		{
+			name:          "binaries installed",
+			existingFiles: map[string]os.FileMode{"istio-cni": 0o755, "istio-iptables": 0o755},
+		},
+		{
+			name:            "binary removed",
+			existingFiles:   map[string]os.FileMode{"istio-cni": 0o755},
+			expectedFailure: true,
+		},
+		{
+			name:          "skipped binary not installed",
+			existingFiles: map[string]os.FileMode{"istio-cni": 0o755},
+			skipBinaries:  []string{"istio-iptables"},
+		},
+		{
+			name:            "binary not executable",
+			existingFiles:   map[string]os.FileMode{"istio-cni": 0o644, "istio-iptables": 0o755},
+			expectedFailure: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srcDir := t.TempDir()
+			for _, filename := range []string{"istio-cni", "istio-iptables"} {
+				if err := os.WriteFile(filepath.Join(srcDir, filename), []byte(filename), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			targetDir := t.TempDir()
+			for filename, mode := range c.existingFiles {
+				if err := os.WriteFile(filepath.Join(targetDir, filename), []byte(filename), mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := checkBinaries(srcDir, []string{targetDir}, c.skipBinaries)
+			if (c.expectedFailure && err == nil) || (!c.expectedFailure && err != nil) {
+				t.Fatalf("expected failure: %t, got %v", c.expectedFailure, err)
+			}
 		})
 	}
 }
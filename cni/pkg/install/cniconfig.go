@@ -311,18 +311,19 @@ func insertCNIConfig(newCNIConfig, existingCNIConfig []byte) ([]byte, error) {
 			return nil, fmt.Errorf("existing CNI config: %v", err)
 		}
 
-		for i, rawPlugin := range plugins {
+		// Remove any existing istio-cni entry, so that the plugin is present once at the end of the chain.
+		newPlugins := make([]any, 0, len(plugins)+1)
+		for _, rawPlugin := range plugins {
 			plugin, err := util.GetPlugin(rawPlugin)
 			if err != nil {
 				return nil, fmt.Errorf("existing CNI plugin: %v", err)
 			}
-			if plugin["type"] == "istio-cni" {
-				plugins = append(plugins[:i], plugins[i+1:]...)
-				break
+			if plugin["type"] != "istio-cni" {
+				newPlugins = append(newPlugins, rawPlugin)
 			}
 		}
 
-		newMap["plugins"] = append(plugins, istioMap)
+		newMap["plugins"] = append(newPlugins, istioMap)
 	}
 
 	return util.MarshalCNIConfig(newMap)
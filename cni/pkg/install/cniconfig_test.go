@@ -337,6 +337,16 @@ func TestInsertCNIConfig(t *testing.T) {
 			existingConfFilename: "list-with-istio.conflist",
 			newConfFilename:      "istio-cni.conf",
 		},
+		{
+			name:                 "list network file with existing istio not last",
+			existingConfFilename: "list-with-istio-not-last.conflist",
+			newConfFilename:      "istio-cni.conf",
+		},
+		{
+			name:                 "list network file with duplicated istio",
+			existingConfFilename: "list-with-istio-duplicated.conflist",
+			newConfFilename:      "istio-cni.conf",
+		},
 	}
 
 	for _, c := range cases {
@@ -25,13 +25,23 @@ import (
 	"istio.io/istio/cni/pkg/config"
 	"istio.io/istio/cni/pkg/constants"
 	"istio.io/istio/cni/pkg/util"
+	"istio.io/istio/pkg/backoff"
 	"istio.io/istio/pkg/file"
+	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/sleep"
 	"istio.io/pkg/log"
 )
 
 var installLog = log.RegisterScope("install", "CNI install")
 
+// checkInterval is the interval of the periodic checks of the installation, in addition to the file changes
+// notifications that do not cover all changes, such as the binaries permissions.
+var checkInterval = 30 * time.Second
+
+// reinstallInitialInterval is the initial interval of the backoff of the reinstallations while the configuration
+// stays invalid.
+var reinstallInitialInterval = time.Second
+
 type Installer struct {
 	cfg                *config.InstallConfig
 	isReady            *atomic.Value
@@ -39,6 +49,16 @@ type Installer struct {
 	kubeconfigFilepath string
 	cniConfigFilepath  string
 	saTokenFilepath    string
+
+	// kubeClient records events on the node and lists its pods for the strict readiness.
+	kubeClient kube.Client
+	// invalidSince is when the CNI configuration was found invalid, zero while it is valid.
+	invalidSince time.Time
+	// uncapturedSince and uncapturedUntil bound the periods during which the CNI configuration was invalid, until
+	// no pod scheduled during them is left running.
+	uncapturedSince     time.Time
+	uncapturedUntil     time.Time
+	notCapturedReported bool
 }
 
 // NewInstaller returns an instance of Installer with the given config
@@ -79,6 +99,16 @@ func (in *Installer) install(ctx context.Context) (err error) {
 // Run starts the installation process, verifies the configuration, then sleeps.
 // If an invalid configuration is detected, the installation process will restart to restore a valid state.
 func (in *Installer) Run(ctx context.Context) (err error) {
+	if in.kubeClient == nil {
+		if in.kubeClient, err = clientSetup(); err != nil {
+			if in.cfg.CNIStrictReadiness {
+				return fmt.Errorf("strict readiness requires a Kubernetes client: %v", err)
+			}
+			installLog.Warnf("Failed to create Kubernetes client, events will not be recorded: %v", err)
+			err = nil
+		}
+	}
+
 	if in.cfg.CNIEnableInstall {
 		if err = in.install(ctx); err != nil {
 			return
@@ -88,10 +118,19 @@ func (in *Installer) Run(ctx context.Context) (err error) {
 		installLog.Info("Skip installing CNI configuration and binaries.")
 	}
 
+	// Back off the reinstallations while the configuration stays invalid, as it may not be fixed by reinstalling.
+	retry := backoff.NewExponentialBackOff(backoff.Option{InitialInterval: reinstallInitialInterval, MaxInterval: checkInterval})
 	for {
 		if err = in.sleepCheckInstall(ctx); err != nil {
 			return
 		}
+		if in.invalidSince.IsZero() {
+			retry.Reset()
+		} else {
+			if !sleep.UntilContext(ctx, retry.NextBackOff()) {
+				return ctx.Err()
+			}
+		}
 
 		installLog.Info("Detect changes to the CNI configuration and binaries, attempt reinstalling...")
 		if in.cfg.CNIEnableInstall && in.cfg.CNIEnableReinstall {
@@ -197,12 +236,15 @@ func (in *Installer) sleepCheckInstall(ctx context.Context) error {
 	// Watch for service account token changes in background
 	in.watchSAToken(ctx, fileModified, errChan)
 
+	ready := false
 	for {
 		if checkErr := checkInstall(in.cfg, in.cniConfigFilepath); checkErr != nil {
 			// Pod set to "NotReady" due to invalid configuration
 			installLog.Infof("Invalid configuration. %v", checkErr)
+			in.configInvalid(ctx, checkErr)
 			return nil
 		}
+		in.configValid(ctx)
 		// Check if file has been modified or if an error has occurred during checkInstall before setting isReady to true
 		select {
 		case <-fileModified:
@@ -212,11 +254,22 @@ func (in *Installer) sleepCheckInstall(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
+		}
+		if !ready && in.podsCaptured(ctx) {
 			// Valid configuration; set isReady to true and wait for modifications before checking again
 			SetReady(in.isReady)
 			cniInstalls.With(resultLabel.Value(resultSuccess)).Increment()
-			// Pod set to "NotReady" before termination
-			return util.WaitForFileMod(ctx, fileModified, errChan)
+			ready = true
+		}
+		// Pod set to "NotReady" before termination
+		select {
+		case <-fileModified:
+			return nil
+		case err := <-errChan:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(checkInterval):
 		}
 	}
 }
@@ -256,17 +309,28 @@ func checkInstall(cfg *config.InstallConfig, cniConfigFilepath string) error {
 		if err != nil {
 			return fmt.Errorf("%s: %w", cniConfigFilepath, err)
 		}
-		for _, rawPlugin := range plugins {
+		position := -1
+		for i, rawPlugin := range plugins {
 			plugin, err := util.GetPlugin(rawPlugin)
 			if err != nil {
 				return fmt.Errorf("%s: %w", cniConfigFilepath, err)
 			}
-			if plugin["type"] == "istio-cni" {
-				return nil
+			if plugin["type"] != "istio-cni" {
+				continue
+			}
+			if position >= 0 {
+				return fmt.Errorf("istio-cni CNI config duplicated in CNI config file: %s", cniConfigFilepath)
 			}
+			position = i
 		}
-
-		return fmt.Errorf("istio-cni CNI config removed from CNI config file: %s", cniConfigFilepath)
+		if position < 0 {
+			return fmt.Errorf("istio-cni CNI config removed from CNI config file: %s", cniConfigFilepath)
+		}
+		// The plugin must run last, once the pod network is set up by the primary CNI plugins.
+		if position != len(plugins)-1 {
+			return fmt.Errorf("istio-cni CNI config is not the last plugin of CNI config file: %s", cniConfigFilepath)
+		}
+		return checkBinaries(cfg.CNIBinSourceDir, cfg.CNIBinTargetDirs, cfg.SkipCNIBinaries)
 	}
 	// Verify that Istio CNI config exists as a standalone plugin
 	cniConfigMap, err := util.ReadCNIConfigMap(cniConfigFilepath)
@@ -277,7 +341,7 @@ func checkInstall(cfg *config.InstallConfig, cniConfigFilepath string) error {
 	if cniConfigMap["type"] != "istio-cni" {
 		return fmt.Errorf("istio-cni CNI config file modified: %s", cniConfigFilepath)
 	}
-	return nil
+	return checkBinaries(cfg.CNIBinSourceDir, cfg.CNIBinTargetDirs, cfg.SkipCNIBinaries)
 }
 
 // watchSAToken periodically reads SA token file and compares its content with the token stored in the Installer.
@@ -37,6 +37,7 @@ func TestCheckInstall(t *testing.T) {
 		chainedCNIPlugin  bool
 		skipInstall       bool
 		existingConfFiles map[string]string // {srcFilename: targetFilename, ...}
+		existingBinaries  map[string]os.FileMode
 	}{
 		{
 			name:              "preempted config",
@@ -84,6 +85,42 @@ func TestCheckInstall(t *testing.T) {
 			chainedCNIPlugin:  true,
 			existingConfFiles: map[string]string{"list.conflist.golden": "list.conflist"},
 		},
+		{
+			name:              "istio-cni config not last in CNI config file",
+			expectedFailure:   true,
+			cniConfigFilename: "list.conflist",
+			chainedCNIPlugin:  true,
+			existingConfFiles: map[string]string{"list-with-istio-not-last.conflist": "list.conflist"},
+		},
+		{
+			name:              "istio-cni config duplicated in CNI config file",
+			expectedFailure:   true,
+			cniConfigFilename: "list.conflist",
+			chainedCNIPlugin:  true,
+			existingConfFiles: map[string]string{"list-with-istio-duplicated.conflist": "list.conflist"},
+		},
+		{
+			name:              "CNI binary removed",
+			expectedFailure:   true,
+			cniConfigFilename: "list.conflist",
+			chainedCNIPlugin:  true,
+			existingConfFiles: map[string]string{"list.conflist.golden": "list.conflist"},
+			existingBinaries:  map[string]os.FileMode{},
+		},
+		{
+			name:              "CNI binary not executable",
+			expectedFailure:   true,
+			cniConfigFilename: "istio-cni.conf",
+			existingConfFiles: map[string]string{"istio-cni.conf": "istio-cni.conf"},
+			existingBinaries:  map[string]os.FileMode{"istio-cni": 0o644},
+		},
+		{
+			name:              "CNI binary installed",
+			cniConfigFilename: "list.conflist",
+			chainedCNIPlugin:  true,
+			existingConfFiles: map[string]string{"list.conflist.golden": "list.conflist"},
+			existingBinaries:  map[string]os.FileMode{"istio-cni": 0o755},
+		},
 		{
 			name:              "standalone CNI plugin istio-cni config not in CNI config file",
 			expectedFailure:   true,
@@ -115,6 +152,20 @@ func TestCheckInstall(t *testing.T) {
 				ChainedCNIPlugin: c.chainedCNIPlugin,
 				CNIEnableInstall: !c.skipInstall,
 			}
+
+			// Create existing binaries if specified in test case
+			if c.existingBinaries != nil {
+				cfg.CNIBinSourceDir = t.TempDir()
+				if err := os.WriteFile(filepath.Join(cfg.CNIBinSourceDir, "istio-cni"), []byte{1, 2, 3}, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				cfg.CNIBinTargetDirs = []string{t.TempDir()}
+				for filename, mode := range c.existingBinaries {
+					if err := os.WriteFile(filepath.Join(cfg.CNIBinTargetDirs[0], filename), []byte{1, 2, 3}, mode); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
 			err := checkInstall(cfg, filepath.Join(tempDir, c.cniConfigFilename))
 			if (c.expectedFailure && err == nil) || (!c.expectedFailure && err != nil) {
 				t.Fatalf("expected failure: %t, got %v", c.expectedFailure, err)
@@ -0,0 +1,183 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/pkg/kube"
+	iptables "istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+const (
+	eventComponent        = "istio-cni-install"
+	reasonConfigInvalid   = "IstioCNIConfigInvalid"
+	reasonConfigRestored  = "IstioCNIConfigRestored"
+	reasonPodsNotCaptured = "IstioCNIPodsNotCaptured"
+	reasonPodsCaptured    = "IstioCNIPodsCaptured"
+)
+
+// configInvalid records that the CNI configuration was found invalid, pods scheduled from now on are not captured.
+func (in *Installer) configInvalid(ctx context.Context, checkErr error) {
+	if !in.invalidSince.IsZero() {
+		return
+	}
+	in.invalidSince = time.Now()
+	in.recordEvent(ctx, corev1.EventTypeWarning, reasonConfigInvalid,
+		fmt.Sprintf("Istio CNI configuration is invalid, pods scheduled on the node may not be captured: %v", checkErr))
+}
+
+// configValid records that the CNI configuration is valid. If it was found invalid before, the pods scheduled in
+// between are checked by the strict readiness.
+func (in *Installer) configValid(ctx context.Context) {
+	if in.invalidSince.IsZero() {
+		return
+	}
+	if in.uncapturedSince.IsZero() {
+		in.uncapturedSince = in.invalidSince
+	}
+	in.uncapturedUntil = time.Now()
+	in.invalidSince = time.Time{}
+	in.recordEvent(ctx, corev1.EventTypeNormal, reasonConfigRestored, "Istio CNI configuration restored")
+}
+
+// podsCaptured returns false if the strict readiness is enabled and pods scheduled while the CNI configuration was
+// invalid are still running on the node, and their traffic capture could not be validated.
+func (in *Installer) podsCaptured(ctx context.Context) bool {
+	if !in.cfg.CNIStrictReadiness || in.uncapturedSince.IsZero() {
+		return true
+	}
+	if in.kubeClient == nil {
+		installLog.Warnf("Cannot check the pods scheduled while the CNI configuration was invalid without a Kubernetes client")
+		return false
+	}
+	pods, err := uncapturedPods(ctx, in.kubeClient, in.cfg.K8sNodeName, in.uncapturedSince, in.uncapturedUntil)
+	if err != nil {
+		installLog.Warnf("Failed to list the pods scheduled while the CNI configuration was invalid: %v", err)
+		return false
+	}
+	if len(pods) > 0 {
+		installLog.Warnf("Not ready, pods scheduled while the CNI configuration was invalid are running: %s", strings.Join(pods, ", "))
+		if !in.notCapturedReported {
+			in.notCapturedReported = true
+			in.recordEvent(ctx, corev1.EventTypeWarning, reasonPodsNotCaptured,
+				fmt.Sprintf("Pods scheduled while the Istio CNI configuration was invalid are running: %s", strings.Join(pods, ", ")))
+		}
+		return false
+	}
+	if in.notCapturedReported {
+		in.recordEvent(ctx, corev1.EventTypeNormal, reasonPodsCaptured,
+			"No pod scheduled while the Istio CNI configuration was invalid is running anymore")
+	}
+	in.uncapturedSince, in.uncapturedUntil, in.notCapturedReported = time.Time{}, time.Time{}, false
+	return true
+}
+
+// uncapturedPods returns the namespaced names of the running sidecar pods of the node started between since and
+// until, whose traffic capture was not validated by the validation init container.
+func uncapturedPods(ctx context.Context, client kube.Client, nodeName string, since, until time.Time) ([]string, error) {
+	pods, err := client.Kube().CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Timestamps of the API server are truncated to the second.
+	since = since.Truncate(time.Second)
+	var names []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, ok := pod.Annotations[annotation.SidecarStatus.Name]; !ok {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		started := pod.CreationTimestamp.Time
+		if pod.Status.StartTime != nil {
+			started = pod.Status.StartTime.Time
+		}
+		if started.Before(since) || started.After(until) {
+			continue
+		}
+		if captureValidated(pod) {
+			continue
+		}
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names, nil
+}
+
+// captureValidated returns true if the validation init container of the pod checked its traffic capture.
+func captureValidated(pod *corev1.Pod) bool {
+	for _, container := range pod.Status.InitContainerStatuses {
+		if container.Name != iptables.ValidationContainerName {
+			continue
+		}
+		if state := container.State.Terminated; state != nil && state.ExitCode == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEvent reports a change of the CNI configuration on the node. Failing to record it is not an error.
+func (in *Installer) recordEvent(ctx context.Context, eventType, reason, message string) {
+	if in.kubeClient == nil {
+		return
+	}
+	nodeName := in.cfg.K8sNodeName
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named as by the event recorder of client-go.
+			Name:      fmt.Sprintf("%v.%x", nodeName, now.UnixNano()),
+			Namespace: metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+			// Node events use the node name as UID, as the kubelet does.
+			UID: types.UID(nodeName),
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: eventComponent, Host: nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := in.kubeClient.Kube().CoreV1().Events(metav1.NamespaceDefault).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		installLog.Warnf("Failed to record event for node %s: %v", nodeName, err)
+	}
+}
+
+// Set up Kubernetes client using kubeconfig (or in-cluster config if no file provided)
+func clientSetup() (kube.Client, error) {
+	config, err := kube.DefaultRestConfig("", "")
+	if err != nil {
+		return nil, err
+	}
+	return kube.NewClient(kube.NewClientConfigForRestConfig(config), "")
+}
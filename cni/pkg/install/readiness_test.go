@@ -0,0 +1,184 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/cni/pkg/config"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func makePod(name string, started time.Time, sidecar bool, phase corev1.PodPhase, validationExitCode *int32) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(started),
+		},
+		Spec: corev1.PodSpec{NodeName: "node"},
+		Status: corev1.PodStatus{
+			Phase:     phase,
+			StartTime: &metav1.Time{Time: started},
+		},
+	}
+	if sidecar {
+		pod.Annotations = map[string]string{annotation.SidecarStatus.Name: "{}"}
+	}
+	if validationExitCode != nil {
+		pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "istio-validation",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: *validationExitCode}},
+		}}
+	}
+	return pod
+}
+
+func TestUncapturedPods(t *testing.T) {
+	since := time.Date(2023, 5, 1, 10, 0, 0, 500*int(time.Millisecond), time.UTC)
+	until := since.Add(time.Minute)
+	failed, succeeded := int32(126), int32(0)
+	cases := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected []string
+	}{
+		{
+			name:     "sidecar pod started while invalid",
+			pod:      makePod("pod", since.Add(time.Second), true, corev1.PodRunning, nil),
+			expected: []string{"default/pod"},
+		},
+		{
+			name:     "sidecar pod started in the same second",
+			pod:      makePod("pod", since.Truncate(time.Second), true, corev1.PodPending, &failed),
+			expected: []string{"default/pod"},
+		},
+		{
+			name: "sidecar pod started before",
+			pod:  makePod("pod", since.Add(-time.Second), true, corev1.PodRunning, nil),
+		},
+		{
+			name: "sidecar pod started after",
+			pod:  makePod("pod", until.Add(time.Second), true, corev1.PodRunning, nil),
+		},
+		{
+			name: "pod without sidecar",
+			pod:  makePod("pod", since.Add(time.Second), false, corev1.PodRunning, nil),
+		},
+		{
+			name: "completed pod",
+			pod:  makePod("pod", since.Add(time.Second), true, corev1.PodSucceeded, nil),
+		},
+		{
+			name: "validated pod",
+			pod:  makePod("pod", since.Add(time.Second), true, corev1.PodRunning, &succeeded),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := kube.NewFakeClient(c.pod)
+			pods, err := uncapturedPods(context.Background(), client, "node", since, until)
+			assert.NoError(t, err)
+			assert.Equal(t, pods, c.expected)
+		})
+	}
+}
+
+func TestStrictReadiness(t *testing.T) {
+	failed, succeeded := int32(126), int32(0)
+	cases := []struct {
+		name            string
+		strictReadiness bool
+		pods            []*corev1.Pod
+		expectedReady   bool
+		expectedReasons []string
+	}{
+		{
+			name:            "no pod scheduled",
+			strictReadiness: true,
+			expectedReady:   true,
+			expectedReasons: []string{reasonConfigInvalid, reasonConfigRestored},
+		},
+		{
+			name:            "pod scheduled",
+			strictReadiness: true,
+			pods:            []*corev1.Pod{makePod("pod", time.Time{}, true, corev1.PodRunning, &failed)},
+			expectedReady:   false,
+			expectedReasons: []string{reasonConfigInvalid, reasonConfigRestored, reasonPodsNotCaptured},
+		},
+		{
+			name:            "pod scheduled and validated",
+			strictReadiness: true,
+			pods:            []*corev1.Pod{makePod("pod", time.Time{}, true, corev1.PodRunning, &succeeded)},
+			expectedReady:   true,
+			expectedReasons: []string{reasonConfigInvalid, reasonConfigRestored},
+		},
+		{
+			name:            "pod scheduled without strict readiness",
+			pods:            []*corev1.Pod{makePod("pod", time.Time{}, true, corev1.PodRunning, &failed)},
+			expectedReady:   true,
+			expectedReasons: []string{reasonConfigInvalid, reasonConfigRestored},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := kube.NewFakeClient()
+			isReady := &atomic.Value{}
+			in := NewInstaller(&config.InstallConfig{K8sNodeName: "node", CNIStrictReadiness: c.strictReadiness}, isReady)
+			in.kubeClient = client
+
+			in.configInvalid(ctx, errors.New("istio-cni CNI config removed"))
+			// Reported once while the configuration stays invalid
+			in.configInvalid(ctx, errors.New("istio-cni CNI config removed"))
+			for _, pod := range c.pods {
+				pod.Status.StartTime = &metav1.Time{Time: time.Now()}
+				_, err := client.Kube().CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+				assert.NoError(t, err)
+			}
+			in.configValid(ctx)
+			assert.Equal(t, in.podsCaptured(ctx), c.expectedReady)
+			// Reported once while the pods are running
+			assert.Equal(t, in.podsCaptured(ctx), c.expectedReady)
+
+			events, err := client.Kube().CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+			assert.NoError(t, err)
+			var reasons []string
+			for _, e := range events.Items {
+				assert.Equal(t, e.InvolvedObject.Kind, "Node")
+				assert.Equal(t, e.InvolvedObject.Name, "node")
+				reasons = append(reasons, e.Reason)
+			}
+			assert.Equal(t, reasons, c.expectedReasons)
+
+			if !c.expectedReady {
+				// Ready once the pods are gone
+				assert.NoError(t, client.Kube().CoreV1().Pods("default").Delete(ctx, "pod", metav1.DeleteOptions{}))
+				assert.Equal(t, in.podsCaptured(ctx), true)
+			}
+		})
+	}
+}
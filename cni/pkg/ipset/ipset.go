@@ -0,0 +1,68 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipset
+
+import (
+	"net/netip"
+
+	"istio.io/istio/cni/pkg/hostnet"
+)
+
+type IPSet struct {
+	// the name of the ipset to use
+	Name string
+	// Net manages the ipset in the host network namespace
+	Net hostnet.Interface
+}
+
+func (m *IPSet) CreateSet() error {
+	return hostnet.IgnoreExist(m.Net.IPSetCreate(m.Name))
+}
+
+func (m *IPSet) DestroySet() error {
+	return m.Net.IPSetDestroy(m.Name)
+}
+
+func (m *IPSet) AddIP(ip netip.Addr, comment string) error {
+	return m.Net.IPSetAdd(m.Name, hostnet.IPSetEntry{IP: ip, Comment: comment})
+}
+
+func (m *IPSet) Flush() error {
+	return m.Net.IPSetFlush(m.Name)
+}
+
+func (m *IPSet) List() ([]hostnet.IPSetEntry, error) {
+	return m.Net.IPSetList(m.Name)
+}
+
+func (m *IPSet) DeleteIP(ip netip.Addr) error {
+	return m.Net.IPSetDel(m.Name, ip)
+}
+
+// This is only supported in kernel module from revision 2 or 4, so may not be present
+func (m *IPSet) ClearEntriesWithComment(comment string) error {
+	entries, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Comment == comment {
+			if err := m.DeleteIP(entry.IP); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
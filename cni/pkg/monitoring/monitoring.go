@@ -27,6 +27,14 @@ import (
 	"istio.io/pkg/log"
 )
 
+// debugMux serves the debug endpoints registered by the CNI agent components, next to the metrics.
+var debugMux = http.NewServeMux()
+
+// RegisterDebugHandler registers a debug endpoint served on the monitoring port. The path must start with /debug/.
+func RegisterDebugHandler(path string, handler http.Handler) {
+	debugMux.Handle(path, handler)
+}
+
 func SetupMonitoring(port int, path string, stop <-chan struct{}) {
 	if port <= 0 {
 		return
@@ -45,6 +53,7 @@ func SetupMonitoring(port int, path string, stop <-chan struct{}) {
 	}
 	view.RegisterExporter(exporter)
 	mux.Handle(path, exporter)
+	mux.Handle("/debug/", debugMux)
 	monitoringServer := &http.Server{
 		Handler: mux,
 	}
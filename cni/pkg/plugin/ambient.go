@@ -79,10 +79,12 @@ func checkAmbient(conf Config, ambientConfig ambient.AmbientConfigFile, podName,
 			}
 
 			// Can't set this on GKE, but needed in AWS.. so silently ignore failures
-			_ = ambient.SetProc("/proc/sys/net/ipv4/conf/"+podIfname+"/rp_filter", "0")
+			_ = ambient.SetSysctl("net/ipv4/conf/"+podIfname+"/rp_filter", "0")
 
 			for _, ip := range podIPs {
-				ambient.AddPodToMesh(client, pod, ip.IP.String())
+				if err := ambient.AddPodToMesh(client, pod, ip.IP.String()); err != nil {
+					log.Errorf("failed to add pod to mesh: %v", err)
+				}
 			}
 			return true, nil
 		}
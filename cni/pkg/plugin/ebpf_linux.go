@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+
+	"istio.io/istio/cni/pkg/ebpf/sidecar"
+	"istio.io/istio/tools/istio-iptables/pkg/cmd"
+	"istio.io/pkg/log"
+)
+
+// Program redirects the traffic of the pod to the proxy with the eBPF programs configured from Redirect.
+func (e *ebpfRedirect) Program(podName, netns string, rdrct *Redirect) error {
+	_, err := e.program(podName, netns, rdrct)
+	return err
+}
+
+// Repair programs the redirection again, returning true if the config of the pod or the inbound program was missing.
+func (e *ebpfRedirect) Repair(podName, netns string, rdrct *Redirect) (bool, error) {
+	return e.program(podName, netns, rdrct)
+}
+
+func (e *ebpfRedirect) program(podName, netns string, rdrct *Redirect) (bool, error) {
+	setRedirectConfig(netns, rdrct)
+
+	netNs, err := getNs(netns)
+	if err != nil {
+		return false, fmt.Errorf("failed to open netns %q: %s", netns, err)
+	}
+	defer netNs.Close()
+
+	var cfg *sidecar.Config
+	if err := netNs.Do(func(_ ns.NetNS) error {
+		captureCfg, err := cmd.ConstructConfig()
+		if err != nil {
+			return err
+		}
+		podIPs, err := podIPv4s()
+		if err != nil {
+			return err
+		}
+		cfg, err = sidecar.NewConfig(captureCfg, podIPs)
+		return err
+	}); err != nil {
+		return false, err
+	}
+
+	log.Infof("============= Start eBPF redirection for %v =============", podName)
+	defer log.Infof("============= End eBPF redirection for %v =============", podName)
+	return sidecar.Program(netns, cfg)
+}
+
+// podIPv4s returns the IPv4 addresses of the current network namespace, but the loopback ones.
+func podIPv4s() ([]netip.Addr, error) {
+	addrs, err := netlink.AddrList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pod addresses: %v", err)
+	}
+	var ips []netip.Addr
+	for _, a := range addrs {
+		ip, ok := netip.AddrFromSlice(a.IP.To4())
+		if ok && !ip.IsLoopback() {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// removeEbpfRedirect deletes the eBPF redirection config of the pod.
+func removeEbpfRedirect(netns string) error {
+	return sidecar.Remove(netns)
+}
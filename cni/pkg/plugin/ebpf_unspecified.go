@@ -15,40 +15,19 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package ipset
+package plugin
 
-import (
-	"errors"
-	"net"
-)
-
-var ErrNotImplemented = errors.New("not implemented")
-
-type IPSet struct {
-	// the name of the ipset to use
-	Name string
-}
-
-func (m *IPSet) CreateSet() error {
+// Program redirects the traffic of the pod to the proxy with the eBPF programs configured from Redirect.
+func (e *ebpfRedirect) Program(podName, netns string, rdrct *Redirect) error {
 	return ErrNotImplemented
 }
 
-func (m *IPSet) DestroySet() error {
-	return ErrNotImplemented
-}
-
-func (m *IPSet) AddIP(ip net.IP, comment string) error {
-	return ErrNotImplemented
+// Repair programs the redirection again, returning true if the config of the pod or the inbound program was missing.
+func (e *ebpfRedirect) Repair(podName, netns string, rdrct *Redirect) (bool, error) {
+	return false, ErrNotImplemented
 }
 
-func (m *IPSet) Flush() error {
-	return ErrNotImplemented
-}
-
-func (m *IPSet) DeleteIP(ip net.IP) error {
-	return ErrNotImplemented
-}
-
-func (m *IPSet) ClearEntriesWithComment(comment string) error {
-	return ErrNotImplemented
+// removeEbpfRedirect deletes the eBPF redirection config of the pod.
+func removeEbpfRedirect(netns string) error {
+	return nil
 }
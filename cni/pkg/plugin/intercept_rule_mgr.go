@@ -22,12 +22,20 @@ const (
 // redirecting traffic to an Istio proxy.
 type InterceptRuleMgr interface {
 	Program(podName, netns string, redirect *Redirect) error
+	// Repair re-applies the redirection in place, returning true if any rule had drifted.
+	Repair(podName, netns string, redirect *Redirect) (bool, error)
 }
 
 type InterceptRuleMgrCtor func() InterceptRuleMgr
 
 var InterceptRuleMgrTypes = map[string]InterceptRuleMgrCtor{
 	"iptables": IptablesInterceptRuleMgrCtor,
+	"ebpf":     EbpfInterceptRuleMgrCtor,
+}
+
+// DNSCaptureInterceptTypes are the types of InterceptRuleMgr's supporting the DNS capture.
+var DNSCaptureInterceptTypes = map[string]bool{
+	"iptables": true,
 }
 
 // Constructor factory for known types of InterceptRuleMgr's
@@ -39,3 +47,8 @@ func GetInterceptRuleMgrCtor(interceptType string) InterceptRuleMgrCtor {
 func IptablesInterceptRuleMgrCtor() InterceptRuleMgr {
 	return newIPTables()
 }
+
+// Constructor for eBPF InterceptRuleMgr
+func EbpfInterceptRuleMgrCtor() InterceptRuleMgr {
+	return newEbpfRedirect()
+}
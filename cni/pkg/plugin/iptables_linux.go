@@ -18,6 +18,7 @@ package plugin
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/spf13/viper"
@@ -34,25 +35,7 @@ var getNs = ns.GetNS
 // Program defines a method which programs iptables based on the parameters
 // provided in Redirect.
 func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
-	viper.Set(constants.CNIMode, true)
-	viper.Set(constants.HostNSEnterExec, rdrct.hostNSEnterExec)
-	viper.Set(constants.NetworkNamespace, netns)
-	viper.Set(constants.EnvoyPort, rdrct.targetPort)
-	viper.Set(constants.ProxyUID, rdrct.noRedirectUID)
-	viper.Set(constants.ProxyGID, rdrct.noRedirectGID)
-	viper.Set(constants.InboundInterceptionMode, rdrct.redirectMode)
-	viper.Set(constants.ServiceCidr, rdrct.includeIPCidrs)
-	viper.Set(constants.LocalExcludePorts, rdrct.excludeInboundPorts)
-	viper.Set(constants.InboundPorts, rdrct.includeInboundPorts)
-	viper.Set(constants.ExcludeInterfaces, rdrct.excludeInterfaces)
-	viper.Set(constants.LocalOutboundPortsExclude, rdrct.excludeOutboundPorts)
-	viper.Set(constants.OutboundPorts, rdrct.includeOutboundPorts)
-	viper.Set(constants.ServiceExcludeCidr, rdrct.excludeIPCidrs)
-	viper.Set(constants.KubeVirtInterfaces, rdrct.kubevirtInterfaces)
-	viper.Set(constants.DryRun, dependencies.DryRunFilePath.Get() != "")
-	viper.Set(constants.RedirectDNS, rdrct.dnsRedirect)
-	viper.Set(constants.CaptureAllDNS, rdrct.dnsRedirect)
-	viper.Set(constants.DropInvalid, rdrct.invalidDrop)
+	setRedirectConfig(netns, rdrct)
 
 	netNs, err := getNs(netns)
 	if err != nil {
@@ -75,3 +58,51 @@ func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
 
 	return nil
 }
+
+// Repair re-applies the iptables rules described by Redirect in place, only fixing the chains
+// that drifted, and verifies them afterwards. It returns true if any chain had to be repaired.
+func (ipt *iptables) Repair(podName, netns string, rdrct *Redirect) (bool, error) {
+	setRedirectConfig(netns, rdrct)
+
+	netNs, err := getNs(netns)
+	if err != nil {
+		return false, fmt.Errorf("failed to open netns %q: %s", netns, err)
+	}
+	defer netNs.Close()
+
+	repaired := false
+	err = netNs.Do(func(_ ns.NetNS) error {
+		log.Infof("============= Start iptables repair for %v =============", podName)
+		defer log.Infof("============= End iptables repair for %v =============", podName)
+		drift, err := cmd.ReconcileCapture()
+		for _, d := range drift {
+			log.Infof("repaired drift for %v: %v", podName, d)
+		}
+		repaired = len(drift) > 0
+		return err
+	})
+	return repaired, err
+}
+
+func setRedirectConfig(netns string, rdrct *Redirect) {
+	viper.Set(constants.CNIMode, true)
+	viper.Set(constants.HostNSEnterExec, rdrct.hostNSEnterExec)
+	viper.Set(constants.NetworkNamespace, netns)
+	viper.Set(constants.EnvoyPort, rdrct.targetPort)
+	viper.Set(constants.ProxyUID, rdrct.noRedirectUID)
+	viper.Set(constants.ProxyGID, rdrct.noRedirectGID)
+	viper.Set(constants.InboundInterceptionMode, rdrct.redirectMode)
+	viper.Set(constants.ServiceCidr, rdrct.includeIPCidrs)
+	viper.Set(constants.LocalExcludePorts, rdrct.excludeInboundPorts)
+	viper.Set(constants.InboundPorts, rdrct.includeInboundPorts)
+	viper.Set(constants.ExcludeInterfaces, rdrct.excludeInterfaces)
+	viper.Set(constants.LocalOutboundPortsExclude, rdrct.excludeOutboundPorts)
+	viper.Set(constants.OutboundPorts, rdrct.includeOutboundPorts)
+	viper.Set(constants.ServiceExcludeCidr, rdrct.excludeIPCidrs)
+	viper.Set(constants.KubeVirtInterfaces, rdrct.kubevirtInterfaces)
+	viper.Set(constants.DryRun, dependencies.DryRunFilePath.Get() != "")
+	viper.Set(constants.RedirectDNS, rdrct.dnsRedirect)
+	viper.Set(constants.CaptureAllDNS, rdrct.dnsRedirect && len(rdrct.dnsServers) == 0)
+	viper.Set(constants.DNSServers, strings.Join(rdrct.dnsServers, ","))
+	viper.Set(constants.DropInvalid, rdrct.invalidDrop)
+}
@@ -29,3 +29,9 @@ var ErrNotImplemented = errors.New("not implemented")
 func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
 	return ErrNotImplemented
 }
+
+// Repair re-applies the iptables rules described by Redirect in place, only fixing the chains
+// that drifted, and verifies them afterwards. It returns true if any chain had to be repaired.
+func (ipt *iptables) Repair(podName, netns string, rdrct *Redirect) (bool, error) {
+	return false, ErrNotImplemented
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -66,38 +67,52 @@ func getK8sPodInfo(client *kubernetes.Clientset, podName, podNamespace string) (
 		return nil, err
 	}
 
+	return ExtractPodInfo(pod), nil
+}
+
+// ExtractPodInfo returns the information of a POD relevant to traffic redirection.
+func ExtractPodInfo(pod *corev1.Pod) *PodInfo {
 	pi := &PodInfo{
 		InitContainers:    make(map[string]struct{}),
-		Containers:        make([]string, len(pod.Spec.Containers)),
+		Containers:        make([]string, 0, len(pod.Spec.Containers)+1),
 		Labels:            pod.Labels,
 		Annotations:       pod.Annotations,
 		ProxyEnvironments: make(map[string]string),
 	}
 	for _, initContainer := range pod.Spec.InitContainers {
 		pi.InitContainers[initContainer.Name] = struct{}{}
+		if initContainer.Name == "istio-proxy" {
+			// The proxy is injected as a native sidecar, which runs alongside the
+			// application containers, so it is treated as one of them.
+			pi.Containers = append(pi.Containers, initContainer.Name)
+			pi.extractProxyInfo(initContainer)
+		}
 	}
-	for containerIdx, container := range pod.Spec.Containers {
-		log.Debugf("Inspecting pod %v/%v container %v", podNamespace, podName, container.Name)
-		pi.Containers[containerIdx] = container.Name
+	for _, container := range pod.Spec.Containers {
+		log.Debugf("Inspecting pod %v/%v container %v", pod.Namespace, pod.Name, container.Name)
+		pi.Containers = append(pi.Containers, container.Name)
 
 		if container.Name == "istio-proxy" {
 			// don't include ports from istio-proxy in the redirect ports
-			// Get proxy container env variable, and extract out ProxyConfig from it.
-			for _, e := range container.Env {
-				pi.ProxyEnvironments[e.Name] = e.Value
-			}
+			pi.extractProxyInfo(container)
+		}
+	}
+	log.Debugf("Pod %v/%v info: \n%+v", pod.Namespace, pod.Name, pi)
 
-			if container.SecurityContext != nil {
-				pi.ProxyUID = container.SecurityContext.RunAsUser
-				pi.ProxyGID = container.SecurityContext.RunAsGroup
-			}
+	return pi
+}
 
-			continue
-		}
+// extractProxyInfo records the environment and the identity of the istio-proxy container.
+func (pi *PodInfo) extractProxyInfo(container corev1.Container) {
+	// Get proxy container env variable, and extract out ProxyConfig from it.
+	for _, e := range container.Env {
+		pi.ProxyEnvironments[e.Name] = e.Value
 	}
-	log.Debugf("Pod %v/%v info: \n%+v", podNamespace, podName, pi)
 
-	return pi, nil
+	if container.SecurityContext != nil {
+		pi.ProxyUID = container.SecurityContext.RunAsUser
+		pi.ProxyGID = container.SecurityContext.RunAsGroup
+	}
 }
 
 func (pi PodInfo) String() string {
@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestExtractPodInfo(t *testing.T) {
+	proxy := corev1.Container{
+		Name: "istio-proxy",
+		Env:  []corev1.EnvVar{{Name: "ISTIO_META_DNS_CAPTURE", Value: "true"}},
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser:  ptr.Of(int64(1337)),
+			RunAsGroup: ptr.Of(int64(1337)),
+		},
+	}
+	cases := []struct {
+		name           string
+		spec           corev1.PodSpec
+		containers     []string
+		initContainers []string
+	}{
+		{
+			name: "sidecar container",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "istio-init"}},
+				Containers:     []corev1.Container{{Name: "app"}, proxy},
+			},
+			containers:     []string{"app", "istio-proxy"},
+			initContainers: []string{"istio-init"},
+		},
+		{
+			name: "native sidecar",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "istio-init"}, proxy},
+				Containers:     []corev1.Container{{Name: "app"}},
+			},
+			containers:     []string{"istio-proxy", "app"},
+			initContainers: []string{"istio-init", "istio-proxy"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			pi := ExtractPodInfo(&corev1.Pod{Spec: tt.spec})
+			assert.Equal(t, pi.Containers, tt.containers)
+			for _, ic := range tt.initContainers {
+				if _, f := pi.InitContainers[ic]; !f {
+					t.Errorf("missing init container %v", ic)
+				}
+			}
+			assert.Equal(t, pi.ProxyEnvironments, map[string]string{"ISTIO_META_DNS_CAPTURE": "true"})
+			assert.Equal(t, *pi.ProxyUID, int64(1337))
+			assert.Equal(t, *pi.ProxyGID, int64(1337))
+		})
+	}
+}
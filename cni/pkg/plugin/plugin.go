@@ -57,6 +57,14 @@ type Kubernetes struct {
 	CNIBinDir            string   `json:"cni_bin_dir"`
 }
 
+// DNSCapture holds the config of the DNS capture done by the plugin for the pods whose proxy does not set
+// ISTIO_META_DNS_CAPTURE.
+type DNSCapture struct {
+	Enabled bool `json:"enabled"`
+	// Servers are the DNS server IPs whose traffic is captured. All the DNS traffic is captured if empty.
+	Servers []string `json:"servers"`
+}
+
 // Config is whatever you expect your configuration json to be. This is whatever
 // is passed in on stdin. Your plugin may wish to expose its functionality via
 // runtime args, see CONVENTIONS.md in the CNI spec.
@@ -81,6 +89,7 @@ type Config struct {
 	AmbientEnabled  bool       `json:"ambient_enabled"`
 	Kubernetes      Kubernetes `json:"kubernetes"`
 	HostNSEnterExec bool       `json:"hostNSEnterExec"`
+	DNSCapture      DNSCapture `json:"dns_capture"`
 }
 
 // K8sArgs is the valid CNI_ARGS used for Kubernetes
@@ -303,6 +312,9 @@ func CmdAdd(args *skel.CmdArgs) (err error) {
 								interceptRuleMgrType)
 						} else {
 							redirect.hostNSEnterExec = conf.HostNSEnterExec
+							if DNSCaptureInterceptTypes[interceptRuleMgrType] {
+								redirect.SetDNSCapture(conf.DNSCapture, pi)
+							}
 							rulesMgr := interceptMgrCtor()
 							if err := rulesMgr.Program(podName, args.Netns, redirect); err != nil {
 								return err
@@ -341,6 +353,21 @@ func CmdCheck(args *skel.CmdArgs) (err error) {
 }
 
 func CmdDelete(args *skel.CmdArgs) (err error) {
+	if args.Netns == "" {
+		return nil
+	}
+	conf, err := parseConfig(args.StdinData)
+	if err != nil {
+		log.Errorf("istio-cni cmdDelete failed to parse config %v %v", string(args.StdinData), err)
+		return nil
+	}
+	if conf.Kubernetes.InterceptRuleMgrType == "ebpf" {
+		// Never fail the deletion, the config of a pod whose namespace is gone is never matched again, and is
+		// evicted from the config map.
+		if err := removeEbpfRedirect(args.Netns); err != nil {
+			log.Warnf("istio-cni cmdDelete failed to remove the eBPF redirection of %v: %v", args.ContainerID, err)
+		}
+	}
 	return nil
 }
 
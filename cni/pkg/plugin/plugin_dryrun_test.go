@@ -90,9 +90,10 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 	zero := int64(0)
 
 	tests := []struct {
-		name   string
-		input  *PodInfo
-		golden string
+		name       string
+		input      *PodInfo
+		dnsCapture string
+		golden     string
 	}{
 		{
 			name: "basic",
@@ -156,6 +157,17 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 			},
 			golden: filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/dns.txt.golden"),
 		},
+		{
+			name: "dns-servers",
+			input: &PodInfo{
+				Containers:        []string{"test", "istio-proxy"},
+				InitContainers:    map[string]struct{}{"istio-validate": {}},
+				Annotations:       map[string]string{annotation.SidecarStatus.Name: "true"},
+				ProxyEnvironments: map[string]string{},
+			},
+			dnsCapture: `{"enabled": true, "servers": ["10.96.0.10"]}`,
+			golden:     filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/dns-servers.txt.golden"),
+		},
 		{
 			name: "invalid-drop",
 			input: &PodInfo{
@@ -214,11 +226,16 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 				t.Fatalf("Failed to create temp file for IPTables rule output: %v", err)
 			}
 			t.Setenv(dependencies.DryRunFilePath.Name, outputFilePath)
+			stdinData, args := cniConf, args
+			if tt.dnsCapture != "" {
+				stdinData = strings.Replace(cniConf, `"log_level": "debug",`, `"log_level": "debug", "dns_capture": `+tt.dnsCapture+",", 1)
+				args = testSetArgs(stdinData)
+			}
 			_, _, err := testutils.CmdAddWithArgs(
 				&skel.CmdArgs{
 					Netns:     sandboxDirectory,
 					IfName:    ifname,
-					StdinData: []byte(cniConf),
+					StdinData: []byte(stdinData),
 				}, func() error { return CmdAdd(args) })
 			if err != nil {
 				t.Fatalf("CNI cmdAdd failed with error: %v", err)
@@ -112,6 +112,11 @@ func (mrdir *mockInterceptRuleMgr) Program(podName, netns string, redirect *Redi
 	return nil
 }
 
+func (mrdir *mockInterceptRuleMgr) Repair(podName, netns string, redirect *Redirect) (bool, error) {
+	mrdir.lastRedirect = append(mrdir.lastRedirect, redirect)
+	return false, nil
+}
+
 func NewMockInterceptRuleMgr() InterceptRuleMgr {
 	return singletonMockInterceptRuleMgr
 }
@@ -442,6 +447,73 @@ func TestCmdAddWithExcludeInterfaces(t *testing.T) {
 	}
 }
 
+func TestCmdAddWithDNSCapture(t *testing.T) {
+	cases := []struct {
+		name            string
+		interceptType   string
+		dnsCapture      string
+		proxyEnv        map[string]string
+		expectRedirect  bool
+		expectedServers []string
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:           "all DNS traffic",
+			dnsCapture:     `{"enabled": true}`,
+			expectRedirect: true,
+		},
+		{
+			name:            "DNS servers",
+			dnsCapture:      `{"enabled": true, "servers": ["10.96.0.10", "fd00::10"]}`,
+			expectRedirect:  true,
+			expectedServers: []string{"10.96.0.10", "fd00::10"},
+		},
+		{
+			name:       "disabled by the proxy",
+			dnsCapture: `{"enabled": true}`,
+			proxyEnv:   map[string]string{"ISTIO_META_DNS_CAPTURE": "false"},
+		},
+		{
+			name:          "not supported by the intercept type",
+			interceptType: "mock-nodns",
+			dnsCapture:    `{"enabled": true}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer resetGlobalTestVariables()
+			testContainers = []string{"mockContainer", "mockContainer2"}
+			if c.proxyEnv != nil {
+				testProxyEnv = c.proxyEnv
+			}
+
+			interceptType := "mock"
+			if c.interceptType != "" {
+				interceptType = c.interceptType
+			}
+			cniConf := fmt.Sprintf(conf, currentVersion, currentVersion, ifname, sandboxDirectory, interceptType)
+			if c.dnsCapture != "" {
+				cniConf = strings.Replace(cniConf, `"log_level": "debug",`, `"log_level": "debug", "dns_capture": `+c.dnsCapture+",", 1)
+			}
+			testCmdAddWithStdinData(t, cniConf)
+
+			mockIntercept, ok := GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
+			if !ok {
+				t.Fatalf("expect using mockInterceptRuleMgr, actual %v", InterceptRuleMgrTypes["mock"]())
+			}
+			r := mockIntercept.lastRedirect[len(mockIntercept.lastRedirect)-1]
+			if r.dnsRedirect != c.expectRedirect {
+				t.Fatalf("expect dnsRedirect is %v, actual %v", c.expectRedirect, r.dnsRedirect)
+			}
+			if !reflect.DeepEqual(r.dnsServers, c.expectedServers) {
+				t.Fatalf("expect dnsServers are %v, actual %v", c.expectedServers, r.dnsServers)
+			}
+		})
+	}
+}
+
 func TestCmdAddInvalidK8sArgsKeyword(t *testing.T) {
 	defer resetGlobalTestVariables()
 
@@ -496,6 +568,9 @@ func TestMain(m *testing.M) {
 	// call flag.Parse() here if TestMain uses flags
 
 	InterceptRuleMgrTypes["mock"] = MockInterceptRuleMgrCtor
+	DNSCaptureInterceptTypes["mock"] = true
+	// mock-nodns is an intercept type which does not support the DNS capture, like ebpf.
+	InterceptRuleMgrTypes["mock-nodns"] = MockInterceptRuleMgrCtor
 
 	os.Exit(m.Run())
 }
@@ -88,6 +88,7 @@ type Redirect struct {
 	kubevirtInterfaces   string
 	excludeInterfaces    string
 	dnsRedirect          bool
+	dnsServers           []string
 	invalidDrop          bool
 	hostNSEnterExec      bool
 }
@@ -294,3 +295,13 @@ func NewRedirect(pi *PodInfo) (*Redirect, error) {
 	}
 	return redir, nil
 }
+
+// SetDNSCapture enables the capture of the DNS traffic to the given servers, or of all the DNS traffic if no server
+// is given, unless the proxy of the pod sets ISTIO_META_DNS_CAPTURE.
+func (rdrct *Redirect) SetDNSCapture(dns DNSCapture, pi *PodInfo) {
+	if _, found := pi.ProxyEnvironments["ISTIO_META_DNS_CAPTURE"]; found || !dns.Enabled {
+		return
+	}
+	rdrct.dnsRedirect = true
+	rdrct.dnsServers = dns.Servers
+}
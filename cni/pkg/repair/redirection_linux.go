@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"path/filepath"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/cni/pkg/ambient"
+	"istio.io/istio/cni/pkg/config"
+	"istio.io/istio/cni/pkg/plugin"
+	pconstants "istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube"
+)
+
+// netnsDir is where the container runtime bind mounts the pod network namespaces.
+var netnsDir = "/var/run/netns"
+
+// repairRedirection re-applies the traffic redirection of the pod in place and verifies it.
+// It returns true if the redirection had drifted and was repaired.
+func repairRedirection(client kube.Client, pod *corev1.Pod, cfg config.RepairConfig) (bool, error) {
+	if pod.Status.PodIP == "" {
+		return false, fmt.Errorf("pod %s/%s has no IP allocated", pod.Namespace, pod.Name)
+	}
+	if pod.Annotations[pconstants.AmbientRedirection] == pconstants.AmbientRedirectionEnabled {
+		return repairAmbientRedirection(client, pod)
+	}
+
+	netns, err := findNetns(pod.Status.PodIP)
+	if err != nil {
+		return false, err
+	}
+	pi := plugin.ExtractPodInfo(pod)
+	redirect, err := plugin.NewRedirect(pi)
+	if err != nil {
+		return false, fmt.Errorf("failed to build redirect for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+	if plugin.DNSCaptureInterceptTypes[cfg.InterceptType] {
+		redirect.SetDNSCapture(plugin.DNSCapture{Enabled: cfg.DNSCapture, Servers: cfg.DNSServers}, pi)
+	}
+	interceptMgrCtor := plugin.GetInterceptRuleMgrCtor(cfg.InterceptType)
+	if interceptMgrCtor == nil {
+		return false, fmt.Errorf("unexpected intercept type %q", cfg.InterceptType)
+	}
+	return interceptMgrCtor().Repair(pod.Name, netns, redirect)
+}
+
+// repairAmbientRedirection adds the pod back to the ambient ipset and routes on the node.
+func repairAmbientRedirection(client kube.Client, pod *corev1.Pod) (bool, error) {
+	ambientConfig, err := ambient.ReadAmbientConfig()
+	if err != nil {
+		return false, err
+	}
+	if ambientConfig.RedirectMode == ambient.EbpfMode.String() {
+		return false, fmt.Errorf("in place repair is not supported with the %s redirect mode", ambientConfig.RedirectMode)
+	}
+	if ambient.IsPodInIpset(pod) {
+		return false, nil
+	}
+	if err := ambient.AddPodToMesh(client.Kube(), pod, ""); err != nil {
+		return false, err
+	}
+	if !ambient.IsPodInIpset(pod) {
+		return false, fmt.Errorf("pod %s/%s is still missing from the ambient ipset", pod.Namespace, pod.Name)
+	}
+	return true, nil
+}
+
+// findNetns returns the path of the network namespace holding the given pod IP.
+func findNetns(podIP string) (string, error) {
+	ip := net.ParseIP(podIP)
+	if ip == nil {
+		return "", fmt.Errorf("invalid pod IP %q", podIP)
+	}
+	found := errors.New("netns found, stop iterating")
+	netnsPath := ""
+	err := filepath.WalkDir(netnsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		netNs, err := ns.GetNS(p)
+		if err != nil {
+			repairLog.Debugf("failed to open netns %s: %v", p, err)
+			return nil
+		}
+		defer netNs.Close()
+		var addrs []netlink.Addr
+		if err := netNs.Do(func(ns.NetNS) error {
+			addrs, err = netlink.AddrList(nil, netlink.FAMILY_ALL)
+			return err
+		}); err != nil {
+			repairLog.Debugf("failed to list addresses in netns %s: %v", p, err)
+			return nil
+		}
+		for _, addr := range addrs {
+			if addr.IP.Equal(ip) {
+				netnsPath = p
+				return found
+			}
+		}
+		return nil
+	})
+	if err == found {
+		return netnsPath, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find netns for %s: %v", podIP, err)
+	}
+	return "", fmt.Errorf("no netns in %s holds %s", netnsDir, podIP)
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/cni/pkg/config"
+	"istio.io/istio/pkg/kube"
+)
+
+// repairRedirection re-applies the traffic redirection of the pod in place and verifies it.
+// It returns true if the redirection had drifted and was repaired.
+func repairRedirection(client kube.Client, pod *corev1.Pod, cfg config.RepairConfig) (bool, error) {
+	return false, errors.New("not implemented")
+}
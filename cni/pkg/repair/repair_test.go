@@ -15,6 +15,8 @@
 package repair
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -376,6 +378,98 @@ func TestDeletePods(t *testing.T) {
 	}
 }
 
+func TestRepairPods(t *testing.T) {
+	repairedAll := func(kube.Client, *corev1.Pod) (bool, error) { return true, nil }
+	inSync := func(kube.Client, *corev1.Pod) (bool, error) { return false, nil }
+	failed := func(kube.Client, *corev1.Pod) (bool, error) { return false, errors.New("no netns") }
+	tests := []struct {
+		name       string
+		client     kube.Client
+		config     config.RepairConfig
+		repair     func(kube.Client, *corev1.Pod) (bool, error)
+		wantEvents []string
+		wantLabels map[string]string
+		wantCount  float64
+		wantTags   []tag.Tag
+	}{
+		{
+			name:       "No broken pods",
+			client:     fakeClient(workingPod, workingPodDiedPreviously),
+			repair:     repairedAll,
+			wantLabels: map[string]string{workingPod.Name: "", workingPodDiedPreviously.Name: ""},
+			wantCount:  0,
+		},
+		{
+			name:       "With broken pods",
+			client:     fakeClient(workingPod, workingPodDiedPreviously, brokenPodWaiting),
+			repair:     repairedAll,
+			wantEvents: []string{brokenPodWaiting.Name + "/" + reasonRepaired},
+			wantLabels: map[string]string{workingPod.Name: "", workingPodDiedPreviously.Name: "", brokenPodWaiting.Name: ""},
+			wantCount:  1,
+			wantTags:   []tag.Tag{{Key: tag.Key(resultLabel), Value: resultSuccess}, {Key: tag.Key(typeLabel), Value: repairType}},
+		},
+		{
+			name:       "With redirection already in place",
+			client:     fakeClient(workingPod, workingPodDiedPreviously, brokenPodWaiting),
+			repair:     inSync,
+			wantLabels: map[string]string{workingPod.Name: "", workingPodDiedPreviously.Name: "", brokenPodWaiting.Name: ""},
+			wantCount:  1,
+			wantTags:   []tag.Tag{{Key: tag.Key(resultLabel), Value: resultSkip}, {Key: tag.Key(typeLabel), Value: repairType}},
+		},
+		{
+			name:   "With failed repair falling back to label",
+			client: fakeClient(workingPod, workingPodDiedPreviously, brokenPodWaiting),
+			config: config.RepairConfig{
+				LabelPods:  true,
+				LabelKey:   "testkey",
+				LabelValue: "testval",
+			},
+			repair:     failed,
+			wantEvents: []string{brokenPodWaiting.Name + "/" + reasonRepairFailed},
+			wantLabels: map[string]string{workingPod.Name: "", workingPodDiedPreviously.Name: "", brokenPodWaiting.Name: "testkey=testval"},
+			wantCount:  1,
+			wantTags:   []tag.Tag{{Key: tag.Key(resultLabel), Value: resultFail}, {Key: tag.Key(typeLabel), Value: repairType}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.RepairPods = true
+			tt.config.InitContainerName = constants.ValidationContainerName
+			tt.config.InitExitCode = 126
+			tt.config.InitTerminationMsg = "Died for some reason"
+			exp := initStats(tt.name)
+			c, err := NewRepairController(tt.client, tt.config)
+			assert.NoError(t, err)
+			c.repairRedirection = tt.repair
+			t.Cleanup(func() {
+				assert.NoError(t, c.queue.WaitForClose(time.Second))
+			})
+			stop := test.NewStop(t)
+			tt.client.RunAndWait(stop)
+			go c.Run(stop)
+			kube.WaitForCacheSync("test", stop, c.queue.HasSynced)
+
+			assert.EventuallyEqual(t, func() map[string]string {
+				havePods := c.pods.List(metav1.NamespaceAll, klabels.Everything())
+				slices.SortFunc(havePods, func(a, b *corev1.Pod) bool {
+					return a.Name < b.Name
+				})
+				return makePodLabelMap(havePods)
+			}, tt.wantLabels)
+			checkStats(t, tt.wantCount, tt.wantTags, exp)
+			assert.EventuallyEqual(t, func() []string {
+				events, err := tt.client.Kube().CoreV1().Events(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+				assert.NoError(t, err)
+				var have []string
+				for _, e := range events.Items {
+					have = append(have, e.InvolvedObject.Name+"/"+e.Reason)
+				}
+				return have
+			}, tt.wantEvents)
+		})
+	}
+}
+
 type testExporter struct {
 	sync.Mutex
 
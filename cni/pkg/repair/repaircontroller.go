@@ -29,11 +29,20 @@ import (
 	"istio.io/istio/pkg/kube/kclient"
 )
 
+const (
+	eventComponent     = "istio-cni-repair"
+	reasonRepaired     = "TrafficRedirectionRepaired"
+	reasonRepairFailed = "TrafficRedirectionRepairFailed"
+)
+
 type Controller struct {
 	client kube.Client
 	pods   kclient.Client[*corev1.Pod]
 	queue  controllers.Queue
 	cfg    config.RepairConfig
+
+	// repairRedirection re-applies the traffic redirection of a pod in place, overridden in tests.
+	repairRedirection func(client kube.Client, pod *corev1.Pod) (bool, error)
 }
 
 func NewRepairController(client kube.Client, cfg config.RepairConfig) (*Controller, error) {
@@ -41,6 +50,9 @@ func NewRepairController(client kube.Client, cfg config.RepairConfig) (*Controll
 		cfg:    cfg,
 		client: client,
 	}
+	c.repairRedirection = func(client kube.Client, pod *corev1.Pod) (bool, error) {
+		return repairRedirection(client, pod, cfg)
+	}
 	fieldSelectors := []string{}
 	if cfg.FieldSelectors != "" {
 		fieldSelectors = append(fieldSelectors, cfg.FieldSelectors)
@@ -79,6 +91,15 @@ func (c *Controller) Reconcile(key types.NamespacedName) error {
 func (c *Controller) ReconcilePod(pod *corev1.Pod) (err error) {
 	repairLog.Debugf("Reconciling pod %s", pod.Name)
 
+	// A pod labeled by a previous fallback already failed to be repaired in place, do not retry on its update.
+	_, labeled := pod.Labels[c.cfg.LabelKey]
+	if c.cfg.RepairPods && !(c.cfg.LabelPods && labeled) {
+		err := c.repairBrokenPod(pod)
+		if err == nil || !(c.cfg.DeletePods || c.cfg.LabelPods) {
+			return err
+		}
+		repairLog.Warnf("Failed to repair pod %s/%s in place, falling back: %v", pod.Namespace, pod.Name, err)
+	}
 	if c.cfg.DeletePods {
 		return c.deleteBrokenPod(pod)
 	} else if c.cfg.LabelPods {
@@ -87,6 +108,61 @@ func (c *Controller) ReconcilePod(pod *corev1.Pod) (err error) {
 	return nil
 }
 
+func (c *Controller) repairBrokenPod(pod *corev1.Pod) error {
+	m := podsRepaired.With(typeLabel.Value(repairType))
+	// Added for safety, to make sure we only touch the network of broken pods.
+	if !c.matchesFilter(pod) {
+		m.With(resultLabel.Value(resultSkip)).Increment()
+		return nil
+	}
+	repairLog.Infof("Pod detected as broken, repairing traffic redirection: %s/%s", pod.Namespace, pod.Name)
+
+	repaired, err := c.repairRedirection(c.client, pod)
+	if err != nil {
+		m.With(resultLabel.Value(resultFail)).Increment()
+		c.recordEvent(pod, corev1.EventTypeWarning, reasonRepairFailed,
+			fmt.Sprintf("Failed to repair the traffic redirection in place: %v", err))
+		return err
+	}
+	if !repaired {
+		m.With(resultLabel.Value(resultSkip)).Increment()
+		repairLog.Infof("Pod %s/%s traffic redirection is already in place, skipping", pod.Namespace, pod.Name)
+		return nil
+	}
+	m.With(resultLabel.Value(resultSuccess)).Increment()
+	c.recordEvent(pod, corev1.EventTypeNormal, reasonRepaired, "Repaired the traffic redirection in place")
+	return nil
+}
+
+// recordEvent reports the outcome of a repair on the pod. Failing to record it does not fail the repair.
+func (c *Controller) recordEvent(pod *corev1.Pod, eventType, reason, message string) {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pod.Name + ".",
+			Namespace:    pod.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "Pod",
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: eventComponent, Host: c.cfg.NodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := c.client.Kube().CoreV1().Events(pod.Namespace).Create(context.Background(), event, metav1.CreateOptions{}); err != nil {
+		repairLog.Warnf("Failed to record event for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+}
+
 func (c *Controller) deleteBrokenPod(pod *corev1.Pod) error {
 	m := podsRepaired.With(typeLabel.Value(deleteType))
 	// Added for safety, to make sure no healthy pods get labeled. Could occur if pod changes since we enqueued
@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasmcache
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	corev1 "k8s.io/api/core/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	extensions "istio.io/client-go/pkg/apis/extensions/v1alpha1"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/controllers"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/wasm"
+	"istio.io/pkg/log"
+)
+
+var prewarmLog = log.RegisterScope("wasmcache", "CNI node level Wasm module cache")
+
+// StartPrewarm starts pre-fetching, in the node level cache, the modules of the WasmPlugins selecting the pods of the node.
+func StartPrewarm(ctx context.Context, cache *wasm.NodeCacheServer, nodeName string) error {
+	config, err := kube.DefaultRestConfig("", "")
+	if err != nil {
+		return err
+	}
+	client, err := kube.NewClient(kube.NewClientConfigForRestConfig(config), "")
+	if err != nil {
+		return err
+	}
+	p := NewPrewarmer(client, cache, nodeName)
+	go p.Run(ctx.Done())
+	client.RunAndWait(ctx.Done())
+	return nil
+}
+
+// Prewarmer fetches the OCI images of the WasmPlugins selecting the pods of the node in the node level cache, before
+// the agents of the pods load them. The WasmPlugins with an image pull secret are skipped, as the node level cache
+// does not read secrets, nor are the modules pulled with a secret shared between pods. The WasmPlugins pinning the
+// checksum of their module are skipped too, as the agents do not fetch them from the node level cache.
+type Prewarmer struct {
+	pods    kclient.Client[*corev1.Pod]
+	plugins kclient.Client[*extensions.WasmPlugin]
+	queue   controllers.Queue
+
+	// prewarm fetches the module in the node level cache, overridden in tests.
+	prewarm func(downloadURL string, opts wasm.GetOptions) error
+}
+
+func NewPrewarmer(client kube.Client, cache *wasm.NodeCacheServer, nodeName string) *Prewarmer {
+	p := &Prewarmer{
+		prewarm: cache.Prewarm,
+	}
+	p.pods = kclient.NewFiltered[*corev1.Pod](client, kclient.Filter{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%v", nodeName),
+	})
+	p.plugins = kclient.New[*extensions.WasmPlugin](client)
+	p.queue = controllers.NewQueue("wasm prewarm",
+		controllers.WithReconciler(p.Reconcile),
+		controllers.WithMaxAttempts(5))
+	p.plugins.AddEventHandler(controllers.ObjectHandler(p.queue.AddObject))
+	// A pod scheduled on the node may be the first one selected by the WasmPlugins of its namespace.
+	p.pods.AddEventHandler(controllers.EventHandler[*corev1.Pod]{
+		AddFunc: func(pod *corev1.Pod) {
+			for _, plugin := range p.plugins.List(pod.Namespace, klabels.Everything()) {
+				p.queue.AddObject(plugin)
+			}
+		},
+	})
+	return p
+}
+
+func (p *Prewarmer) Run(stop <-chan struct{}) {
+	kube.WaitForCacheSync("wasm prewarm", stop, p.pods.HasSynced, p.plugins.HasSynced)
+	p.queue.Run(stop)
+	controllers.ShutdownAll(p.pods, p.plugins)
+}
+
+func (p *Prewarmer) Reconcile(key types.NamespacedName) error {
+	plugin := p.plugins.Get(key.Name, key.Namespace)
+	if plugin == nil {
+		// WasmPlugin deleted, the module is purged from the cache once expired.
+		return nil
+	}
+	// The agents fetch the modules pinned with a checksum from their registry.
+	if plugin.Spec.ImagePullSecret != "" || plugin.Spec.Sha256 != "" || !p.selectsNodePod(plugin) {
+		return nil
+	}
+	u, err := url.Parse(plugin.Spec.Url)
+	if err != nil {
+		prewarmLog.Debugf("skipping WasmPlugin %s with invalid URL: %v", key, err)
+		return nil
+	}
+	// Like istiod, default to oci:// when no scheme is given.
+	if u.Scheme == "" {
+		u.Scheme = "oci"
+	}
+	if u.Scheme != "oci" {
+		return nil
+	}
+	prewarmLog.Debugf("pre-fetching the Wasm module %s of WasmPlugin %s", u, key)
+	// The options match the ones of the agents, so they find the pre-fetched module.
+	return p.prewarm(u.String(), wasm.GetOptions{
+		Checksum:        plugin.Spec.Sha256,
+		ResourceName:    key.Namespace + "." + key.Name,
+		ResourceVersion: plugin.ResourceVersion,
+		PullPolicy:      plugin.Spec.ImagePullPolicy,
+	})
+}
+
+// selectsNodePod returns true if the WasmPlugin selects one of the pods of the node.
+func (p *Prewarmer) selectsNodePod(plugin *extensions.WasmPlugin) bool {
+	selector := plugin.Spec.GetSelector().GetMatchLabels()
+	for _, pod := range p.pods.List(plugin.Namespace, klabels.Everything()) {
+		if labels.Instance(selector).SubsetOf(pod.Labels) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasmcache
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	extensionsv1alpha1 "istio.io/api/extensions/v1alpha1"
+	"istio.io/api/type/v1beta1"
+	extensions "istio.io/client-go/pkg/apis/extensions/v1alpha1"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient/clienttest"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/wasm"
+)
+
+func TestPrewarmerReconcile(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{"app": "foo"}},
+		Spec:       corev1.PodSpec{NodeName: "node"},
+	}
+	client := kube.NewFakeClient(pod)
+	p := NewPrewarmer(client, wasm.NewNodeCacheServer(t.TempDir(), wasm.Options{}), "node")
+	type fetch struct {
+		URL  string
+		Opts wasm.GetOptions
+	}
+	var got []fetch
+	p.prewarm = func(downloadURL string, opts wasm.GetOptions) error {
+		got = append(got, fetch{URL: downloadURL, Opts: opts})
+		return nil
+	}
+
+	plugins := clienttest.NewWriter[*extensions.WasmPlugin](t, client)
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "default", ResourceVersion: "1"}
+	}
+	for _, plugin := range []*extensions.WasmPlugin{
+		{
+			ObjectMeta: meta("oci"),
+			Spec: extensionsv1alpha1.WasmPlugin{
+				Url:             "oci://registry/plugin:v1",
+				ImagePullPolicy: extensionsv1alpha1.PullPolicy_Always,
+			},
+		},
+		{ObjectMeta: meta("pinned"), Spec: extensionsv1alpha1.WasmPlugin{Url: "oci://registry/plugin:v1", Sha256: "abc"}},
+		{ObjectMeta: meta("no-scheme"), Spec: extensionsv1alpha1.WasmPlugin{Url: "registry/plugin:v1"}},
+		{
+			ObjectMeta: meta("selected"),
+			Spec: extensionsv1alpha1.WasmPlugin{
+				Url:      "oci://registry/selected:v1",
+				Selector: &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "foo"}},
+			},
+		},
+		{
+			ObjectMeta: meta("not-selected"),
+			Spec: extensionsv1alpha1.WasmPlugin{
+				Url:      "oci://registry/plugin:v1",
+				Selector: &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "bar"}},
+			},
+		},
+		{ObjectMeta: meta("http"), Spec: extensionsv1alpha1.WasmPlugin{Url: "https://example.com/plugin.wasm"}},
+		{ObjectMeta: meta("secret"), Spec: extensionsv1alpha1.WasmPlugin{Url: "oci://registry/plugin:v1", ImagePullSecret: "secret"}},
+	} {
+		plugins.Create(plugin)
+	}
+	client.RunAndWait(test.NewStop(t))
+
+	cases := []struct {
+		name string
+		want []fetch
+	}{
+		{
+			name: "oci",
+			want: []fetch{{
+				URL: "oci://registry/plugin:v1",
+				Opts: wasm.GetOptions{
+					ResourceName:    "default.oci",
+					ResourceVersion: "1",
+					PullPolicy:      extensionsv1alpha1.PullPolicy_Always,
+				},
+			}},
+		},
+		{
+			name: "no-scheme",
+			want: []fetch{{URL: "oci://registry/plugin:v1", Opts: wasm.GetOptions{ResourceName: "default.no-scheme", ResourceVersion: "1"}}},
+		},
+		{
+			name: "selected",
+			want: []fetch{{URL: "oci://registry/selected:v1", Opts: wasm.GetOptions{ResourceName: "default.selected", ResourceVersion: "1"}}},
+		},
+		{name: "pinned"},
+		{name: "not-selected"},
+		{name: "http"},
+		{name: "secret"},
+		{name: "deleted"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got = nil
+			assert.NoError(t, p.Reconcile(types.NamespacedName{Name: c.name, Namespace: "default"}))
+			assert.Equal(t, got, c.want)
+		})
+	}
+}
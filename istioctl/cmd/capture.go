@@ -0,0 +1,321 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/annotation"
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+const (
+	captureProxyContainerName = "istio-proxy"
+	captureDefaultInterface   = "eth0"
+)
+
+// capturePortNames names the ports traffic is redirected to.
+var capturePortNames = map[string]string{
+	"15001": "outbound proxy listener",
+	"15006": "inbound proxy listener",
+	"15053": "agent DNS proxy",
+}
+
+func captureCmd() *cobra.Command {
+	captureCmd := &cobra.Command{
+		Use:   "capture",
+		Short: "Inspect the traffic capture of sidecar pods",
+	}
+	captureCmd.AddCommand(captureExplainCmd())
+	return captureCmd
+}
+
+func captureExplainCmd() *cobra.Command {
+	var dst, protocol, uid, gid string
+	var inbound bool
+	cmd := &cobra.Command{
+		Use:   "explain [<type>/]<name>[.<namespace>]",
+		Short: "Explain whether a connection of a pod is captured by its sidecar",
+		Long: `Rebuilds the iptables rules of a sidecar pod from its injected traffic.sidecar.istio.io annotations, its proxy
+container and the mesh config, then walks the first packet of a connection through them to show whether it is
+redirected to the proxy, not captured, or dropped, and which rule decided it.
+
+The rules are rebuilt as istio-iptables would program them, they are not read from the pod. DNS servers are not
+known, so DNS capture is explained as if all DNS traffic was captured.`,
+		Example: `  # Explain whether a connection of the application to 10.0.0.5:8080 goes through the sidecar
+  istioctl x capture explain productpage-v1-7f44c4d57c-h9p6t --dst 10.0.0.5:8080
+
+  # Explain why a connection opened by the proxy itself is not captured
+  istioctl x capture explain productpage-v1-7f44c4d57c-h9p6t --dst 10.0.0.5:8080 --uid 1337
+
+  # Explain whether an inbound connection to port 9080 of the pod reaches the sidecar
+  istioctl x capture explain deployment/productpage-v1 --inbound --dst 10.244.0.12:9080`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("capture explain requires a pod, [<type>/]<name>[.<namespace>]")
+			}
+			if dst == "" {
+				return fmt.Errorf("--dst is required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return err
+			}
+			podName, podNs, err := handlers.InferPodInfoFromTypedResource(args[0],
+				handlers.HandleNamespace(namespace, defaultNamespace),
+				MakeKubeFactory(client))
+			if err != nil {
+				return err
+			}
+			pod, err := client.Kube().CoreV1().Pods(podNs).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			revClient, err := kubeClientWithRevision(kubeconfig, configContext, getRevisionFromPodAnnotation(pod.Annotations))
+			if err != nil {
+				return err
+			}
+			meshCfg, err := getMeshConfig(revClient)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: using the default mesh config: %v\n", err)
+				meshCfg = mesh.DefaultMeshConfig()
+			}
+			cfg, err := podCaptureConfig(pod, meshCfg)
+			if err != nil {
+				return err
+			}
+			packet, err := capturePacket(pod, dst, protocol, uid, gid, inbound)
+			if err != nil {
+				return err
+			}
+			exp, err := capture.Explain(cfg, packet)
+			if err != nil {
+				return err
+			}
+			printCaptureExplanation(cmd.OutOrStdout(), exp)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVar(&dst, "dst", "", "Destination <ip>:<port> of the connection")
+	cmd.PersistentFlags().StringVar(&protocol, "protocol", "tcp", "Protocol of the connection, tcp or udp")
+	cmd.PersistentFlags().StringVar(&uid, "uid", "",
+		"UID of the process opening an outbound connection, any UID but the proxy one if not set")
+	cmd.PersistentFlags().StringVar(&gid, "gid", "",
+		"GID of the process opening an outbound connection, any GID but the proxy one if not set")
+	cmd.PersistentFlags().BoolVar(&inbound, "inbound", false, "Explain an inbound connection to the pod instead of an outbound one")
+	return cmd
+}
+
+// podCaptureConfig returns the istio-iptables configuration of an injected pod, as set by the injection template and
+// the CNI plugin from the pod annotations, the proxy container and the mesh config.
+func podCaptureConfig(pod *corev1.Pod, meshCfg *meshconfig.MeshConfig) (*config.Config, error) {
+	if _, ok := pod.Annotations[annotation.SidecarStatus.Name]; !ok {
+		return nil, fmt.Errorf("pod %s/%s is not injected with a sidecar", pod.Namespace, pod.Name)
+	}
+	proxyConfig := meshCfg.GetDefaultConfig()
+	if proxyConfig == nil {
+		proxyConfig = mesh.DefaultProxyConfig()
+	}
+	if pc, ok := pod.Annotations[annotation.ProxyConfig.Name]; ok {
+		merged, err := mesh.MergeProxyConfig(pc, proxyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %v", annotation.ProxyConfig.Name, err)
+		}
+		proxyConfig = merged
+	}
+	annotationOrDefault := func(name, def string) string {
+		if v, ok := pod.Annotations[name]; ok {
+			return v
+		}
+		return def
+	}
+
+	mode := annotationOrDefault(annotation.SidecarInterceptionMode.Name, proxyConfig.GetInterceptionMode().String())
+	if mode == meshconfig.ProxyConfig_NONE.String() {
+		return nil, fmt.Errorf("pod %s/%s does not capture traffic, its interception mode is %s", pod.Namespace, pod.Name, mode)
+	}
+
+	cfg := &config.Config{
+		ProxyPort:               "15001",
+		InboundCapturePort:      "15006",
+		InboundTunnelPort:       "15008",
+		ProxyUID:                constants.DefaultProxyUID,
+		InboundInterceptionMode: mode,
+		InboundTProxyMark:       "1337",
+		InboundTProxyRouteTable: "133",
+		InboundPortsInclude:     annotationOrDefault(annotation.SidecarTrafficIncludeInboundPorts.Name, "*"),
+		OutboundPortsInclude:    annotationOrDefault(annotation.SidecarTrafficIncludeOutboundPorts.Name, ""),
+		OutboundPortsExclude:    annotationOrDefault(annotation.SidecarTrafficExcludeOutboundPorts.Name, ""),
+		OutboundIPRangesInclude: annotationOrDefault(annotation.SidecarTrafficIncludeOutboundIPRanges.Name, "*"),
+		OutboundIPRangesExclude: annotationOrDefault(annotation.SidecarTrafficExcludeOutboundIPRanges.Name, ""),
+		KubeVirtInterfaces:      annotationOrDefault(annotation.SidecarTrafficKubevirtInterfaces.Name, ""),
+		ExcludeInterfaces:       annotationOrDefault(annotation.SidecarTrafficExcludeInterfaces.Name, ""),
+		OwnerGroupsInclude:      constants.OwnerGroupsInclude.DefaultValue,
+	}
+
+	// The proxy admin, health and metrics ports are never captured.
+	statusPort := annotationOrDefault(annotation.SidecarStatusPort.Name, strconv.Itoa(int(proxyConfig.GetStatusPort())))
+	excludeInbound := []string{"15090", "15021", statusPort}
+	excludeInbound = append(excludeInbound, strings.Split(pod.Annotations[annotation.SidecarTrafficExcludeInboundPorts.Name], ",")...)
+	cfg.InboundPortsExclude = joinUnique(excludeInbound)
+
+	proxyEnv := proxyConfig.GetProxyMetadata()
+	if proxy := findCaptureProxyContainer(pod); proxy != nil {
+		if sc := proxy.SecurityContext; sc != nil && sc.RunAsUser != nil {
+			cfg.ProxyUID = strconv.FormatInt(*sc.RunAsUser, 10)
+			if sc.RunAsGroup != nil {
+				cfg.ProxyGID = strconv.FormatInt(*sc.RunAsGroup, 10)
+			}
+		}
+		merged := map[string]string{}
+		for k, v := range proxyEnv {
+			merged[k] = v
+		}
+		for _, e := range proxy.Env {
+			merged[e.Name] = e.Value
+		}
+		proxyEnv = merged
+	}
+	if cfg.ProxyGID == "" {
+		cfg.ProxyGID = cfg.ProxyUID
+	}
+	// Without the DNS servers of the pod, all the DNS traffic is considered captured, as the CNI plugin does.
+	cfg.RedirectDNS, _ = strconv.ParseBool(proxyEnv["ISTIO_META_DNS_CAPTURE"])
+	cfg.CaptureAllDNS = cfg.RedirectDNS
+	cfg.DropInvalid, _ = strconv.ParseBool(proxyEnv["INVALID_DROP"])
+
+	for _, ip := range pod.Status.PodIPs {
+		if addr, err := netip.ParseAddr(ip.IP); err == nil && addr.Is6() {
+			cfg.EnableInboundIPv6 = true
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// findCaptureProxyContainer returns the proxy container of the pod, a regular or a native sidecar container.
+func findCaptureProxyContainer(pod *corev1.Pod) *corev1.Container {
+	for _, containers := range [][]corev1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for i := range containers {
+			if containers[i].Name == captureProxyContainerName {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
+
+func joinUnique(values []string) string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		unique = append(unique, v)
+	}
+	return strings.Join(unique, ",")
+}
+
+// capturePacket returns the first packet of the connection to explain. Outbound packets to the loopback or to the
+// pod itself are routed to the loopback interface.
+func capturePacket(pod *corev1.Pod, dst, protocol, uid, gid string, inbound bool) (builder.Packet, error) {
+	addrPort, err := netip.ParseAddrPort(dst)
+	if err != nil {
+		return builder.Packet{}, fmt.Errorf("invalid destination %q, expected <ip>:<port>: %v", dst, err)
+	}
+	protocol = strings.ToLower(protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		return builder.Packet{}, fmt.Errorf("unsupported protocol %q, expected tcp or udp", protocol)
+	}
+	p := builder.Packet{
+		Inbound:  inbound,
+		Protocol: protocol,
+		Dst:      addrPort.Addr().Unmap(),
+		DstPort:  addrPort.Port(),
+	}
+	if inbound {
+		p.InInterface = captureDefaultInterface
+		return p, nil
+	}
+	p.UID, p.GID = uid, gid
+	p.OutInterface = captureDefaultInterface
+	if p.Dst.IsLoopback() {
+		p.OutInterface = "lo"
+	}
+	for _, ip := range pod.Status.PodIPs {
+		addr, err := netip.ParseAddr(ip.IP)
+		if err != nil || addr.Is4() != p.Dst.Is4() {
+			continue
+		}
+		if addr == p.Dst {
+			p.OutInterface = "lo"
+		}
+		if !p.Src.IsValid() {
+			p.Src = addr
+		}
+	}
+	return p, nil
+}
+
+func printCaptureExplanation(w io.Writer, exp *builder.Explanation) {
+	fmt.Fprintf(w, "Traffic capture of the %s:\n", exp.Packet)
+	if len(exp.Steps) == 0 {
+		fmt.Fprintln(w, "  no rule matched")
+	}
+	for _, s := range exp.Steps {
+		fmt.Fprintf(w, "  %-7s %-18s %s\n", s.Table, s.Chain, strings.Join(s.Rule, " "))
+	}
+	switch exp.Verdict {
+	case builder.VerdictRedirected:
+		name := capturePortNames[exp.Port]
+		if name == "" {
+			name = "unknown listener"
+		}
+		fmt.Fprintf(w, "Result: redirected to port %s (%s)\n", exp.Port, name)
+	case builder.VerdictDropped:
+		fmt.Fprintln(w, "Result: dropped")
+	default:
+		fmt.Fprintln(w, "Result: not captured, the connection bypasses the sidecar")
+	}
+	if exp.Decision != nil {
+		fmt.Fprintf(w, "Decided by: %s\n", exp.Decision)
+	} else {
+		fmt.Fprintln(w, "Decided by: no capture rule matched")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+)
+
+func captureTestPod(annotations map[string]string, env ...corev1.EnvVar) *corev1.Pod {
+	uid := int64(1337)
+	a := map[string]string{"sidecar.istio.io/status": `{"revision":"default"}`}
+	for k, v := range annotations {
+		a[k] = v
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "productpage", Namespace: "default", Annotations: a},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "productpage"},
+				{Name: "istio-proxy", Env: env, SecurityContext: &corev1.SecurityContext{RunAsUser: &uid}},
+			},
+		},
+		Status: corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.244.0.12"}}},
+	}
+}
+
+func TestCaptureExplain(t *testing.T) {
+	cases := []struct {
+		name        string
+		pod         *corev1.Pod
+		dst         string
+		protocol    string
+		uid         string
+		inbound     bool
+		wantResult  string
+		wantDecided string
+	}{
+		{
+			name:        "outbound redirected",
+			pod:         captureTestPod(nil),
+			dst:         "10.0.0.5:8080",
+			wantResult:  "Result: redirected to port 15001 (outbound proxy listener)",
+			wantDecided: "Decided by: -t nat -A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001",
+		},
+		{
+			name:        "outbound from the proxy",
+			pod:         captureTestPod(nil),
+			dst:         "10.0.0.5:8080",
+			uid:         "1337",
+			wantResult:  "Result: not captured",
+			wantDecided: "Decided by: -t nat -A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN",
+		},
+		{
+			name:        "outbound excluded range",
+			pod:         captureTestPod(map[string]string{"traffic.sidecar.istio.io/excludeOutboundIPRanges": "10.0.0.0/16"}),
+			dst:         "10.0.0.5:8080",
+			wantResult:  "Result: not captured",
+			wantDecided: "Decided by: -t nat -A ISTIO_OUTPUT -d 10.0.0.0/16 -j RETURN",
+		},
+		{
+			name:        "outbound to the pod itself",
+			pod:         captureTestPod(nil),
+			dst:         "10.244.0.12:9080",
+			wantResult:  "Result: not captured",
+			wantDecided: "Decided by: -t nat -A ISTIO_OUTPUT -o lo -m owner ! --uid-owner 1337 -j RETURN",
+		},
+		{
+			name:        "dns capture",
+			pod:         captureTestPod(nil, corev1.EnvVar{Name: "ISTIO_META_DNS_CAPTURE", Value: "true"}),
+			dst:         "10.96.0.10:53",
+			protocol:    "udp",
+			wantResult:  "Result: redirected to port 15053 (agent DNS proxy)",
+			wantDecided: "Decided by: -t nat -A OUTPUT -p udp --dport 53 -j REDIRECT --to-port 15053",
+		},
+		{
+			name:        "inbound redirected",
+			pod:         captureTestPod(nil),
+			dst:         "10.244.0.12:9080",
+			inbound:     true,
+			wantResult:  "Result: redirected to port 15006 (inbound proxy listener)",
+			wantDecided: "Decided by: -t nat -A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-ports 15006",
+		},
+		{
+			name:        "inbound status port",
+			pod:         captureTestPod(map[string]string{"status.sidecar.istio.io/port": "15999"}),
+			dst:         "10.244.0.12:15999",
+			inbound:     true,
+			wantResult:  "Result: not captured",
+			wantDecided: "Decided by: -t nat -A ISTIO_INBOUND -p tcp --dport 15999 -j RETURN",
+		},
+		{
+			name:        "inbound not included",
+			pod:         captureTestPod(map[string]string{"traffic.sidecar.istio.io/includeInboundPorts": "8080"}),
+			dst:         "10.244.0.12:9080",
+			inbound:     true,
+			wantResult:  "Result: not captured",
+			wantDecided: "Decided by: no capture rule matched",
+		},
+		{
+			name:        "inbound tproxy",
+			pod:         captureTestPod(map[string]string{"sidecar.istio.io/interceptionMode": "TPROXY"}),
+			dst:         "10.244.0.12:9080",
+			inbound:     true,
+			wantResult:  "Result: redirected to port 15006 (inbound proxy listener)",
+			wantDecided: "Decided by: -t mangle -A ISTIO_TPROXY",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := podCaptureConfig(tc.pod, mesh.DefaultMeshConfig())
+			assert.NoError(t, err)
+			protocol := tc.protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			packet, err := capturePacket(tc.pod, tc.dst, protocol, tc.uid, "", tc.inbound)
+			assert.NoError(t, err)
+			exp, err := capture.Explain(cfg, packet)
+			assert.NoError(t, err)
+			var out bytes.Buffer
+			printCaptureExplanation(&out, exp)
+			for _, want := range []string{tc.wantResult, tc.wantDecided} {
+				if !strings.Contains(out.String(), want) {
+					t.Fatalf("expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPodCaptureConfigErrors(t *testing.T) {
+	notInjected := captureTestPod(nil)
+	delete(notInjected.Annotations, "sidecar.istio.io/status")
+	_, err := podCaptureConfig(notInjected, mesh.DefaultMeshConfig())
+	assert.Error(t, err)
+
+	_, err = podCaptureConfig(captureTestPod(map[string]string{"sidecar.istio.io/interceptionMode": "NONE"}), mesh.DefaultMeshConfig())
+	assert.Error(t, err)
+
+	_, err = capturePacket(captureTestPod(nil), "10.0.0.5", "tcp", "", "", false)
+	assert.Error(t, err)
+	_, err = capturePacket(captureTestPod(nil), "10.0.0.5:53", "sctp", "", "", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,205 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/certs"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	pilotxds "istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube"
+)
+
+func certsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Inspect the workload certificates used across the mesh",
+	}
+	cmd.AddCommand(certsInventoryCmd())
+	return cmd
+}
+
+func certsInventoryCmd() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+	var multiXdsOpts multixds.Options
+	var (
+		expiryThreshold   time.Duration
+		rootCertFile      string
+		intermediateFiles []string
+		concurrency       int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Reports the workload certificates of all proxies in the mesh",
+		Long: `Gathers the secrets of every proxy connected to Istiod and reports the expiration distribution, issuer chains
+and trust domains of their workload certificates. Proxies with certificates close to expiry, signed by unexpected
+intermediates, or that do not trust the current root are flagged.
+
+The current root is read from the istio-ca-root-cert ConfigMap in the Istio namespace, unless --root-cert is set.`,
+		Example: `  # Summarize the certificates of all proxies in the mesh
+  istioctl x certs inventory
+
+  # Flag certificates expiring within 2 hours, and output JSON for alerting
+  istioctl x certs inventory --expiry-threshold 2h -o json
+
+  # Flag certificates not signed by the given intermediate
+  istioctl x certs inventory --intermediate-cert ca-cert.pem`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if outputFormat != summaryOutput && outputFormat != jsonOutput {
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+			kubeClient, err := kubeClientWithRevision(kubeconfig, configContext, opts.Revision)
+			if err != nil {
+				return err
+			}
+			multiXdsOpts.MessageWriter = c.ErrOrStderr()
+
+			certOpts := certs.Options{
+				Now:             time.Now(),
+				ExpiryThreshold: expiryThreshold,
+			}
+			if certOpts.Roots, err = loadInventoryRoots(kubeClient, rootCertFile); err != nil {
+				return err
+			}
+			for _, f := range intermediateFiles {
+				intermediates, err := loadCertificates(f)
+				if err != nil {
+					return err
+				}
+				certOpts.Intermediates = append(certOpts.Intermediates, intermediates...)
+			}
+
+			xdsRequest := discovery.DiscoveryRequest{
+				TypeUrl: pilotxds.TypeDebugSyncronization,
+			}
+			xdsResponses, err := multixds.AllRequestAndProcessXds(&xdsRequest, centralOpts, istioNamespace, "", "", kubeClient, multiXdsOpts)
+			if err != nil {
+				return err
+			}
+			proxies, err := certs.ProxyIDs(xdsResponses)
+			if err != nil {
+				return err
+			}
+			if len(proxies) == 0 {
+				return fmt.Errorf("no proxies found (checked %d istiods)", len(xdsResponses))
+			}
+
+			report := certs.BuildReport(gatherProxyCerts(kubeClient, proxies, concurrency, certOpts), certOpts)
+			if outputFormat == jsonOutput {
+				return report.PrintJSON(c.OutOrStdout())
+			}
+			return report.PrintSummary(c.OutOrStdout())
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	cmd.PersistentFlags().IntVar(&proxyAdminPort, "proxy-admin-port", defaultProxyAdminPort, "Envoy proxy admin port")
+	cmd.PersistentFlags().DurationVar(&expiryThreshold, "expiry-threshold", 6*time.Hour,
+		"Flag workload certificates that expire within this duration")
+	cmd.PersistentFlags().StringVar(&rootCertFile, "root-cert", "",
+		"PEM file containing the current root certificates. Defaults to the root distributed by Istiod")
+	cmd.PersistentFlags().StringSliceVar(&intermediateFiles, "intermediate-cert", nil,
+		"PEM files containing the intermediate certificates workload certificates are expected to be signed by. "+
+			"If unset, any intermediate chaining to the root is accepted")
+	cmd.PersistentFlags().IntVar(&concurrency, "concurrency", 10, "Number of proxies to query in parallel")
+	cmd.PersistentFlags().BoolVar(&multiXdsOpts.XdsViaAgents, "xds-via-agents", false,
+		"Access Istiod via the tap service of each agent")
+	cmd.PersistentFlags().IntVar(&multiXdsOpts.XdsViaAgentsLimit, "xds-via-agents-limit", 100,
+		"Maximum number of pods being visited by istioctl when `xds-via-agent` flag is true."+
+			"To iterate all the agent pods without limit, set to 0")
+	return cmd
+}
+
+// gatherProxyCerts fetches the config dump of each proxy, with at most concurrency requests in flight.
+func gatherProxyCerts(kubeClient kube.CLIClient, proxies []string, concurrency int, opts certs.Options) []certs.ProxyCerts {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]certs.ProxyCerts, len(proxies))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, proxy := range proxies {
+		i, proxy := i, proxy
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// Proxy IDs are <pod>.<namespace>; namespaces cannot contain dots, but pod names can.
+			idx := strings.LastIndex(proxy, ".")
+			if idx < 0 {
+				results[i] = certs.ProxyCerts{Proxy: proxy, Error: "proxy is not a pod"}
+				return
+			}
+			podName, podNamespace := proxy[:idx], proxy[idx+1:]
+			dump, err := kubeClient.EnvoyDoWithPort(context.TODO(), podName, podNamespace, "GET", "config_dump", proxyAdminPort)
+			if err != nil {
+				results[i] = certs.ProxyCerts{Proxy: proxy, Error: fmt.Sprintf("failed to get config dump: %v", err)}
+				return
+			}
+			results[i] = certs.AnalyzeProxy(proxy, dump, opts)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func loadInventoryRoots(kubeClient kube.CLIClient, rootCertFile string) ([]*x509.Certificate, error) {
+	if rootCertFile != "" {
+		return loadCertificates(rootCertFile)
+	}
+	cm, err := kubeClient.Kube().CoreV1().ConfigMaps(istioNamespace).Get(context.TODO(), controller.CACertNamespaceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the current root, set --root-cert to provide it: %v", err)
+	}
+	roots, err := certs.ParseCertificates([]byte(cm.Data[constants.CACertNamespaceConfigMapDataName]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse root certificate from %s/%s: %v", istioNamespace, controller.CACertNamespaceConfigMap, err)
+	}
+	return roots, nil
+}
+
+func loadCertificates(file string) ([]*x509.Certificate, error) {
+	by, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	res, err := certs.ParseCertificates(by)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificates from %s: %v", file, err)
+	}
+	return res, nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	admitv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
@@ -69,6 +70,7 @@ Checks associated resources of the given resource, and running webhooks to exami
 			}
 			var podName, podNs string
 			var podLabels, nsLabels map[string]string
+			var pod *corev1.Pod
 			if len(args) == 1 {
 				podName, podNs, err = handlers.InferPodInfoFromTypedResource(args[0],
 					handlers.HandleNamespace(namespace, defaultNamespace),
@@ -76,7 +78,7 @@ Checks associated resources of the given resource, and running webhooks to exami
 				if err != nil {
 					return err
 				}
-				pod, err := client.Kube().CoreV1().Pods(podNs).Get(context.TODO(), podName, metav1.GetOptions{})
+				pod, err = client.Kube().CoreV1().Pods(podNs).Get(context.TODO(), podName, metav1.GetOptions{})
 				if err != nil {
 					return err
 				}
@@ -106,7 +108,15 @@ Checks associated resources of the given resource, and running webhooks to exami
 				return err
 			}
 			checkResults := analyzeRunningWebhooks(whs.Items, podLabels, nsLabels)
-			return printCheckInjectorResults(cmd.OutOrStdout(), checkResults)
+			if err := printCheckInjectorResults(cmd.OutOrStdout(), checkResults); err != nil {
+				return err
+			}
+			if pod != nil {
+				if mode := proxyInjectionMode(pod); mode != "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "The istio-proxy of pod %s.%s runs as %s.\n", pod.Name, pod.Namespace, mode)
+				}
+			}
+			return nil
 		},
 	}
 	cmd.PersistentFlags().StringVarP(&labelPairs, "labels", "l", "",
@@ -149,6 +159,22 @@ func printCheckInjectorResults(writer io.Writer, was []webhookAnalysis) error {
 	return nil
 }
 
+// proxyInjectionMode describes how the istio-proxy is injected into the pod: as a native
+// sidecar (an init container) or as a regular container. It is empty if the pod has no proxy.
+func proxyInjectionMode(pod *corev1.Pod) string {
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name == analyzer_util.IstioProxyName {
+			return "a native sidecar (init container)"
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == analyzer_util.IstioProxyName {
+			return "a container"
+		}
+	}
+	return ""
+}
+
 type webhookAnalysis struct {
 	Name     string
 	Revision string
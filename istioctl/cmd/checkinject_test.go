@@ -321,6 +321,41 @@ func Test_analyzeRunningWebhooks(t *testing.T) {
 	}
 }
 
+func Test_proxyInjectionMode(t *testing.T) {
+	cases := []struct {
+		name string
+		spec corev1.PodSpec
+		want string
+	}{
+		{
+			name: "not injected",
+			spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			want: "",
+		},
+		{
+			name: "container",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "istio-init"}},
+				Containers:     []corev1.Container{{Name: "app"}, {Name: "istio-proxy"}},
+			},
+			want: "a container",
+		},
+		{
+			name: "native sidecar",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "istio-init"}, {Name: "istio-proxy"}},
+				Containers:     []corev1.Container{{Name: "app"}},
+			},
+			want: "a native sidecar (init container)",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, proxyInjectionMode(&corev1.Pod{Spec: c.spec}), c.want)
+		})
+	}
+}
+
 var nsTestObject = func(namespace, injLabelValue, revLabelValue string) *corev1.Namespace {
 	labels := map[string]string{}
 	if injLabelValue != "" {
@@ -298,12 +298,21 @@ func extractObject(in runtime.Object) (any, error) {
 			sidecarInjected = true
 		}
 	}
+	// the proxy may also be injected as a native sidecar, which is an init container
+	for _, c := range podSpec.InitContainers {
+		if c.Name == proxyContainerName {
+			sidecarInjected = true
+		}
+	}
 	if !sidecarInjected {
 		return out, nil
 	}
 
 	var appProbe inject.KubeAppProbers
 	appProbeStr := retrieveAppProbe(podSpec.Containers)
+	if appProbeStr == "" {
+		appProbeStr = retrieveAppProbe(podSpec.InitContainers)
+	}
 	if appProbeStr != "" {
 		if err := json.Unmarshal([]byte(appProbeStr), &appProbe); err != nil {
 			return nil, err
@@ -316,6 +325,7 @@ func extractObject(in runtime.Object) (any, error) {
 	podSpec.InitContainers = removeInjectedContainers(podSpec.InitContainers, initContainerName)
 	podSpec.InitContainers = removeInjectedContainers(podSpec.InitContainers, initValidationContainerName)
 	podSpec.InitContainers = removeInjectedContainers(podSpec.InitContainers, enableCoreDumpContainerName)
+	podSpec.InitContainers = removeInjectedContainers(podSpec.InitContainers, proxyContainerName)
 	podSpec.Containers = removeInjectedContainers(podSpec.Containers, proxyContainerName)
 	podSpec.Volumes = removeInjectedVolumes(podSpec.Volumes, certVolumeName)
 	podSpec.Volumes = removeInjectedVolumes(podSpec.Volumes, dataVolumeName)
@@ -105,6 +105,11 @@ func TestKubeUninject(t *testing.T) {
 				"experimental kube-uninject -f testdata/uninject/deploymentconfig-app-probe.yaml.injected", " "),
 			goldenFilename: "testdata/uninject/deploymentconfig-app-probe.yaml",
 		},
+		{ // case 16: proxy injected as a native sidecar
+			args: strings.Split(
+				"experimental kube-uninject -f testdata/uninject/job-native-sidecar.yaml.injected", " "),
+			goldenFilename: "testdata/uninject/job-native-sidecar.yaml",
+		},
 	}
 
 	for i, c := range cases {
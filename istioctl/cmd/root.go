@@ -240,6 +240,8 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(statsConfigCmd())
 	experimentalCmd.AddCommand(checkInjectCommand())
 	experimentalCmd.AddCommand(waypointCmd())
+	experimentalCmd.AddCommand(certsCmd())
+	experimentalCmd.AddCommand(captureCmd())
 
 	analyzeCmd := Analyze()
 	hideInheritedFlags(analyzeCmd, FlagIstioNamespace)
@@ -0,0 +1,350 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certs builds a mesh wide inventory of the workload certificates in use by proxies.
+package certs
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	xdsstatus "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/security"
+)
+
+// Options configures how proxy certificates are checked.
+type Options struct {
+	// Now is the time certificate validity is evaluated at.
+	Now time.Time
+	// ExpiryThreshold flags workload certificates expiring within this duration.
+	ExpiryThreshold time.Duration
+	// Roots are the roots proxies are expected to trust and chain to. If empty, root checks are skipped.
+	Roots []*x509.Certificate
+	// Intermediates are the only intermediates workload certificates may be signed by.
+	// If empty, any intermediate that chains to Roots is accepted.
+	Intermediates []*x509.Certificate
+}
+
+// ProxyCerts describes the workload certificate and trust anchors of a single proxy.
+type ProxyCerts struct {
+	// Proxy is the proxy name, in <pod>.<namespace> form.
+	Proxy       string    `json:"proxy"`
+	Identity    string    `json:"identity,omitempty"`
+	TrustDomain string    `json:"trustDomain,omitempty"`
+	Serial      string    `json:"serialNumber,omitempty"`
+	NotAfter    time.Time `json:"notAfter,omitempty"`
+	// Issuers are the subjects of the certificates that issued the workload certificate, from the
+	// direct issuer up to the root.
+	Issuers []string `json:"issuers,omitempty"`
+	// Roots are the SHA-256 fingerprints of the trust anchors the proxy is configured with.
+	Roots []string `json:"roots,omitempty"`
+	// OldRoot is set if the proxy does not trust any of the expected roots.
+	OldRoot bool `json:"oldRoot,omitempty"`
+	// Issues lists the problems found with the proxy certificates.
+	Issues []string `json:"issues,omitempty"`
+	// Error is set if the certificates of the proxy could not be retrieved or parsed.
+	Error string `json:"error,omitempty"`
+}
+
+// Count is the number of proxies sharing a value.
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Report is the mesh wide certificate inventory.
+type Report struct {
+	Time time.Time `json:"time"`
+	// Expiry is the distribution of the remaining lifetime of workload certificates.
+	Expiry       []Count      `json:"expiry"`
+	TrustDomains []Count      `json:"trustDomains"`
+	IssuerChains []Count      `json:"issuerChains"`
+	OldRoot      []string     `json:"oldRoot,omitempty"`
+	Flagged      []ProxyCerts `json:"flagged,omitempty"`
+	Proxies      []ProxyCerts `json:"proxies"`
+}
+
+type expiryBucket struct {
+	label string
+	upTo  time.Duration
+}
+
+var expiryBuckets = []expiryBucket{
+	{"<1h", time.Hour},
+	{"<6h", 6 * time.Hour},
+	{"<24h", 24 * time.Hour},
+	{"<7d", 7 * 24 * time.Hour},
+	{">=7d", 0},
+}
+
+// ProxyIDs returns the names, in <pod>.<namespace> form, of the Envoy proxies listed in
+// debug synchronization responses from Istiod.
+func ProxyIDs(responses map[string]*discovery.DiscoveryResponse) ([]string, error) {
+	ids := map[string]struct{}{}
+	for _, dr := range responses {
+		for _, resource := range dr.Resources {
+			clientConfig := xdsstatus.ClientConfig{}
+			if err := resource.UnmarshalTo(&clientConfig); err != nil {
+				return nil, fmt.Errorf("could not unmarshal ClientConfig: %w", err)
+			}
+			meta, err := model.ParseMetadata(clientConfig.GetNode().GetMetadata())
+			if err != nil {
+				return nil, fmt.Errorf("could not parse node metadata: %w", err)
+			}
+			node, err := model.ParseServiceNodeWithMetadata(clientConfig.GetNode().GetId(), meta)
+			if err != nil {
+				continue
+			}
+			if node.Type != model.SidecarProxy && node.Type != model.Router {
+				continue
+			}
+			ids[node.ID] = struct{}{}
+		}
+	}
+	res := make([]string, 0, len(ids))
+	for id := range ids {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// ParseCertificates parses all PEM encoded certificates in data.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return certs, nil
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of a certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// AnalyzeProxy inspects the secrets in an Envoy config dump of the given proxy.
+func AnalyzeProxy(proxy string, dump []byte, opts Options) ProxyCerts {
+	res := ProxyCerts{Proxy: proxy}
+	cd := &configdump.Wrapper{}
+	if err := json.Unmarshal(dump, cd); err != nil {
+		res.Error = fmt.Sprintf("failed to parse config dump: %v", err)
+		return res
+	}
+	secretDump, err := cd.GetSecretConfigDump()
+	if err != nil {
+		res.Error = fmt.Sprintf("failed to get secrets: %v", err)
+		return res
+	}
+	var chain, roots []*x509.Certificate
+	for _, s := range secretDump.DynamicActiveSecrets {
+		secret := &auth.Secret{}
+		if err := s.GetSecret().UnmarshalTo(secret); err != nil {
+			continue
+		}
+		switch s.Name {
+		case security.WorkloadKeyCertResourceName:
+			chain, err = ParseCertificates(secret.GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+			if err != nil {
+				res.Error = fmt.Sprintf("failed to parse workload certificate: %v", err)
+				return res
+			}
+		case security.RootCertReqResourceName:
+			roots, err = ParseCertificates(secret.GetValidationContext().GetTrustedCa().GetInlineBytes())
+			if err != nil {
+				res.Error = fmt.Sprintf("failed to parse root certificate: %v", err)
+				return res
+			}
+		}
+	}
+	if len(chain) == 0 {
+		res.Error = "no workload certificate found"
+		return res
+	}
+
+	leaf := chain[0]
+	if len(leaf.URIs) > 0 {
+		res.Identity = leaf.URIs[0].String()
+		res.TrustDomain = leaf.URIs[0].Host
+	}
+	res.Serial = fmt.Sprintf("%x", leaf.SerialNumber)
+	res.NotAfter = leaf.NotAfter
+	if len(chain) == 1 {
+		res.Issuers = []string{leaf.Issuer.String()}
+	}
+	for _, c := range chain[1:] {
+		res.Issuers = append(res.Issuers, c.Subject.String())
+	}
+	for _, r := range roots {
+		res.Roots = append(res.Roots, Fingerprint(r))
+	}
+
+	if remaining := leaf.NotAfter.Sub(opts.Now); remaining <= 0 {
+		res.Issues = append(res.Issues, fmt.Sprintf("workload certificate expired at %v", leaf.NotAfter.Format(time.RFC3339)))
+	} else if remaining < opts.ExpiryThreshold {
+		res.Issues = append(res.Issues, fmt.Sprintf("workload certificate expires in %v", remaining.Round(time.Second)))
+	}
+	if len(opts.Roots) > 0 {
+		expected := fingerprints(opts.Roots)
+		res.OldRoot = true
+		for _, fp := range res.Roots {
+			if _, f := expected[fp]; f {
+				res.OldRoot = false
+				break
+			}
+		}
+		if res.OldRoot {
+			res.Issues = append(res.Issues, "proxy does not trust the current root")
+		}
+		if err := verifyChain(chain, opts); err != nil {
+			res.Issues = append(res.Issues, fmt.Sprintf("workload certificate does not chain to the current root: %v", err))
+		}
+	}
+	if len(opts.Intermediates) > 0 {
+		expected := fingerprints(opts.Intermediates)
+		for _, c := range chain[1:] {
+			if isSelfSigned(c) {
+				continue
+			}
+			if _, f := expected[Fingerprint(c)]; !f {
+				res.Issues = append(res.Issues, fmt.Sprintf("workload certificate signed by unexpected intermediate %q", c.Subject.String()))
+			}
+		}
+	}
+	return res
+}
+
+// BuildReport aggregates the per proxy results into a Report.
+func BuildReport(proxies []ProxyCerts, opts Options) *Report {
+	sort.Slice(proxies, func(i, j int) bool {
+		return proxies[i].Proxy < proxies[j].Proxy
+	})
+	r := &Report{
+		Time:    opts.Now,
+		Proxies: proxies,
+	}
+	expiry := map[string]int{}
+	trustDomains := map[string]int{}
+	chains := map[string]int{}
+	for _, p := range proxies {
+		if p.Error != "" {
+			r.Flagged = append(r.Flagged, p)
+			continue
+		}
+		expiry[expiryLabel(p.NotAfter.Sub(opts.Now))]++
+		trustDomains[p.TrustDomain]++
+		chains[strings.Join(p.Issuers, " <- ")]++
+		if p.OldRoot {
+			r.OldRoot = append(r.OldRoot, p.Proxy)
+		}
+		if len(p.Issues) > 0 {
+			r.Flagged = append(r.Flagged, p)
+		}
+	}
+	for _, b := range append([]expiryBucket{{label: "expired"}}, expiryBuckets...) {
+		r.Expiry = append(r.Expiry, Count{Value: b.label, Count: expiry[b.label]})
+	}
+	r.TrustDomains = sortedCounts(trustDomains)
+	r.IssuerChains = sortedCounts(chains)
+	return r
+}
+
+func expiryLabel(remaining time.Duration) string {
+	if remaining <= 0 {
+		return "expired"
+	}
+	for _, b := range expiryBuckets {
+		if b.upTo == 0 || remaining < b.upTo {
+			return b.label
+		}
+	}
+	return expiryBuckets[len(expiryBuckets)-1].label
+}
+
+func sortedCounts(m map[string]int) []Count {
+	res := make([]Count, 0, len(m))
+	for v, c := range m {
+		res = append(res, Count{Value: v, Count: c})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Value < res[j].Value
+	})
+	return res
+}
+
+func verifyChain(chain []*x509.Certificate, opts Options) error {
+	roots := x509.NewCertPool()
+	for _, r := range opts.Roots {
+		roots.AddCert(r)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	// Expiry is reported separately, so verify expired certificates as of their last valid moment.
+	at := opts.Now
+	if at.After(chain[0].NotAfter) {
+		at = chain[0].NotAfter
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func fingerprints(certs []*x509.Certificate) map[string]struct{} {
+	res := make(map[string]struct{}, len(certs))
+	for _, c := range certs {
+		res[Fingerprint(c)] = struct{}{}
+	}
+	return res
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(cert) == nil
+}
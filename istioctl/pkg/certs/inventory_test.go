@@ -0,0 +1,177 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+
+	admin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type testCA struct {
+	pem  []byte
+	cert *x509.Certificate
+	key  crypto.PrivateKey
+}
+
+func genCert(t *testing.T, opts util.CertOptions, signer *testCA) *testCA {
+	t.Helper()
+	if signer != nil {
+		opts.SignerCert = signer.cert
+		opts.SignerPriv = signer.key
+	} else {
+		opts.IsSelfSigned = true
+	}
+	opts.RSAKeySize = 2048
+	certPem, keyPem, err := util.GenCertKeyFromOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := util.ParsePemEncodedKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{pem: certPem, cert: cert, key: key}
+}
+
+func secretAny(t *testing.T, name string, s *auth.Secret) *admin.SecretsConfigDump_DynamicSecret {
+	t.Helper()
+	s.Name = name
+	return &admin.SecretsConfigDump_DynamicSecret{Name: name, Secret: protoconv.MessageToAny(s)}
+}
+
+func configDump(t *testing.T, chain, root []byte) []byte {
+	t.Helper()
+	sd := &admin.SecretsConfigDump{}
+	if chain != nil {
+		sd.DynamicActiveSecrets = append(sd.DynamicActiveSecrets, secretAny(t, "default", &auth.Secret{
+			Type: &auth.Secret_TlsCertificate{TlsCertificate: &auth.TlsCertificate{
+				CertificateChain: &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: chain}},
+			}},
+		}))
+	}
+	if root != nil {
+		sd.DynamicActiveSecrets = append(sd.DynamicActiveSecrets, secretAny(t, "ROOTCA", &auth.Secret{
+			Type: &auth.Secret_ValidationContext{ValidationContext: &auth.CertificateValidationContext{
+				TrustedCa: &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: root}},
+			}},
+		}))
+	}
+	out, err := protomarshal.Marshal(&admin.ConfigDump{Configs: []*anypb.Any{protoconv.MessageToAny(sd)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestInventory(t *testing.T) {
+	now := time.Now()
+	// The certificates are backdated, so they are valid at now.
+	notBefore := now.Add(-time.Minute)
+	root := genCert(t, util.CertOptions{Host: "root", Org: "root", IsCA: true, NotBefore: notBefore, TTL: 24 * time.Hour * 365}, nil)
+	oldRoot := genCert(t, util.CertOptions{Host: "old-root", Org: "old-root", IsCA: true, NotBefore: notBefore, TTL: 24 * time.Hour * 365}, nil)
+	intermediate := genCert(t, util.CertOptions{
+		Host: "intermediate", Org: "intermediate", IsCA: true, NotBefore: notBefore, TTL: 24 * time.Hour * 30,
+	}, root)
+	rogue := genCert(t, util.CertOptions{Host: "rogue", Org: "rogue", IsCA: true, NotBefore: notBefore, TTL: 24 * time.Hour * 30}, root)
+
+	leaf := func(signer *testCA, ttl time.Duration) []byte {
+		c := genCert(t, util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/app", NotBefore: notBefore, TTL: ttl}, signer)
+		return bytes.Join([][]byte{c.pem, signer.pem}, nil)
+	}
+
+	opts := Options{
+		Now:             now,
+		ExpiryThreshold: time.Hour,
+		Roots:           []*x509.Certificate{root.cert},
+		Intermediates:   []*x509.Certificate{intermediate.cert},
+	}
+	proxies := []ProxyCerts{
+		AnalyzeProxy("healthy.default", configDump(t, leaf(intermediate, 12*time.Hour), root.pem), opts),
+		AnalyzeProxy("expiring.default", configDump(t, leaf(intermediate, 30*time.Minute), root.pem), opts),
+		AnalyzeProxy("old-root.default", configDump(t, leaf(intermediate, 12*time.Hour), oldRoot.pem), opts),
+		AnalyzeProxy("rogue.default", configDump(t, leaf(rogue, 12*time.Hour), root.pem), opts),
+		AnalyzeProxy("no-cert.default", configDump(t, nil, root.pem), opts),
+		AnalyzeProxy("invalid.default", []byte("{"), opts),
+	}
+	report := BuildReport(proxies, opts)
+
+	flagged := map[string]string{}
+	for _, p := range report.Flagged {
+		flagged[p.Proxy] = p.Error + strings.Join(p.Issues, ";")
+	}
+	if _, f := flagged["healthy.default"]; f {
+		t.Errorf("healthy proxy should not be flagged: %v", flagged["healthy.default"])
+	}
+	expect := map[string]string{
+		"expiring.default": "expires in",
+		"old-root.default": "does not trust the current root",
+		"rogue.default":    "unexpected intermediate",
+		"no-cert.default":  "no workload certificate found",
+		"invalid.default":  "failed to parse config dump",
+	}
+	for proxy, issue := range expect {
+		if !strings.Contains(flagged[proxy], issue) {
+			t.Errorf("expected %v to be flagged with %q, got %q", proxy, issue, flagged[proxy])
+		}
+	}
+	if len(report.OldRoot) != 1 || report.OldRoot[0] != "old-root.default" {
+		t.Errorf("unexpected old root proxies: %v", report.OldRoot)
+	}
+	if len(report.TrustDomains) != 1 || report.TrustDomains[0] != (Count{Value: "cluster.local", Count: 4}) {
+		t.Errorf("unexpected trust domains: %v", report.TrustDomains)
+	}
+	if len(report.IssuerChains) != 2 || report.IssuerChains[0].Count != 3 {
+		t.Errorf("unexpected issuer chains: %v", report.IssuerChains)
+	}
+	expiry := map[string]int{}
+	for _, c := range report.Expiry {
+		expiry[c.Value] = c.Count
+	}
+	if expiry["<1h"] != 1 || expiry["<24h"] != 3 {
+		t.Errorf("unexpected expiry distribution: %v", report.Expiry)
+	}
+
+	var out bytes.Buffer
+	if err := report.PrintSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Proxies inspected: 6, flagged: 5, using an old root: 1") {
+		t.Errorf("unexpected summary:\n%s", out.String())
+	}
+	out.Reset()
+	if err := report.PrintJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"proxy": "rogue.default"`) {
+		t.Errorf("unexpected json:\n%s", out.String())
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// PrintJSON writes the full report as indented JSON.
+func (r *Report) PrintJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// PrintSummary writes a human readable summary of the report.
+func (r *Report) PrintSummary(w io.Writer) error {
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintf(tw, "Proxies inspected: %d, flagged: %d, using an old root: %d\n\n", len(r.Proxies), len(r.Flagged), len(r.OldRoot))
+
+	fmt.Fprintln(tw, "EXPIRES\tPROXIES")
+	for _, c := range r.Expiry {
+		fmt.Fprintf(tw, "%s\t%d\n", c.Value, c.Count)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "TRUST DOMAIN\tPROXIES")
+	for _, c := range r.TrustDomains {
+		fmt.Fprintf(tw, "%s\t%d\n", c.Value, c.Count)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "ISSUER CHAIN\tPROXIES")
+	for _, c := range r.IssuerChains {
+		fmt.Fprintf(tw, "%s\t%d\n", c.Value, c.Count)
+	}
+
+	if len(r.Flagged) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "PROXY\tISSUES")
+		for _, p := range r.Flagged {
+			issues := p.Issues
+			if p.Error != "" {
+				issues = []string{p.Error}
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", p.Proxy, strings.Join(issues, "; "))
+		}
+	}
+	return tw.Flush()
+}
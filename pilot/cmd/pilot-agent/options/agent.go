@@ -15,6 +15,7 @@
 package options
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,9 +23,14 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/bootstrap/platform"
+	"istio.io/istio/pkg/config/constants"
+	dnsClient "istio.io/istio/pkg/dns/client"
+	"istio.io/istio/pkg/envoy"
 	istioagent "istio.io/istio/pkg/istio-agent"
+	"istio.io/istio/pkg/istio-agent/health"
 	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/wasm"
+	"istio.io/pkg/log"
 )
 
 // Similar with ISTIO_META_, which is used to customize the node metadata - this customizes extra header.
@@ -46,6 +52,7 @@ func NewAgentOptions(proxy *model.Proxy, cfg *meshconfig.ProxyConfig) *istioagen
 			PurgeInterval:         wasmPurgeInterval,
 			HTTPRequestTimeout:    wasmHTTPRequestTimeout,
 			HTTPRequestMaxRetries: wasmHTTPRequestMaxRetries,
+			NodeCacheAddress:      wasmNodeCacheAddress,
 		},
 		ProxyIPAddresses:            proxy.IPAddresses,
 		ServiceNode:                 proxy.ServiceNode(),
@@ -60,10 +67,43 @@ func NewAgentOptions(proxy *model.Proxy, cfg *meshconfig.ProxyConfig) *istioagen
 		ProxyXDSDebugViaAgentPort:   proxyXDSDebugViaAgentPort,
 		DNSCapture:                  DNSCaptureByAgent.Get(),
 		DNSForwardParallel:          DNSForwardParallel.Get(),
+		DNSMaxAnswerRecords:         DNSMaxAnswerRecords.Get(),
 		DNSAddr:                     DNSCaptureAddr.Get(),
 		ProxyNamespace:              PodNamespaceVar.Get(),
 		ProxyDomain:                 proxy.DNSDomain,
 		IstiodSAN:                   istiodSAN.Get(),
+		XDSCacheMaxAge:              proxyXDSCacheMaxAge,
+		XDSCacheStaleNotReady:       proxyXDSCacheStaleNotReady,
+		XDSRequestLimit:             proxyXDSRequestLimit,
+		XDSRequestBurst:             proxyXDSRequestBurst,
+		XDSMaxResponseSize:          proxyXDSMaxResponseSize,
+		DNSCache: dnsClient.CacheOptions{
+			MaxEntries:     DNSCacheMaxEntries.Get(),
+			MaxTTL:         DNSCacheMaxTTL.Get(),
+			MaxNegativeTTL: DNSCacheMaxNegativeTTL.Get(),
+		},
+	}
+	if wasmVerificationKeys != "" {
+		o.WASMOptions.VerificationKeys = strings.Split(wasmVerificationKeys, ",")
+	}
+	if proxyXDSCache {
+		o.XDSCacheDir = filepath.Join(constants.IstioDataDir, "xds-cache")
+	}
+	if envoyCrashForensics {
+		o.EnvoyForensics = envoy.ForensicsOptions{
+			Dir:         constants.EnvoyCrashDir,
+			Interval:    envoyCrashForensicsInterval,
+			MaxBundles:  envoyCrashForensicsMaxBundles,
+			MaxFileSize: envoyCrashForensicsMaxFileSize,
+		}
+	}
+	if grpcReadinessProbe != "" {
+		grpcCfg := &health.GRPCHealthCheckConfig{}
+		if err := json.Unmarshal([]byte(grpcReadinessProbe), grpcCfg); err != nil {
+			log.Warnf("Invalid GRPC_READINESS_PROBE, ignoring: %v", err)
+		} else {
+			o.GRPCHealthCheck = grpcCfg
+		}
 	}
 	extractXDSHeadersFromEnv(o)
 	return o
@@ -76,6 +76,9 @@ var (
 
 	secretRotationGracePeriodRatioEnv = env.Register("SECRET_GRACE_PERIOD_RATIO", 0.5,
 		"The grace period ratio for the cert rotation, by default 0.5.").Get()
+	secretRotationJitterRatioEnv = env.Register("SECRET_ROTATION_JITTER_RATIO", 0.0,
+		"The ratio of the cert lifetime used to randomly move the cert rotation earlier than the grace period, "+
+			"to avoid many proxies renewing at the same time. Disabled by default.").Get()
 	workloadRSAKeySizeEnv = env.Register("WORKLOAD_RSA_KEY_SIZE", 2048,
 		"Specify the RSA key size to use for workload certificates.").Get()
 	pkcs8KeysEnv = env.Register("PKCS8_KEY", false,
@@ -84,13 +87,67 @@ var (
 	eccCurvEnv          = env.Register("ECC_CURVE", "P256", "The elliptic curve to use when ECC_SIGNATURE_ALGORITHM is set to ECDSA").Get()
 	fileMountedCertsEnv = env.Register("FILE_MOUNTED_CERTS", false, "").Get()
 	credFetcherTypeEnv  = env.Register("CREDENTIAL_FETCHER_TYPE", security.JWT,
-		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine").Get()
+		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine, JWT and Metadata").Get()
+	credFetcherMetadataAddress = env.Register("CREDENTIAL_FETCHER_METADATA_ADDRESS", "",
+		"The address of the metadata endpoint used by the Metadata credential fetcher, either an http(s) URL "+
+			"or a unix domain socket in the form unix:///path/to/socket").Get()
+	credFetcherMetadataPath = env.Register("CREDENTIAL_FETCHER_METADATA_PATH", "",
+		"The path, including any query string, of the token on the metadata endpoint used by the Metadata credential fetcher").Get()
+	credFetcherMetadataHeaders = env.Register("CREDENTIAL_FETCHER_METADATA_HEADERS", "",
+		"Comma separated list of name=value headers sent to the metadata endpoint by the Metadata credential fetcher").Get()
+	credFetcherMetadataTokenField = env.Register("CREDENTIAL_FETCHER_METADATA_TOKEN_FIELD", "",
+		"The dot separated path of the token in the JSON response of the metadata endpoint. "+
+			"If unset, the whole response is used as the token").Get()
+	credFetcherMetadataExpiresInField = env.Register("CREDENTIAL_FETCHER_METADATA_EXPIRES_IN_FIELD", "",
+		"The dot separated path of the token lifetime, in seconds, in the JSON response of the metadata endpoint. "+
+			"If unset, the expiry is read from the token if it is a JWT").Get()
 	credIdentityProvider = env.Register("CREDENTIAL_IDENTITY_PROVIDER", "GoogleComputeEngine",
 		"The identity provider for credential. Currently default supported identity provider is GoogleComputeEngine").Get()
+	stsOAuth2TokenEndpoint = env.Register("STS_OAUTH2_TOKEN_ENDPOINT", "",
+		"The OAuth 2.0 token endpoint used by the OAuth2TokenExchange token manager plugin").Get()
+	stsOAuth2ClientID = env.Register("STS_OAUTH2_CLIENT_ID", "",
+		"The client ID used to authenticate to the OAuth 2.0 token endpoint").Get()
+	stsOAuth2ClientSecretFile = env.Register("STS_OAUTH2_CLIENT_SECRET_FILE", "",
+		"The file containing the client secret used to authenticate to the OAuth 2.0 token endpoint").Get()
+	stsOAuth2ClientAuthMethod = env.Register("STS_OAUTH2_CLIENT_AUTH_METHOD", "client_secret_basic",
+		"How the client authenticates to the OAuth 2.0 token endpoint. One of client_secret_basic, client_secret_post or none").Get()
+	stsOAuth2Scopes = env.Register("STS_OAUTH2_SCOPES", "",
+		"Comma separated list of scopes requested from the OAuth 2.0 token endpoint, unless the STS request sets a scope").Get()
+	stsOAuth2Audiences = env.Register("STS_OAUTH2_AUDIENCES", "",
+		"Comma separated list of audiences requested from the OAuth 2.0 token endpoint, unless the STS request sets an audience").Get()
+	stsOAuth2CACertFile = env.Register("STS_OAUTH2_CA_CERT_FILE", "",
+		"The PEM file of the roots used to verify the OAuth 2.0 token endpoint. If unset, the system roots are used").Get()
 	proxyXDSDebugViaAgent = env.Register("PROXY_XDS_DEBUG_VIA_AGENT", true,
 		"If set to true, the agent will listen on tap port and offer pilot's XDS istio.io/debug debug API there.").Get()
 	proxyXDSDebugViaAgentPort = env.Register("PROXY_XDS_DEBUG_VIA_AGENT_PORT", 15004,
 		"Agent debugging port.").Get()
+	proxyXDSCache = env.Register("PROXY_XDS_CACHE", false,
+		"If set to true, the agent persists the last xDS configuration ACKed by Envoy, and serves it to Envoy "+
+			"while istiod is unreachable, for example when Envoy restarts during an istiod outage. "+
+			"Only the state of the world xDS protocol is supported, delta xDS streams are not cached.").Get()
+	proxyXDSCacheMaxAge = env.Register("PROXY_XDS_CACHE_MAX_AGE", 24*time.Hour,
+		"The maximum age of the persisted xDS configuration the agent serves to Envoy.").Get()
+	proxyXDSCacheStaleNotReady = env.Register("PROXY_XDS_CACHE_STALE_NOT_READY", false,
+		"If set to true, the readiness probe fails while Envoy runs the persisted xDS configuration "+
+			"because istiod is unreachable.").Get()
+	proxyXDSRequestLimit = env.Register("PROXY_XDS_REQUEST_RATE_LIMIT", 0.0,
+		"The number of xDS requests per second of each type the agent forwards to istiod, extra requests are delayed. "+
+			"If set to 0, the requests are not rate limited.").Get()
+	proxyXDSRequestBurst = env.Register("PROXY_XDS_REQUEST_BURST", 10,
+		"The number of xDS requests of each type the agent forwards to istiod in a burst, "+
+			"when PROXY_XDS_REQUEST_RATE_LIMIT is set.").Get()
+	proxyXDSMaxResponseSize = env.Register("PROXY_XDS_MAX_RESPONSE_SIZE", 0,
+		"The maximum size in bytes of the xDS responses the agent forwards to Envoy, larger responses are rejected. "+
+			"If set to 0, the size is unlimited.").Get()
+	envoyCrashForensics = env.Register("ENVOY_CRASH_FORENSICS", false,
+		"If set to true, the agent writes a crash bundle with the last xDS versions applied by Envoy, and its config dump "+
+			"and stats, when Envoy exits unexpectedly. The crash is reported to istiod, which records an event on the pod.").Get()
+	envoyCrashForensicsInterval = env.Register("ENVOY_CRASH_FORENSICS_INTERVAL", time.Minute,
+		"The interval between two snapshots of the Envoy config dump and stats kept for the crash bundles.").Get()
+	envoyCrashForensicsMaxBundles = env.Register("ENVOY_CRASH_FORENSICS_MAX_BUNDLES", 3,
+		"The number of crash bundles kept by the agent, the oldest bundles are removed.").Get()
+	envoyCrashForensicsMaxFileSize = env.Register("ENVOY_CRASH_FORENSICS_MAX_FILE_SIZE", 16*1024*1024,
+		"The maximum size in bytes of each file of a crash bundle, larger content is truncated.").Get()
 	// DNSCaptureByAgent is a copy of the env var in the init code.
 	DNSCaptureByAgent = env.Register("ISTIO_META_DNS_CAPTURE", false,
 		"If set to true, enable the capture of outgoing DNS packets on port 53, redirecting to istio-agent on :15053")
@@ -102,6 +159,22 @@ var (
 	DNSForwardParallel = env.Register("DNS_FORWARD_PARALLEL", false,
 		"If set to true, agent will send parallel DNS queries to all upstream nameservers")
 
+	DNSCacheMaxEntries = env.Register("DNS_CACHE_MAX_ENTRIES", 0,
+		"The maximum number of responses of the upstream nameservers cached by the agent DNS proxy. "+
+			"If set to 0, the responses are not cached")
+
+	DNSCacheMaxTTL = env.Register("DNS_CACHE_MAX_TTL", 5*time.Minute,
+		"The maximum time the agent DNS proxy caches a response of the upstream nameservers, "+
+			"which is at most the TTL of its records")
+
+	DNSCacheMaxNegativeTTL = env.Register("DNS_CACHE_MAX_NEGATIVE_TTL", 30*time.Second,
+		"The maximum time the agent DNS proxy caches a NXDOMAIN or NODATA response of the upstream nameservers, "+
+			"which is at most the negative TTL of its SOA record")
+
+	DNSMaxAnswerRecords = env.Register("DNS_MAX_ANSWER_RECORDS", 0,
+		"If set, the agent DNS proxy returns at most this number of A/AAAA records, picked at random, for the hosts "+
+			"of the mesh, for example headless services with many endpoints. If set to 0, all the records are returned")
+
 	// Ability of istio-agent to retrieve proxyConfig via XDS for dynamic configuration updates
 	enableProxyConfigXdsEnv = env.Register("PROXY_CONFIG_XDS_AGENT", false,
 		"If set to true, agent retrieves dynamic proxy-config updates via xds channel").Get()
@@ -121,10 +194,27 @@ var (
 	wasmHTTPRequestMaxRetries = env.Register("WASM_HTTP_REQUEST_MAX_RETRIES", wasm.DefaultHTTPRequestMaxRetries,
 		"maximum number of HTTP/HTTPS request retries for pulling a Wasm module via http/https").Get()
 
+	wasmNodeCacheAddress = env.Register("WASM_NODE_CACHE_ADDRESS", "",
+		"UDS address of the node level Wasm module cache, for example /var/run/istio-cni/wasm.sock. If set, the agent "+
+			"fetches the Wasm OCI images from the node level cache first, and from their registry if it fails").Get()
+
+	wasmVerificationKeys = env.Register("WASM_VERIFICATION_KEYS", "",
+		"comma separated paths of PEM encoded public keys. If set, the agent only loads the Wasm modules with a signature "+
+			"verified by one of the keys: the cosign signatures of OCI images, or the detached signature served at "+
+			"the module URL suffixed with '.sig' for http/https").Get()
+
 	// Ability of istio-agent to retrieve bootstrap via XDS
 	enableBootstrapXdsEnv = env.Register("BOOTSTRAP_XDS_AGENT", false,
 		"If set to true, agent retrieves the bootstrap configuration prior to starting Envoy").Get()
 
+	grpcReadinessProbe = env.Register("GRPC_READINESS_PROBE", "",
+		"If set, the readiness probe of the workload uses the gRPC health checking protocol (grpc.health.v1) "+
+			"instead of its tcpSocket or httpGet method. The value is a JSON object with the optional fields host, port, "+
+			"service, and tls with the serverName and caCertificates fields, for example "+
+			`{"service":"my.Service","tls":{"caCertificates":"/etc/certs/root-cert.pem"}}. `+
+			"The host and port default to the ones of the readiness probe. The port must be set for exec readiness probes, "+
+			"otherwise the gRPC probe is ignored.").Get()
+
 	envoyStatusPortEnv = env.Register("ENVOY_STATUS_PORT", 15021,
 		"Envoy health status port value").Get()
 	envoyPrometheusPortEnv = env.Register("ENVOY_PROMETHEUS_PORT", 15090,
@@ -16,6 +16,7 @@ package options
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -25,9 +26,11 @@ import (
 	"istio.io/istio/pkg/jwt"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/credentialfetcher"
+	"istio.io/istio/security/pkg/credentialfetcher/plugin"
 	"istio.io/istio/security/pkg/nodeagent/cafile"
 	"istio.io/istio/security/pkg/nodeagent/plugin/providers/google/stsclient"
 	"istio.io/istio/security/pkg/stsservice/tokenmanager"
+	"istio.io/istio/security/pkg/stsservice/tokenmanager/oauth2"
 	"istio.io/pkg/log"
 )
 
@@ -51,6 +54,7 @@ func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenM
 		SecretTTL:                      secretTTLEnv,
 		FileDebounceDuration:           fileDebounceDuration,
 		SecretRotationGracePeriodRatio: secretRotationGracePeriodRatioEnv,
+		SecretRotationJitterRatio:      secretRotationJitterRatioEnv,
 		STSPort:                        stsPort,
 		CertSigner:                     certSigner.Get(),
 		CARootPath:                     cafile.CACertFilePath,
@@ -67,9 +71,14 @@ func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenM
 
 	var tokenManager security.TokenManager
 	if stsPort > 0 || xdsAuthProvider.Get() != "" {
+		tmConfig := tokenmanager.Config{CredFetcher: o.CredFetcher, TrustDomain: o.TrustDomain}
+		if tokenManagerPlugin == tokenmanager.OAuth2TokenExchange {
+			if tmConfig.OAuth2, err = oauth2TokenManagerConfig(); err != nil {
+				return o, err
+			}
+		}
 		// tokenManager is gcp token manager when using the default token manager plugin.
-		tokenManager, err = tokenmanager.CreateTokenManager(tokenManagerPlugin,
-			tokenmanager.Config{CredFetcher: o.CredFetcher, TrustDomain: o.TrustDomain})
+		tokenManager, err = tokenmanager.CreateTokenManager(tokenManagerPlugin, tmConfig)
 	}
 	o.TokenManager = tokenManager
 
@@ -100,7 +109,8 @@ func SetupSecurityOptions(proxyConfig *meshconfig.ProxyConfig, secOpt *security.
 	}
 
 	o.CredIdentityProvider = credIdentityProvider
-	credFetcher, err := credentialfetcher.NewCredFetcher(credFetcherTypeEnv, o.TrustDomain, jwtPath, o.CredIdentityProvider)
+	credFetcher, err := credentialfetcher.NewCredFetcher(credFetcherTypeEnv, o.TrustDomain, jwtPath, o.CredIdentityProvider,
+		metadataCredFetcherConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create credential fetcher: %v", err)
 	}
@@ -142,3 +152,57 @@ func SetupSecurityOptions(proxyConfig *meshconfig.ProxyConfig, secOpt *security.
 	}
 	return o, nil
 }
+
+// metadataCredFetcherConfig builds the configuration of the Metadata credential fetcher from the environment.
+func metadataCredFetcherConfig() plugin.MetadataConfig {
+	headers := map[string]string{}
+	for _, h := range strings.Split(credFetcherMetadataHeaders, ",") {
+		if h == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(h, "=")
+		if !ok {
+			log.Warnf("Invalid CREDENTIAL_FETCHER_METADATA_HEADERS, ignoring: %v", h)
+			continue
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return plugin.MetadataConfig{
+		Address:        credFetcherMetadataAddress,
+		Path:           credFetcherMetadataPath,
+		Headers:        headers,
+		TokenField:     credFetcherMetadataTokenField,
+		ExpiresInField: credFetcherMetadataExpiresInField,
+	}
+}
+
+// oauth2TokenManagerConfig builds the configuration of the OAuth2TokenExchange token manager from the environment.
+func oauth2TokenManagerConfig() (oauth2.Config, error) {
+	cfg := oauth2.Config{
+		TokenEndpoint:    stsOAuth2TokenEndpoint,
+		ClientID:         stsOAuth2ClientID,
+		ClientAuthMethod: stsOAuth2ClientAuthMethod,
+		Scopes:           splitList(stsOAuth2Scopes),
+		Audiences:        splitList(stsOAuth2Audiences),
+		CACertPath:       stsOAuth2CACertFile,
+		EnableCache:      true,
+	}
+	if stsOAuth2ClientSecretFile != "" {
+		secret, err := os.ReadFile(stsOAuth2ClientSecretFile)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read OAuth 2.0 client secret: %v", err)
+		}
+		cfg.ClientSecret = strings.TrimSpace(string(secret))
+	}
+	return cfg, nil
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -300,6 +300,10 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	}
 
 	s.XDSServer.InitGenerators(e, args.Namespace, s.internalDebugMux)
+	if s.kubeClient != nil {
+		// Record the Envoy crashes reported by the proxies as events on their pods.
+		s.XDSServer.EnvoyCrashRecorder = xds.NewKubeEnvoyCrashRecorder(s.kubeClient.Kube(), s.clusterID, args.PodName)
+	}
 
 	// Initialize workloadTrustBundle after CA has been initialized
 	if err := s.initWorkloadTrustBundle(args); err != nil {
@@ -76,10 +76,11 @@ func (s *Server) initSidecarInjector(args *PilotArgs) (*inject.Webhook, error) {
 	log.Info("initializing sidecar injector")
 
 	parameters := inject.WebhookParameters{
-		Watcher:  watcher,
-		Env:      s.environment,
-		Mux:      s.httpsMux,
-		Revision: args.Revision,
+		Watcher:    watcher,
+		Env:        s.environment,
+		Mux:        s.httpsMux,
+		Revision:   args.Revision,
+		KubeClient: s.kubeClient,
 	}
 
 	wh, err := inject.NewWebhook(parameters)
@@ -435,6 +435,14 @@ var (
 		return res
 	}()
 
+	CAIssuancePolicyFile = env.Register(
+		"CA_ISSUANCE_POLICY_FILE",
+		"",
+		"If set, the path to a certificate issuance policy file for the Istiod CA. The policy can cap certificate TTLs "+
+			"per namespace, allow or deny identities by service account, and restrict which callers may impersonate "+
+			"other identities.",
+	).Get()
+
 	EnableServiceEntrySelectPods = env.Register("PILOT_ENABLE_SERVICEENTRY_SELECT_PODS", true,
 		"If enabled, service entries with selectors will select pods from the cluster. "+
 			"It is safe to disable it if you are quite sure you don't need this feature").Get()
@@ -446,6 +454,12 @@ var (
 	InjectionWebhookConfigName = env.Register("INJECTION_WEBHOOK_CONFIG_NAME", "istio-sidecar-injector",
 		"Name of the mutatingwebhookconfiguration to patch, if istioctl is not used.").Get()
 
+	EnableNativeSidecars = env.Register("ENABLE_NATIVE_SIDECARS", false,
+		"If enabled, the istio-proxy container is injected as a Kubernetes native sidecar, an init container with "+
+			"restartPolicy: Always, instead of a regular container. Requires the SidecarContainers feature of Kubernetes, "+
+			"it is ignored by the injection webhook on clusters older than Kubernetes 1.29. The proxy of the pods without "+
+			"other containers, such as gateways, is still injected as a regular container.").Get()
+
 	ValidationWebhookConfigName = env.Register("VALIDATION_WEBHOOK_CONFIG_NAME", "istio-istio-system",
 		"If not empty, the controller will automatically patch validatingwebhookconfiguration when the CA certificate changes. "+
 			"Only works in kubernetes environment.").Get()
@@ -597,6 +611,10 @@ var (
 	MulticlusterHeadlessEnabled = env.Register("ENABLE_MULTICLUSTER_HEADLESS", true,
 		"If true, the DNS name table for a headless service will resolve to same-network endpoints in any cluster.").Get()
 
+	EnableDNSSRVAndPTRRecords = env.Register("PILOT_ENABLE_DNS_SRV_PTR_RECORDS", false,
+		"If true, the DNS name table sent to the proxies includes SRV records for the named ports of the services, "+
+			"and PTR records for the service and workload IPs, resolved by the DNS proxy of the agent.").Get()
+
 	ResolveHostnameGateways = env.Register("RESOLVE_HOSTNAME_GATEWAYS", true,
 		"If true, hostnames in the LoadBalancer addresses of a Service will be resolved at the control plane for use in cross-network gateways.").Get()
 
@@ -49,6 +49,40 @@ const (
 	WasmResourceVersionEnv = "ISTIO_META_WASM_PLUGIN_RESOURCE_VERSION"
 )
 
+// Sources of the Wasm modules loaded by the proxies.
+const (
+	// WasmModuleSourceCache is set when the module is found in the local cache of the agent.
+	WasmModuleSourceCache = "cache"
+	// WasmModuleSourceNodeCache is set when the module is fetched from the node level cache.
+	WasmModuleSourceNodeCache = "node-cache"
+	// WasmModuleSourceRemote is set when the module is fetched from its URL.
+	WasmModuleSourceRemote = "remote"
+)
+
+// WasmModuleStatus is the status of the Wasm module of a WasmPlugin, reported by the agent fetching it.
+type WasmModuleStatus struct {
+	// ResourceName is the name of the ECDS resource of the WasmPlugin, <namespace>.<name>.
+	ResourceName string `json:"resourceName"`
+	// URL of the module.
+	URL string `json:"url,omitempty"`
+	// Digest is the hex encoded sha256 checksum of the module, or the manifest digest of OCI images.
+	Digest string `json:"digest,omitempty"`
+	// Size of the module in bytes.
+	Size int64 `json:"size,omitempty"`
+	// Source the module is loaded from.
+	Source string `json:"source,omitempty"`
+	// Error is the reason the module could not be loaded, if any.
+	Error string `json:"error,omitempty"`
+	// AllowAll is true if an allow-all filter replaced the module which could not be loaded, as the plugin fails open.
+	AllowAll bool `json:"allowAll,omitempty"`
+}
+
+// WasmPluginFromResourceName returns the namespace and name of the WasmPlugin of an ECDS resource name.
+func WasmPluginFromResourceName(resourceName string) (namespace, name string, ok bool) {
+	namespace, name, ok = strings.Cut(resourceName, ".")
+	return namespace, name, ok && namespace != "" && name != ""
+}
+
 func workloadModeForListenerClass(class istionetworking.ListenerClass) typeapi.WorkloadMode {
 	switch class {
 	case istionetworking.ListenerClassGateway:
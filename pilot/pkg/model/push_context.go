@@ -891,6 +891,11 @@ func (ps *PushContext) GetAllServices() []*Service {
 	return ps.servicesExportedToNamespace(NamespaceAll)
 }
 
+// GetPublicServices returns the services visible from all the namespaces.
+func (ps *PushContext) GetPublicServices() []*Service {
+	return ps.ServiceIndex.public
+}
+
 // ServiceForHostname returns the service associated with a given hostname following SidecarScope
 func (ps *PushContext) ServiceForHostname(proxy *Proxy, hostname host.Name) *Service {
 	if proxy != nil && proxy.SidecarScope != nil {
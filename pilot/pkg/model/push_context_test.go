@@ -777,6 +777,7 @@ func TestServiceIndex(t *testing.T) {
 	g.Expect(serviceNames(si.exportedToNamespace["namespace"])).To(Equal([]string{"svc-namespace"}))
 
 	g.Expect(serviceNames(si.public)).To(Equal([]string{"svc-public", "svc-unset"}))
+	g.Expect(serviceNames(pc.GetPublicServices())).To(Equal([]string{"svc-public", "svc-unset"}))
 
 	// Should just have "test1"
 	g.Expect(si.privateByNamespace).To(HaveLen(1))
@@ -29,5 +29,6 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 		Node:                        node,
 		Push:                        push,
 		MulticlusterHeadlessEnabled: features.MulticlusterHeadlessEnabled,
+		SRVAndPTREnabled:            features.EnableDNSSRVAndPTRRecords,
 	})
 }
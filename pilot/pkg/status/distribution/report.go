@@ -22,6 +22,26 @@ type Report struct {
 	Reporter            string         `json:"reporter"`
 	DataPlaneCount      int            `json:"dataPlaneCount"`
 	InProgressResources map[string]int `json:"inProgressResources"`
+	// WasmPlugins is the progress of the Wasm module of each WasmPlugin, by ECDS resource name.
+	// It is omitted when empty, so the reports of older istiods are unchanged.
+	WasmPlugins map[string]WasmModuleProgress `json:"wasmPlugins,omitempty" yaml:",omitempty"`
+}
+
+// WasmModuleProgress counts the proxies which loaded the Wasm module of a WasmPlugin.
+type WasmModuleProgress struct {
+	LoadedInstances int `json:"loadedInstances"`
+	TotalInstances  int `json:"totalInstances"`
+	// LastError is the error of one of the proxies which could not load the module: the lexically smallest one,
+	// so it does not depend on the order the progresses are summed and the status is not rewritten needlessly.
+	LastError string `json:"lastError,omitempty"`
+}
+
+func (p *WasmModuleProgress) PlusEquals(p2 WasmModuleProgress) {
+	p.LoadedInstances += p2.LoadedInstances
+	p.TotalInstances += p2.TotalInstances
+	if p2.LastError != "" && (p.LastError == "" || p2.LastError < p.LastError) {
+		p.LastError = p2.LastError
+	}
 }
 
 func ReportFromYaml(content []byte) (Report, error) {
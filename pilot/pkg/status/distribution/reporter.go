@@ -28,6 +28,7 @@ import (
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/utils/clock"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/status"
 	"istio.io/istio/pilot/pkg/xds"
 	"istio.io/istio/pkg/config"
@@ -63,6 +64,8 @@ type Reporter struct {
 	ledger                 ledger.Ledger
 	distributionEventQueue chan distributionEvent
 	controller             *Controller
+	// map from connection id to the status of the Wasm modules loaded by the dataplane
+	wasmModules map[string][]model.WasmModuleStatus
 }
 
 var _ xds.DistributionStatusCache = &Reporter{}
@@ -83,6 +86,7 @@ func (r *Reporter) Init(ledger ledger.Ledger, stop <-chan struct{}) {
 	r.status = make(map[string]string)
 	r.reverseStatus = make(map[string]sets.String)
 	r.inProgressResources = make(map[string]*inProgressEntry)
+	r.wasmModules = make(map[string][]model.WasmModuleStatus)
 	go r.readFromEventQueue(stop)
 }
 
@@ -137,6 +141,7 @@ func (r *Reporter) buildReport() (Report, []status.Resource) {
 		Reporter:            r.PodName,
 		DataPlaneCount:      len(r.status),
 		InProgressResources: map[string]int{},
+		WasmPlugins:         r.buildWasmModuleProgress(),
 	}
 	// for every resource in flight
 	for _, ipr := range r.inProgressResources {
@@ -172,6 +177,29 @@ func (r *Reporter) buildReport() (Report, []status.Resource) {
 	return out, finishedResources
 }
 
+// buildWasmModuleProgress counts the dataplanes which loaded the Wasm module of each WasmPlugin.
+// must have read lock before calling.
+func (r *Reporter) buildWasmModuleProgress() map[string]WasmModuleProgress {
+	if len(r.wasmModules) == 0 {
+		return nil
+	}
+	out := map[string]WasmModuleProgress{}
+	for _, modules := range r.wasmModules {
+		for _, m := range modules {
+			p := WasmModuleProgress{TotalInstances: 1}
+			if m.Error == "" {
+				p.LoadedInstances = 1
+			} else {
+				p.LastError = m.Error
+			}
+			progress := out[m.ResourceName]
+			progress.PlusEquals(p)
+			out[m.ResourceName] = progress
+		}
+	}
+	return out
+}
+
 // For efficiency, we don't want to be checking on resources that have already reached 100% distribution.
 // When this happens, we remove them from our watch list.
 func (r *Reporter) removeCompletedResource(completedResources []status.Resource) {
@@ -327,10 +355,22 @@ func (r *Reporter) deleteKeyFromReverseMap(key string) {
 	}
 }
 
+// RegisterWasmModuleStatus records the status of the Wasm modules loaded by a dataplane.
+func (r *Reporter) RegisterWasmModuleStatus(conID string, modules []model.WasmModuleStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(modules) == 0 {
+		delete(r.wasmModules, conID)
+		return
+	}
+	r.wasmModules[conID] = modules
+}
+
 // RegisterDisconnect : when a dataplane disconnects, we should no longer count it, nor expect it to ack config.
 func (r *Reporter) RegisterDisconnect(conID string, types []xds.EventType) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	delete(r.wasmModules, conID)
 	for _, xdsType := range types {
 		key := GenStatusReporterMapKey(conID, xdsType)
 		r.deleteKeyFromReverseMap(key)
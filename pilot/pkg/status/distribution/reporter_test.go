@@ -21,6 +21,7 @@ import (
 	. "github.com/onsi/gomega"
 	"k8s.io/utils/clock"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/status"
 	"istio.io/istio/pilot/pkg/xds"
 	"istio.io/istio/pkg/config"
@@ -48,6 +49,38 @@ func TestStatusMaps(t *testing.T) {
 	Expect(r.reverseStatus).To(Equal(map[string]sets.String{"a": {"conB~": x}, "c": {"conC~": x}, "d": {"conD~": x}}))
 }
 
+func TestBuildReportWasmModules(t *testing.T) {
+	RegisterTestingT(t)
+	r := initReporterWithoutStarting()
+	r.ledger = ledger.Make(time.Minute)
+	r.RegisterWasmModuleStatus("conA", []model.WasmModuleStatus{
+		{ResourceName: "default.foo", Digest: "digest"},
+		{ResourceName: "default.bar", Error: "checksum mismatch"},
+	})
+	r.RegisterWasmModuleStatus("conB", []model.WasmModuleStatus{{ResourceName: "default.foo", Digest: "digest"}})
+	r.RegisterWasmModuleStatus("conC", []model.WasmModuleStatus{{ResourceName: "default.foo", Error: "fetch failure"}})
+	r.RegisterWasmModuleStatus("conD", []model.WasmModuleStatus{{ResourceName: "default.foo", Error: "timeout"}})
+	for i := 0; i < 10; i++ {
+		// The error does not depend on the iteration order of the dataplanes.
+		report, _ := r.buildReport()
+		Expect(report.WasmPlugins["default.foo"].LastError).To(Equal("fetch failure"))
+	}
+	r.RegisterDisconnect("conD", xds.AllEventTypesList)
+	report, _ := r.buildReport()
+	Expect(report.WasmPlugins).To(Equal(map[string]WasmModuleProgress{
+		"default.foo": {LoadedInstances: 2, TotalInstances: 3, LastError: "fetch failure"},
+		"default.bar": {LoadedInstances: 0, TotalInstances: 1, LastError: "checksum mismatch"},
+	}))
+
+	// The modules of disconnected dataplanes are no longer counted.
+	r.RegisterDisconnect("conC", xds.AllEventTypesList)
+	r.RegisterWasmModuleStatus("conA", nil)
+	report, _ = r.buildReport()
+	Expect(report.WasmPlugins).To(Equal(map[string]WasmModuleProgress{
+		"default.foo": {LoadedInstances: 1, TotalInstances: 1},
+	}))
+}
+
 func initReporterWithoutStarting() (out Reporter) {
 	out.PodName = "tespod"
 	out.inProgressResources = map[string]*inProgressEntry{}
@@ -57,6 +90,7 @@ func initReporterWithoutStarting() (out Reporter) {
 	out.cm = nil // TODO
 	out.reverseStatus = make(map[string]sets.String)
 	out.status = make(map[string]string)
+	out.wasmModules = make(map[string][]model.WasmModuleStatus)
 	return
 }
 
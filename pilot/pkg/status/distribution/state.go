@@ -16,6 +16,7 @@ package distribution
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +37,8 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/status"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/gvr"
 	"istio.io/pkg/log"
 )
 
@@ -64,6 +67,9 @@ type Controller struct {
 	StaleInterval   time.Duration
 	cmInformer      cache.SharedIndexInformer
 	cmHandle        cache.ResourceEventHandlerRegistration
+	// WasmState is the progress of the Wasm module of each WasmPlugin by reporter, by ECDS resource name.
+	WasmState   map[string]map[string]WasmModuleProgress
+	wasmWorkers *status.Controller
 }
 
 func NewController(restConfig *rest.Config, namespace string, cs model.ConfigStore, m *status.Manager) *Controller {
@@ -84,6 +90,16 @@ func NewController(restConfig *rest.Config, namespace string, cs model.ConfigSto
 			}
 			return status
 		}),
+		WasmState: make(map[string]map[string]WasmModuleProgress),
+		wasmWorkers: m.CreateIstioStatusController(func(status *v1alpha1.IstioStatus, context any) *v1alpha1.IstioStatus {
+			if status == nil {
+				return nil
+			}
+			if needsReconcile, desiredStatus := ReconcileWasmModuleStatus(status, context.(WasmModuleProgress)); needsReconcile {
+				return desiredStatus
+			}
+			return status
+		}),
 	}
 
 	// client-go defaults to 5 QPS, with 10 Boost, which is insufficient for updating status on all the config
@@ -140,6 +156,21 @@ func (c *Controller) handleReport(d Report) {
 		}
 		c.CurrentState[res][d.Reporter] = Progress{d.InProgressResources[resstr], d.DataPlaneCount}
 	}
+	// The report holds all the WasmPlugins loaded by the dataplanes of the reporter.
+	for resourceName, fractions := range c.WasmState {
+		if _, ok := d.WasmPlugins[resourceName]; !ok {
+			delete(fractions, d.Reporter)
+		}
+		if len(fractions) == 0 {
+			delete(c.WasmState, resourceName)
+		}
+	}
+	for resourceName, progress := range d.WasmPlugins {
+		if _, ok := c.WasmState[resourceName]; !ok {
+			c.WasmState[resourceName] = make(map[string]WasmModuleProgress)
+		}
+		c.WasmState[resourceName][d.Reporter] = progress
+	}
 	c.ObservationTime[d.Reporter] = c.clock.Now()
 }
 
@@ -166,6 +197,19 @@ func (c *Controller) writeAllStatus() (staleReporters []string) {
 			c.queueWriteStatus(config, distributionState)
 		}
 	}
+	for resourceName, fractions := range c.WasmState {
+		var progress WasmModuleProgress
+		for reporter, w := range fractions {
+			if c.clock.Since(c.ObservationTime[reporter]) > c.StaleInterval {
+				staleReporters = append(staleReporters, reporter)
+			} else {
+				progress.PlusEquals(w)
+			}
+		}
+		if progress.TotalInstances > 0 {
+			c.queueWriteWasmModuleStatus(resourceName, progress)
+		}
+	}
 	return
 }
 
@@ -178,15 +222,46 @@ func (c *Controller) removeStaleReporters(staleReporters []string) {
 		}
 		c.CurrentState[key] = fractions
 	}
+	for key, fractions := range c.WasmState {
+		for _, staleReporter := range staleReporters {
+			delete(fractions, staleReporter)
+		}
+		if len(fractions) == 0 {
+			delete(c.WasmState, key)
+		}
+	}
 }
 
 func (c *Controller) queueWriteStatus(config status.Resource, state Progress) {
 	c.workers.EnqueueStatusUpdateResource(state, config)
 }
 
+// queueWriteWasmModuleStatus writes the progress of the Wasm module in the status of the current generation of the WasmPlugin.
+func (c *Controller) queueWriteWasmModuleStatus(resourceName string, progress WasmModuleProgress) {
+	namespace, name, ok := model.WasmPluginFromResourceName(resourceName)
+	if !ok {
+		return
+	}
+	cfg := c.configStore.Get(gvk.WasmPlugin, name, namespace)
+	if cfg == nil {
+		return
+	}
+	c.wasmWorkers.EnqueueStatusUpdateResource(progress, status.Resource{
+		GroupVersionResource: gvr.WasmPlugin,
+		Namespace:            namespace,
+		Name:                 name,
+		Generation:           strconv.FormatInt(cfg.Generation, 10),
+	})
+}
+
 func (c *Controller) configDeleted(res config.Config) {
 	r := status.ResourceFromModelConfig(res)
 	c.workers.Delete(r)
+	if res.GroupVersionKind == gvk.WasmPlugin {
+		c.mu.Lock()
+		delete(c.WasmState, res.Namespace+"."+res.Name)
+		c.mu.Unlock()
+	}
 }
 
 func boolToConditionStatus(b bool) string {
@@ -197,19 +272,39 @@ func boolToConditionStatus(b bool) string {
 }
 
 func ReconcileStatuses(current *v1alpha1.IstioStatus, desired Progress) (bool, *v1alpha1.IstioStatus) {
-	needsReconcile := false
-	desiredCondition := v1alpha1.IstioCondition{
+	return reconcileCondition(current, &v1alpha1.IstioCondition{
 		Type:               "Reconciled",
 		Status:             boolToConditionStatus(desired.AckedInstances == desired.TotalInstances),
 		LastProbeTime:      timestamppb.Now(),
 		LastTransitionTime: timestamppb.Now(),
 		Message:            fmt.Sprintf("%d/%d proxies up to date.", desired.AckedInstances, desired.TotalInstances),
+	})
+}
+
+// ReconcileWasmModuleStatus sets the WasmModulesLoaded condition of a WasmPlugin, counting the proxies
+// which loaded its Wasm module.
+func ReconcileWasmModuleStatus(current *v1alpha1.IstioStatus, desired WasmModuleProgress) (bool, *v1alpha1.IstioStatus) {
+	message := fmt.Sprintf("%d/%d proxies loaded the Wasm module.", desired.LoadedInstances, desired.TotalInstances)
+	if desired.LoadedInstances < desired.TotalInstances && desired.LastError != "" {
+		message += " Last error: " + desired.LastError
 	}
+	return reconcileCondition(current, &v1alpha1.IstioCondition{
+		Type:               "WasmModulesLoaded",
+		Status:             boolToConditionStatus(desired.LoadedInstances == desired.TotalInstances),
+		LastProbeTime:      timestamppb.Now(),
+		LastTransitionTime: timestamppb.Now(),
+		Message:            message,
+	})
+}
+
+// reconcileCondition sets the desired condition in the status, and returns true if it changed.
+func reconcileCondition(current *v1alpha1.IstioStatus, desiredCondition *v1alpha1.IstioCondition) (bool, *v1alpha1.IstioStatus) {
+	needsReconcile := false
 	current = current.DeepCopy()
 	var currentCondition *v1alpha1.IstioCondition
 	conditionIndex := -1
 	for i, c := range current.Conditions {
-		if c.Type == "Reconciled" {
+		if c.Type == desiredCondition.Type {
 			currentCondition = current.Conditions[i]
 			conditionIndex = i
 			break
@@ -221,9 +316,9 @@ func ReconcileStatuses(current *v1alpha1.IstioStatus, desired Progress) (bool, *
 		needsReconcile = true
 	}
 	if conditionIndex > -1 {
-		current.Conditions[conditionIndex] = &desiredCondition
+		current.Conditions[conditionIndex] = desiredCondition
 	} else {
-		current.Conditions = append(current.Conditions, &desiredCondition)
+		current.Conditions = append(current.Conditions, desiredCondition)
 	}
 	return needsReconcile, current
 }
@@ -145,6 +145,71 @@ func TestReconcileStatuses(t *testing.T) {
 	}
 }
 
+func TestReconcileWasmModuleStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     *v1alpha1.IstioStatus
+		desired     WasmModuleProgress
+		want        bool
+		wantStatus  string
+		wantMessage string
+		// the other conditions are kept
+		wantConditions int
+	}{
+		{
+			name:           "all proxies loaded the module",
+			current:        statusStillPropagating,
+			desired:        WasmModuleProgress{LoadedInstances: 2, TotalInstances: 2},
+			want:           true,
+			wantStatus:     "True",
+			wantMessage:    "2/2 proxies loaded the Wasm module.",
+			wantConditions: 3,
+		},
+		{
+			name:           "proxies failed to load the module",
+			current:        statusStillPropagating,
+			desired:        WasmModuleProgress{LoadedInstances: 1, TotalInstances: 2, LastError: "checksum mismatch"},
+			want:           true,
+			wantStatus:     "False",
+			wantMessage:    "1/2 proxies loaded the Wasm module. Last error: checksum mismatch",
+			wantConditions: 3,
+		},
+		{
+			name: "no change",
+			current: &v1alpha1.IstioStatus{
+				Conditions: []*v1alpha1.IstioCondition{{
+					Type:    "WasmModulesLoaded",
+					Status:  "True",
+					Message: "2/2 proxies loaded the Wasm module.",
+				}},
+			},
+			desired:        WasmModuleProgress{LoadedInstances: 2, TotalInstances: 2},
+			want:           false,
+			wantStatus:     "True",
+			wantMessage:    "2/2 proxies loaded the Wasm module.",
+			wantConditions: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, got1 := ReconcileWasmModuleStatus(tt.current, tt.desired)
+			assert.Equal(t, got, tt.want)
+			var condition *v1alpha1.IstioCondition
+			for _, c := range got1.Conditions {
+				if c.Type == "WasmModulesLoaded" {
+					condition = c
+				}
+			}
+			if condition == nil {
+				t.Fatalf("missing WasmModulesLoaded condition in %v", got1)
+			}
+			assert.Equal(t, condition.Status, tt.wantStatus)
+			assert.Equal(t, condition.Message, tt.wantMessage)
+			assert.Equal(t, len(got1.Conditions), tt.wantConditions)
+		})
+	}
+}
+
 func Test_getTypedStatus(t *testing.T) {
 	x := v1alpha1.IstioStatus{}
 	b, _ := json.Marshal(statusStillPropagating)
@@ -23,6 +23,7 @@ import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	uatomic "go.uber.org/atomic"
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
@@ -37,6 +38,7 @@ import (
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/wasm"
 	"istio.io/pkg/env"
 	istiolog "istio.io/pkg/log"
 )
@@ -106,6 +108,12 @@ type Connection struct {
 
 	// errorChan is used to process error during discovery request processing.
 	errorChan chan error
+
+	// wasmModules is the status of the Wasm modules last reported by the proxy, protected by the proxy lock.
+	wasmModules []model.WasmModuleStatus
+
+	// envoyCrashReported is set once the proxy reported a crash of its Envoy on the connection.
+	envoyCrashReported bool
 }
 
 // Event represents a config or registry event that results in a push.
@@ -195,6 +203,14 @@ func (s *DiscoveryServer) processRequest(req *discovery.DiscoveryRequest, con *C
 		s.handleWorkloadHealthcheck(con.proxy, req)
 		return nil
 	}
+	if req.TypeUrl == v3.WasmModuleStatusType {
+		s.handleWasmModuleStatus(con, req.ErrorDetail)
+		return nil
+	}
+	if req.TypeUrl == v3.EnvoyCrashType {
+		s.handleEnvoyCrash(con, req.ErrorDetail)
+		return nil
+	}
 
 	// For now, don't let xDS piggyback debug requests start watchers.
 	if strings.HasPrefix(req.TypeUrl, v3.DebugType) {
@@ -721,6 +737,21 @@ func (s *DiscoveryServer) handleWorkloadHealthcheck(proxy *model.Proxy, req *dis
 	}
 }
 
+// handleWasmModuleStatus processes WasmModuleStatus type Url.
+func (s *DiscoveryServer) handleWasmModuleStatus(con *Connection, detail *google_rpc.Status) {
+	modules, err := wasm.ModuleStatusFromDetail(detail)
+	if err != nil {
+		log.Warnf("ADS: %s sent invalid Wasm module status: %v", con.conID, err)
+		return
+	}
+	con.proxy.Lock()
+	con.wasmModules = modules
+	con.proxy.Unlock()
+	if s.StatusReporter != nil {
+		s.StatusReporter.RegisterWasmModuleStatus(con.conID, con.WasmModules())
+	}
+}
+
 // DeltaAggregatedResources is not implemented.
 // Instead, Generators may send only updates/add, with Delete indicated by an empty spec.
 // This works if both ends follow this model. For example EDS and the API generator follow this
@@ -977,6 +1008,24 @@ func (conn *Connection) Watched(typeUrl string) *model.WatchedResource {
 	return nil
 }
 
+// WasmModules returns the status of the Wasm modules reported by the proxy, for the ECDS resources it watches.
+func (conn *Connection) WasmModules() []model.WasmModuleStatus {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	var watched sets.String
+	if w := conn.proxy.WatchedResources[v3.ExtensionConfigurationType]; w != nil {
+		watched = sets.New(w.ResourceNames...)
+	}
+	var out []model.WasmModuleStatus
+	for _, m := range conn.wasmModules {
+		// The agent keeps reporting the modules of the resources no longer watched.
+		if watched.Contains(m.ResourceName) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
 // pushDetails returns the details needed for current push. It returns ordered list of
 // watched resources for the proxy, ordered in accordance with known push order.
 // It also returns the lis of typeUrls.
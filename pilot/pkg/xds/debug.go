@@ -606,9 +606,22 @@ func (s *DiscoveryServer) ecdsz(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	if req.URL.Query().Get("include_status") == "true" {
+		writeJSON(w, ecdsStatus{
+			Resources:   dump[v3.ExtensionConfigurationType],
+			WasmModules: con.WasmModules(),
+		}, req)
+		return
+	}
 	writeJSON(w, dump[v3.ExtensionConfigurationType], req)
 }
 
+// ecdsStatus is the output of /debug/ecdsz including the status of the Wasm modules reported by the proxy.
+type ecdsStatus struct {
+	Resources   []*discoveryv3.Resource  `json:"resources"`
+	WasmModules []model.WasmModuleStatus `json:"wasmModules"`
+}
+
 // ConfigDump returns information in the form of the Envoy admin API config dump for the specified proxy
 // The dump will only contain dynamic listeners/clusters/routes and can be used to compare what an Envoy instance
 // should look like according to Pilot vs what it currently does look like.
@@ -271,6 +271,14 @@ func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryReque
 		s.handleWorkloadHealthcheck(con.proxy, deltaToSotwRequest(req))
 		return nil
 	}
+	if req.TypeUrl == v3.WasmModuleStatusType {
+		s.handleWasmModuleStatus(con, req.ErrorDetail)
+		return nil
+	}
+	if req.TypeUrl == v3.EnvoyCrashType {
+		s.handleEnvoyCrash(con, req.ErrorDetail)
+		return nil
+	}
 	if strings.HasPrefix(req.TypeUrl, v3.DebugType) {
 		return s.pushXds(con,
 			&model.WatchedResource{TypeUrl: req.TypeUrl, ResourceNames: req.ResourceNamesSubscribe},
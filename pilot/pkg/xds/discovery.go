@@ -122,6 +122,9 @@ type DiscoveryServer struct {
 
 	StatusReporter DistributionStatusCache
 
+	// EnvoyCrashRecorder records the Envoy crashes reported by the proxies, if set.
+	EnvoyCrashRecorder EnvoyCrashRecorder
+
 	// Authenticators for XDS requests. Should be same/subset of the CA authenticators.
 	Authenticators []security.Authenticator
 
@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+)
+
+const (
+	// EnvoyCrashEventReason is the reason of the Kubernetes events recorded for the Envoy crashes.
+	EnvoyCrashEventReason = "EnvoyCrashed"
+
+	// maxEnvoyCrashMessageLength limits the size of the crash messages reported by the agents.
+	maxEnvoyCrashMessageLength = 1024
+	envoyCrashEventTimeout     = 5 * time.Second
+)
+
+// EnvoyCrashRecorder records the crashes of Envoy reported by the agents of the proxies.
+type EnvoyCrashRecorder interface {
+	RecordEnvoyCrash(proxy *model.Proxy, message string)
+}
+
+// handleEnvoyCrash records the crash of Envoy reported by the agent of the proxy. The agent reports a crash once,
+// on the first connection following it, so the other reports of the connection are ignored and a proxy cannot
+// flood the recorder.
+func (s *DiscoveryServer) handleEnvoyCrash(con *Connection, detail *google_rpc.Status) {
+	if s.EnvoyCrashRecorder == nil || detail == nil {
+		return
+	}
+	if con.envoyCrashReported {
+		log.Debugf("ADS: %s ignoring repeated Envoy crash report", con.conID)
+		return
+	}
+	con.envoyCrashReported = true
+	message := detail.Message
+	if len(message) > maxEnvoyCrashMessageLength {
+		message = message[:maxEnvoyCrashMessageLength]
+	}
+	log.Infof("ADS: %s reported an Envoy crash: %s", con.conID, message)
+	s.EnvoyCrashRecorder.RecordEnvoyCrash(con.proxy, message)
+}
+
+// KubeEnvoyCrashRecorder records the Envoy crashes as Kubernetes events on the pods of the proxies. Only the
+// proxies running in the cluster of the client are recorded.
+type KubeEnvoyCrashRecorder struct {
+	client    kubernetes.Interface
+	clusterID cluster.ID
+	// host is the name of the istiod instance, recorded as the source of the events.
+	host string
+}
+
+func NewKubeEnvoyCrashRecorder(client kubernetes.Interface, clusterID cluster.ID, host string) *KubeEnvoyCrashRecorder {
+	return &KubeEnvoyCrashRecorder{client: client, clusterID: clusterID, host: host}
+}
+
+func (r *KubeEnvoyCrashRecorder) RecordEnvoyCrash(proxy *model.Proxy, message string) {
+	if proxy.Metadata == nil || proxy.Metadata.ClusterID != r.clusterID {
+		return
+	}
+	// The ID of the Kubernetes proxies is <pod>.<namespace>.
+	parts := strings.Split(proxy.ID, ".")
+	if len(parts) != 2 || parts[1] != proxy.Metadata.Namespace {
+		return
+	}
+	pod, namespace := parts[0], parts[1]
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named like the events of the client-go event recorder.
+			Name:      fmt.Sprintf("%v.%x", pod, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       pod,
+			Namespace:  namespace,
+			FieldPath:  "spec.containers{istio-proxy}",
+		},
+		Type:           corev1.EventTypeWarning,
+		Reason:         EnvoyCrashEventReason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: "istiod", Host: r.host},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	// Do not block the connection on the API server.
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), envoyCrashEventTimeout)
+		defer cancel()
+		if _, err := r.client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+			log.Warnf("failed to record Envoy crash event for pod %s/%s: %v", namespace, pod, err)
+		}
+	}()
+}
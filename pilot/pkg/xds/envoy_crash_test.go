@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestKubeEnvoyCrashRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	s := &DiscoveryServer{EnvoyCrashRecorder: NewKubeEnvoyCrashRecorder(client, "cluster", "istiod-1")}
+	listEvents := func(namespace string) []corev1.Event {
+		events, err := client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
+		assert.NoError(t, err)
+		return events.Items
+	}
+	newConnection := func(id, namespace string, clusterID cluster.ID) *Connection {
+		return &Connection{conID: id, proxy: &model.Proxy{
+			ID:       id,
+			Metadata: &model.NodeMetadata{Namespace: namespace, ClusterID: clusterID},
+		}}
+	}
+
+	con := newConnection("pod.default", "default", "cluster")
+	s.handleEnvoyCrash(con, &google_rpc.Status{Message: "Envoy exited unexpectedly (signal: segmentation fault)"})
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(listEvents("default")); n != 1 {
+			return fmt.Errorf("expected one event, got %d", n)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+	event := listEvents("default")[0]
+	assert.Equal(t, event.Reason, EnvoyCrashEventReason)
+	assert.Equal(t, event.Type, corev1.EventTypeWarning)
+	assert.Equal(t, event.InvolvedObject.Name, "pod")
+	assert.Equal(t, event.Message, "Envoy exited unexpectedly (signal: segmentation fault)")
+	assert.Equal(t, event.Source, corev1.EventSource{Component: "istiod", Host: "istiod-1"})
+
+	// A proxy reports a single crash per connection, and the messages are truncated.
+	s.handleEnvoyCrash(con, &google_rpc.Status{Message: "again"})
+	con = newConnection("pod.default", "default", "cluster")
+	s.handleEnvoyCrash(con, &google_rpc.Status{Message: strings.Repeat("x", 2*maxEnvoyCrashMessageLength)})
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(listEvents("default")); n != 2 {
+			return fmt.Errorf("expected two events, got %d", n)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+	for _, e := range listEvents("default") {
+		if e.Message != event.Message {
+			assert.Equal(t, len(e.Message), maxEnvoyCrashMessageLength)
+		}
+	}
+
+	// The proxies of other clusters, and the proxies which do not run in a pod, are not recorded.
+	s.handleEnvoyCrash(newConnection("pod.other", "other", "remote"), &google_rpc.Status{Message: "crash"})
+	s.handleEnvoyCrash(newConnection("vm", "other", "cluster"), &google_rpc.Status{Message: "crash"})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, len(listEvents("other")), 0)
+}
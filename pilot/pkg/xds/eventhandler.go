@@ -14,7 +14,10 @@
 
 package xds
 
-import v3 "istio.io/istio/pilot/pkg/xds/v3"
+import (
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
 
 // EventType represents the type of object we are tracking, mapping to envoy TypeUrl.
 type EventType = string
@@ -36,4 +39,6 @@ type DistributionStatusCache interface {
 	RegisterEvent(conID string, eventType EventType, nonce string)
 	RegisterDisconnect(s string, types []EventType)
 	QueryLastNonce(conID string, eventType EventType) (noncePrefix string)
+	// RegisterWasmModuleStatus notifies the implementer of the status of the Wasm modules loaded by a proxy
+	RegisterWasmModuleStatus(conID string, modules []model.WasmModuleStatus)
 }
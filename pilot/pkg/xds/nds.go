@@ -28,6 +28,8 @@ import (
 // The agent then updates its internal DNS based on this data. If DNS capture is enabled
 // in the pod the agent will capture all DNS requests and attempt to resolve locally before
 // forwarding to upstream dns servers.
+// Ztunnel is served the same name table, built from all the services of the mesh, to resolve
+// DNS for the ambient pods of its node.
 type NdsGenerator struct {
 	Server *DiscoveryServer
 }
@@ -33,6 +33,10 @@ const (
 	NameTableType   = resource.APITypePrefix + "istio.networking.nds.v1.NameTable"
 	HealthInfoType  = resource.APITypePrefix + "istio.v1.HealthInformation"
 	ProxyConfigType = resource.APITypePrefix + "istio.mesh.v1alpha1.ProxyConfig"
+	// WasmModuleStatusType reports the status of the Wasm modules loaded by the proxy to istiod.
+	WasmModuleStatusType = resource.APITypePrefix + "istio.v1.WasmModuleStatus"
+	// EnvoyCrashType reports a crash of the Envoy of the proxy to istiod, which records it as an event on its pod.
+	EnvoyCrashType = resource.APITypePrefix + "istio.v1.EnvoyCrash"
 	// DebugType requests debug info from istio, a secured implementation for istio debug interface.
 	DebugType                 = "istio.io/debug"
 	BootstrapType             = resource.APITypePrefix + "envoy.config.bootstrap.v3.Bootstrap"
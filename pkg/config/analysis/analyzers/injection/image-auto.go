@@ -96,6 +96,12 @@ func hasAutoImage(spec *v1.PodSpec) bool {
 			return true
 		}
 	}
+	// the proxy may also be injected as a native sidecar
+	for _, c := range spec.InitContainers {
+		if c.Name == istioProxyContainerName && c.Image == manualInjectionImage {
+			return true
+		}
+	}
 	return false
 }
 
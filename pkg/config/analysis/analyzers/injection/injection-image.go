@@ -119,19 +119,18 @@ func (a *ImageAnalyzer) Analyze(c analysis.Context) {
 			return true
 		}
 
-		for _, container := range pod.Containers {
-			if container.Name != util.IstioProxyName {
-				continue
-			}
+		container := util.FindIstioProxy(pod)
+		if container == nil {
+			return true
+		}
 
-			proxyImage, okImage := proxyImageMap[injectionCMName]
-			if !okImage {
-				return true
-			}
-			if container.Image != proxyImage {
-				namespaceMismatchedPods[r.Metadata.FullName.Namespace.String()] = append(
-					namespaceMismatchedPods[r.Metadata.FullName.Namespace.String()], r.Metadata.FullName.Name.String())
-			}
+		proxyImage, okImage := proxyImageMap[injectionCMName]
+		if !okImage {
+			return true
+		}
+		if container.Image != proxyImage {
+			namespaceMismatchedPods[r.Metadata.FullName.Namespace.String()] = append(
+				namespaceMismatchedPods[r.Metadata.FullName.Namespace.String()], r.Metadata.FullName.Name.String())
 		}
 
 		return true
@@ -153,11 +153,8 @@ func (a *Analyzer) Analyze(c analysis.Context) {
 		}
 
 		proxyImage := ""
-		for _, container := range pod.Containers {
-			if container.Name == util.IstioProxyName {
-				proxyImage = container.Image
-				break
-			}
+		if proxy := util.FindIstioProxy(pod); proxy != nil {
+			proxyImage = proxy.Image
 		}
 
 		if proxyImage == "" {
@@ -29,14 +29,14 @@ import (
 func DeploymentInMesh(r *resource.Instance, c analysis.Context) bool {
 	d := r.Message.(*appsv1.DeploymentSpec)
 	return inMesh(d.Template.Annotations, d.Template.Labels,
-		resource.Namespace(r.Metadata.FullName.Namespace.String()), d.Template.Spec.Containers, c)
+		resource.Namespace(r.Metadata.FullName.Namespace.String()), &d.Template.Spec, c)
 }
 
 // PodInMesh returns true if a Pod is in the service mesh (has sidecar)
 func PodInMesh(r *resource.Instance, c analysis.Context) bool {
 	p := r.Message.(*v1.PodSpec)
 	return inMesh(r.Metadata.Annotations, r.Metadata.Labels,
-		r.Metadata.FullName.Namespace, p.Containers, c)
+		r.Metadata.FullName.Namespace, p, c)
 }
 
 // PodInAmbientMode returns true if a Pod is in the service mesh with the ambient mode
@@ -44,9 +44,9 @@ func PodInAmbientMode(r *resource.Instance) bool {
 	return r.Metadata.Annotations[constants.AmbientRedirection] == constants.AmbientRedirectionEnabled
 }
 
-func inMesh(annos, labels map[string]string, namespace resource.Namespace, containers []v1.Container, c analysis.Context) bool {
+func inMesh(annos, labels map[string]string, namespace resource.Namespace, spec *v1.PodSpec, c analysis.Context) bool {
 	// If pod has the sidecar container set, then, the pod is in the mesh
-	if hasIstioProxy(containers) {
+	if hasIstioProxy(spec) {
 		return true
 	}
 
@@ -91,14 +91,24 @@ func getNamesSidecarInjectionStatus(ns resource.Namespace, c analysis.Context) (
 	return enabled, ok
 }
 
-func hasIstioProxy(containers []v1.Container) bool {
-	proxyImage := ""
-	for _, container := range containers {
-		if container.Name == IstioProxyName {
-			proxyImage = container.Image
-			break
+func hasIstioProxy(spec *v1.PodSpec) bool {
+	proxy := FindIstioProxy(spec)
+	return proxy != nil && proxy.Image != ""
+}
+
+// FindIstioProxy returns the istio-proxy container of the pod spec, or nil if there is none.
+// The proxy is looked up in both the containers and the init containers, as it may be
+// injected as a native sidecar.
+func FindIstioProxy(spec *v1.PodSpec) *v1.Container {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == IstioProxyName {
+			return &spec.Containers[i]
 		}
 	}
-
-	return proxyImage != ""
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == IstioProxyName {
+			return &spec.InitContainers[i]
+		}
+	}
+	return nil
 }
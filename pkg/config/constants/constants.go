@@ -56,6 +56,9 @@ const (
 	// IstioDataDir is the directory to store binary data such as envoy core dump, profile, and downloaded Wasm modules.
 	IstioDataDir = "/var/lib/istio/data"
 
+	// EnvoyCrashDir is the directory where istio-agent writes the crash bundles captured when Envoy exits unexpectedly.
+	EnvoyCrashDir = IstioDataDir + "/crash"
+
 	// BinaryPathFilename envoy binary location
 	BinaryPathFilename = "/usr/local/bin/envoy"
 
@@ -0,0 +1,206 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/miekg/dns"
+)
+
+// CacheOptions configures the cache of the responses of the upstream DNS servers.
+type CacheOptions struct {
+	// MaxEntries is the maximum number of cached responses, the least recently used ones are evicted beyond it.
+	// The cache is disabled if it is 0.
+	MaxEntries int
+	// MaxTTL caps the time a positive response is cached, its records are cached at most for their TTL.
+	MaxTTL time.Duration
+	// MaxNegativeTTL caps the time a NXDOMAIN or NODATA response is cached, which is at most the negative TTL
+	// of its SOA record (RFC 2308).
+	MaxNegativeTTL time.Duration
+}
+
+// cacheKey identifies the responses of the upstream servers. The EDNS0 options of the request are part of the key,
+// as the OPT record of the response and the DNSSEC records depend on them.
+type cacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+	edns   bool
+	do     bool
+}
+
+type cacheEntry struct {
+	msg      *dns.Msg
+	storedAt time.Time
+	expiry   time.Time
+	// maxTTL caps the TTL of the records of the response, in seconds.
+	maxTTL uint32
+}
+
+// responseCache caches the responses of the upstream servers for hostnames not found in the lookup table.
+type responseCache struct {
+	entries        *lru.Cache[cacheKey, *cacheEntry]
+	maxTTL         time.Duration
+	maxNegativeTTL time.Duration
+	// now is the clock of the cache, overridden in tests.
+	now func() time.Time
+}
+
+// newResponseCache returns nil if the cache is disabled.
+func newResponseCache(opts CacheOptions) (*responseCache, error) {
+	if opts.MaxEntries <= 0 {
+		return nil, nil
+	}
+	entries, err := lru.New[cacheKey, *cacheEntry](opts.MaxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &responseCache{
+		entries:        entries,
+		maxTTL:         opts.MaxTTL,
+		maxNegativeTTL: opts.MaxNegativeTTL,
+		now:            time.Now,
+	}, nil
+}
+
+func newCacheKey(req *dns.Msg) cacheKey {
+	q := req.Question[0]
+	key := cacheKey{
+		name:   strings.ToLower(q.Name),
+		qtype:  q.Qtype,
+		qclass: q.Qclass,
+	}
+	if o := req.IsEdns0(); o != nil {
+		key.edns = true
+		key.do = o.Do()
+	}
+	return key
+}
+
+// get returns a copy of the cached response to the request, with the TTLs of its records decremented by the time
+// it was cached, or nil.
+func (c *responseCache) get(req *dns.Msg) *dns.Msg {
+	key := newCacheKey(req)
+	entry, ok := c.entries.Get(key)
+	now := c.now()
+	if ok && !now.Before(entry.expiry) {
+		c.entries.Remove(key)
+		ok = false
+	}
+	if !ok {
+		cacheMisses.Increment()
+		return nil
+	}
+	cacheHits.Increment()
+	elapsed := uint32(now.Sub(entry.storedAt) / time.Second)
+	response := entry.msg.Copy()
+	response.Id = req.Id
+	response.Question = req.Question
+	for _, rrs := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+		for _, rr := range rrs {
+			h := rr.Header()
+			if h.Rrtype == dns.TypeOPT {
+				continue
+			}
+			if h.Ttl > entry.maxTTL {
+				h.Ttl = entry.maxTTL
+			}
+			if h.Ttl > elapsed {
+				h.Ttl -= elapsed
+			} else {
+				h.Ttl = 0
+			}
+		}
+	}
+	return response
+}
+
+// add caches the response of the upstream servers to the request, if it is cacheable.
+func (c *responseCache) add(req *dns.Msg, response *dns.Msg) {
+	ttl := c.ttl(response)
+	if ttl <= 0 {
+		return
+	}
+	now := c.now()
+	c.entries.Add(newCacheKey(req), &cacheEntry{
+		msg:      response.Copy(),
+		storedAt: now,
+		expiry:   now.Add(ttl),
+		maxTTL:   uint32(ttl / time.Second),
+	})
+}
+
+// ttl returns the time the response can be cached, or 0 if it must not be cached.
+func (c *responseCache) ttl(response *dns.Msg) time.Duration {
+	// Truncated responses are retried over TCP by the clients, and server failures are not cached.
+	if response.Truncated || len(response.Question) != 1 {
+		return 0
+	}
+	// Responses tailored to the EDNS0 client subnet of the request are only valid for that subnet (RFC 7871).
+	if o := response.IsEdns0(); o != nil {
+		for _, opt := range o.Option {
+			if ecs, ok := opt.(*dns.EDNS0_SUBNET); ok && ecs.SourceScope > 0 {
+				return 0
+			}
+		}
+	}
+	switch {
+	case response.Rcode == dns.RcodeSuccess && len(response.Answer) > 0:
+		return capTTL(minTTL(response), c.maxTTL)
+	case response.Rcode == dns.RcodeSuccess || response.Rcode == dns.RcodeNameError:
+		// NODATA and NXDOMAIN responses are cached for the negative TTL of the SOA record of the authority
+		// section, and not at all without SOA record (RFC 2308 section 5).
+		for _, rr := range response.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl := soa.Hdr.Ttl
+				if soa.Minttl < ttl {
+					ttl = soa.Minttl
+				}
+				return capTTL(ttl, c.maxNegativeTTL)
+			}
+		}
+	}
+	return 0
+}
+
+// minTTL returns the smallest TTL of the records of the response.
+func minTTL(response *dns.Msg) uint32 {
+	var ttl uint32
+	first := true
+	for _, rrs := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+		for _, rr := range rrs {
+			h := rr.Header()
+			if h.Rrtype == dns.TypeOPT {
+				continue
+			}
+			if first || h.Ttl < ttl {
+				ttl = h.Ttl
+				first = false
+			}
+		}
+	}
+	return ttl
+}
+
+func capTTL(ttl uint32, limit time.Duration) time.Duration {
+	d := time.Duration(ttl) * time.Second
+	if limit > 0 && d > limit {
+		return limit
+	}
+	return d
+}
@@ -0,0 +1,239 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func soa(ttl, minttl uint32) dns.RR {
+	return &dns.SOA{
+		Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+		Ns:     "ns.example.com.",
+		Mbox:   "admin.example.com.",
+		Minttl: minttl,
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	answer := func(ttls ...uint32) []dns.RR {
+		var out []dns.RR
+		for _, ttl := range ttls {
+			rr := a("www.example.com.", []netip.Addr{netip.MustParseAddr("1.1.1.1")})[0]
+			rr.Header().Ttl = ttl
+			out = append(out, rr)
+		}
+		return out
+	}
+	cases := []struct {
+		name     string
+		response func(m *dns.Msg)
+		want     time.Duration
+	}{
+		{
+			name:     "smallest TTL of the answers",
+			response: func(m *dns.Msg) { m.Answer = answer(60, 20, 90) },
+			want:     20 * time.Second,
+		},
+		{
+			name:     "capped by the max TTL",
+			response: func(m *dns.Msg) { m.Answer = answer(3600) },
+			want:     5 * time.Minute,
+		},
+		{
+			name: "NXDOMAIN with SOA",
+			response: func(m *dns.Msg) {
+				m.Rcode = dns.RcodeNameError
+				m.Ns = []dns.RR{soa(60, 10)}
+			},
+			want: 10 * time.Second,
+		},
+		{
+			name: "NODATA with SOA",
+			response: func(m *dns.Msg) {
+				m.Ns = []dns.RR{soa(15, 60)}
+			},
+			want: 15 * time.Second,
+		},
+		{
+			name: "negative response capped by the max negative TTL",
+			response: func(m *dns.Msg) {
+				m.Rcode = dns.RcodeNameError
+				m.Ns = []dns.RR{soa(3600, 3600)}
+			},
+			want: 30 * time.Second,
+		},
+		{
+			name:     "NXDOMAIN without SOA",
+			response: func(m *dns.Msg) { m.Rcode = dns.RcodeNameError },
+		},
+		{
+			name:     "server failure",
+			response: func(m *dns.Msg) { m.Rcode = dns.RcodeServerFailure },
+		},
+		{
+			name:     "zero TTL",
+			response: func(m *dns.Msg) { m.Answer = answer(0) },
+		},
+		{
+			name: "client subnet scope",
+			response: func(m *dns.Msg) {
+				m.Answer = answer(60)
+				m.SetEdns0(dns.DefaultMsgSize, false)
+				o := m.IsEdns0()
+				o.Option = append(o.Option, &dns.EDNS0_SUBNET{
+					Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, SourceScope: 24, Address: netip.MustParseAddr("10.0.0.0").AsSlice(),
+				})
+			},
+		},
+		{
+			name: "client subnet without scope",
+			response: func(m *dns.Msg) {
+				m.Answer = answer(60)
+				m.SetEdns0(dns.DefaultMsgSize, false)
+				o := m.IsEdns0()
+				o.Option = append(o.Option, &dns.EDNS0_SUBNET{
+					Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: netip.MustParseAddr("10.0.0.0").AsSlice(),
+				})
+			},
+			want: time.Minute,
+		},
+		{
+			name: "truncated",
+			response: func(m *dns.Msg) {
+				m.Answer = answer(60)
+				m.Truncated = true
+			},
+		},
+	}
+	c, err := newResponseCache(CacheOptions{MaxEntries: 10, MaxTTL: 5 * time.Minute, MaxNegativeTTL: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := new(dns.Msg)
+			req.SetQuestion("www.example.com.", dns.TypeA)
+			response := new(dns.Msg)
+			response.SetReply(req)
+			tt.response(response)
+			assert.Equal(t, c.ttl(response), tt.want)
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	now := time.Now()
+	c, err := newResponseCache(CacheOptions{MaxEntries: 2, MaxTTL: time.Minute, MaxNegativeTTL: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return now }
+	request := func(host string, edns bool) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion(host, dns.TypeA)
+		if edns {
+			req.SetEdns0(dns.DefaultMsgSize, false)
+		}
+		return req
+	}
+	reply := func(req *dns.Msg) *dns.Msg {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Answer = a(req.Question[0].Name, []netip.Addr{netip.MustParseAddr("1.1.1.1")})
+		return response
+	}
+
+	req := request("www.example.com.", false)
+	c.add(req, reply(req))
+	req = request("capped.example.com.", false)
+	capped := reply(req)
+	capped.Answer[0].Header().Ttl = 3600
+	c.add(req, capped)
+
+	// The cached response answers the requests with the same question, the TTLs are decremented.
+	now = now.Add(10 * time.Second)
+	req = request("WWW.example.com.", false)
+	req.Id = 1234
+	got := c.get(req)
+	if got == nil {
+		t.Fatal("expected a cached response")
+	}
+	assert.Equal(t, got.Id, req.Id)
+	assert.Equal(t, got.Question, req.Question)
+	assert.Equal(t, got.Answer[0].Header().Ttl, uint32(defaultTTLInSeconds-10))
+	// The cached response is not modified by the callers.
+	got.Answer = nil
+	assert.Equal(t, len(c.get(req).Answer), 1)
+	// The TTLs are capped by the max TTL.
+	assert.Equal(t, c.get(request("capped.example.com.", false)).Answer[0].Header().Ttl, uint32(50))
+
+	// Requests with EDNS0 are cached separately.
+	if c.get(request("www.example.com.", true)) != nil {
+		t.Fatal("unexpected cached response for an EDNS0 request")
+	}
+
+	// Expired responses are removed, the capped response is cached for the max TTL.
+	now = now.Add(time.Duration(defaultTTLInSeconds) * time.Second)
+	if c.get(req) != nil {
+		t.Fatal("unexpected expired response")
+	}
+	assert.Equal(t, c.entries.Len(), 1)
+
+	// The least recently used responses are evicted.
+	for _, host := range []string{"a.example.com.", "b.example.com.", "c.example.com."} {
+		req := request(host, false)
+		c.add(req, reply(req))
+	}
+	assert.Equal(t, c.entries.Len(), 2)
+	if c.get(request("a.example.com.", false)) != nil {
+		t.Fatal("unexpected response of an evicted entry")
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	d := initDNSWithOptions(t, false, CacheOptions{MaxEntries: 10, MaxTTL: time.Minute, MaxNegativeTTL: 30 * time.Second}, 0)
+	c := dns.Client{Timeout: 3 * time.Second}
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("www.bing.com.", dns.TypeA)
+		res, _, err := c.Exchange(m, d.dnsProxies[0].Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := a("www.bing.com.", []netip.Addr{netip.MustParseAddr("1.1.1.1")})
+		if !equalsDNSrecords(res.Answer, want) {
+			t.Fatalf("got %v, want %v", res.Answer, want)
+		}
+	}
+	// Hosts of the lookup table are not cached, nor the NXDOMAIN responses without SOA record.
+	for _, host := range []string{"www.google.com.", "nxdomain.example.com."} {
+		m := new(dns.Msg)
+		m.SetQuestion(host, dns.TypeA)
+		if _, _, err := c.Exchange(m, d.dnsProxies[0].Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, d.cache.entries.Len(), 1)
+	if !d.cache.entries.Contains(cacheKey{name: "www.bing.com.", qtype: dns.TypeA, qclass: dns.ClassINET}) {
+		t.Fatal("expected the upstream response to be cached")
+	}
+}
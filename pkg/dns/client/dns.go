@@ -58,6 +58,12 @@ type LocalDNSServer struct {
 
 	respondBeforeSync         bool
 	forwardToUpstreamParallel bool
+
+	// cache holds the responses of the upstream servers, nil if disabled.
+	cache *responseCache
+	// maxAnswerRecords limits the number of A/AAAA records of the responses for hosts of the lookup table,
+	// for example headless services with many endpoints. No limit if 0.
+	maxAnswerRecords int
 }
 
 // LookupTable is borrowed from https://github.com/coredns/coredns/blob/master/plugin/hosts/hostsfile.go
@@ -77,6 +83,10 @@ type LookupTable struct {
 	// The cname records here (comprised of different variants of the hosts above,
 	// expanded by the search namespaces) pointing to the actual host.
 	cname map[string][]dns.RR
+	// The SRV records of the named ports of the hosts above, keyed by "_<port>._<protocol>.<host>.".
+	srv map[string][]dns.RR
+	// The PTR records of the known IPs, keyed by their reverse name (like 4.3.2.1.in-addr.arpa.).
+	ptr map[string][]dns.RR
 }
 
 const (
@@ -84,13 +94,25 @@ const (
 	// the latest IP for a host.
 	// TODO: make it configurable
 	defaultTTLInSeconds = 30
+
+	// upstreamUDPSize is the EDNS0 buffer size advertised to the upstream servers over UDP, the size
+	// recommended by the DNS flag day 2020 to avoid IP fragmentation.
+	upstreamUDPSize = 1232
 )
 
-func NewLocalDNSServer(proxyNamespace, proxyDomain string, addr string, forwardToUpstreamParallel bool) (*LocalDNSServer, error) {
+func NewLocalDNSServer(proxyNamespace, proxyDomain string, addr string, forwardToUpstreamParallel bool,
+	cacheOptions CacheOptions, maxAnswerRecords int,
+) (*LocalDNSServer, error) {
 	h := &LocalDNSServer{
 		proxyNamespace:            proxyNamespace,
 		forwardToUpstreamParallel: forwardToUpstreamParallel,
+		maxAnswerRecords:          maxAnswerRecords,
+	}
+	cache, err := newResponseCache(cacheOptions)
+	if err != nil {
+		return nil, err
 	}
+	h.cache = cache
 
 	registerStats()
 
@@ -192,8 +214,16 @@ func (h *LocalDNSServer) UpdateLookupTable(nt *dnsProto.NameTable) {
 		name4:    map[string][]dns.RR{},
 		name6:    map[string][]dns.RR{},
 		cname:    map[string][]dns.RR{},
+		srv:      map[string][]dns.RR{},
+		ptr:      map[string][]dns.RR{},
 	}
 	h.BuildAlternateHosts(nt, lookupTable.buildDNSAnswers)
+	for hostname, ni := range nt.Table {
+		if len(ni.Ports) > 0 {
+			lookupTable.buildSRVAnswers(h.altHosts(hostname, ni), hostname, ni.Ports)
+		}
+	}
+	lookupTable.buildPTRAnswers(nt.Ptr)
 	h.lookupTable.Store(lookupTable)
 	h.nameTable.Store(nt)
 	log.Debugf("updated lookup table with %d hosts", len(lookupTable.allHosts))
@@ -205,37 +235,62 @@ func (h *LocalDNSServer) BuildAlternateHosts(nt *dnsProto.NameTable,
 	apply func(map[string]struct{}, []netip.Addr, []netip.Addr, []string),
 ) {
 	for hostname, ni := range nt.Table {
-		// Given a host
-		// if its a non-k8s host, store the host+. as the key with the pre-computed DNS RR records
-		// if its a k8s host, store all variants (i.e. shortname+., shortname+namespace+., fqdn+., etc.)
-		// shortname+. is only for hosts in current namespace
-		var altHosts sets.String
-		if ni.Registry == string(provider.Kubernetes) {
-			altHosts = generateAltHosts(hostname, ni, h.proxyNamespace, h.proxyDomain, h.proxyDomainParts)
-		} else {
-			if !strings.HasSuffix(hostname, ".") {
-				hostname += "."
-			}
-			altHosts = sets.New(hostname)
-		}
 		ipv4, ipv6 := netutil.ParseIPsSplitToV4V6(ni.Ips)
 		if len(ipv6) == 0 && len(ipv4) == 0 {
 			// malformed ips
 			continue
 		}
-		apply(altHosts, ipv4, ipv6, h.searchNamespaces)
+		apply(h.altHosts(hostname, ni), ipv4, ipv6, h.searchNamespaces)
+	}
+}
+
+// altHosts returns the alternate hosts of a host of the name table.
+func (h *LocalDNSServer) altHosts(hostname string, ni *dnsProto.NameTable_NameInfo) sets.String {
+	// Given a host
+	// if its a non-k8s host, store the host+. as the key with the pre-computed DNS RR records
+	// if its a k8s host, store all variants (i.e. shortname+., shortname+namespace+., fqdn+., etc.)
+	// shortname+. is only for hosts in current namespace
+	if ni.Registry == string(provider.Kubernetes) {
+		return generateAltHosts(hostname, ni, h.proxyNamespace, h.proxyDomain, h.proxyDomainParts)
+	}
+	if !strings.HasSuffix(hostname, ".") {
+		hostname += "."
 	}
+	return sets.New(hostname)
 }
 
 // upstream sends the request to the upstream server, with associated logs and metrics
 func (h *LocalDNSServer) upstream(proxy *dnsProxy, req *dns.Msg, hostname string) *dns.Msg {
+	if h.cache != nil {
+		if response := h.cache.get(req); response != nil {
+			log.Debugf("cached upstream response for hostname %q : %v", hostname, response)
+			return response
+		}
+	}
 	upstreamRequests.Increment()
 	start := time.Now()
 	// We did not find the host in our internal cache. Query upstream and return the response as is.
 	log.Debugf("response for hostname %q not found in dns proxy, querying upstream", hostname)
-	response := h.queryUpstream(proxy.upstreamClient, req, log)
+	upstreamReq := req
+	if proxy.protocol == "udp" {
+		upstreamReq = upstreamUDPRequest(req)
+	}
+	response := h.queryUpstream(proxy.upstreamClient, upstreamReq, log)
+	if response.Truncated && proxy.upstreamTCPClient != nil {
+		// The response does not fit in UDP: get the complete response over TCP, which is truncated to the
+		// buffer size of the client afterwards.
+		log.Debugf("truncated upstream response for hostname %q, querying upstream over TCP", hostname)
+		response = h.queryUpstream(proxy.upstreamTCPClient, req, log)
+	}
+	if req.IsEdns0() == nil {
+		// The OPT record must not be sent to clients without EDNS0 support (RFC 6891).
+		removeOPT(response)
+	}
 	requestDuration.Record(time.Since(start).Seconds())
 	log.Debugf("upstream response for hostname %q : %v", hostname, response)
+	if h.cache != nil {
+		h.cache.add(req, response)
+	}
 	return response
 }
 
@@ -297,6 +352,13 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 		// upstream DNS server would already round robin if desired.
 		if len(answers) > 0 {
 			roundRobinResponse(response)
+			if h.maxAnswerRecords > 0 {
+				response.Answer = limitAddressRecords(response.Answer, h.maxAnswerRecords)
+			}
+		}
+		if o := req.IsEdns0(); o != nil {
+			// Clients using EDNS0 expect an OPT record in the response (RFC 6891).
+			response.SetEdns0(ednsSize(proxy.protocol, o.UDPSize()), o.Do())
 		}
 		log.Debugf("response for hostname %q (found=true): %v", hostname, response)
 	} else {
@@ -309,6 +371,51 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 	_ = w.WriteMsg(response)
 }
 
+// upstreamUDPRequest returns the request forwarded to the upstream servers over UDP. It advertises at least
+// upstreamUDPSize, so the upstream servers only truncate the responses which do not fit in the buffer of the proxy,
+// rather than in the one of the client. The responses are truncated to the buffer size of the client afterwards.
+func upstreamUDPRequest(req *dns.Msg) *dns.Msg {
+	o := req.IsEdns0()
+	if o != nil && o.UDPSize() >= upstreamUDPSize {
+		return req
+	}
+	upstreamReq := req.Copy()
+	if o = upstreamReq.IsEdns0(); o != nil {
+		o.SetUDPSize(upstreamUDPSize)
+	} else {
+		upstreamReq.SetEdns0(upstreamUDPSize, false)
+	}
+	return upstreamReq
+}
+
+// removeOPT removes the OPT record of the response.
+func removeOPT(response *dns.Msg) {
+	extra := response.Extra[:0]
+	for _, rr := range response.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	response.Extra = extra
+}
+
+// limitAddressRecords keeps at most limit A/AAAA records of the shuffled answers, and all the other records.
+// Clients of huge headless services then get a random subset of their endpoints, which fits in UDP responses.
+func limitAddressRecords(answers []dns.RR, limit int) []dns.RR {
+	out := make([]dns.RR, 0, len(answers))
+	addresses := 0
+	for _, rr := range answers {
+		if t := rr.Header().Rrtype; t == dns.TypeA || t == dns.TypeAAAA {
+			if addresses == limit {
+				continue
+			}
+			addresses++
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
 // IsReady returns true if DNS lookup table is updated atleast once.
 func (h *LocalDNSServer) IsReady() bool {
 	return h.lookupTable.Load() != nil
@@ -511,6 +618,17 @@ func generateAltHosts(hostname string, nameinfo *dnsProto.NameTable_NameInfo, pr
 func (table *LookupTable) lookupHost(qtype uint16, hostname string) ([]dns.RR, bool) {
 	var hostFound bool
 
+	switch qtype {
+	case dns.TypeSRV:
+		if srv, f := table.srv[hostname]; f {
+			return srv, true
+		}
+	case dns.TypePTR:
+		if ptr, f := table.ptr[hostname]; f {
+			return ptr, true
+		}
+	}
+
 	question := host.Name(hostname)
 	wildcard := false
 	// First check if host exists in all hosts.
@@ -551,7 +669,6 @@ func (table *LookupTable) lookupHost(qtype uint16, hostname string) ([]dns.RR, b
 	case dns.TypeAAAA:
 		ipAnswers = table.name6[hostname]
 	default:
-		// TODO: handle PTR records for reverse dns lookups
 		return nil, false
 	}
 
@@ -627,6 +744,38 @@ func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []n
 	}
 }
 
+// buildSRVAnswers stores the SRV records of the named ports of a host, for each of its alternate hosts.
+// The records point to the FQDN of the host, so clients resolve its A/AAAA records in the lookup table.
+func (table *LookupTable) buildSRVAnswers(altHosts sets.String, hostname string, ports []*dnsProto.NameTable_Port) {
+	target := strings.ToLower(hostname)
+	if !strings.HasSuffix(target, ".") {
+		target += "."
+	}
+	for h := range altHosts {
+		h = strings.ToLower(h)
+		for _, p := range ports {
+			name := "_" + strings.ToLower(p.Name) + "._" + p.Protocol + "." + h
+			table.srv[name] = append(table.srv[name], srv(name, target, p.Number))
+		}
+	}
+}
+
+// buildPTRAnswers stores the PTR records of the IPs of the name table, for reverse lookups.
+func (table *LookupTable) buildPTRAnswers(hostnames map[string]string) {
+	for ip, hostname := range hostnames {
+		name, err := dns.ReverseAddr(ip)
+		if err != nil {
+			// malformed ip
+			continue
+		}
+		target := strings.ToLower(hostname)
+		if !strings.HasSuffix(target, ".") {
+			target += "."
+		}
+		table.ptr[name] = []dns.RR{ptr(name, target)}
+	}
+}
+
 // Borrowed from https://github.com/coredns/coredns/blob/master/plugin/hosts/hosts.go
 // a takes a slice of ip string and returns a slice of A RRs.
 func a(host string, ips []netip.Addr) []dns.RR {
@@ -664,6 +813,34 @@ func cname(host string, targetHost string) []dns.RR {
 	return []dns.RR{answer}
 }
 
+func srv(name string, target string, port uint32) dns.RR {
+	answer := new(dns.SRV)
+	answer.Hdr = dns.RR_Header{
+		Name:   name,
+		Rrtype: dns.TypeSRV,
+		Class:  dns.ClassINET,
+		Ttl:    defaultTTLInSeconds,
+	}
+	// Like Kubernetes DNS, all the records have the same priority and weight.
+	answer.Priority = 0
+	answer.Weight = 100
+	answer.Port = uint16(port)
+	answer.Target = target
+	return answer
+}
+
+func ptr(name string, target string) dns.RR {
+	answer := new(dns.PTR)
+	answer.Hdr = dns.RR_Header{
+		Name:   name,
+		Rrtype: dns.TypePTR,
+		Class:  dns.ClassINET,
+		Ttl:    defaultTTLInSeconds,
+	}
+	answer.Ptr = target
+	return answer
+}
+
 // Size returns if buffer size *advertised* in the requests OPT record.
 // Or when the request was over TCP, we return the maximum allowed size of 64K.
 func size(proto string, r *dns.Msg) int {
@@ -27,6 +27,7 @@ import (
 
 	dnsProto "istio.io/istio/pkg/dns/proto"
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/util/sets"
 )
 
 func TestDNSForwardParallel(t *testing.T) {
@@ -240,6 +241,69 @@ func testDNS(t *testing.T, d *LocalDNSServer) {
 			expectResolutionFailure: dns.RcodeSuccess,
 			expected:                giantResponse[:29],
 		},
+		{
+			name:                    "large request truncated by upstream over UDP only",
+			host:                    "giant-udp-tc.",
+			expectResolutionFailure: dns.RcodeSuccess,
+			expected:                giantResponse,
+			modifyReq: func(msg *dns.Msg) {
+				msg.SetEdns0(dns.MaxMsgSize, false)
+			},
+		},
+		{
+			name: "success: k8s host SRV - shortname",
+			host: "_grpc._tcp.productpage.",
+			expected: []dns.RR{
+				srv("_grpc._tcp.productpage.", "productpage.ns1.svc.cluster.local.", 9080),
+			},
+			modifyReq: func(msg *dns.Msg) {
+				msg.Question[0].Qtype = dns.TypeSRV
+			},
+		},
+		{
+			name: "success: k8s host SRV - fqdn",
+			host: "_grpc._tcp.productpage.ns1.svc.cluster.local.",
+			expected: []dns.RR{
+				srv("_grpc._tcp.productpage.ns1.svc.cluster.local.", "productpage.ns1.svc.cluster.local.", 9080),
+			},
+			modifyReq: func(msg *dns.Msg) {
+				msg.Question[0].Qtype = dns.TypeSRV
+			},
+		},
+		{
+			name:                    "failure: SRV of an unknown port",
+			host:                    "_http._tcp.productpage.",
+			expectResolutionFailure: dns.RcodeNameError,
+			modifyReq: func(msg *dns.Msg) {
+				msg.Question[0].Qtype = dns.TypeSRV
+			},
+		},
+		{
+			name:     "success: PTR of a known IPv4",
+			host:     "9.9.9.9.in-addr.arpa.",
+			expected: []dns.RR{ptr("9.9.9.9.in-addr.arpa.", "productpage.ns1.svc.cluster.local.")},
+			modifyReq: func(msg *dns.Msg) {
+				msg.Question[0].Qtype = dns.TypePTR
+			},
+		},
+		{
+			name: "success: PTR of a known IPv6",
+			host: "9.2.3.8.2.4.0.0.0.0.f.f.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+			expected: []dns.RR{
+				ptr("9.2.3.8.2.4.0.0.0.0.f.f.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "ipv6.localhost."),
+			},
+			modifyReq: func(msg *dns.Msg) {
+				msg.Question[0].Qtype = dns.TypePTR
+			},
+		},
+		{
+			name:                    "failure: PTR of an unknown IP",
+			host:                    "8.8.8.8.in-addr.arpa.",
+			expectResolutionFailure: dns.RcodeNameError,
+			modifyReq: func(msg *dns.Msg) {
+				msg.Question[0].Qtype = dns.TypePTR
+			},
+		},
 		{
 			name:     "success: hostname with a period",
 			host:     "example.localhost.",
@@ -442,6 +506,33 @@ func makeUpstream(t test.Failer, responses map[string]string) string {
 			t.Fatalf("err: %s", err)
 		}
 	})
+	// Like an upstream server with a small buffer, truncates the UDP responses regardless of EDNS0.
+	mux.HandleFunc("giant-udp-tc.", func(resp dns.ResponseWriter, msg *dns.Msg) {
+		answer := &dns.Msg{
+			Answer: giantResponse,
+		}
+		answer.SetReply(msg)
+		answer.Rcode = dns.RcodeSuccess
+		if resp.LocalAddr().Network() == "udp" {
+			answer.Truncate(dns.MinMsgSize)
+		}
+		if err := resp.WriteMsg(answer); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+	// Echoes the EDNS0 buffer size of the request in the OPT record of the response.
+	mux.HandleFunc("edns.", func(resp dns.ResponseWriter, msg *dns.Msg) {
+		answer := &dns.Msg{
+			Answer: a("edns.", []netip.Addr{netip.MustParseAddr("1.1.1.1")}),
+		}
+		answer.SetReply(msg)
+		if o := msg.IsEdns0(); o != nil {
+			answer.SetEdns0(o.UDPSize(), false)
+		}
+		if err := resp.WriteMsg(answer); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
 	up := make(chan struct{})
 
 	tcp := &dns.Server{
@@ -496,8 +587,13 @@ func makeUpstream(t test.Failer, responses map[string]string) string {
 }
 
 func initDNS(t test.Failer, forwardToUpstreamParallel bool) *LocalDNSServer {
+	return initDNSWithOptions(t, forwardToUpstreamParallel, CacheOptions{}, 0)
+}
+
+func initDNSWithOptions(t test.Failer, forwardToUpstreamParallel bool, cacheOptions CacheOptions, maxAnswerRecords int) *LocalDNSServer {
 	srv := makeUpstream(t, map[string]string{"www.bing.com.": "1.1.1.1"})
-	testAgentDNS, err := NewLocalDNSServer("ns1", "ns1.svc.cluster.local", "localhost:0", forwardToUpstreamParallel)
+	testAgentDNS, err := NewLocalDNSServer("ns1", "ns1.svc.cluster.local", "localhost:0", forwardToUpstreamParallel,
+		cacheOptions, maxAnswerRecords)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -515,6 +611,7 @@ func initDNS(t test.Failer, forwardToUpstreamParallel bool) *LocalDNSServer {
 				Registry:  "Kubernetes",
 				Namespace: "ns1",
 				Shortname: "productpage",
+				Ports:     []*dnsProto.NameTable_Port{{Name: "grpc", Number: 9080, Protocol: "tcp"}},
 			},
 			"example.ns2.svc.cluster.local": {
 				Ips:       []string{"10.10.10.10"},
@@ -566,11 +663,103 @@ func initDNS(t test.Failer, forwardToUpstreamParallel bool) *LocalDNSServer {
 				Registry: "External",
 			},
 		},
+		Ptr: map[string]string{
+			"9.9.9.9":                     "productpage.ns1.svc.cluster.local",
+			"2001:db8:0:0:0:ff00:42:8329": "ipv6.localhost",
+		},
 	})
 	t.Cleanup(testAgentDNS.Close)
 	return testAgentDNS
 }
 
+func TestDNSEDNS0(t *testing.T) {
+	d := initDNS(t, false)
+	c := dns.Client{Timeout: 3 * time.Second, Net: "udp"}
+	cases := []struct {
+		name     string
+		host     string
+		udpSize  uint16
+		wantOPT  bool
+		wantSize uint16
+	}{
+		{
+			name: "upstream response without EDNS0",
+			host: "edns.",
+		},
+		{
+			name:     "upstream request advertises the proxy buffer size",
+			host:     "edns.",
+			udpSize:  dns.MinMsgSize,
+			wantOPT:  true,
+			wantSize: upstreamUDPSize,
+		},
+		{
+			name:     "upstream request keeps a larger buffer size",
+			host:     "edns.",
+			udpSize:  dns.DefaultMsgSize,
+			wantOPT:  true,
+			wantSize: dns.DefaultMsgSize,
+		},
+		{
+			name: "mesh host without EDNS0",
+			host: "productpage.ns1.svc.cluster.local.",
+		},
+		{
+			name:     "mesh host with EDNS0",
+			host:     "productpage.ns1.svc.cluster.local.",
+			udpSize:  dns.DefaultMsgSize,
+			wantOPT:  true,
+			wantSize: dns.DefaultMsgSize,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion(tt.host, dns.TypeA)
+			if tt.udpSize > 0 {
+				m.SetEdns0(tt.udpSize, false)
+			}
+			res, _, err := c.Exchange(m, d.dnsProxies[0].Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Answer) != 1 {
+				t.Fatalf("got answers %v, want 1 answer", res.Answer)
+			}
+			o := res.IsEdns0()
+			if (o != nil) != tt.wantOPT {
+				t.Fatalf("got OPT record %v, want OPT record %v", o, tt.wantOPT)
+			}
+			if o != nil && o.UDPSize() != tt.wantSize {
+				t.Fatalf("got UDP size %d, want %d", o.UDPSize(), tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestDNSMaxAnswerRecords(t *testing.T) {
+	d := initDNSWithOptions(t, false, CacheOptions{}, 2)
+	c := dns.Client{Timeout: 3 * time.Second}
+	all := sets.New("11.11.11.11", "12.12.12.12", "13.13.13.13", "14.14.14.14")
+	m := new(dns.Msg)
+	m.SetQuestion("details.ns2.svc.cluster.remote.", dns.TypeA)
+	res, _, err := c.Exchange(m, d.dnsProxies[0].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Answer) != 2 {
+		t.Fatalf("got %d answers, want 2: %v", len(res.Answer), res.Answer)
+	}
+	for _, answer := range res.Answer {
+		if !all.Contains(answer.(*dns.A).A.String()) {
+			t.Errorf("unexpected answer %v", answer)
+		}
+	}
+	if res.Truncated {
+		t.Errorf("unexpected truncated response")
+	}
+}
+
 // reflect.DeepEqual doesn't seem to work well for dns.RR
 // as the Rdlength field is not updated in the a(), or aaaa() calls.
 // so zero them out before doing reflect.Deepequal
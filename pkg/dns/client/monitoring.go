@@ -39,6 +39,16 @@ var (
 		"Total time in seconds Istio takes to get DNS response from upstream.",
 		[]float64{.005, .001, 0.01, 0.1, 1, 5},
 	)
+
+	cacheHits = monitoring.NewSum(
+		"dns_cache_hits_total",
+		"Total number of DNS requests answered from the cache of upstream responses.",
+	)
+
+	cacheMisses = monitoring.NewSum(
+		"dns_cache_misses_total",
+		"Total number of DNS requests not found in the cache of upstream responses.",
+	)
 )
 
 func registerStats() {
@@ -46,4 +56,6 @@ func registerStats() {
 	monitoring.MustRegister(upstreamRequests)
 	monitoring.MustRegister(failures)
 	monitoring.MustRegister(requestDuration)
+	monitoring.MustRegister(cacheHits)
+	monitoring.MustRegister(cacheMisses)
 }
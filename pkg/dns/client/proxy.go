@@ -28,22 +28,22 @@ type dnsProxy struct {
 	// This is the upstream Client used to make upstream DNS queries
 	// in case the data is not in our name table.
 	upstreamClient *dns.Client
-	protocol       string
-	resolver       *LocalDNSServer
+	// upstreamTCPClient retries over TCP the queries truncated by the upstream UDP servers, nil for TCP.
+	upstreamTCPClient *dns.Client
+	protocol          string
+	resolver          *LocalDNSServer
 }
 
 func newDNSProxy(protocol, addr string, resolver *LocalDNSServer) (*dnsProxy, error) {
 	p := &dnsProxy{
-		serveMux: dns.NewServeMux(),
-		server:   &dns.Server{},
-		upstreamClient: &dns.Client{
-			Net:          protocol,
-			DialTimeout:  5 * time.Second,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
-		},
-		protocol: protocol,
-		resolver: resolver,
+		serveMux:       dns.NewServeMux(),
+		server:         &dns.Server{},
+		upstreamClient: newUpstreamClient(protocol),
+		protocol:       protocol,
+		resolver:       resolver,
+	}
+	if protocol == "udp" {
+		p.upstreamTCPClient = newUpstreamClient("tcp")
 	}
 
 	var err error
@@ -62,6 +62,15 @@ func newDNSProxy(protocol, addr string, resolver *LocalDNSServer) (*dnsProxy, er
 	return p, nil
 }
 
+func newUpstreamClient(protocol string) *dns.Client {
+	return &dns.Client{
+		Net:          protocol,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+}
+
 func (p *dnsProxy) start() {
 	err := p.server.ActivateAndServe()
 	if err != nil {
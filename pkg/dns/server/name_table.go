@@ -20,6 +20,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/protocol"
 	dnsProto "istio.io/istio/pkg/dns/proto"
 	netutil "istio.io/istio/pkg/util/net"
 )
@@ -32,21 +33,37 @@ type Config struct {
 	// MulticlusterHeadlessEnabled if true, the DNS name table for a headless service will resolve to
 	// same-network endpoints in any cluster.
 	MulticlusterHeadlessEnabled bool
+
+	// SRVAndPTREnabled if true, the DNS name table includes the named ports of the services, served as SRV records,
+	// and the hostnames of the service and workload IPs, served as PTR records.
+	SRVAndPTREnabled bool
 }
 
 // BuildNameTable produces a table of hostnames and their associated IPs that can then
 // be used by the agent to resolve DNS. This logic is always active. However, local DNS resolution
 // will only be effective if DNS capture is enabled in the proxy
 func BuildNameTable(cfg Config) *dnsProto.NameTable {
-	if cfg.Node.Type != model.SidecarProxy {
-		// DNS resolution is only for sidecars
+	var services []*model.Service
+	switch cfg.Node.Type {
+	case model.SidecarProxy:
+		services = cfg.Node.SidecarScope.Services()
+	case model.Ztunnel:
+		// Ztunnel resolves DNS for all the pods of its node, which have no sidecar scope, with a single name table.
+		// Only the services visible from all the namespaces are included, the pods resolve the other services
+		// with the upstream DNS server.
+		services = cfg.Push.GetPublicServices()
+	default:
+		// DNS resolution is only for sidecars and ztunnel
 		return nil
 	}
 
 	out := &dnsProto.NameTable{
 		Table: make(map[string]*dnsProto.NameTable_NameInfo),
 	}
-	for _, svc := range cfg.Node.SidecarScope.Services() {
+	if cfg.SRVAndPTREnabled {
+		out.Ptr = make(map[string]string)
+	}
+	for _, svc := range services {
 		svcAddress := svc.GetAddressForProxy(cfg.Node)
 		var addressList []string
 		hostName := svc.Hostname
@@ -89,6 +106,10 @@ func BuildNameTable(cfg Config) *dnsProto.NameTable {
 							// We can only return a single IP for these queries. We should prefer the local cluster,
 							// so if the entry already exists only overwrite it if the instance is in our own cluster.
 							out.Table[host] = nameInfo
+							if out.Ptr != nil {
+								// The hostname of the pod takes precedence over the one of its services.
+								out.Ptr[instance.Endpoint.Address] = host
+							}
 						}
 					}
 					skipForMulticluster := !cfg.MulticlusterHeadlessEnabled && !sameCluster
@@ -129,6 +150,9 @@ func BuildNameTable(cfg Config) *dnsProto.NameTable {
 				nameInfo.Namespace = svc.Attributes.Namespace
 				nameInfo.Shortname = svc.Attributes.Name
 			}
+			if cfg.SRVAndPTREnabled {
+				nameInfo.Ports = namedPorts(svc)
+			}
 			out.Table[hostName.String()] = nameInfo
 		} else if provider.ID(ni.Registry) != provider.Kubernetes {
 			// 2 possible cases:
@@ -141,10 +165,44 @@ func BuildNameTable(cfg Config) *dnsProto.NameTable {
 					ni.Namespace = svc.Attributes.Namespace
 					ni.Shortname = svc.Attributes.Name
 				}
+				if cfg.SRVAndPTREnabled {
+					ni.Ports = namedPorts(svc)
+				}
 			} else {
 				ni.Ips = append(ni.Ips, addressList...)
 			}
 		}
+		if out.Ptr != nil && !hostName.IsWildCarded() {
+			for _, address := range addressList {
+				if _, f := out.Ptr[address]; !f {
+					out.Ptr[address] = hostName.String()
+				}
+			}
+		}
 	}
 	return out
 }
+
+// namedPorts returns the named ports of the service, served as SRV records. The ports of wildcard hosts are skipped,
+// as the SRV records of such hosts cannot be queried.
+func namedPorts(svc *model.Service) []*dnsProto.NameTable_Port {
+	if svc.Hostname.IsWildCarded() {
+		return nil
+	}
+	var ports []*dnsProto.NameTable_Port
+	for _, p := range svc.Ports {
+		if p.Name == "" {
+			continue
+		}
+		transport := "tcp"
+		if p.Protocol == protocol.UDP {
+			transport = "udp"
+		}
+		ports = append(ports, &dnsProto.NameTable_Port{
+			Name:     p.Name,
+			Number:   uint32(p.Port),
+			Protocol: transport,
+		})
+	}
+	return ports
+}
@@ -22,10 +22,13 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
+	meshwatcher "istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/visibility"
 	dnsProto "istio.io/istio/pkg/dns/proto"
 	dnsServer "istio.io/istio/pkg/dns/server"
 )
@@ -63,6 +66,16 @@ func TestNameTable(t *testing.T) {
 		Type:        model.SidecarProxy,
 		DNSDomain:   "testns.svc.cluster.local",
 	}
+	ztunnel := &model.Proxy{
+		IPAddresses: []string{"9.9.9.9"},
+		Metadata:    &model.NodeMetadata{},
+		Type:        model.Ztunnel,
+	}
+	gateway := &model.Proxy{
+		IPAddresses: []string{"9.9.9.9"},
+		Metadata:    &model.NodeMetadata{},
+		Type:        model.Router,
+	}
 
 	pod1 := &model.Proxy{
 		IPAddresses: []string{"1.2.3.4"},
@@ -229,6 +242,7 @@ func TestNameTable(t *testing.T) {
 		proxy                      *model.Proxy
 		push                       *model.PushContext
 		enableMultiClusterHeadless bool
+		enableSRVAndPTR            bool
 		expectedNameTable          *dnsProto.NameTable
 	}{
 		{
@@ -444,6 +458,54 @@ func TestNameTable(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "ztunnel service entry with resolution = NONE",
+			proxy: ztunnel,
+			push:  sepush,
+			expectedNameTable: &dnsProto.NameTable{
+				Table: map[string]*dnsProto.NameTable_NameInfo{
+					"foo.bar.com": {
+						Ips:      []string{"1.2.3.4", "9.6.7.8", "19.6.7.8", "9.16.7.8"},
+						Registry: "External",
+					},
+				},
+			},
+		},
+		{
+			name:  "ztunnel only includes the services visible from all namespaces",
+			proxy: ztunnel,
+			push: func() *model.PushContext {
+				public := serviceWithVIP1.DeepCopy()
+				public.Attributes.ExportTo = map[visibility.Instance]bool{visibility.Public: true}
+				private := serviceWithVIP1.DeepCopy()
+				private.Hostname = "private.foo.bar"
+				private.DefaultAddress = "10.0.0.8"
+				private.Attributes.ExportTo = map[visibility.Instance]bool{visibility.Private: true}
+				env := model.NewEnvironment()
+				env.ConfigStore = model.NewFakeStore()
+				env.ServiceDiscovery = memory.NewServiceDiscovery(public, private)
+				env.Watcher = meshwatcher.NewFixedWatcher(mesh)
+				env.Init()
+				push := model.NewPushContext()
+				if err := push.InitContext(env, nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				return push
+			}(),
+			expectedNameTable: &dnsProto.NameTable{
+				Table: map[string]*dnsProto.NameTable_NameInfo{
+					serviceWithVIP1.Hostname.String(): {
+						Ips:      []string{serviceWithVIP1.DefaultAddress},
+						Registry: provider.External.String(),
+					},
+				},
+			},
+		},
+		{
+			name:  "gateway",
+			proxy: gateway,
+			push:  sepush,
+		},
 		{
 			name:  "service entry with multiple VIPs",
 			proxy: proxy,
@@ -462,6 +524,93 @@ func TestNameTable(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:            "headless service pods with SRV and PTR records",
+			proxy:           proxy,
+			push:            push,
+			enableSRVAndPTR: true,
+			expectedNameTable: &dnsProto.NameTable{
+				Table: map[string]*dnsProto.NameTable_NameInfo{
+					"pod1.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"1.2.3.4"},
+						Registry:  "Kubernetes",
+						Shortname: "pod1.headless-svc",
+						Namespace: "testns",
+					},
+					"pod2.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"9.6.7.8"},
+						Registry:  "Kubernetes",
+						Shortname: "pod2.headless-svc",
+						Namespace: "testns",
+					},
+					"pod3.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"19.6.7.8"},
+						Registry:  "Kubernetes",
+						Shortname: "pod3.headless-svc",
+						Namespace: "testns",
+					},
+					"pod4.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"9.16.7.8"},
+						Registry:  "Kubernetes",
+						Shortname: "pod4.headless-svc",
+						Namespace: "testns",
+					},
+					"headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"1.2.3.4", "9.6.7.8", "19.6.7.8", "9.16.7.8"},
+						Registry:  "Kubernetes",
+						Shortname: "headless-svc",
+						Namespace: "testns",
+						Ports:     []*dnsProto.NameTable_Port{{Name: "tcp-port", Number: 9000, Protocol: "tcp"}},
+					},
+				},
+				Ptr: map[string]string{
+					"1.2.3.4":  "pod1.headless-svc.testns.svc.cluster.local",
+					"9.6.7.8":  "pod2.headless-svc.testns.svc.cluster.local",
+					"19.6.7.8": "pod3.headless-svc.testns.svc.cluster.local",
+					"9.16.7.8": "pod4.headless-svc.testns.svc.cluster.local",
+				},
+			},
+		},
+		{
+			name:            "wildcard service pods with SRV and PTR records",
+			proxy:           proxy,
+			push:            wpush,
+			enableSRVAndPTR: true,
+			expectedNameTable: &dnsProto.NameTable{
+				Table: map[string]*dnsProto.NameTable_NameInfo{
+					"*.testns.svc.cluster.local": {
+						Ips:       []string{"172.10.10.10"},
+						Registry:  "Kubernetes",
+						Shortname: "wildcard-svc",
+						Namespace: "testns",
+					},
+				},
+			},
+		},
+		{
+			name:  "service entry with multiple VIPs and SRV and PTR records",
+			proxy: proxy,
+			push: func() *model.PushContext {
+				push := model.NewPushContext()
+				push.Mesh = mesh
+				push.AddPublicServices([]*model.Service{serviceWithVIP1, serviceWithVIP2})
+				return push
+			}(),
+			enableSRVAndPTR: true,
+			expectedNameTable: &dnsProto.NameTable{
+				Table: map[string]*dnsProto.NameTable_NameInfo{
+					serviceWithVIP1.Hostname.String(): {
+						Ips:      []string{serviceWithVIP1.DefaultAddress, serviceWithVIP2.DefaultAddress},
+						Registry: provider.External.String(),
+						Ports:    []*dnsProto.NameTable_Port{{Name: "tcp", Number: 3306, Protocol: "tcp"}},
+					},
+				},
+				Ptr: map[string]string{
+					serviceWithVIP1.DefaultAddress: serviceWithVIP1.Hostname.String(),
+					serviceWithVIP2.DefaultAddress: serviceWithVIP1.Hostname.String(),
+				},
+			},
+		},
 		{
 			name:  "service entry as a decorator(created before k8s service)",
 			proxy: proxy,
@@ -505,11 +654,14 @@ func TestNameTable(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.proxy.SidecarScope = model.ConvertToSidecarScope(tt.push, nil, "default")
+			if tt.proxy.Type == model.SidecarProxy {
+				tt.proxy.SidecarScope = model.ConvertToSidecarScope(tt.push, nil, "default")
+			}
 			if diff := cmp.Diff(dnsServer.BuildNameTable(dnsServer.Config{
 				Node:                        tt.proxy,
 				Push:                        tt.push,
 				MulticlusterHeadlessEnabled: tt.enableMultiClusterHeadless,
+				SRVAndPTREnabled:            tt.enableSRVAndPTR,
 			}), tt.expectedNameTable, protocmp.Transform()); diff != "" {
 				t.Fatalf("got diff: %v", diff)
 			}
@@ -90,6 +90,15 @@ type Agent struct {
 	knownIstioListeners sets.String
 
 	exitOnZeroActiveConnections bool
+
+	// forensics, if set, captures a crash bundle when the proxy exits unexpectedly.
+	forensics *Forensics
+}
+
+// SetForensics enables the capture of a crash bundle by forensics when the proxy exits unexpectedly.
+// It must be called before Run.
+func (a *Agent) SetForensics(forensics *Forensics) {
+	a.forensics = forensics
 }
 
 type exitStatus struct {
@@ -100,6 +109,9 @@ type exitStatus struct {
 func (a *Agent) Run(ctx context.Context) {
 	log.Info("Starting proxy agent")
 	go a.runWait(a.abortCh)
+	if a.forensics != nil {
+		go a.forensics.Run(ctx.Done())
+	}
 
 	select {
 	case status := <-a.statusCh:
@@ -108,6 +120,7 @@ func (a *Agent) Run(ctx context.Context) {
 				log.Warnf("Envoy may have been out of memory killed. Check memory usage and limits.")
 			}
 			log.Errorf("Envoy exited with error: %v", status.err)
+			a.captureCrash(status.err)
 		} else {
 			log.Infof("Envoy exited normally")
 		}
@@ -119,11 +132,25 @@ func (a *Agent) Run(ctx context.Context) {
 			log.Infof("Envoy aborted normally")
 		} else {
 			log.Warnf("Envoy aborted abnormally")
+			a.captureCrash(status.err)
 		}
 		log.Info("Agent has successfully terminated")
 	}
 }
 
+// captureCrash writes a crash bundle for the unexpected exit of the proxy, if crash forensics are enabled.
+func (a *Agent) captureCrash(exitErr error) {
+	if a.forensics == nil {
+		return
+	}
+	dir, err := a.forensics.WriteBundle(exitErr)
+	if err != nil {
+		log.Warnf("Failed to write Envoy crash bundle: %v", err)
+		return
+	}
+	log.Infof("Envoy crash bundle written to %s", dir)
+}
+
 func (a *Agent) terminate() {
 	log.Infof("Agent draining Proxy")
 	e := a.proxy.Drain()
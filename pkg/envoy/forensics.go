@@ -0,0 +1,300 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/http"
+	"istio.io/pkg/log"
+)
+
+const (
+	// CrashBundlePrefix is the prefix of the names of the crash bundle directories.
+	CrashBundlePrefix = "crash-"
+
+	crashBundleTimeFormat = "20060102T150405.000Z"
+	// maxXdsHistory is the number of xDS updates applied by Envoy kept in the crash bundles.
+	maxXdsHistory = 64
+
+	crashMetadataFile = "crash.json"
+	configDumpFile    = "config_dump.json"
+	statsFile         = "stats.txt"
+	// unreportedFile marks the crash bundles not reported to istiod yet. It survives the restarts of the agent.
+	unreportedFile = "unreported"
+)
+
+// ForensicsOptions configures the crash forensics captured by the agent when Envoy exits unexpectedly.
+type ForensicsOptions struct {
+	// Dir is the directory where the crash bundles are written. Crash forensics are disabled if it is empty.
+	Dir string
+	// Interval is the interval between two snapshots of the Envoy config dump and stats.
+	Interval time.Duration
+	// MaxBundles is the number of crash bundles kept in Dir. The oldest bundles are removed.
+	MaxBundles int
+	// MaxFileSize is the maximum size in bytes of each file of a crash bundle. Larger content is truncated.
+	MaxFileSize int
+}
+
+// XdsVersion is an xDS update applied by Envoy.
+type XdsVersion struct {
+	TypeURL   string    `json:"typeUrl"`
+	Version   string    `json:"version,omitempty"`
+	Nonce     string    `json:"nonce"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// crashMetadata is the content of the crash.json file of a crash bundle.
+type crashMetadata struct {
+	ExitError  string    `json:"exitError"`
+	ExitedAt   time.Time `json:"exitedAt"`
+	SnapshotAt time.Time `json:"snapshotAt,omitempty"`
+	// XdsVersions holds the last xDS update applied by Envoy for each type.
+	XdsVersions []XdsVersion `json:"xdsVersions"`
+	// XdsHistory holds the last xDS updates applied by Envoy, oldest first.
+	XdsHistory []XdsVersion `json:"xdsHistory"`
+	// Truncated lists the files of the bundle truncated to the maximum file size.
+	Truncated []string `json:"truncated,omitempty"`
+}
+
+// CrashBundle is a crash bundle written by the forensics.
+type CrashBundle struct {
+	// Dir is the directory of the bundle.
+	Dir string
+	// ExitError is the error Envoy exited with.
+	ExitError string
+}
+
+// Forensics keeps a rolling snapshot of the state of Envoy: the last xDS updates it applied, and its config
+// dump and stats captured periodically from the admin port. When Envoy exits unexpectedly, the snapshot is
+// written to a crash bundle, so the configuration and stats which explain the crash survive the restart.
+type Forensics struct {
+	opts      ForensicsOptions
+	localhost string
+	adminPort int
+	// onBundle, if set, is called with the directory of each crash bundle written.
+	onBundle func(dir string, exitErr error)
+
+	mu         sync.Mutex
+	configDump []byte
+	stats      []byte
+	snapshotAt time.Time
+	versions   map[string]XdsVersion
+	history    []XdsVersion
+}
+
+// NewForensics creates the crash forensics of the Envoy listening on the admin port, writing the crash bundles
+// to opts.Dir. onBundle, if set, is called with the directory of each crash bundle written.
+func NewForensics(opts ForensicsOptions, localhost string, adminPort int, onBundle func(dir string, exitErr error)) (*Forensics, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create crash bundle directory %s: %v", opts.Dir, err)
+	}
+	if opts.MaxBundles < 1 {
+		opts.MaxBundles = 1
+	}
+	return &Forensics{
+		opts:      opts,
+		localhost: localhost,
+		adminPort: adminPort,
+		onBundle:  onBundle,
+		versions:  map[string]XdsVersion{},
+	}, nil
+}
+
+// RecordXdsVersion records an xDS update applied by Envoy. The version is empty for delta xDS updates.
+func (f *Forensics) RecordXdsVersion(typeURL, version, nonce string) {
+	v := XdsVersion{TypeURL: typeURL, Version: version, Nonce: nonce, AppliedAt: time.Now()}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions[typeURL] = v
+	f.history = append(f.history, v)
+	if len(f.history) > maxXdsHistory {
+		f.history = f.history[len(f.history)-maxXdsHistory:]
+	}
+}
+
+// Run captures a snapshot of the Envoy config dump and stats every interval, until stop is closed.
+func (f *Forensics) Run(stop <-chan struct{}) {
+	if f.opts.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(f.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.snapshot(); err != nil {
+				log.Debugf("failed to capture Envoy snapshot: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// snapshot captures the config dump and stats of Envoy. The previous snapshot is kept if Envoy does not answer.
+func (f *Forensics) snapshot() error {
+	configDump, err := http.DoHTTPGet(fmt.Sprintf("http://%s:%d/config_dump?include_eds", f.localhost, f.adminPort))
+	if err != nil {
+		return fmt.Errorf("unable to get config dump from Envoy: %v", err)
+	}
+	stats, err := http.DoHTTPGet(fmt.Sprintf("http://%s:%d/stats", f.localhost, f.adminPort))
+	if err != nil {
+		return fmt.Errorf("unable to get stats from Envoy: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.configDump = configDump.Bytes()
+	f.stats = stats.Bytes()
+	f.snapshotAt = time.Now()
+	return nil
+}
+
+// WriteBundle writes a crash bundle for the Envoy exit with exitErr, and removes the oldest bundles beyond the
+// maximum number of bundles. It returns the directory of the bundle.
+func (f *Forensics) WriteBundle(exitErr error) (string, error) {
+	// Envoy is usually gone at this point, but it may still answer when it exits while draining.
+	if err := f.snapshot(); err != nil {
+		log.Debugf("failed to capture Envoy snapshot at exit, using the last snapshot: %v", err)
+	}
+
+	now := time.Now().UTC()
+	f.mu.Lock()
+	md := crashMetadata{
+		ExitedAt:   now,
+		SnapshotAt: f.snapshotAt,
+		XdsHistory: append([]XdsVersion(nil), f.history...),
+	}
+	for _, v := range f.versions {
+		md.XdsVersions = append(md.XdsVersions, v)
+	}
+	files := map[string][]byte{
+		configDumpFile: f.configDump,
+		statsFile:      f.stats,
+	}
+	f.mu.Unlock()
+	if exitErr != nil {
+		md.ExitError = exitErr.Error()
+	}
+	sort.Slice(md.XdsVersions, func(i, j int) bool {
+		return md.XdsVersions[i].TypeURL < md.XdsVersions[j].TypeURL
+	})
+	for name, content := range files {
+		if f.opts.MaxFileSize > 0 && len(content) > f.opts.MaxFileSize {
+			files[name] = content[:f.opts.MaxFileSize]
+			md.Truncated = append(md.Truncated, name)
+		}
+	}
+	sort.Strings(md.Truncated)
+	b, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	files[crashMetadataFile] = b
+
+	// The bundle is written to a hidden directory renamed once complete, so it is never collected partially.
+	name := CrashBundlePrefix + now.Format(crashBundleTimeFormat)
+	dir := filepath.Join(f.opts.Dir, name)
+	tmp := filepath.Join(f.opts.Dir, "."+name)
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create crash bundle directory %s: %v", tmp, err)
+	}
+	for fname, content := range files {
+		if len(content) == 0 {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(tmp, fname), content, 0o644); err != nil {
+			_ = os.RemoveAll(tmp)
+			return "", fmt.Errorf("failed to write crash bundle file %s: %v", fname, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, unreportedFile), nil, 0o644); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to write crash bundle file %s: %v", unreportedFile, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to write crash bundle %s: %v", dir, err)
+	}
+	f.prune()
+
+	if f.onBundle != nil {
+		f.onBundle(dir, exitErr)
+	}
+	return dir, nil
+}
+
+// UnreportedBundles returns the crash bundles not marked as reported with MarkReported, oldest first, including
+// the ones written before a restart of the agent.
+func (f *Forensics) UnreportedBundles() []CrashBundle {
+	var unreported []CrashBundle
+	for _, name := range f.bundles() {
+		dir := filepath.Join(f.opts.Dir, name)
+		if _, err := os.Stat(filepath.Join(dir, unreportedFile)); err != nil {
+			continue
+		}
+		bundle := CrashBundle{Dir: dir}
+		var md crashMetadata
+		if b, err := os.ReadFile(filepath.Join(dir, crashMetadataFile)); err == nil && json.Unmarshal(b, &md) == nil {
+			bundle.ExitError = md.ExitError
+		}
+		unreported = append(unreported, bundle)
+	}
+	return unreported
+}
+
+// MarkReported marks the crash bundle in dir as reported to istiod.
+func (f *Forensics) MarkReported(dir string) error {
+	if err := os.Remove(filepath.Join(dir, unreportedFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// bundles returns the names of the crash bundles, oldest first.
+func (f *Forensics) bundles() []string {
+	entries, err := os.ReadDir(f.opts.Dir)
+	if err != nil {
+		log.Warnf("failed to list crash bundles in %s: %v", f.opts.Dir, err)
+		return nil
+	}
+	var bundles []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), CrashBundlePrefix) {
+			bundles = append(bundles, e.Name())
+		}
+	}
+	// The names of the bundles sort in the order they were written.
+	sort.Strings(bundles)
+	return bundles
+}
+
+// prune removes the oldest crash bundles beyond the maximum number of bundles.
+func (f *Forensics) prune() {
+	bundles := f.bundles()
+	for len(bundles) > f.opts.MaxBundles {
+		if err := os.RemoveAll(filepath.Join(f.opts.Dir, bundles[0])); err != nil {
+			log.Warnf("failed to remove crash bundle %s: %v", bundles[0], err)
+		}
+		bundles = bundles[1:]
+	}
+}
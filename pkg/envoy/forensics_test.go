@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func newFakeAdmin(t *testing.T, configDump, stats string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config_dump", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, configDump)
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, stats)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readBundle(t *testing.T, dir string) (crashMetadata, map[string]string) {
+	t.Helper()
+	files := map[string]string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(b)
+	}
+	var md crashMetadata
+	if err := json.Unmarshal([]byte(files[crashMetadataFile]), &md); err != nil {
+		t.Fatal(err)
+	}
+	return md, files
+}
+
+func TestForensicsWriteBundle(t *testing.T) {
+	server := newFakeAdmin(t, `{"configs":[]}`, "server.live: 1\nserver.uptime: 120")
+	var events []string
+	f, err := NewForensics(ForensicsOptions{Dir: t.TempDir(), MaxBundles: 2, MaxFileSize: 16}, "localhost",
+		server.Listener.Addr().(*net.TCPAddr).Port, func(dir string, exitErr error) {
+			events = append(events, fmt.Sprintf("%s: %v", filepath.Base(dir), exitErr))
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.RecordXdsVersion("type.googleapis.com/envoy.config.cluster.v3.Cluster", "v1", "n1")
+	f.RecordXdsVersion("type.googleapis.com/envoy.config.listener.v3.Listener", "v1", "n2")
+	f.RecordXdsVersion("type.googleapis.com/envoy.config.cluster.v3.Cluster", "v2", "n3")
+	if err := f.snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	// Envoy is gone when it crashes, the last snapshot is written.
+	server.Close()
+
+	dir, err := f.WriteBundle(errors.New("signal: segmentation fault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.HasPrefix(filepath.Base(dir), CrashBundlePrefix), true)
+	md, files := readBundle(t, dir)
+	assert.Equal(t, md.ExitError, "signal: segmentation fault")
+	assert.Equal(t, md.SnapshotAt.IsZero(), false)
+	assert.Equal(t, len(md.XdsHistory), 3)
+	assert.Equal(t, len(md.XdsVersions), 2)
+	assert.Equal(t, md.XdsVersions[0].Version, "v2")
+	assert.Equal(t, md.XdsVersions[0].Nonce, "n3")
+	assert.Equal(t, files[configDumpFile], `{"configs":[]}`)
+	assert.Equal(t, files[statsFile], "server.live: 1\ns")
+	assert.Equal(t, md.Truncated, []string{statsFile})
+	assert.Equal(t, events, []string{filepath.Base(dir) + ": signal: segmentation fault"})
+
+	// The bundle is unreported until marked as reported, including for the forensics of a restarted agent.
+	restarted, err := NewForensics(f.opts, "localhost", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, restarted.UnreportedBundles(), []CrashBundle{{Dir: dir, ExitError: "signal: segmentation fault"}})
+	assert.NoError(t, restarted.MarkReported(dir))
+	assert.Equal(t, len(f.UnreportedBundles()), 0)
+
+	// Only the last bundles are kept.
+	var bundles []string
+	for i := 0; i < 3; i++ {
+		time.Sleep(2 * time.Millisecond)
+		b, err := f.WriteBundle(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundles = append(bundles, filepath.Base(b))
+	}
+	entries, err := os.ReadDir(f.opts.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	assert.Equal(t, got, bundles[1:])
+}
+
+func TestForensicsXdsHistory(t *testing.T) {
+	f, err := NewForensics(ForensicsOptions{Dir: t.TempDir()}, "localhost", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxXdsHistory+10; i++ {
+		f.RecordXdsVersion("type.googleapis.com/envoy.config.cluster.v3.Cluster", fmt.Sprint(i), fmt.Sprint(i))
+	}
+	assert.Equal(t, len(f.history), maxXdsHistory)
+	assert.Equal(t, f.history[0].Version, "10")
+	assert.Equal(t, f.versions["type.googleapis.com/envoy.config.cluster.v3.Cluster"].Version, fmt.Sprint(maxXdsHistory+9))
+}
+
+// TestAgentCrashBundle ensures the agent writes a crash bundle when the proxy exits with an error
+func TestAgentCrashBundle(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewForensics(ForensicsOptions{Dir: dir, MaxBundles: 1}, "localhost", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAgent(TestProxy{run: func(_ <-chan error) error {
+		return errors.New("exit status 1")
+	}}, 0, 0, "", 0, 0, 0, true)
+	a.SetForensics(f)
+	a.Run(context.Background())
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one crash bundle, got %v", entries)
+	}
+	md, _ := readBundle(t, filepath.Join(dir, entries[0].Name()))
+	assert.Equal(t, md.ExitError, "exit status 1")
+}
@@ -43,6 +43,7 @@ import (
 	dnsProto "istio.io/istio/pkg/dns/proto"
 	"istio.io/istio/pkg/envoy"
 	"istio.io/istio/pkg/istio-agent/grpcxds"
+	"istio.io/istio/pkg/istio-agent/health"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/wasm"
 	"istio.io/istio/security/pkg/nodeagent/cache"
@@ -94,6 +95,8 @@ type Agent struct {
 
 	envoyAgent             *envoy.Agent
 	dynamicBootstrapWaitCh chan error
+	// envoyForensics, if set, writes a crash bundle when Envoy exits unexpectedly.
+	envoyForensics *envoy.Forensics
 
 	sdsServer   *sds.Server
 	secretCache *cache.SecretManagerClient
@@ -125,6 +128,10 @@ type AgentOptions struct {
 	DNSAddr string
 	// DNSForwardParallel indicates whether the agent should send parallel DNS queries to all upstream nameservers.
 	DNSForwardParallel bool
+	// DNSCache configures the cache of the responses of the upstream DNS servers.
+	DNSCache dnsClient.CacheOptions
+	// DNSMaxAnswerRecords limits the number of A/AAAA records of the DNS responses for mesh hosts. No limit if 0.
+	DNSMaxAnswerRecords int
 	// ProxyType is the type of proxy we are configured to handle
 	ProxyType model.NodeType
 	// ProxyNamespace to use for local dns resolution
@@ -186,6 +193,28 @@ type AgentOptions struct {
 	IstiodSAN string
 
 	WASMOptions wasm.Options
+
+	// GRPCHealthCheck, if set, performs the readiness probe of the workload with the gRPC health checking protocol.
+	GRPCHealthCheck *health.GRPCHealthCheckConfig
+
+	// XDSCacheDir, if set, is the directory where the last xDS configuration ACKed by Envoy is persisted.
+	// It is served to Envoy while Istiod is unreachable.
+	XDSCacheDir string
+	// XDSCacheMaxAge is the maximum age of the persisted xDS configuration served to Envoy.
+	XDSCacheMaxAge time.Duration
+	// XDSCacheStaleNotReady, if true, fails the readiness check while Envoy runs the persisted xDS configuration.
+	XDSCacheStaleNotReady bool
+
+	// XDSRequestLimit is the number of xDS requests per second of each type the xDS proxy forwards to Istiod,
+	// with bursts of XDSRequestBurst requests. The requests are not rate limited if it is 0.
+	XDSRequestLimit float64
+	XDSRequestBurst int
+	// XDSMaxResponseSize is the maximum size in bytes of the xDS responses forwarded to Envoy. Larger responses
+	// are rejected by the xDS proxy. The size is unlimited if it is 0.
+	XDSMaxResponseSize int
+
+	// EnvoyForensics configures the crash bundles written when Envoy exits unexpectedly.
+	EnvoyForensics envoy.ForensicsOptions
 }
 
 // NewAgent hosts the functionality for local SDS and XDS. This consists of the local SDS server and
@@ -290,6 +319,9 @@ func (a *Agent) initializeEnvoyAgent(ctx context.Context) error {
 	}
 	a.envoyAgent = envoy.NewAgent(envoyProxy, drainDuration, a.cfg.MinimumDrainDuration, localHostAddr,
 		int(a.proxyConfig.ProxyAdminPort), a.cfg.EnvoyStatusPort, a.cfg.EnvoyPrometheusPort, a.cfg.ExitOnZeroActiveConnections)
+	if a.envoyForensics != nil {
+		a.envoyAgent.SetForensics(a.envoyForensics)
+	}
 	if a.cfg.EnableDynamicBootstrap {
 		a.dynamicBootstrapWaitCh = make(chan error, 1)
 		// Simulate an xDS request for a bootstrap
@@ -347,10 +379,17 @@ func (a *Agent) Run(ctx context.Context) (func(), error) {
 			return nil, fmt.Errorf("failed to start SDS server: %v", err)
 		}
 	}
+	if !a.EnvoyDisabled() && a.cfg.EnvoyForensics.Dir != "" {
+		if err := a.initEnvoyForensics(); err != nil {
+			log.Warnf("Envoy crash forensics disabled: %v", err)
+		}
+	}
 	a.xdsProxy, err = initXdsProxy(a)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start xds proxy: %v", err)
 	}
+	// Report the crashes of Envoy before the restart of the agent.
+	a.reportEnvoyCrashes()
 	if a.cfg.ProxyXDSDebugViaAgent {
 		err = a.xdsProxy.initDebugInterface(a.cfg.ProxyXDSDebugViaAgentPort)
 		if err != nil {
@@ -493,7 +532,7 @@ func (a *Agent) initLocalDNSServer() (err error) {
 	// we don't need dns server on gateways
 	if a.cfg.DNSCapture && a.cfg.ProxyType == model.SidecarProxy {
 		if a.localDNSServer, err = dnsClient.NewLocalDNSServer(a.cfg.ProxyNamespace, a.cfg.ProxyDomain, a.cfg.DNSAddr,
-			a.cfg.DNSForwardParallel); err != nil {
+			a.cfg.DNSForwardParallel, a.cfg.DNSCache, a.cfg.DNSMaxAnswerRecords); err != nil {
 			return err
 		}
 		a.localDNSServer.StartDNS()
@@ -536,6 +575,9 @@ func (a *Agent) Check() (err error) {
 			return errors.New("istio DNS capture is turned ON and DNS lookup table is not ready yet")
 		}
 	}
+	if a.cfg.XDSCacheStaleNotReady && a.xdsProxy != nil && a.xdsProxy.ServingStaleConfig() {
+		return errors.New("istiod is unreachable and Envoy runs the last known good configuration")
+	}
 	return nil
 }
 
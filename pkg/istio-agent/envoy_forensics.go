@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"fmt"
+
+	"istio.io/istio/pkg/envoy"
+	"istio.io/pkg/log"
+)
+
+// initEnvoyForensics creates the crash forensics of Envoy, passed to the xDS proxy and the Envoy agent.
+func (a *Agent) initEnvoyForensics() error {
+	localHostAddr := localHostIPv4
+	if a.cfg.IsIPv6 {
+		localHostAddr = localHostIPv6
+	}
+	forensics, err := envoy.NewForensics(a.cfg.EnvoyForensics, localHostAddr, int(a.proxyConfig.ProxyAdminPort),
+		func(string, error) { a.reportEnvoyCrashes() })
+	if err != nil {
+		return err
+	}
+	a.envoyForensics = forensics
+	return nil
+}
+
+// reportEnvoyCrashes reports the crash bundles not reported yet to istiod through the xDS proxy. Envoy exiting stops
+// the agent, so the bundles are usually reported by the next agent, once the pod restarted. Istiod records an event
+// on the pod of the proxy pointing at the last bundle, so the proxy needs no permission on the Kubernetes API.
+func (a *Agent) reportEnvoyCrashes() {
+	if a.xdsProxy == nil || a.envoyForensics == nil {
+		return
+	}
+	bundles := a.envoyForensics.UnreportedBundles()
+	if len(bundles) == 0 {
+		return
+	}
+	last := bundles[len(bundles)-1]
+	message := fmt.Sprintf("Envoy exited unexpectedly (%s), crash bundle written to %s", last.ExitError, last.Dir)
+	if len(bundles) > 1 {
+		message += fmt.Sprintf(", %d earlier crash bundles", len(bundles)-1)
+	}
+	a.xdsProxy.reportEnvoyCrash(message, func() {
+		for _, b := range bundles {
+			if err := a.envoyForensics.MarkReported(b.Dir); err != nil {
+				log.Warnf("failed to mark crash bundle %s as reported: %v", b.Dir, err)
+			}
+		}
+	})
+}
@@ -81,7 +81,41 @@ func fillInDefaults(cfg *v1alpha3.ReadinessProbe, ipAddresses []string) *v1alpha
 	return cfg
 }
 
-func NewWorkloadHealthChecker(cfg *v1alpha3.ReadinessProbe, envoyProbe ready.Prober, proxyAddrs []string, ipv6 bool) *WorkloadHealthChecker {
+// fillInGRPCDefaults defaults the host and port of the gRPC probe to the ones of the readiness probe.
+// The port is left unset if the readiness probe is neither an http nor a tcp probe.
+func fillInGRPCDefaults(grpcCfg *GRPCHealthCheckConfig, cfg *v1alpha3.ReadinessProbe, ipAddresses []string) *GRPCHealthCheckConfig {
+	c := *grpcCfg
+	switch h := cfg.HealthCheckMethod.(type) {
+	case *v1alpha3.ReadinessProbe_HttpGet:
+		if c.Host == "" {
+			c.Host = h.HttpGet.Host
+		}
+		if c.Port == 0 {
+			c.Port = h.HttpGet.Port
+		}
+	case *v1alpha3.ReadinessProbe_TcpSocket:
+		if c.Host == "" {
+			c.Host = h.TcpSocket.Host
+		}
+		if c.Port == 0 {
+			c.Port = h.TcpSocket.Port
+		}
+	}
+	if c.Host == "" {
+		if len(ipAddresses) == 0 || status.LegacyLocalhostProbeDestination.Get() {
+			c.Host = "localhost"
+		} else {
+			c.Host = ipAddresses[0]
+		}
+	}
+	return &c
+}
+
+// NewWorkloadHealthChecker creates the checker of the readiness probe. If grpcCfg is set, the readiness
+// probe is performed with the gRPC health checking protocol, instead of the method of cfg.
+func NewWorkloadHealthChecker(cfg *v1alpha3.ReadinessProbe, grpcCfg *GRPCHealthCheckConfig,
+	envoyProbe ready.Prober, proxyAddrs []string, ipv6 bool,
+) *WorkloadHealthChecker {
 	// if a config does not exist return a no-op prober
 	if cfg == nil {
 		return nil
@@ -98,6 +132,15 @@ func NewWorkloadHealthChecker(cfg *v1alpha3.ReadinessProbe, envoyProbe ready.Pro
 	default:
 		prober = nil
 	}
+	if grpcCfg != nil {
+		if grpcCfg = fillInGRPCDefaults(grpcCfg, cfg, proxyAddrs); grpcCfg.Port == 0 {
+			// Only the http and tcp probes have a port to default to.
+			healthCheckLog.Warnf("ignoring the gRPC readiness probe: no port is set and the %T readiness probe has none",
+				cfg.HealthCheckMethod)
+		} else {
+			prober = NewGRPCProber(grpcCfg, ipv6)
+		}
+	}
 
 	probers := []Prober{}
 	if envoyProbe != nil {
@@ -47,7 +47,7 @@ func TestWorkloadHealthChecker_PerformApplicationHealthCheck(t *testing.T) {
 					Port: uint32(port),
 				},
 			},
-		}, nil, []string{"127.0.0.1"}, false)
+		}, nil, nil, []string{"127.0.0.1"}, false)
 		// Speed up tests
 		tcpHealthChecker.config.CheckFrequency = time.Millisecond
 
@@ -142,7 +142,7 @@ func TestWorkloadHealthChecker_PerformApplicationHealthCheck(t *testing.T) {
 					Host:   host,
 				},
 			},
-		}, nil, []string{"127.0.0.1"}, false)
+		}, nil, nil, []string{"127.0.0.1"}, false)
 		// Speed up tests
 		httpHealthChecker.config.CheckFrequency = time.Millisecond
 		quitChan := test.NewStop(t)
@@ -170,3 +170,69 @@ func TestWorkloadHealthChecker_PerformApplicationHealthCheck(t *testing.T) {
 		}, retry.Delay(time.Millisecond*10), retry.Timeout(time.Second))
 	})
 }
+
+func TestNewWorkloadHealthCheckerGRPC(t *testing.T) {
+	tests := []struct {
+		desc     string
+		probe    *v1alpha3.ReadinessProbe
+		grpcCfg  *GRPCHealthCheckConfig
+		expected GRPCHealthCheckConfig
+	}{
+		{
+			desc: "host and port of the tcp probe",
+			probe: &v1alpha3.ReadinessProbe{
+				HealthCheckMethod: &v1alpha3.ReadinessProbe_TcpSocket{
+					TcpSocket: &v1alpha3.TCPHealthCheckConfig{Host: "localhost", Port: 8080},
+				},
+			},
+			grpcCfg:  &GRPCHealthCheckConfig{Service: "svc"},
+			expected: GRPCHealthCheckConfig{Host: "localhost", Port: 8080, Service: "svc"},
+		},
+		{
+			desc: "proxy address and port of the http probe",
+			probe: &v1alpha3.ReadinessProbe{
+				HealthCheckMethod: &v1alpha3.ReadinessProbe_HttpGet{
+					HttpGet: &v1alpha3.HTTPHealthCheckConfig{Port: 8080},
+				},
+			},
+			grpcCfg:  &GRPCHealthCheckConfig{},
+			expected: GRPCHealthCheckConfig{Host: "10.0.0.1", Port: 8080},
+		},
+		{
+			desc: "explicit host and port",
+			probe: &v1alpha3.ReadinessProbe{
+				HealthCheckMethod: &v1alpha3.ReadinessProbe_TcpSocket{
+					TcpSocket: &v1alpha3.TCPHealthCheckConfig{Port: 8080},
+				},
+			},
+			grpcCfg:  &GRPCHealthCheckConfig{Host: "127.0.0.1", Port: 9090},
+			expected: GRPCHealthCheckConfig{Host: "127.0.0.1", Port: 9090},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			checker := NewWorkloadHealthChecker(tt.probe, tt.grpcCfg, nil, []string{"10.0.0.1"}, false)
+			probes := checker.prober.(AggregateProber).Probes
+			grpcProber, ok := probes[len(probes)-1].(*GRPCProber)
+			if !ok {
+				t.Fatalf("expected a grpc prober, got %T", probes[len(probes)-1])
+			}
+			if *grpcProber.Config != tt.expected {
+				t.Errorf("got config %+v, expected %+v", *grpcProber.Config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewWorkloadHealthCheckerGRPCWithoutPort(t *testing.T) {
+	probe := &v1alpha3.ReadinessProbe{
+		HealthCheckMethod: &v1alpha3.ReadinessProbe_Exec{
+			Exec: &v1alpha3.ExecHealthCheckConfig{Command: []string{"true"}},
+		},
+	}
+	checker := NewWorkloadHealthChecker(probe, &GRPCHealthCheckConfig{Service: "svc"}, nil, []string{"10.0.0.1"}, false)
+	probes := checker.prober.(AggregateProber).Probes
+	if _, ok := probes[len(probes)-1].(*ExecProber); !ok {
+		t.Fatalf("expected the exec prober to be kept, got %T", probes[len(probes)-1])
+	}
+}
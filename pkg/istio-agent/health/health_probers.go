@@ -17,14 +17,23 @@ package health
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"strconv"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcHealth "google.golang.org/grpc/health/grpc_health_v1"
+	grpcStatus "google.golang.org/grpc/status"
+
 	"istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/cmd/pilot-agent/status"
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
@@ -166,6 +175,124 @@ func (t *TCPProber) Probe(timeout time.Duration) (ProbeResult, error) {
 	return Healthy, nil
 }
 
+// GRPCHealthCheckConfig configures a probe using the gRPC health checking protocol (grpc.health.v1).
+// The ReadinessProbe API has no gRPC method, so it is configured on the agent and replaces the
+// method of the readiness probe, keeping its delays, timeout and thresholds.
+type GRPCHealthCheckConfig struct {
+	// Host to connect to. Defaults to the host of the readiness probe, or the proxy IP.
+	Host string `json:"host,omitempty"`
+	// Port of the gRPC server. Defaults to the port of the http or tcp readiness probe. It must be set
+	// otherwise, or the gRPC probe is ignored.
+	Port uint32 `json:"port,omitempty"`
+	// Service is the name of the service to check. If empty, the overall health of the server is checked.
+	Service string `json:"service,omitempty"`
+	// TLS, if set, makes the probe connect to the server with TLS.
+	TLS *GRPCHealthCheckTLSConfig `json:"tls,omitempty"`
+}
+
+// GRPCHealthCheckTLSConfig configures the TLS connection of a gRPC probe.
+type GRPCHealthCheckTLSConfig struct {
+	// ServerName is the name used for SNI and to verify the server certificate.
+	ServerName string `json:"serverName,omitempty"`
+	// CACertificates is the path to the PEM encoded roots verifying the server certificate.
+	// If empty, the server certificate is not verified, like HTTPS probes.
+	CACertificates string `json:"caCertificates,omitempty"`
+}
+
+type GRPCProber struct {
+	Config    *GRPCHealthCheckConfig
+	LocalAddr net.Addr
+}
+
+var _ Prober = &GRPCProber{}
+
+func NewGRPCProber(cfg *GRPCHealthCheckConfig, ipv6 bool) *GRPCProber {
+	g := &GRPCProber{
+		Config:    cfg,
+		LocalAddr: status.UpstreamLocalAddressIPv4,
+	}
+	if ipv6 {
+		g.LocalAddr = status.UpstreamLocalAddressIPv6
+	}
+	return g
+}
+
+// Probe will return whether or not the target is healthy (true -> healthy)
+// by calling the grpc.health.v1.Health/Check method.
+func (g *GRPCProber) Probe(timeout time.Duration) (ProbeResult, error) {
+	creds, err := g.transportCredentials()
+	if err != nil {
+		return Unknown, err
+	}
+	// the DialOptions are referenced from https://github.com/kubernetes/kubernetes/blob/v1.23.1/pkg/probe/grpc/grpc.go#L55-L59
+	opts := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUserAgent("istio-probe/1.0"),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			d := status.ProbeDialer()
+			d.LocalAddr = g.LocalAddr
+			d.Timeout = timeout
+			return d.DialContext(ctx, "tcp", addr)
+		}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(g.Config.Host, strconv.Itoa(int(g.Config.Port)))
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	// if we were unable to connect, count as failure
+	if err != nil {
+		return Unhealthy, err
+	}
+	defer conn.Close()
+
+	resp, err := grpcHealth.NewHealthClient(conn).Check(ctx, &grpcHealth.HealthCheckRequest{
+		Service: g.Config.Service,
+	})
+	if err != nil {
+		switch grpcStatus.Code(err) {
+		case codes.Unimplemented:
+			return Unhealthy, fmt.Errorf("server does not implement the grpc health protocol (grpc.health.v1.Health): %v", err)
+		case codes.DeadlineExceeded:
+			return Unhealthy, fmt.Errorf("grpc request not finished within timeout: %v", err)
+		default:
+			return Unhealthy, err
+		}
+	}
+	if resp.GetStatus() != grpcHealth.HealthCheckResponse_SERVING {
+		return Unhealthy, fmt.Errorf("service %q status was %v", g.Config.Service, resp.GetStatus())
+	}
+	return Healthy, nil
+}
+
+func (g *GRPCProber) transportCredentials() (credentials.TransportCredentials, error) {
+	if g.Config.TLS == nil {
+		return insecure.NewCredentials(), nil
+	}
+	cfg := &tls.Config{
+		ServerName: g.Config.TLS.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if g.Config.TLS.CACertificates == "" {
+		// nolint: gosec
+		// This is matching HTTPS probes. It is a reasonable usage of this, as it is just a health check of the workload.
+		cfg.InsecureSkipVerify = true
+		return credentials.NewTLS(cfg), nil
+	}
+	// The roots are read on each probe, so they can be rotated.
+	roots, err := os.ReadFile(g.Config.TLS.CACertificates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates of grpc probe: %v", err)
+	}
+	cfg.RootCAs = x509.NewCertPool()
+	if !cfg.RootCAs.AppendCertsFromPEM(roots) {
+		return nil, fmt.Errorf("failed to parse CA certificates of grpc probe %s", g.Config.TLS.CACertificates)
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
 type ExecProber struct {
 	Config *v1alpha3.ExecHealthCheckConfig
 }
@@ -20,11 +20,20 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	grpcHealth "google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/reserveport"
 )
 
 func TestHttpProber(t *testing.T) {
@@ -119,6 +128,102 @@ func TestTcpProber(t *testing.T) {
 	}
 }
 
+func TestGRPCProber(t *testing.T) {
+	certs := filepath.Join(env.IstioSrc, "tests/testdata/certs/pilot")
+	tests := []struct {
+		desc                string
+		service             string
+		tls                 *GRPCHealthCheckTLSConfig
+		serverTLS           bool
+		stopped             bool
+		expectedProbeResult ProbeResult
+		expectedError       string
+	}{
+		{
+			desc:                "Healthy",
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Healthy service",
+			service:             "serving",
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Unhealthy service",
+			service:             "not-serving",
+			expectedProbeResult: Unhealthy,
+			expectedError:       `service "not-serving" status was NOT_SERVING`,
+		},
+		{
+			desc:                "Unhealthy - unknown service",
+			service:             "unknown",
+			expectedProbeResult: Unhealthy,
+			expectedError:       "rpc error: code = NotFound desc = unknown service",
+		},
+		{
+			desc:                "Unhealthy - Could not connect to server",
+			stopped:             true,
+			expectedProbeResult: Unhealthy,
+			expectedError:       "context deadline exceeded",
+		},
+		{
+			desc:                "Healthy - TLS without verification",
+			tls:                 &GRPCHealthCheckTLSConfig{},
+			serverTLS:           true,
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc: "Healthy - TLS with verification",
+			tls: &GRPCHealthCheckTLSConfig{
+				ServerName:     "istiod.istio-system.svc",
+				CACertificates: filepath.Join(certs, "root-cert.pem"),
+			},
+			serverTLS:           true,
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc: "Unknown - missing CA certificates",
+			tls: &GRPCHealthCheckTLSConfig{
+				CACertificates: filepath.Join(certs, "missing.pem"),
+			},
+			serverTLS:           true,
+			expectedProbeResult: Unknown,
+			expectedError:       "failed to read CA certificates of grpc probe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var opts []grpc.ServerOption
+			if tt.serverTLS {
+				creds, err := credentials.NewServerTLSFromFile(filepath.Join(certs, "cert-chain.pem"), filepath.Join(certs, "key.pem"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts = append(opts, grpc.Creds(creds))
+			}
+			port := createGRPCServer(t, opts...)
+			grpcProber := NewGRPCProber(&GRPCHealthCheckConfig{
+				Host:    "127.0.0.1",
+				Port:    port,
+				Service: tt.service,
+				TLS:     tt.tls,
+			}, false)
+			if tt.stopped {
+				grpcProber.Config.Port = uint32(reserveport.NewPortManagerOrFail(t).ReservePortNumberOrFail(t))
+			}
+
+			got, err := grpcProber.Probe(time.Second)
+			if got != tt.expectedProbeResult {
+				t.Errorf("got: %v, expected: %v", got, tt.expectedProbeResult)
+			}
+			if tt.expectedError == "" && err != nil || tt.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tt.expectedError)) {
+				t.Errorf("got err: %v, expected err: %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
 func TestExecProber(t *testing.T) {
 	tests := []struct {
 		desc                string
@@ -171,6 +276,23 @@ func TestExecProber(t *testing.T) {
 	}
 }
 
+func createGRPCServer(t *testing.T, opts ...grpc.ServerOption) uint32 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	hs.SetServingStatus("serving", grpcHealth.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("not-serving", grpcHealth.HealthCheckResponse_NOT_SERVING)
+	grpcHealth.RegisterHealthServer(server, hs)
+	go func() {
+		_ = server.Serve(l)
+	}()
+	t.Cleanup(server.Stop)
+	return uint32(l.Addr().(*net.TCPAddr).Port)
+}
+
 func createHTTPServer(statusCode int) (*httptest.Server, uint32) {
 	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(statusCode)
@@ -25,6 +25,8 @@ const (
 
 var (
 	disconnectionTypeTag = monitoring.MustCreateLabel("type")
+	// XdsTypeTag is the short name of the xDS type of the requests and responses, e.g. "cds".
+	XdsTypeTag = monitoring.MustCreateLabel("type")
 
 	// IstiodConnectionFailures records total number of connection failures to Istiod.
 	IstiodConnectionFailures = monitoring.NewSum(
@@ -60,6 +62,33 @@ var (
 		"The total number of Xds Proxy Responses",
 	)
 
+	// XdsProxyStaleConfig records whether the xds proxy serves the last known good configuration to Envoy.
+	XdsProxyStaleConfig = monitoring.NewGauge(
+		"xds_proxy_stale_config",
+		"Whether the Xds Proxy serves the last known good configuration to Envoy because Istiod is unreachable (1) or not (0)",
+	)
+
+	// XdsProxyRequestsRateLimited records total number of downstream requests delayed by the request rate limit.
+	XdsProxyRequestsRateLimited = monitoring.NewSum(
+		"xds_proxy_requests_rate_limited",
+		"The total number of Xds Proxy Requests delayed by the request rate limit",
+		monitoring.WithLabels(XdsTypeTag),
+	)
+
+	// XdsProxyRequestsDeduplicated records total number of downstream requests dropped as duplicates.
+	XdsProxyRequestsDeduplicated = monitoring.NewSum(
+		"xds_proxy_requests_deduplicated",
+		"The total number of Xds Proxy Requests dropped because they duplicate the previous request of their type",
+		monitoring.WithLabels(XdsTypeTag),
+	)
+
+	// XdsProxyResponsesRejected records total number of upstream responses rejected because of their size.
+	XdsProxyResponsesRejected = monitoring.NewSum(
+		"xds_proxy_responses_rejected",
+		"The total number of Xds Proxy Responses rejected because they exceed the maximum response size",
+		monitoring.WithLabels(XdsTypeTag),
+	)
+
 	IstiodConnectionCancellations = istiodDisconnections.With(disconnectionTypeTag.Value(Cancel))
 	IstiodConnectionErrors        = istiodDisconnections.With(disconnectionTypeTag.Value(Error))
 	EnvoyConnectionCancellations  = envoyDisconnections.With(disconnectionTypeTag.Value(Cancel))
@@ -74,5 +103,9 @@ func init() {
 		envoyDisconnections,
 		XdsProxyRequests,
 		XdsProxyResponses,
+		XdsProxyStaleConfig,
+		XdsProxyRequestsRateLimited,
+		XdsProxyRequestsDeduplicated,
+		XdsProxyResponsesRejected,
 	)
 }
@@ -45,6 +45,7 @@ import (
 	"istio.io/istio/pkg/channels"
 	"istio.io/istio/pkg/config/constants"
 	dnsProto "istio.io/istio/pkg/dns/proto"
+	"istio.io/istio/pkg/envoy"
 	"istio.io/istio/pkg/h2c"
 	"istio.io/istio/pkg/istio-agent/health"
 	"istio.io/istio/pkg/istio-agent/metrics"
@@ -52,6 +53,7 @@ import (
 	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/uds"
 	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/wasm"
 	"istio.io/istio/security/pkg/nodeagent/caclient"
 	"istio.io/istio/security/pkg/pki/util"
@@ -99,7 +101,14 @@ type XdsProxy struct {
 	connected                 *ProxyConnection
 	initialHealthRequest      *discovery.DiscoveryRequest
 	initialDeltaHealthRequest *discovery.DeltaDiscoveryRequest
-	connectedMutex            sync.RWMutex
+	// initialWasmStatusRequest and initialDeltaWasmStatusRequest hold the last status of the Wasm modules,
+	// reported again on new connections.
+	initialWasmStatusRequest      *discovery.DiscoveryRequest
+	initialDeltaWasmStatusRequest *discovery.DeltaDiscoveryRequest
+	// envoyCrashReport is the pending report of the crashes of Envoy, sent on the next connections until it is sent
+	// upstream.
+	envoyCrashReport *envoyCrashReport
+	connectedMutex   sync.RWMutex
 
 	// Wasm cache and ecds channel are used to replace wasm remote load with local file.
 	wasmCache wasm.Cache
@@ -114,6 +123,24 @@ type XdsProxy struct {
 	ecdsLastNonce         atomic.String
 	downstreamGrpcOptions []grpc.ServerOption
 	istiodSAN             string
+
+	// xdsCache persists the last known good configuration, served to Envoy while Istiod is unreachable.
+	// It is nil if disabled.
+	xdsCache *xdsCache
+	// servingStaleConfig is true from the time the last known good configuration is served to Envoy
+	// until a response is received from Istiod.
+	servingStaleConfig atomic.Bool
+
+	// requestLimit and requestBurst configure the rate limit of the requests of each type forwarded to istiod.
+	// The requests are not rate limited if requestLimit is 0.
+	requestLimit float64
+	requestBurst int
+	// maxResponseSize is the maximum size of the responses forwarded to Envoy, larger responses are rejected.
+	// It is unlimited if 0.
+	maxResponseSize int
+
+	// forensics, if set, records the xDS updates applied by Envoy for the crash bundles.
+	forensics *envoy.Forensics
 }
 
 var proxyLog = log.RegisterScope("xdsproxy", "XDS Proxy in Istio Agent")
@@ -138,19 +165,33 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 	}
 
 	cache := wasm.NewLocalFileCache(constants.IstioDataDir, ia.cfg.WASMOptions)
+	healthChecker := health.NewWorkloadHealthChecker(ia.proxyConfig.ReadinessProbe, ia.cfg.GRPCHealthCheck,
+		envoyProbe, ia.cfg.ProxyIPAddresses, ia.cfg.IsIPv6)
 	proxy := &XdsProxy{
 		istiodAddress:         ia.proxyConfig.DiscoveryAddress,
 		istiodSAN:             ia.cfg.IstiodSAN,
 		clusterID:             ia.secOpts.ClusterID,
 		handlers:              map[string]ResponseHandler{},
 		stopChan:              make(chan struct{}),
-		healthChecker:         health.NewWorkloadHealthChecker(ia.proxyConfig.ReadinessProbe, envoyProbe, ia.cfg.ProxyIPAddresses, ia.cfg.IsIPv6),
+		healthChecker:         healthChecker,
 		xdsHeaders:            ia.cfg.XDSHeaders,
 		xdsUdsPath:            ia.cfg.XdsUdsPath,
 		wasmCache:             cache,
 		proxyAddresses:        ia.cfg.ProxyIPAddresses,
 		ia:                    ia,
 		downstreamGrpcOptions: ia.cfg.DownstreamGrpcOptions,
+		requestLimit:          ia.cfg.XDSRequestLimit,
+		requestBurst:          ia.cfg.XDSRequestBurst,
+		maxResponseSize:       ia.cfg.XDSMaxResponseSize,
+		forensics:             ia.envoyForensics,
+	}
+
+	if ia.cfg.XDSCacheDir != "" {
+		if proxy.xdsCache, err = newXdsCache(ia.cfg.XDSCacheDir, ia.cfg.XDSCacheMaxAge); err != nil {
+			proxyLog.Warnf("last known good xds cache disabled: %v", err)
+		} else {
+			go proxy.xdsCache.run(proxy.stopChan)
+		}
 	}
 
 	if ia.localDNSServer != nil {
@@ -233,6 +274,82 @@ func (p *XdsProxy) sendHealthCheckRequest(req *discovery.DiscoveryRequest) {
 	p.connectedMutex.Unlock()
 }
 
+// sendWasmModuleStatus reports the status of the Wasm modules fetched by the Wasm cache to istiod, on the
+// current connection and on any reconnection to the upstream XDS server.
+func (p *XdsProxy) sendWasmModuleStatus() {
+	reporter, ok := p.wasmCache.(wasm.StatusReporter)
+	if !ok {
+		return
+	}
+	statuses := reporter.ModuleStatuses()
+	if len(statuses) == 0 {
+		return
+	}
+	detail, err := wasm.ModuleStatusDetail(statuses)
+	if err != nil {
+		proxyLog.Warnf("failed to encode the status of the Wasm modules: %v", err)
+		return
+	}
+	// Store the same request as Delta and SotW. Depending on how Envoy connects we will use one or the other.
+	req := &discovery.DiscoveryRequest{TypeUrl: v3.WasmModuleStatusType, ErrorDetail: detail}
+	deltaReq := &discovery.DeltaDiscoveryRequest{TypeUrl: v3.WasmModuleStatusType, ErrorDetail: detail}
+	p.connectedMutex.Lock()
+	if p.connected != nil && p.connected.requestsChan != nil {
+		p.connected.requestsChan.Put(req)
+	}
+	if p.connected != nil && p.connected.deltaRequestsChan != nil {
+		p.connected.deltaRequestsChan.Put(deltaReq)
+	}
+	p.initialWasmStatusRequest = req
+	p.initialDeltaWasmStatusRequest = deltaReq
+	p.connectedMutex.Unlock()
+}
+
+// envoyCrashReport is a report of crashes of Envoy.
+type envoyCrashReport struct {
+	detail *google_rpc.Status
+	// reported is called once the report is sent upstream.
+	reported func()
+}
+
+// reportEnvoyCrash reports crashes of Envoy to istiod, which records them as an event on the pod of the proxy. The
+// connection of the crashed Envoy is gone, so the report is sent on the next connection. reported is called once
+// the report is sent upstream.
+func (p *XdsProxy) reportEnvoyCrash(message string, reported func()) {
+	p.connectedMutex.Lock()
+	p.envoyCrashReport = &envoyCrashReport{detail: &google_rpc.Status{Message: message}, reported: reported}
+	p.connectedMutex.Unlock()
+}
+
+// envoyCrashRequests returns the requests of the pending report of the crashes of Envoy, if any. Depending on how
+// Envoy connects we will use one or the other.
+func (p *XdsProxy) envoyCrashRequests() (*discovery.DiscoveryRequest, *discovery.DeltaDiscoveryRequest) {
+	p.connectedMutex.RLock()
+	defer p.connectedMutex.RUnlock()
+	if p.envoyCrashReport == nil {
+		return nil, nil
+	}
+	detail := p.envoyCrashReport.detail
+	return &discovery.DiscoveryRequest{TypeUrl: v3.EnvoyCrashType, ErrorDetail: detail},
+		&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EnvoyCrashType, ErrorDetail: detail}
+}
+
+// envoyCrashReported clears the pending report of the crashes of Envoy once its request was sent upstream.
+func (p *XdsProxy) envoyCrashReported(detail *google_rpc.Status) {
+	p.connectedMutex.Lock()
+	report := p.envoyCrashReport
+	if report == nil || report.detail != detail {
+		// A newer report is pending.
+		p.connectedMutex.Unlock()
+		return
+	}
+	p.envoyCrashReport = nil
+	p.connectedMutex.Unlock()
+	if report.reported != nil {
+		report.reported()
+	}
+}
+
 func (p *XdsProxy) unregisterStream(c *ProxyConnection) {
 	p.connectedMutex.Lock()
 	defer p.connectedMutex.Unlock()
@@ -266,6 +383,7 @@ type ProxyConnection struct {
 	upstream           xds.DiscoveryClient
 	downstreamDeltas   xds.DeltaDiscoveryStream
 	upstreamDeltas     xds.DeltaDiscoveryClient
+	limits             *requestLimiter
 }
 
 // sendRequest is a small wrapper around sending to con.requestsChan. This ensures that we do not
@@ -326,6 +444,7 @@ func (p *XdsProxy) handleStream(downstream adsStream) error {
 		responsesChan: make(chan *discovery.DiscoveryResponse, 1),
 		stopChan:      make(chan struct{}),
 		downstream:    downstream,
+		limits:        newRequestLimiter(p.requestLimit, p.requestBurst),
 	}
 
 	p.registerStream(con)
@@ -338,7 +457,7 @@ func (p *XdsProxy) handleStream(downstream adsStream) error {
 	if err != nil {
 		proxyLog.Errorf("failed to connect to upstream %s: %v", p.istiodAddress, err)
 		metrics.IstiodConnectionFailures.Increment()
-		return err
+		return p.serveStaleConfig(con, err)
 	}
 	defer upstreamConn.Close()
 
@@ -351,6 +470,75 @@ func (p *XdsProxy) handleStream(downstream adsStream) error {
 	return p.handleUpstream(ctx, con, xds)
 }
 
+// serveStaleConfig serves the last known good configuration to Envoy when the upstream stream cannot
+// be established, for each type Envoy requests. After staleConfigRetryInterval, the stream is closed
+// with upstreamErr, so Envoy reconnects and the connection to Istiod is retried. If there is no last
+// known good configuration, upstreamErr is returned immediately.
+// Only the state of the world streams are served the last known good configuration: the delta streams
+// return the upstream error, as before.
+func (p *XdsProxy) serveStaleConfig(con *ProxyConnection, upstreamErr error) error {
+	if p.xdsCache == nil || p.xdsCache.empty() {
+		return upstreamErr
+	}
+	if !p.servingStaleConfig.Swap(true) {
+		proxyLog.Warnf("upstream %s unreachable, serving last known good config to Envoy", p.istiodAddress)
+	}
+	metrics.XdsProxyStaleConfig.Record(1)
+
+	requests := make(chan *discovery.DiscoveryRequest)
+	go func() {
+		for {
+			req, err := con.downstream.Recv()
+			if err != nil {
+				select {
+				case con.downstreamError <- err:
+				case <-con.stopChan:
+				}
+				return
+			}
+			select {
+			case requests <- req:
+			case <-con.stopChan:
+				return
+			}
+		}
+	}()
+
+	retry := time.NewTimer(staleConfigRetryInterval)
+	defer retry.Stop()
+	served := sets.New[string]()
+	for {
+		select {
+		case req := <-requests:
+			if req.ErrorDetail != nil {
+				proxyLog.Warnf("downstream [%d] rejected last known good config for type url %s: %v",
+					con.conID, req.TypeUrl, req.ErrorDetail.GetMessage())
+				continue
+			}
+			if served.Contains(req.TypeUrl) {
+				continue
+			}
+			if resp := p.xdsCache.get(req.TypeUrl); resp != nil {
+				proxyLog.Debugf("downstream [%d] serving last known good config for type url %s, version %q",
+					con.conID, req.TypeUrl, resp.VersionInfo)
+				served.Insert(req.TypeUrl)
+				forwardToEnvoy(con, resp)
+			}
+		case err := <-con.downstreamError:
+			return err
+		case <-retry.C:
+			return upstreamErr
+		case <-con.stopChan:
+			return nil
+		}
+	}
+}
+
+// ServingStaleConfig returns true if Envoy runs the last known good configuration because Istiod is unreachable.
+func (p *XdsProxy) ServingStaleConfig() bool {
+	return p.servingStaleConfig.Load()
+}
+
 func (p *XdsProxy) buildUpstreamConn(ctx context.Context) (*grpc.ClientConn, error) {
 	p.optsMutex.RLock()
 	opts := p.dialOptions
@@ -366,7 +554,7 @@ func (p *XdsProxy) handleUpstream(ctx context.Context, con *ProxyConnection, xds
 		proxyLog.Debugf("failed to create upstream grpc client: %v", err)
 		// Increase metric when xds connection error, for example: forgot to restart ingressgateway or sidecar after changing root CA.
 		metrics.IstiodConnectionErrors.Increment()
-		return err
+		return p.serveStaleConfig(con, err)
 	}
 	proxyLog.Infof("connected to upstream XDS server: %s", p.istiodAddress)
 	defer proxyLog.Debugf("disconnected from XDS server: %s", p.istiodAddress)
@@ -439,6 +627,15 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 				return
 			}
 
+			if p.xdsCache != nil {
+				p.xdsCache.requested(req)
+			}
+			if p.forensics != nil && req.ResponseNonce != "" && req.ErrorDetail == nil {
+				p.forensics.RecordXdsVersion(req.TypeUrl, req.VersionInfo, req.ResponseNonce)
+			}
+			if req = con.limits.admit(req, con.stopChan, con.sendRequest); req == nil {
+				continue
+			}
 			// forward to istiod
 			con.sendRequest(req)
 			if !initialRequestsSent.Load() && req.TypeUrl == v3.ListenerType {
@@ -462,7 +659,13 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 				if initialRequest != nil {
 					con.sendRequest(initialRequest)
 				}
+				if p.initialWasmStatusRequest != nil {
+					con.sendRequest(p.initialWasmStatusRequest)
+				}
 				p.connectedMutex.RUnlock()
+				if crash, _ := p.envoyCrashRequests(); crash != nil {
+					con.sendRequest(crash)
+				}
 			}
 		}
 	}()
@@ -472,8 +675,8 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 		select {
 		case req := <-con.requestsChan.Get():
 			con.requestsChan.Load()
-			if req.TypeUrl == v3.HealthInfoType && !initialRequestsSent.Load() {
-				// only send healthcheck probe after LDS request has been sent
+			if (req.TypeUrl == v3.HealthInfoType || req.TypeUrl == v3.WasmModuleStatusType) && !initialRequestsSent.Load() {
+				// only send healthcheck probe and Wasm module status after LDS request has been sent
 				continue
 			}
 			proxyLog.Debugf("request for type url %s", req.TypeUrl)
@@ -489,6 +692,9 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 				con.upstreamError <- err
 				return
 			}
+			if req.TypeUrl == v3.EnvoyCrashType {
+				p.envoyCrashReported(req.ErrorDetail)
+			}
 		case <-con.stopChan:
 			return
 		}
@@ -497,12 +703,22 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 
 func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 	forwardEnvoyCh := make(chan *discovery.DiscoveryResponse, 1)
+	forward := func(resp *discovery.DiscoveryResponse) {
+		if p.xdsCache != nil {
+			p.xdsCache.forwarded(resp)
+		}
+		forwardToEnvoy(con, resp)
+	}
 	for {
 		select {
 		case resp := <-con.responsesChan:
 			// TODO: separate upstream response handling from requests sending, which are both time costly
 			proxyLog.Debugf("response for type url %s", resp.TypeUrl)
 			metrics.XdsProxyResponses.Increment()
+			if p.servingStaleConfig.CompareAndSwap(true, false) {
+				proxyLog.Infof("upstream %s reachable, no longer serving last known good config", p.istiodAddress)
+				metrics.XdsProxyStaleConfig.Record(0)
+			}
 			if h, f := p.handlers[resp.TypeUrl]; f {
 				if len(resp.Resources) == 0 {
 					// Empty response, nothing to do
@@ -526,6 +742,20 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 				})
 				continue
 			}
+			if errorResp := p.checkResponseSize(con, resp.TypeUrl, resp.Nonce, resp); errorResp != nil {
+				// Send NACK, on behalf of Envoy which keeps its current configuration
+				nack := &discovery.DiscoveryRequest{
+					TypeUrl:       resp.TypeUrl,
+					ResponseNonce: resp.Nonce,
+					ErrorDetail:   errorResp,
+				}
+				if last, ok := con.limits.lastRequest(resp.TypeUrl).(*discovery.DiscoveryRequest); ok {
+					nack.VersionInfo = last.VersionInfo
+					nack.ResourceNames = last.ResourceNames
+				}
+				con.sendRequest(nack)
+				continue
+			}
 			switch resp.TypeUrl {
 			case v3.ExtensionConfigurationType:
 				if features.WasmRemoteLoadConversion {
@@ -540,17 +770,17 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 					})
 				} else {
 					// Otherwise, forward ECDS resource update directly to Envoy.
-					forwardToEnvoy(con, resp)
+					forward(resp)
 				}
 			default:
 				if strings.HasPrefix(resp.TypeUrl, v3.DebugType) {
 					p.forwardToTap(resp)
 				} else {
-					forwardToEnvoy(con, resp)
+					forward(resp)
 				}
 			}
 		case resp := <-forwardEnvoyCh:
-			forwardToEnvoy(con, resp)
+			forward(resp)
 		case <-con.stopChan:
 			return
 		}
@@ -558,7 +788,9 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 }
 
 func (p *XdsProxy) rewriteAndForward(con *ProxyConnection, resp *discovery.DiscoveryResponse, forward func(resp *discovery.DiscoveryResponse)) {
-	if err := wasm.MaybeConvertWasmExtensionConfig(resp.Resources, p.wasmCache); err != nil {
+	err := wasm.MaybeConvertWasmExtensionConfig(resp.Resources, p.wasmCache)
+	p.sendWasmModuleStatus()
+	if err != nil {
 		proxyLog.Debugf("sending NACK for ECDS resources %+v", resp.Resources)
 		con.sendRequest(&discovery.DiscoveryRequest{
 			VersionInfo:   p.ecdsLastAckVersion.Load(),
@@ -591,6 +823,7 @@ func forwardToEnvoy(con *ProxyConnection, resp *discovery.DiscoveryResponse) {
 		proxyLog.Errorf("downstream [%d] dropped xds push to Envoy, connection already closed", con.conID)
 		return
 	}
+	con.limits.forwarded(resp.TypeUrl)
 	if err := sendDownstream(con.downstream, resp); err != nil {
 		select {
 		case con.downstreamError <- err:
@@ -0,0 +1,359 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/file"
+	"istio.io/istio/pkg/sleep"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// xdsCacheFileSuffix is the suffix of the files persisting the xDS responses.
+	xdsCacheFileSuffix = ".json"
+	// staleConfigRetryInterval is how long the stale configuration is served to Envoy on a stream
+	// before the stream is closed, so Envoy reconnects and the connection to Istiod is retried.
+	staleConfigRetryInterval = 15 * time.Second
+	// xdsCacheSaveDelay is how long the ACKed responses are held before being persisted, so the ACKs of a push
+	// are persisted together.
+	xdsCacheSaveDelay = time.Second
+)
+
+// xdsCache persists the last xDS responses ACKed by Envoy, one per type, in a local directory.
+// They are the last known good configuration, served to a freshly started Envoy while Istiod is unreachable.
+// Only the state of the world protocol is supported: the responses of the delta streams are not persisted, and the
+// delta streams are not served the last known good configuration.
+// The ACKed responses are persisted by run, so the requests of Envoy are not delayed by the writes.
+type xdsCache struct {
+	dir    string
+	maxAge time.Duration
+
+	mu sync.Mutex
+	// pending holds the responses forwarded to Envoy and not ACKed yet, by type.
+	pending map[string]*discovery.DiscoveryResponse
+	// unsaved holds the responses ACKed by Envoy and not persisted yet, by type, in the order of the ACKs.
+	unsaved map[string][]ackedResponse
+	// acked holds the last responses ACKed by Envoy and persisted, by type.
+	acked map[string]*cachedResponse
+	// saveCh notifies run of ACKed responses to persist.
+	saveCh chan struct{}
+}
+
+// ackedResponse is a response ACKed by Envoy, with the resources it subscribed to in the ACK.
+type ackedResponse struct {
+	resp       *discovery.DiscoveryResponse
+	subscribed []string
+}
+
+// cachedResponse is the format of the files persisting the xDS responses.
+type cachedResponse struct {
+	TypeURL string    `json:"typeUrl"`
+	SavedAt time.Time `json:"savedAt"`
+	// Checksum is the hex encoded SHA-256 of Response, checked when loading the file.
+	Checksum string `json:"checksum"`
+	// Response is the serialized DiscoveryResponse.
+	Response []byte `json:"response"`
+
+	resp *discovery.DiscoveryResponse
+	// clusters holds the cluster names of the ClusterLoadAssignments of an EDS response, in the order of its
+	// resources, so merging a partial push does not unmarshal them again.
+	clusters []string
+}
+
+// newXdsCache creates the cache persisting the responses in dir, and loads the valid responses
+// persisted by a previous run. Responses older than maxAge are never served.
+func newXdsCache(dir string, maxAge time.Duration) (*xdsCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create xds cache directory %s: %v", dir, err)
+	}
+	c := &xdsCache{
+		dir:     dir,
+		maxAge:  maxAge,
+		pending: map[string]*discovery.DiscoveryResponse{},
+		unsaved: map[string][]ackedResponse{},
+		acked:   map[string]*cachedResponse{},
+		saveCh:  make(chan struct{}, 1),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xds cache directory %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if !strings.HasSuffix(e.Name(), xdsCacheFileSuffix) {
+			// Leftover of an interrupted write.
+			_ = os.Remove(path)
+			continue
+		}
+		cr, err := c.load(path)
+		if err != nil {
+			proxyLog.Warnf("discarding xds cache file %s: %v", path, err)
+			_ = os.Remove(path)
+			continue
+		}
+		c.acked[cr.TypeURL] = cr
+		proxyLog.Infof("loaded last known good config for type url %s, version %q, saved at %v",
+			cr.TypeURL, cr.resp.VersionInfo, cr.SavedAt)
+	}
+	return c, nil
+}
+
+func (c *xdsCache) load(path string) (*cachedResponse, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cr := &cachedResponse{}
+	if err := json.Unmarshal(b, cr); err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(cr.Response); hex.EncodeToString(sum[:]) != cr.Checksum {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	if c.expired(cr) {
+		return nil, fmt.Errorf("saved at %v, older than %v", cr.SavedAt, c.maxAge)
+	}
+	resp := &discovery.DiscoveryResponse{}
+	if err := proto.Unmarshal(cr.Response, resp); err != nil {
+		return nil, err
+	}
+	if resp.TypeUrl != cr.TypeURL || filepath.Base(path) != xdsCacheFileName(cr.TypeURL) {
+		return nil, fmt.Errorf("unexpected type url %s", resp.TypeUrl)
+	}
+	cr.resp = resp
+	if resp.TypeUrl == v3.EndpointType {
+		if cr.clusters, err = clusterNames(resp); err != nil {
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
+func (c *xdsCache) expired(cr *cachedResponse) bool {
+	return c.maxAge > 0 && time.Since(cr.SavedAt) > c.maxAge
+}
+
+// forwarded records a response forwarded to Envoy, to be persisted once ACKed.
+func (c *xdsCache) forwarded(resp *discovery.DiscoveryResponse) {
+	if !v3.IsEnvoyType(resp.TypeUrl) {
+		return
+	}
+	c.mu.Lock()
+	c.pending[resp.TypeUrl] = resp
+	c.mu.Unlock()
+}
+
+// requested records the response ACKed by the Envoy request, if any, to be persisted by run.
+func (c *xdsCache) requested(req *discovery.DiscoveryRequest) {
+	if req.ResponseNonce == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := c.pending[req.TypeUrl]
+	if resp == nil || resp.Nonce != req.ResponseNonce {
+		return
+	}
+	delete(c.pending, req.TypeUrl)
+	if req.ErrorDetail != nil {
+		// NACK, keep the previous config.
+		return
+	}
+	ack := ackedResponse{resp: resp, subscribed: req.ResourceNames}
+	if resp.TypeUrl == v3.EndpointType {
+		// The EDS pushes are partial, all of them are merged in order.
+		c.unsaved[resp.TypeUrl] = append(c.unsaved[resp.TypeUrl], ack)
+	} else {
+		c.unsaved[resp.TypeUrl] = []ackedResponse{ack}
+	}
+	select {
+	case c.saveCh <- struct{}{}:
+	default:
+	}
+}
+
+// run persists the ACKed responses until stop is closed. The ACKs received within xdsCacheSaveDelay are persisted
+// together.
+func (c *xdsCache) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-c.saveCh:
+			sleep.Until(stop, xdsCacheSaveDelay)
+		case <-stop:
+		}
+		c.flush()
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// flush persists the ACKed responses not persisted yet.
+func (c *xdsCache) flush() {
+	c.mu.Lock()
+	unsaved := c.unsaved
+	c.unsaved = map[string][]ackedResponse{}
+	c.mu.Unlock()
+	for typeURL, acks := range unsaved {
+		if err := c.save(typeURL, acks); err != nil {
+			proxyLog.Warnf("failed to persist last known good config for type url %s: %v", typeURL, err)
+		}
+	}
+}
+
+// save persists the last of the ACKed responses of the type. The EDS responses are merged in order into the last
+// persisted EDS response.
+func (c *xdsCache) save(typeURL string, acks []ackedResponse) error {
+	var clusters []string
+	resp := acks[len(acks)-1].resp
+	if typeURL == v3.EndpointType {
+		var prev *cachedResponse
+		c.mu.Lock()
+		if cr := c.acked[typeURL]; cr != nil && !c.expired(cr) {
+			prev = cr
+		}
+		c.mu.Unlock()
+		var err error
+		for _, ack := range acks {
+			if resp, clusters, err = mergeEndpoints(prev, ack.resp, ack.subscribed); err != nil {
+				return fmt.Errorf("failed to merge: %v", err)
+			}
+			prev = &cachedResponse{resp: resp, clusters: clusters}
+		}
+	}
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	cr := &cachedResponse{
+		TypeURL:  resp.TypeUrl,
+		SavedAt:  time.Now(),
+		Checksum: hex.EncodeToString(sum[:]),
+		Response: b,
+		resp:     resp,
+		clusters: clusters,
+	}
+	data, err := json.Marshal(cr)
+	if err != nil {
+		return err
+	}
+	if err := file.AtomicWrite(filepath.Join(c.dir, xdsCacheFileName(resp.TypeUrl)), data, 0o600); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.acked[resp.TypeUrl] = cr
+	c.mu.Unlock()
+	return nil
+}
+
+// mergeEndpoints merges the ClusterLoadAssignments of the EDS response into the previous EDS response, if any, by
+// cluster name, and returns the merged response with its cluster names. SotW EDS pushes are partial: they only hold
+// the assignments of the clusters which changed. Only the assignments of the clusters subscribed by Envoy are kept,
+// or all of them if it did not list any.
+func mergeEndpoints(prev *cachedResponse, resp *discovery.DiscoveryResponse, subscribed []string) (*discovery.DiscoveryResponse,
+	[]string, error,
+) {
+	assignments := map[string]*anypb.Any{}
+	if prev != nil {
+		for i, name := range prev.clusters {
+			assignments[name] = prev.resp.Resources[i]
+		}
+	}
+	names, err := clusterNames(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, name := range names {
+		assignments[name] = resp.Resources[i]
+	}
+	clusters := sets.New[string]()
+	for name := range assignments {
+		clusters.Insert(name)
+	}
+	if len(subscribed) > 0 {
+		clusters = clusters.Intersection(sets.New(subscribed...))
+	}
+	merged := proto.Clone(resp).(*discovery.DiscoveryResponse)
+	merged.Resources = nil
+	sorted := sets.SortedList(clusters)
+	for _, name := range sorted {
+		merged.Resources = append(merged.Resources, assignments[name])
+	}
+	return merged, sorted, nil
+}
+
+// clusterNames returns the cluster names of the ClusterLoadAssignments of the EDS response.
+func clusterNames(resp *discovery.DiscoveryResponse) ([]string, error) {
+	names := make([]string, 0, len(resp.Resources))
+	for _, res := range resp.Resources {
+		cla := &endpoint.ClusterLoadAssignment{}
+		if err := res.UnmarshalTo(cla); err != nil {
+			return nil, err
+		}
+		names = append(names, cla.ClusterName)
+	}
+	return names, nil
+}
+
+// get returns the last known good response of the type, or nil if there is none or it expired.
+func (c *xdsCache) get(typeURL string) *discovery.DiscoveryResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cr := c.acked[typeURL]
+	if cr == nil || c.expired(cr) {
+		return nil
+	}
+	return cr.resp
+}
+
+// empty returns true if there is no last known good response to serve.
+func (c *xdsCache) empty() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cr := range c.acked {
+		if !c.expired(cr) {
+			return false
+		}
+	}
+	return true
+}
+
+// xdsCacheFileName returns the name of the file persisting the responses of the type,
+// for example envoy.config.cluster.v3.Cluster.json.
+func xdsCacheFileName(typeURL string) string {
+	return typeURL[strings.LastIndex(typeURL, "/")+1:] + xdsCacheFileSuffix
+}
@@ -0,0 +1,234 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestXdsCache(t *testing.T) {
+	cds := &discovery.DiscoveryResponse{TypeUrl: v3.ClusterType, VersionInfo: "v1", Nonce: "n1"}
+	lds := &discovery.DiscoveryResponse{TypeUrl: v3.ListenerType, VersionInfo: "v1", Nonce: "n2"}
+
+	t.Run("ACKed responses are persisted", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, c.empty(), true)
+
+		c.forwarded(cds)
+		c.forwarded(lds)
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "v1", ResponseNonce: "n1"})
+		c.requested(&discovery.DiscoveryRequest{
+			TypeUrl:       v3.ListenerType,
+			ResponseNonce: "n2",
+			ErrorDetail:   &google_rpc.Status{Message: "rejected"},
+		})
+		// The responses are persisted by run.
+		assert.Equal(t, c.empty(), true)
+		c.flush()
+		assert.Equal(t, c.empty(), false)
+
+		reloaded, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		if got := reloaded.get(v3.ClusterType); !proto.Equal(got, cds) {
+			t.Fatalf("expected %v, got %v", cds, got)
+		}
+		if got := reloaded.get(v3.ListenerType); got != nil {
+			t.Fatalf("expected NACKed response not to be persisted, got %v", got)
+		}
+	})
+	t.Run("stale nonce is ignored", func(t *testing.T) {
+		c, err := newXdsCache(t.TempDir(), time.Hour)
+		assert.NoError(t, err)
+		c.forwarded(cds)
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "old"})
+		c.flush()
+		assert.Equal(t, c.empty(), true)
+	})
+	t.Run("corrupted file is discarded", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		c.forwarded(cds)
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n1"})
+		c.flush()
+
+		path := filepath.Join(dir, "envoy.config.cluster.v3.Cluster.json")
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		// Flip a byte of the base64 encoded response.
+		b[len(b)-4] ^= 1
+		assert.NoError(t, os.WriteFile(path, b, 0o600))
+
+		reloaded, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, reloaded.empty(), true)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected corrupted file to be removed, got %v", err)
+		}
+	})
+	t.Run("expired file is discarded", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		c.forwarded(cds)
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n1"})
+		c.flush()
+		c.acked[v3.ClusterType].SavedAt = time.Now().Add(-2 * time.Hour)
+		assert.Equal(t, c.get(v3.ClusterType) == nil, true)
+		assert.Equal(t, c.empty(), true)
+
+		reloaded, err := newXdsCache(dir, time.Millisecond)
+		assert.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		assert.Equal(t, reloaded.empty(), true)
+	})
+	t.Run("partial EDS pushes are merged", func(t *testing.T) {
+		cla := func(cluster string, port uint32) *endpoint.ClusterLoadAssignment {
+			return &endpoint.ClusterLoadAssignment{
+				ClusterName: cluster,
+				Endpoints:   []*endpoint.LocalityLbEndpoints{{LoadBalancingWeight: wrapperspb.UInt32(port)}},
+			}
+		}
+		eds := func(nonce string, clas ...*endpoint.ClusterLoadAssignment) *discovery.DiscoveryResponse {
+			resp := &discovery.DiscoveryResponse{TypeUrl: v3.EndpointType, VersionInfo: nonce, Nonce: nonce}
+			for _, c := range clas {
+				resp.Resources = append(resp.Resources, protoconv.MessageToAny(c))
+			}
+			return resp
+		}
+		dir := t.TempDir()
+		c, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+
+		c.forwarded(eds("n1", cla("a", 1), cla("b", 1), cla("c", 1)))
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.EndpointType, ResponseNonce: "n1", ResourceNames: []string{"a", "b", "c"}})
+		c.flush()
+		// Only the endpoints of b changed.
+		c.forwarded(eds("n2", cla("b", 2)))
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.EndpointType, ResponseNonce: "n2", ResourceNames: []string{"a", "b", "c"}})
+		// The cluster c was removed.
+		c.forwarded(eds("n3", cla("a", 3)))
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.EndpointType, ResponseNonce: "n3", ResourceNames: []string{"a", "b"}})
+		// The pushes ACKed since the last write are merged in order.
+		c.flush()
+
+		reloaded, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		want := eds("n3", cla("a", 3), cla("b", 2))
+		if got := reloaded.get(v3.EndpointType); !proto.Equal(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+	t.Run("ACKed responses are persisted in the background", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			c.run(stop)
+			close(done)
+		}()
+
+		c.forwarded(cds)
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n1"})
+		assert.EventuallyEqual(t, c.empty, false)
+
+		// The responses ACKed before stop are persisted.
+		c.forwarded(lds)
+		c.requested(&discovery.DiscoveryRequest{TypeUrl: v3.ListenerType, ResponseNonce: "n2"})
+		close(stop)
+		<-done
+		reloaded, err := newXdsCache(dir, time.Hour)
+		assert.NoError(t, err)
+		if got := reloaded.get(v3.ListenerType); !proto.Equal(got, lds) {
+			t.Fatalf("expected %v, got %v", lds, got)
+		}
+	})
+}
+
+// Validates the last known good configuration is served to Envoy while Istiod is unreachable.
+func TestXdsProxyServesStaleConfig(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	cache, err := newXdsCache(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+	proxy.xdsCache = cache
+	go cache.run(proxy.stopChan)
+
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	setDialOptions(proxy, f.BufListener)
+	node := &core.Node{
+		Id: "sidecar~1.1.1.1~debug~cluster.local",
+		Metadata: model.NodeMetadata{
+			Namespace:   "default",
+			InstanceIPs: []string{"1.1.1.1"},
+		}.ToStruct(),
+	}
+
+	conn := setupDownstreamConnection(t, proxy)
+	downstream := stream(t, conn)
+	assert.NoError(t, downstream.Send(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, Node: node}))
+	cds, err := downstream.Recv()
+	assert.NoError(t, err)
+	assert.NoError(t, downstream.Send(&discovery.DiscoveryRequest{
+		TypeUrl:       v3.ClusterType,
+		VersionInfo:   cds.VersionInfo,
+		ResponseNonce: cds.Nonce,
+	}))
+	assert.EventuallyEqual(t, func() bool { return cache.get(v3.ClusterType) != nil }, true)
+	assert.Equal(t, proxy.ServingStaleConfig(), false)
+	assert.NoError(t, conn.Close())
+
+	// Istiod is now unreachable.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	proxy.istiodAddress = listener.Addr().String()
+	assert.NoError(t, listener.Close())
+	proxy.dialOptions = []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	conn = setupDownstreamConnection(t, proxy)
+	downstream = stream(t, conn)
+	assert.NoError(t, downstream.Send(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, Node: node}))
+	stale, err := downstream.Recv()
+	assert.NoError(t, err)
+	if !proto.Equal(stale, cds) {
+		t.Fatalf("expected last known good response %v, got %v", cds, stale)
+	}
+	assert.Equal(t, proxy.ServingStaleConfig(), true)
+}
@@ -57,6 +57,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream xds.DeltaDiscoveryStream)
 		deltaResponsesChan: make(chan *discovery.DeltaDiscoveryResponse, 1),
 		stopChan:           make(chan struct{}),
 		downstreamDeltas:   downstream,
+		limits:             newRequestLimiter(p.requestLimit, p.requestBurst),
 	}
 	p.registerStream(con)
 	defer p.unregisterStream(con)
@@ -67,6 +68,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream xds.DeltaDiscoveryStream)
 		// Send initial request
 		p.connectedMutex.RLock()
 		initialRequest := p.initialDeltaHealthRequest
+		initialWasmStatusRequest := p.initialDeltaWasmStatusRequest
 		p.connectedMutex.RUnlock()
 
 		for {
@@ -79,6 +81,12 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream xds.DeltaDiscoveryStream)
 				}
 				return
 			}
+			if p.forensics != nil && req.ResponseNonce != "" && req.ErrorDetail == nil {
+				p.forensics.RecordXdsVersion(req.TypeUrl, "", req.ResponseNonce)
+			}
+			if req = con.limits.admitDelta(req, con.stopChan, con.sendDeltaRequest); req == nil {
+				continue
+			}
 			// forward to istiod
 			con.sendDeltaRequest(req)
 			if !initialRequestsSent && req.TypeUrl == v3.ListenerType {
@@ -98,6 +106,12 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream xds.DeltaDiscoveryStream)
 				if initialRequest != nil {
 					con.sendDeltaRequest(initialRequest)
 				}
+				if initialWasmStatusRequest != nil {
+					con.sendDeltaRequest(initialWasmStatusRequest)
+				}
+				if _, crash := p.envoyCrashRequests(); crash != nil {
+					con.sendDeltaRequest(crash)
+				}
 				initialRequestsSent = true
 			}
 		}
@@ -130,6 +144,7 @@ func (p *XdsProxy) handleDeltaUpstream(ctx context.Context, con *ProxyConnection
 		proxyLog.Debugf("failed to create delta upstream grpc client: %v", err)
 		// Increase metric when xds connection error, for example: forgot to restart ingressgateway or sidecar after changing root CA.
 		metrics.IstiodConnectionErrors.Increment()
+		// The last known good configuration is not served on delta streams, see serveStaleConfig.
 		return err
 	}
 	proxyLog.Infof("connected to delta upstream XDS server: %s", p.istiodAddress)
@@ -216,6 +231,9 @@ func (p *XdsProxy) handleUpstreamDeltaRequest(con *ProxyConnection) {
 				con.upstreamError <- err
 				return
 			}
+			if req.TypeUrl == v3.EnvoyCrashType {
+				p.envoyCrashReported(req.ErrorDetail)
+			}
 		case <-con.stopChan:
 			return
 		}
@@ -252,6 +270,15 @@ func (p *XdsProxy) handleUpstreamDeltaResponse(con *ProxyConnection) {
 				})
 				continue
 			}
+			if errorResp := p.checkResponseSize(con, resp.TypeUrl, resp.Nonce, resp); errorResp != nil {
+				// Send NACK, on behalf of Envoy which keeps its current configuration
+				con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
+					TypeUrl:       resp.TypeUrl,
+					ResponseNonce: resp.Nonce,
+					ErrorDetail:   errorResp,
+				})
+				continue
+			}
 			switch resp.TypeUrl {
 			case v3.ExtensionConfigurationType:
 				if features.WasmRemoteLoadConversion {
@@ -285,7 +312,9 @@ func (p *XdsProxy) deltaRewriteAndForward(con *ProxyConnection, resp *discovery.
 		resources = append(resources, resp.Resources[i].Resource)
 	}
 
-	if err := wasm.MaybeConvertWasmExtensionConfig(resources, p.wasmCache); err != nil {
+	err := wasm.MaybeConvertWasmExtensionConfig(resources, p.wasmCache)
+	p.sendWasmModuleStatus()
+	if err != nil {
 		proxyLog.Debugf("sending NACK for ECDS resources %+v", resp.Resources)
 		con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
 			TypeUrl:       v3.ExtensionConfigurationType,
@@ -301,6 +330,7 @@ func (p *XdsProxy) deltaRewriteAndForward(con *ProxyConnection, resp *discovery.
 }
 
 func forwardDeltaToEnvoy(con *ProxyConnection, resp *discovery.DeltaDiscoveryResponse) {
+	con.limits.forwarded(resp.TypeUrl)
 	if err := sendDownstreamDelta(con.downstreamDeltas, resp); err != nil {
 		select {
 		case con.downstreamError <- err:
@@ -0,0 +1,283 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"golang.org/x/time/rate"
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/istio-agent/metrics"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// requestLimiter applies the limits of the xDS requests a downstream connection forwards to istiod. ACKs and NACKs
+// identical to the previous request of their type do not change the state of the stream in istiod and are dropped,
+// and the requests of each type are rate limited with a token bucket. A rate limited request does not block the
+// requests of the other types: it is held as the pending request of its type, coalesced with the requests of the
+// type received in the meantime, and sent once the bucket of the type has a token again. The requests generated
+// by the agent, such as the health and Wasm module status requests and the NACKs on behalf of the proxy, are not
+// limited.
+// It also tracks the upstream responses rejected by the proxy because they exceed the maximum response size.
+type requestLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	// pending holds the rate limited request of each type url, waiting for a token of the bucket of the type.
+	pending map[string]proto.Message
+	// lastRequests holds the last request received from downstream for each type url.
+	lastRequests map[string]proto.Message
+	// rejected holds the last response rejected for each type url, until a response of the type is forwarded
+	// downstream. Istiod ignores the requests with an older nonce than the last one it sent, so the downstream
+	// requests are rewritten to NACK the rejected response.
+	rejected map[string]rejectedResponse
+}
+
+// rejectedResponse is an upstream response rejected by the proxy.
+type rejectedResponse struct {
+	nonce string
+	// errorDetail is the error detail of the NACK of the response.
+	errorDetail *google_rpc.Status
+}
+
+// newRequestLimiter returns a limiter allowing limit requests per second for each type url, with bursts of
+// burst requests. The requests are not rate limited if limit is 0.
+func newRequestLimiter(limit float64, burst int) *requestLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &requestLimiter{
+		limit:        rate.Limit(limit),
+		burst:        burst,
+		limiters:     map[string]*rate.Limiter{},
+		pending:      map[string]proto.Message{},
+		lastRequests: map[string]proto.Message{},
+		rejected:     map[string]rejectedResponse{},
+	}
+}
+
+// admit applies the limits to a request received from downstream. It returns the request to forward to istiod,
+// or nil if the request is dropped or rate limited. A rate limited request is later passed to send, unless stop
+// is closed before. Requests without a nonce are never dropped as duplicates, as istiod answers them as initial
+// requests.
+func (l *requestLimiter) admit(req *discovery.DiscoveryRequest, stop <-chan struct{},
+	send func(*discovery.DiscoveryRequest),
+) *discovery.DiscoveryRequest {
+	if l.duplicate(req.TypeUrl, req, req.ResponseNonce != "") {
+		return nil
+	}
+	// A SotW request holds the whole state of the subscription of its type, the latest one replaces the others.
+	coalesce := func(_, req proto.Message) proto.Message { return req }
+	if !l.allow(req.TypeUrl, req, coalesce, stop, func(req proto.Message) {
+		send(l.withRejectedNonce(req.(*discovery.DiscoveryRequest)))
+	}) {
+		return nil
+	}
+	return l.withRejectedNonce(req)
+}
+
+// admitDelta is the equivalent of admit for delta requests. Only the requests which do not change the
+// subscriptions are dropped as duplicates, as subscribing again to a resource asks istiod to send it.
+func (l *requestLimiter) admitDelta(req *discovery.DeltaDiscoveryRequest, stop <-chan struct{},
+	send func(*discovery.DeltaDiscoveryRequest),
+) *discovery.DeltaDiscoveryRequest {
+	changesSubscriptions := len(req.ResourceNamesSubscribe) > 0 || len(req.ResourceNamesUnsubscribe) > 0 ||
+		len(req.InitialResourceVersions) > 0
+	if l.duplicate(req.TypeUrl, req, !changesSubscriptions) {
+		return nil
+	}
+	coalesce := func(pending, req proto.Message) proto.Message {
+		return mergeDeltaRequests(pending.(*discovery.DeltaDiscoveryRequest), req.(*discovery.DeltaDiscoveryRequest))
+	}
+	if !l.allow(req.TypeUrl, req, coalesce, stop, func(req proto.Message) {
+		send(l.withRejectedDeltaNonce(req.(*discovery.DeltaDiscoveryRequest)))
+	}) {
+		return nil
+	}
+	return l.withRejectedDeltaNonce(req)
+}
+
+// withRejectedNonce returns the request rewritten to NACK the last response of its type rejected by the proxy,
+// if any, so istiod does not record the rejected response as ACKed. The error detail of a NACK of Envoy is kept.
+// The request received from downstream is not modified.
+func (l *requestLimiter) withRejectedNonce(req *discovery.DiscoveryRequest) *discovery.DiscoveryRequest {
+	if rejected, f := l.rejectedResponse(req.TypeUrl); f && req.ResponseNonce != "" {
+		req = proto.Clone(req).(*discovery.DiscoveryRequest)
+		req.ResponseNonce = rejected.nonce
+		if req.ErrorDetail == nil {
+			req.ErrorDetail = rejected.errorDetail
+		}
+	}
+	return req
+}
+
+// withRejectedDeltaNonce is the equivalent of withRejectedNonce for delta requests.
+func (l *requestLimiter) withRejectedDeltaNonce(req *discovery.DeltaDiscoveryRequest) *discovery.DeltaDiscoveryRequest {
+	if rejected, f := l.rejectedResponse(req.TypeUrl); f && req.ResponseNonce != "" {
+		req = proto.Clone(req).(*discovery.DeltaDiscoveryRequest)
+		req.ResponseNonce = rejected.nonce
+		if req.ErrorDetail == nil {
+			req.ErrorDetail = rejected.errorDetail
+		}
+	}
+	return req
+}
+
+// mergeDeltaRequests returns a delta request equivalent to sending pending then req. Delta requests only hold
+// the changes of the subscriptions, which are accumulated, while the nonce and error detail are the ones of req.
+func mergeDeltaRequests(pending, req *discovery.DeltaDiscoveryRequest) *discovery.DeltaDiscoveryRequest {
+	merged := proto.Clone(req).(*discovery.DeltaDiscoveryRequest)
+	if merged.Node == nil {
+		merged.Node = pending.Node
+	}
+	subscribe := sets.New(pending.ResourceNamesSubscribe...)
+	unsubscribe := sets.New(pending.ResourceNamesUnsubscribe...)
+	for _, name := range req.ResourceNamesSubscribe {
+		subscribe.Insert(name)
+		unsubscribe.Delete(name)
+	}
+	for _, name := range req.ResourceNamesUnsubscribe {
+		unsubscribe.Insert(name)
+		subscribe.Delete(name)
+	}
+	merged.ResourceNamesSubscribe = sets.SortedList(subscribe)
+	merged.ResourceNamesUnsubscribe = sets.SortedList(unsubscribe)
+	for name, version := range pending.InitialResourceVersions {
+		if _, f := merged.InitialResourceVersions[name]; !f {
+			if merged.InitialResourceVersions == nil {
+				merged.InitialResourceVersions = map[string]string{}
+			}
+			merged.InitialResourceVersions[name] = version
+		}
+	}
+	return merged
+}
+
+// duplicate returns true if dedup is set and the request is identical to the previous request of its type.
+// Otherwise, it becomes the previous request of its type.
+func (l *requestLimiter) duplicate(typeURL string, req proto.Message, dedup bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, f := l.lastRequests[typeURL]; dedup && f && proto.Equal(last, req) {
+		proxyLog.Debugf("dropping duplicate request for type url %s", typeURL)
+		metrics.XdsProxyRequestsDeduplicated.With(metrics.XdsTypeTag.Value(v3.GetMetricType(typeURL))).Increment()
+		return true
+	}
+	l.lastRequests[typeURL] = req
+	return false
+}
+
+// lastRequest returns the last request received from downstream for the type url, or nil.
+func (l *requestLimiter) lastRequest(typeURL string) proto.Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastRequests[typeURL]
+}
+
+// allow returns true if the rate limit of the type url allows to send the request now. Otherwise, the request
+// becomes the pending request of its type, coalesced with the pending one if any, and the pending request is
+// passed to send once the rate limit allows it, unless stop is closed before.
+func (l *requestLimiter) allow(typeURL string, req proto.Message, coalesce func(pending, req proto.Message) proto.Message,
+	stop <-chan struct{}, send func(proto.Message),
+) bool {
+	if l.limit == 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if pending, f := l.pending[typeURL]; f {
+		// The request is sent after the pending one, with the token reserved for it.
+		l.pending[typeURL] = coalesce(pending, req)
+		metrics.XdsProxyRequestsRateLimited.With(metrics.XdsTypeTag.Value(v3.GetMetricType(typeURL))).Increment()
+		return false
+	}
+	limiter, f := l.limiters[typeURL]
+	if !f {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[typeURL] = limiter
+	}
+	delay := limiter.Reserve().Delay()
+	if delay == 0 {
+		return true
+	}
+	proxyLog.Debugf("rate limiting request for type url %s for %v", typeURL, delay)
+	metrics.XdsProxyRequestsRateLimited.With(metrics.XdsTypeTag.Value(v3.GetMetricType(typeURL))).Increment()
+	l.pending[typeURL] = req
+	time.AfterFunc(delay, func() {
+		l.mu.Lock()
+		req := l.pending[typeURL]
+		delete(l.pending, typeURL)
+		l.mu.Unlock()
+		select {
+		case <-stop:
+		default:
+			send(req)
+		}
+	})
+	return false
+}
+
+// reject records a response of the type url rejected by the proxy, with the error detail of its NACK.
+func (l *requestLimiter) reject(typeURL, nonce string, errorDetail *google_rpc.Status) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rejected[typeURL] = rejectedResponse{nonce: nonce, errorDetail: errorDetail}
+}
+
+// forwarded records that a response of the type url was forwarded downstream.
+func (l *requestLimiter) forwarded(typeURL string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.rejected, typeURL)
+}
+
+// rejectedResponse returns the last response of the type url rejected by the proxy, if no response of the type
+// was forwarded downstream since.
+func (l *requestLimiter) rejectedResponse(typeURL string) (rejectedResponse, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rejected, f := l.rejected[typeURL]
+	return rejected, f
+}
+
+// checkResponseSize returns the error detail of the NACK of a response to forward to Envoy, if it exceeds the
+// maximum response size, or nil. The response is only sized if a maximum response size is set.
+func (p *XdsProxy) checkResponseSize(con *ProxyConnection, typeURL, nonce string, resp proto.Message) *google_rpc.Status {
+	if p.maxResponseSize <= 0 || !v3.IsEnvoyType(typeURL) {
+		return nil
+	}
+	size := proto.Size(resp)
+	if size <= p.maxResponseSize {
+		return nil
+	}
+	proxyLog.Warnf("upstream [%d] rejected response for type url %s: size %d exceeds the maximum response size %d",
+		con.conID, typeURL, size, p.maxResponseSize)
+	metrics.XdsProxyResponsesRejected.With(metrics.XdsTypeTag.Value(v3.GetMetricType(typeURL))).Increment()
+	errorDetail := &google_rpc.Status{
+		Code:    int32(codes.ResourceExhausted),
+		Message: fmt.Sprintf("response size %d exceeds the maximum response size %d of the xds proxy", size, p.maxResponseSize),
+	}
+	con.limits.reject(typeURL, nonce, errorDetail)
+	return errorDetail
+}
@@ -0,0 +1,237 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestRequestLimiterAdmit(t *testing.T) {
+	l := newRequestLimiter(0, 0)
+	stop := make(chan struct{})
+	cases := []struct {
+		name      string
+		req       *discovery.DiscoveryRequest
+		reject    string
+		forwarded bool
+		// wantNonce is the nonce of the request forwarded to istiod, or "dropped".
+		wantNonce string
+		// wantNACK is set if the request forwarded to istiod is a NACK.
+		wantNACK bool
+	}{
+		{
+			name:      "initial request",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType},
+			wantNonce: "",
+		},
+		{
+			name:      "duplicate initial request",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType},
+			wantNonce: "",
+		},
+		{
+			name:      "same request of another type",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ListenerType},
+			wantNonce: "",
+		},
+		{
+			name:      "ACK",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "v1", ResponseNonce: "n1"},
+			wantNonce: "n1",
+		},
+		{
+			name:      "duplicate ACK",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "v1", ResponseNonce: "n1"},
+			wantNonce: "dropped",
+		},
+		{
+			name: "NACK",
+			req: &discovery.DiscoveryRequest{
+				TypeUrl: v3.ClusterType, VersionInfo: "v1", ResponseNonce: "n1", ErrorDetail: &google_rpc.Status{Message: "rejected"},
+			},
+			wantNonce: "n1",
+			wantNACK:  true,
+		},
+		{
+			name:      "request after a rejected response NACKs it",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "v1", ResponseNonce: "n1", ResourceNames: []string{"a"}},
+			reject:    "n2",
+			wantNonce: "n2",
+			wantNACK:  true,
+		},
+		{
+			name:      "later request after a rejected response still NACKs it",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "v1", ResponseNonce: "n1", ResourceNames: []string{"b"}},
+			wantNonce: "n2",
+			wantNACK:  true,
+		},
+		{
+			name:      "request after a forwarded response",
+			req:       &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, VersionInfo: "v3", ResponseNonce: "n3"},
+			forwarded: true,
+			wantNonce: "n3",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.reject != "" {
+				l.reject(tt.req.TypeUrl, tt.reject, &google_rpc.Status{Message: "too large"})
+			}
+			if tt.forwarded {
+				l.forwarded(tt.req.TypeUrl)
+			}
+			got := l.admit(tt.req, stop, nil)
+			if tt.wantNonce == "dropped" {
+				if got != nil {
+					t.Fatalf("expected the request to be dropped, got %v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("expected the request to be forwarded")
+			}
+			assert.Equal(t, got.ResponseNonce, tt.wantNonce)
+			assert.Equal(t, got.ErrorDetail != nil, tt.wantNACK)
+		})
+	}
+	// The request received from downstream is not modified.
+	assert.Equal(t, cases[6].req.ResponseNonce, "n1")
+	assert.Equal(t, cases[6].req.ErrorDetail == nil, true)
+}
+
+func TestRequestLimiterAdmitDelta(t *testing.T) {
+	l := newRequestLimiter(0, 0)
+	stop := make(chan struct{})
+	subscribe := &discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResourceNamesSubscribe: []string{"a"}}
+	ack := &discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResponseNonce: "n1"}
+	if l.admitDelta(subscribe, stop, nil) == nil || l.admitDelta(subscribe, stop, nil) == nil {
+		t.Fatal("expected the subscriptions to be forwarded")
+	}
+	if l.admitDelta(ack, stop, nil) == nil {
+		t.Fatal("expected the ACK to be forwarded")
+	}
+	if l.admitDelta(ack, stop, nil) != nil {
+		t.Fatal("expected the duplicate ACK to be dropped")
+	}
+}
+
+func TestRequestLimiterRateLimit(t *testing.T) {
+	l := newRequestLimiter(10, 1)
+	stop := make(chan struct{})
+	sent := make(chan *discovery.DiscoveryRequest, 10)
+	send := func(req *discovery.DiscoveryRequest) { sent <- req }
+
+	if l.admit(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n1"}, stop, send) == nil {
+		t.Fatal("expected the burst to be allowed")
+	}
+	// The rate limited requests are coalesced into the last one, without blocking the caller.
+	for _, nonce := range []string{"n2", "n3"} {
+		if got := l.admit(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: nonce}, stop, send); got != nil {
+			t.Fatalf("expected the request to be rate limited, got %v", got)
+		}
+	}
+	// Each type has its own bucket.
+	if l.admit(&discovery.DiscoveryRequest{TypeUrl: v3.ListenerType}, stop, send) == nil {
+		t.Fatal("expected the request of another type to be allowed")
+	}
+	// The rejected nonce is applied when the pending request is sent.
+	l.reject(v3.ClusterType, "n4", &google_rpc.Status{Message: "too large"})
+
+	select {
+	case got := <-sent:
+		assert.Equal(t, got.ResponseNonce, "n4")
+		assert.Equal(t, got.ErrorDetail.GetMessage(), "too large")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the pending request to be sent")
+	}
+	select {
+	case got := <-sent:
+		t.Fatalf("expected a single request to be sent, got %v", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// The pending request is not sent once stop is closed.
+	l.admit(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n5"}, stop, send)
+	l.admit(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n6"}, stop, send)
+	close(stop)
+	select {
+	case got := <-sent:
+		t.Fatalf("expected no request to be sent after stop, got %v", got)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestMergeDeltaRequests(t *testing.T) {
+	pending := &discovery.DeltaDiscoveryRequest{
+		TypeUrl:                  v3.EndpointType,
+		ResponseNonce:            "n1",
+		ResourceNamesSubscribe:   []string{"a", "b"},
+		ResourceNamesUnsubscribe: []string{"c"},
+	}
+	req := &discovery.DeltaDiscoveryRequest{
+		TypeUrl:                  v3.EndpointType,
+		ResponseNonce:            "n2",
+		ResourceNamesSubscribe:   []string{"c"},
+		ResourceNamesUnsubscribe: []string{"b", "d"},
+	}
+	got := mergeDeltaRequests(pending, req)
+	assert.Equal(t, got.ResponseNonce, "n2")
+	assert.Equal(t, got.ResourceNamesSubscribe, []string{"a", "c"})
+	assert.Equal(t, got.ResourceNamesUnsubscribe, []string{"b", "d"})
+	// The requests received from downstream are not modified.
+	assert.Equal(t, pending.ResourceNamesSubscribe, []string{"a", "b"})
+	assert.Equal(t, req.ResourceNamesSubscribe, []string{"c"})
+}
+
+func TestXdsProxyMaxResponseSize(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	proxy.maxResponseSize = 1
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	setDialOptions(proxy, f.BufListener)
+	conn := setupDownstreamConnection(t, proxy)
+	downstream := stream(t, conn)
+
+	node := &core.Node{
+		Id:       "sidecar~1.1.1.1~debug~cluster.local",
+		Metadata: model.NodeMetadata{Namespace: "default", InstanceIPs: []string{"1.1.1.1"}}.ToStruct(),
+	}
+	if err := downstream.Send(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, Node: node}); err != nil {
+		t.Fatal(err)
+	}
+	// The response is rejected instead of being forwarded to Envoy.
+	retry.UntilSuccessOrFail(t, func() error {
+		proxy.connectedMutex.RLock()
+		defer proxy.connectedMutex.RUnlock()
+		if proxy.connected == nil {
+			return fmt.Errorf("not connected")
+		}
+		if _, f := proxy.connected.limits.rejectedResponse(v3.ClusterType); !f {
+			return fmt.Errorf("response not rejected")
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(10*time.Millisecond))
+}
@@ -50,6 +50,7 @@ import (
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/retry"
 	wasmcache "istio.io/istio/pkg/wasm"
 )
@@ -456,6 +457,62 @@ func (f *fakeNackCache) Get(string, wasmcache.GetOptions) (string, error) {
 }
 func (f *fakeNackCache) Cleanup() {}
 
+type fakeEnvoyCrashRecorder chan string
+
+func (r fakeEnvoyCrashRecorder) RecordEnvoyCrash(proxy *model.Proxy, message string) {
+	r <- proxy.ID + ": " + message
+}
+
+// Validates a crash of Envoy is reported once to istiod, on the connection following it.
+func TestXdsProxyReportsEnvoyCrash(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	crashes := make(fakeEnvoyCrashRecorder, 10)
+	f.Discovery.EnvoyCrashRecorder = crashes
+	setDialOptions(proxy, f.BufListener)
+	conn := setupDownstreamConnection(t, proxy)
+	meta := model.NodeMetadata{Namespace: "default", InstanceIPs: []string{"1.1.1.1"}}
+
+	// The crash bundle is written by the agent before its restart.
+	opts := envoy.ForensicsOptions{Dir: t.TempDir(), MaxBundles: 1}
+	crashed, err := envoy.NewForensics(opts, "localhost", 0, nil)
+	assert.NoError(t, err)
+	dir, err := crashed.WriteBundle(errors.New("signal: aborted"))
+	assert.NoError(t, err)
+	proxy.ia.envoyForensics, err = envoy.NewForensics(opts, "localhost", 0, nil)
+	assert.NoError(t, err)
+	proxy.ia.reportEnvoyCrashes()
+
+	downstream := stream(t, conn)
+	sendDownstreamWithNode(t, downstream, meta)
+	select {
+	case got := <-crashes:
+		if want := "debug: Envoy exited unexpectedly (signal: aborted), crash bundle written to " + dir; got != want {
+			t.Fatalf("unexpected crash report %q, expected %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the crash to be reported")
+	}
+	assert.EventuallyEqual(t, func() int { return len(proxy.ia.envoyForensics.UnreportedBundles()) }, 0)
+
+	// The crash is not reported again on the next connections.
+	assert.NoError(t, downstream.CloseSend())
+	retry.UntilSuccessOrFail(t, func() error {
+		proxy.connectedMutex.Lock()
+		defer proxy.connectedMutex.Unlock()
+		if proxy.connected != nil {
+			return fmt.Errorf("still connected")
+		}
+		return nil
+	}, retry.Timeout(time.Second), retry.Delay(time.Millisecond))
+	sendDownstreamWithNode(t, stream(t, conn), meta)
+	select {
+	case got := <-crashes:
+		t.Fatalf("unexpected crash report %q", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
 func TestECDSWasmConversion(t *testing.T) {
 	node := model.NodeMetadata{
 		Namespace:   "default",
@@ -45,6 +45,12 @@ func FindSidecar(containers []corev1.Container) *corev1.Container {
 	return FindContainer(ProxyContainerName, containers)
 }
 
+// FindNativeSidecar returns the pointer to the "istio-proxy" container injected as a native sidecar, in the
+// init containers of the pod.
+func FindNativeSidecar(spec *corev1.PodSpec) *corev1.Container {
+	return FindContainer(ProxyContainerName, spec.InitContainers)
+}
+
 // FindContainer returns the pointer to the first container whose name matches.
 func FindContainer(name string, containers []corev1.Container) *corev1.Container {
 	for i := range containers {
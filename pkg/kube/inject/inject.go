@@ -43,6 +43,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	proxyConfig "istio.io/api/networking/v1beta1"
 	opconfig "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/pkg/log"
 )
@@ -80,6 +81,9 @@ const (
 
 	// EnableCoreDumpName is the name of the init container that allows core dumps
 	EnableCoreDumpName = "enable-core-dump"
+
+	// nativeSidecarRestartPolicy is the restartPolicy of the init containers run as native sidecars
+	nativeSidecarRestartPolicy = "Always"
 )
 
 const (
@@ -103,6 +107,7 @@ type SidecarTemplateData struct {
 	Values         map[string]any
 	Revision       string
 	ProxyImage     string
+	NativeSidecar  bool
 }
 
 type (
@@ -403,6 +408,7 @@ func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod
 		Values:         params.valuesConfig.asMap,
 		Revision:       params.revision,
 		ProxyImage:     ProxyImage(params.valuesConfig.asStruct, params.proxyConfig.Image, strippedPod.Annotations),
+		NativeSidecar:  params.nativeSidecar,
 	}
 
 	mergedPod = params.pod
@@ -473,6 +479,8 @@ func stripPod(req InjectionParameters) *corev1.Pod {
 	// To ensure idempotency, remove our injected containers first
 	for _, c := range prevStatus.Containers {
 		pod.Spec.Containers = modifyContainers(pod.Spec.Containers, c, Remove)
+		// The proxy may have been injected as a native sidecar
+		pod.Spec.InitContainers = modifyContainers(pod.Spec.InitContainers, c, Remove)
 	}
 	for _, c := range prevStatus.InitContainers {
 		pod.Spec.InitContainers = modifyContainers(pod.Spec.InitContainers, c, Remove)
@@ -558,7 +566,13 @@ func IntoResourceFile(injector Injector, sidecarTemplate Templates,
 			if err != nil {
 				return err
 			}
-			if updated, err = yaml.Marshal(outObject); err != nil {
+			if updated, err = json.Marshal(outObject); err != nil {
+				return err
+			}
+			if updated, err = setNativeSidecarRestartPolicy(updated); err != nil {
+				return err
+			}
+			if updated, err = yaml.JSONToYAML(updated); err != nil {
 				return err
 			}
 		} else {
@@ -754,6 +768,7 @@ func IntoObject(injector Injector, sidecarTemplate Templates, valuesConfig Value
 			revision:            revision,
 			proxyEnvs:           map[string]string{},
 			injectedAnnotations: nil,
+			nativeSidecar:       features.EnableNativeSidecars,
 		}
 		patchBytes, err = injectPod(params)
 	}
@@ -34,6 +34,7 @@ import (
 	meshapi "istio.io/api/mesh/v1alpha1"
 	proxyConfig "istio.io/api/networking/v1beta1"
 	opconfig "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/test/util"
 	"istio.io/istio/pkg/config/constants"
@@ -305,6 +306,45 @@ func TestInjection(t *testing.T) {
 				m.DefaultConfig.Tracing = &meshapi.Tracing{}
 			},
 		},
+		{
+			// Verifies that the proxy is injected as a native sidecar
+			in:   "hello.yaml",
+			want: "hello.native-sidecar.yaml.injected",
+			setup: func(t test.Failer) {
+				test.SetForTest(t, &features.EnableNativeSidecars, true)
+			},
+		},
+		{
+			// Verifies that HoldApplicationUntilProxyStarts is not needed by a native sidecar
+			in:   "hello-probes.yaml",
+			want: "hello-probes.native-sidecar.yaml.injected",
+			setFlags: []string{
+				`values.global.proxy.holdApplicationUntilProxyStarts=true`,
+			},
+			setup: func(t test.Failer) {
+				test.SetForTest(t, &features.EnableNativeSidecars, true)
+			},
+		},
+		{
+			in:   "gateway.yaml",
+			want: "gateway.yaml.injected",
+		},
+		{
+			// Verifies that the proxy of a gateway, its only container, is not injected as a native sidecar
+			in:   "gateway.yaml",
+			want: "gateway.yaml.injected",
+			setup: func(t test.Failer) {
+				test.SetForTest(t, &features.EnableNativeSidecars, true)
+			},
+		},
+		{
+			// Verifies that a job is injected with a native sidecar, which does not keep it running
+			in:   "job.yaml",
+			want: "job.native-sidecar.yaml.injected",
+			setup: func(t test.Failer) {
+				test.SetForTest(t, &features.EnableNativeSidecars, true)
+			},
+		},
 	}
 	// Keep track of tests we add options above
 	// We will search for all test files and skip these ones
@@ -452,8 +492,9 @@ func TestInjection(t *testing.T) {
 							ProxyConfigs: &model.ProxyConfigs{},
 						},
 					},
-					valuesConfig: valuesConfig,
-					revision:     "default",
+					valuesConfig:   valuesConfig,
+					revision:       "default",
+					nativeSidecars: features.EnableNativeSidecars,
 				}
 				// Split multi-part yaml documents. Input and output will have the same number of parts.
 				inputYAMLs := splitYamlFile(inputFilePath, t)
@@ -45,6 +45,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	opconfig "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
 	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/kube"
@@ -108,6 +109,8 @@ type Webhook struct {
 
 	env      *model.Environment
 	revision string
+	// nativeSidecars is true if the proxy is injected as a native sidecar.
+	nativeSidecars bool
 }
 
 func (wh *Webhook) GetConfig() WebhookConfig {
@@ -176,6 +179,9 @@ type WebhookParameters struct {
 
 	// The istio.io/rev this injector is responsible for
 	Revision string
+
+	// KubeClient, if set, is used to check that the cluster supports native sidecars.
+	KubeClient kube.Client
 }
 
 // NewWebhook creates a new instance of a mutating webhook for automatic sidecar injection.
@@ -185,10 +191,11 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 	}
 
 	wh := &Webhook{
-		watcher:    p.Watcher,
-		meshConfig: p.Env.Mesh(),
-		env:        p.Env,
-		revision:   p.Revision,
+		watcher:        p.Watcher,
+		meshConfig:     p.Env.Mesh(),
+		env:            p.Env,
+		revision:       p.Revision,
+		nativeSidecars: features.EnableNativeSidecars && nativeSidecarsSupported(p.KubeClient),
 	}
 
 	mc := NewMulticast(p.Watcher, wh.GetConfig)
@@ -214,6 +221,23 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 	return wh, nil
 }
 
+// nativeSidecarsMinorVersion is the minor version of the first Kubernetes release enabling the SidecarContainers
+// feature by default.
+const nativeSidecarsMinorVersion = 29
+
+// nativeSidecarsSupported returns false if the Kubernetes version of the cluster does not enable native sidecars by
+// default. The pods of such clusters would never start, as their proxy init container never completes.
+func nativeSidecarsSupported(client kube.Client) bool {
+	if client == nil {
+		return true
+	}
+	if !kube.IsAtLeastVersion(client, nativeSidecarsMinorVersion) {
+		log.Warnf("ENABLE_NATIVE_SIDECARS is ignored: native sidecars require Kubernetes 1.%d or later", nativeSidecarsMinorVersion)
+		return false
+	}
+	return true
+}
+
 // Run implements the webhook server
 func (wh *Webhook) Run(stop <-chan struct{}) {
 	go wh.watcher.Run(stop)
@@ -343,6 +367,8 @@ type InjectionParameters struct {
 	revision            string
 	proxyEnvs           map[string]string
 	injectedAnnotations map[string]string
+	// nativeSidecar is true if the proxy is injected as a native sidecar, for the pods with application containers.
+	nativeSidecar bool
 }
 
 func checkPreconditions(params InjectionParameters) {
@@ -405,6 +431,8 @@ func getInjectionStatus(podSpec corev1.PodSpec, revision string) string {
 // re-ordering pods, rewriting readiness probes, etc.
 func injectPod(req InjectionParameters) ([]byte, error) {
 	checkPreconditions(req)
+	// A pod whose only container is the proxy, such as a gateway, must keep it as a regular container.
+	req.nativeSidecar = req.nativeSidecar && hasApplicationContainers(req.pod)
 
 	// The patch will be built relative to the initial pod, capture its current state
 	originalPodSpec, err := json.Marshal(req.pod)
@@ -591,6 +619,11 @@ func createPatch(pod *corev1.Pod, original []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if FindNativeSidecar(&pod.Spec) != nil {
+		if reinjected, err = setNativeSidecarRestartPolicy(reinjected); err != nil {
+			return nil, err
+		}
+	}
 	p, err := jsonpatch.CreatePatch(original, reinjected)
 	if err != nil {
 		return nil, err
@@ -665,15 +698,42 @@ func reorderPod(pod *corev1.Pod, req InjectionParameters) error {
 	// Proxy container should be last, unless HoldApplicationUntilProxyStarts is set
 	// This is to ensure `kubectl exec` and similar commands continue to default to the user's container
 	pod.Spec.Containers = modifyContainers(pod.Spec.Containers, ProxyContainerName, proxyLocation)
+	if req.nativeSidecar {
+		moveProxyToInitContainers(pod)
+	}
 	// Validation container must be first to block any user containers
 	pod.Spec.InitContainers = modifyContainers(pod.Spec.InitContainers, ValidationContainerName, MoveFirst)
 	// Init container must be last to allow any traffic to pass before iptables is setup
 	pod.Spec.InitContainers = modifyContainers(pod.Spec.InitContainers, InitContainerName, MoveLast)
 	pod.Spec.InitContainers = modifyContainers(pod.Spec.InitContainers, EnableCoreDumpName, MoveLast)
+	// As a native sidecar, the proxy must be started after the other init containers, before the application
+	pod.Spec.InitContainers = modifyContainers(pod.Spec.InitContainers, ProxyContainerName, MoveLast)
 
 	return nil
 }
 
+// hasApplicationContainers returns true if the pod has containers other than the proxy.
+func hasApplicationContainers(pod *corev1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != ProxyContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// moveProxyToInitContainers moves the injected proxy container to the init containers of the pod, to run it as a
+// native sidecar. A proxy previously injected as a native sidecar is replaced.
+func moveProxyToInitContainers(pod *corev1.Pod) {
+	sidecar := FindSidecar(pod.Spec.Containers)
+	if sidecar == nil {
+		return
+	}
+	proxy := *sidecar
+	pod.Spec.Containers = modifyContainers(pod.Spec.Containers, ProxyContainerName, Remove)
+	pod.Spec.InitContainers = append(modifyContainers(pod.Spec.InitContainers, ProxyContainerName, Remove), proxy)
+}
+
 func applyRewrite(pod *corev1.Pod, req InjectionParameters) error {
 	sidecar := FindSidecar(pod.Spec.Containers)
 	if sidecar == nil {
@@ -1008,6 +1068,7 @@ func (wh *Webhook) inject(ar *kube.AdmissionReview, path string) *kube.Admission
 		revision:            wh.revision,
 		injectedAnnotations: wh.Config.InjectedAnnotations,
 		proxyEnvs:           parseInjectEnvs(path),
+		nativeSidecar:       wh.nativeSidecars,
 	}
 	wh.mu.RUnlock()
 
@@ -1163,3 +1224,43 @@ func handleError(message string) {
 	log.Errorf(message)
 	totalFailedInjections.Increment()
 }
+
+// setNativeSidecarRestartPolicy sets the restartPolicy of the proxy init containers of the serialized object to Always,
+// running them as native sidecars. The Kubernetes API types in use predate the restartPolicy of containers, and drop
+// it, so it is set on the serialized object instead.
+func setNativeSidecarRestartPolicy(obj []byte) ([]byte, error) {
+	var out any
+	if err := json.Unmarshal(obj, &out); err != nil {
+		return nil, err
+	}
+	if !setProxyRestartPolicy(out) {
+		return obj, nil
+	}
+	return json.Marshal(out)
+}
+
+// setProxyRestartPolicy sets the restartPolicy of the proxy init containers found in obj, and returns true if any.
+func setProxyRestartPolicy(obj any) bool {
+	found := false
+	switch o := obj.(type) {
+	case map[string]any:
+		for k, v := range o {
+			if k != "initContainers" {
+				found = setProxyRestartPolicy(v) || found
+				continue
+			}
+			containers, _ := v.([]any)
+			for _, c := range containers {
+				if container, ok := c.(map[string]any); ok && container["name"] == ProxyContainerName {
+					container["restartPolicy"] = nativeSidecarRestartPolicy
+					found = true
+				}
+			}
+		}
+	case []any:
+		for _, v := range o {
+			found = setProxyRestartPolicy(v) || found
+		}
+	}
+	return found
+}
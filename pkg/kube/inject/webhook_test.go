@@ -54,6 +54,7 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/test/util/file"
 	"istio.io/istio/pkg/test/util/retry"
 	sutil "istio.io/istio/security/pkg/nodeagent/util"
@@ -1448,6 +1449,62 @@ func TestParseStatus(t *testing.T) {
 	}
 }
 
+func TestSetNativeSidecarRestartPolicy(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "pod",
+			in:   `{"spec":{"initContainers":[{"name":"istio-init"},{"name":"istio-proxy"}],"containers":[{"name":"app"}]}}`,
+			want: `{"spec":{"containers":[{"name":"app"}],"initContainers":[{"name":"istio-init"},{"name":"istio-proxy","restartPolicy":"Always"}]}}`,
+		},
+		{
+			name: "list of templates",
+			in:   `{"items":[{"spec":{"template":{"spec":{"initContainers":[{"name":"istio-proxy"}]}}}}]}`,
+			want: `{"items":[{"spec":{"template":{"spec":{"initContainers":[{"name":"istio-proxy","restartPolicy":"Always"}]}}}}]}`,
+		},
+		{
+			name: "proxy as container",
+			in:   `{"spec":{"initContainers":[{"name":"istio-init"}],"containers":[{"name":"istio-proxy"}]}}`,
+			want: `{"spec":{"initContainers":[{"name":"istio-init"}],"containers":[{"name":"istio-proxy"}]}}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := setNativeSidecarRestartPolicy([]byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("Expected result %s, but got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNativeSidecarsSupported(t *testing.T) {
+	cases := []struct {
+		minor string
+		want  bool
+	}{
+		{minor: "28", want: false},
+		{minor: "29", want: true},
+		{minor: "30", want: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.minor, func(t *testing.T) {
+			if got := nativeSidecarsSupported(kube.NewFakeClientWithVersion(tc.minor)); got != tc.want {
+				t.Fatalf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+	if !nativeSidecarsSupported(nil) {
+		t.Fatalf("Expected native sidecars to be supported without a client")
+	}
+}
+
 func newProxyConfig(name, ns string, spec config.Spec) config.Config {
 	return config.Config{
 		Meta: config.Meta{
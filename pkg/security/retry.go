@@ -15,11 +15,17 @@
 package security
 
 import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"istio.io/istio/security/pkg/monitoring"
 	"istio.io/pkg/log"
@@ -27,19 +33,123 @@ import (
 
 var caLog = log.RegisterScope("ca", "ca client")
 
+// RetryAfterMetadata is the gRPC header or trailer a CA may set on a failed response to hint how long
+// the client should wait before retrying. The value uses the format of the HTTP Retry-After header:
+// either a number of seconds or an HTTP date.
+const RetryAfterMetadata = "retry-after"
+
+// maxRetryAfter caps the delay a single Retry-After hint can impose on a CA request.
+const maxRetryAfter = time.Minute
+
+// caRetryMax is the maximum number of attempts of a CA call.
+const caRetryMax = 5
+
+// caRetryCodes are the codes of the failed CA calls which are retried.
+var caRetryCodes = []codes.Code{codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unavailable}
+
 // CARetryOptions returns the default retry options recommended for CA calls
 // This includes 5 retries, with backoff from 100ms -> 1.6s with jitter.
 var CARetryOptions = []retry.CallOption{
-	retry.WithMax(5),
+	retry.WithMax(caRetryMax),
 	retry.WithBackoff(wrapBackoffWithMetrics(retry.BackoffExponentialWithJitter(100*time.Millisecond, 0.1))),
-	retry.WithCodes(codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unavailable),
+	retry.WithCodes(caRetryCodes...),
 }
 
 // CARetryInterceptor is a grpc UnaryInterceptor that adds retry options, as a convenience wrapper
-// around CARetryOptions. If needed to chain with other interceptors, the CARetryOptions can be used
-// directly.
+// around CARetryOptions. Each attempt additionally honors a Retry-After hint sent by the CA.
+// If needed to chain with other interceptors, the CARetryOptions and RetryAfterInterceptor can be
+// used directly.
 func CARetryInterceptor() grpc.DialOption {
-	return grpc.WithUnaryInterceptor(retry.UnaryClientInterceptor(CARetryOptions...))
+	return grpc.WithChainUnaryInterceptor(retry.UnaryClientInterceptor(CARetryOptions...), RetryAfterInterceptor())
+}
+
+// RetryAfterInterceptor returns a grpc UnaryClientInterceptor that, when a call fails and the server
+// attached a RetryAfterMetadata hint, waits for the hinted duration (bounded by maxRetryAfter and the
+// call context) before returning the error. It must be chained after the retry interceptor configured
+// with CARetryOptions: it only waits when the error is retried and another attempt follows, delaying
+// the next attempt and allowing an overloaded CA to spread out renewals.
+func RetryAfterInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		var header, trailer metadata.MD
+		opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer))
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			return nil
+		}
+		if !retried(ctx, err) {
+			return err
+		}
+		wait, ok := retryAfter(time.Now(), trailer, header)
+		if !ok {
+			return err
+		}
+		caLog.Warnf("ca request failed, server requested to retry after %v", wait)
+		monitoring.NumRetryAfterHints.With(monitoring.Method.Value(method)).Increment()
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		return err
+	}
+}
+
+// retried returns true if the retry interceptor configured with CARetryOptions retries the call which failed
+// with err: the error code is retried and the attempt, set in the outgoing metadata, is not the last one.
+func retried(ctx context.Context, err error) bool {
+	code := status.Code(err)
+	retryable := false
+	for _, c := range caRetryCodes {
+		if c == code {
+			retryable = true
+			break
+		}
+	}
+	if !retryable {
+		return false
+	}
+	attempt := uint64(0)
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if v := md.Get(retry.AttemptMetadataKey); len(v) > 0 {
+			attempt, _ = strconv.ParseUint(v[0], 10, 32)
+		}
+	}
+	return attempt+1 < caRetryMax
+}
+
+// retryAfter extracts the first valid Retry-After hint from the given metadata.
+func retryAfter(now time.Time, mds ...metadata.MD) (time.Duration, bool) {
+	for _, md := range mds {
+		for _, v := range md.Get(RetryAfterMetadata) {
+			if wait, ok := parseRetryAfter(now, v); ok {
+				return wait, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After value, as defined in RFC 9110 section 10.2.3.
+func parseRetryAfter(now time.Time, v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	var wait time.Duration
+	if secs, err := strconv.ParseUint(v, 10, 32); err == nil {
+		wait = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		wait = t.Sub(now)
+	} else {
+		return 0, false
+	}
+	if wait <= 0 {
+		return 0, false
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
 }
 
 // grpcretry has no hooks to trigger logic on failure (https://github.com/grpc-ecosystem/go-grpc-middleware/issues/375)
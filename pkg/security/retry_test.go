@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		md     []metadata.MD
+		want   time.Duration
+		wantOk bool
+	}{
+		{
+			name: "no hint",
+			md:   []metadata.MD{nil, metadata.Pairs("other", "1")},
+		},
+		{
+			name:   "seconds",
+			md:     []metadata.MD{metadata.Pairs(RetryAfterMetadata, "5")},
+			want:   5 * time.Second,
+			wantOk: true,
+		},
+		{
+			name:   "http date",
+			md:     []metadata.MD{metadata.Pairs(RetryAfterMetadata, now.Add(10*time.Second).Format(http.TimeFormat))},
+			want:   10 * time.Second,
+			wantOk: true,
+		},
+		{
+			name:   "capped",
+			md:     []metadata.MD{metadata.Pairs(RetryAfterMetadata, "3600")},
+			want:   maxRetryAfter,
+			wantOk: true,
+		},
+		{
+			name: "date in the past",
+			md:   []metadata.MD{metadata.Pairs(RetryAfterMetadata, now.Add(-time.Minute).Format(http.TimeFormat))},
+		},
+		{
+			name: "invalid",
+			md:   []metadata.MD{metadata.Pairs(RetryAfterMetadata, "soon")},
+		},
+		{
+			name:   "trailer before header",
+			md:     []metadata.MD{metadata.Pairs(RetryAfterMetadata, "2"), metadata.Pairs(RetryAfterMetadata, "7")},
+			want:   2 * time.Second,
+			wantOk: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(now, tt.md...)
+			if ok != tt.wantOk || got != tt.want {
+				t.Fatalf("expected %v/%v, got %v/%v", tt.want, tt.wantOk, got, ok)
+			}
+		})
+	}
+}
+
+func TestRetryAfterInterceptor(t *testing.T) {
+	invoker := func(code codes.Code) grpc.UnaryInvoker {
+		return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			for _, o := range opts {
+				if tr, ok := o.(grpc.TrailerCallOption); ok {
+					*tr.TrailerAddr = metadata.Pairs(RetryAfterMetadata, "10")
+				}
+			}
+			return status.Error(code, "failed")
+		}
+	}
+	cases := []struct {
+		name     string
+		code     codes.Code
+		attempt  string
+		wantWait bool
+	}{
+		{
+			name:     "retried code",
+			code:     codes.Unavailable,
+			wantWait: true,
+		},
+		{
+			name:     "retried code on a retry",
+			code:     codes.ResourceExhausted,
+			attempt:  "3",
+			wantWait: true,
+		},
+		{
+			name:    "last attempt",
+			code:    codes.Unavailable,
+			attempt: fmt.Sprint(caRetryMax - 1),
+		},
+		{
+			name: "code not retried",
+			code: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			// The interceptor waits until the context is done, as the hint is longer than its timeout.
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			if tt.attempt != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, retry.AttemptMetadataKey, tt.attempt)
+			}
+			start := time.Now()
+			err := RetryAfterInterceptor()(ctx, "/test/Method", nil, nil, nil, invoker(tt.code))
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected code %v, got %v", tt.code, err)
+			}
+			if waited := time.Since(start) >= 200*time.Millisecond; waited != tt.wantWait {
+				t.Fatalf("expected wait %v, got %v", tt.wantWait, waited)
+			}
+		})
+	}
+}
@@ -100,6 +100,9 @@ const (
 	// JWT is a Credential fetcher type that reads from a JWT token file
 	JWT = "JWT"
 
+	// Metadata is Credential fetcher type of the plugin that fetches tokens from a local metadata endpoint
+	Metadata = "Metadata"
+
 	// Mock is Credential fetcher type of mock plugin
 	Mock = "Mock" // testing only
 
@@ -216,6 +219,12 @@ type Options struct {
 	// we would refresh 6 minutes before expiration.
 	SecretRotationGracePeriodRatio float64
 
+	// The ratio of cert lifetime over which a random amount of additional grace period is added
+	// when scheduling a refresh. For example, at 0.10 grace period ratio, 0.05 jitter ratio and 1 hour TTL,
+	// we would refresh between 6 and 9 minutes before expiration. This spreads out renewals of
+	// certificates issued at the same time, so that they do not all hit the CA at once.
+	SecretRotationJitterRatio float64
+
 	// STS port
 	STSPort int
 
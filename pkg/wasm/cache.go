@@ -17,6 +17,7 @@ package wasm
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -29,8 +30,10 @@ import (
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/sync/singleflight"
 
 	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/util/sets"
 	"istio.io/pkg/log"
 )
@@ -43,6 +46,9 @@ const (
 
 	// sha256 scheme prefix
 	sha256SchemePrefix = "sha256:"
+
+	// scopeSeparator separates the module name or URL from the scope of a cache entry.
+	scopeSeparator = "#"
 )
 
 // Cache models a Wasm module cache.
@@ -68,6 +74,23 @@ type LocalFileCache struct {
 
 	// option sets for configurating the cache.
 	cacheOptions
+	// verificationKeys verify the signatures of the Wasm modules, if VerificationKeys option is set.
+	verificationKeys []crypto.PublicKey
+	// verificationKeysErr is the error loading the verification keys. If set, all Wasm modules are rejected.
+	verificationKeysErr error
+	// inflight deduplicates the concurrent fetches of the same module.
+	inflight singleflight.Group
+	// nodeCache is the client of the node level cache, queried before fetching modules from their URL.
+	nodeCache *nodeCacheClient
+	// isolatePullSecrets is true if the modules pulled with a secret are only shared with the requests
+	// having the same secret. Set by the node level cache, shared by the pods of the node.
+	isolatePullSecrets bool
+
+	// statusMux protects moduleStatuses.
+	statusMux sync.Mutex
+	// moduleStatuses is the status of the module of each resource, by resource name.
+	moduleStatuses map[string]model.WasmModuleStatus
+
 	// stopChan currently is only used by test
 	stopChan chan struct{}
 }
@@ -96,6 +119,31 @@ type cacheKey struct {
 	// Resource version of WasmPlugin resource. Even though PullPolicy is Always,
 	// if there is no change of resource state, a cached entry is used instead of pulling newly.
 	resourceVersion string
+	// Hex-Encoded sha256 checksum of the image pulling secret, if any.
+	pullSecretHash string
+	// Scope of the cache entries, isolating the modules pulled with different secrets. Empty if not isolated.
+	scope string
+}
+
+// checksumKey returns the key of the checksum cache, the URL in the scope of the key.
+func (k cacheKey) checksumKey() string {
+	if k.scope == "" {
+		return k.downloadURL
+	}
+	return k.downloadURL + scopeSeparator + k.scope
+}
+
+// fetchKey identifies the concurrent fetches of the same module, which are deduplicated.
+func (k cacheKey) fetchKey() string {
+	return strings.Join([]string{k.downloadURL, k.checksum, k.pullSecretHash}, " ")
+}
+
+// fetchResult is the result of a module fetch.
+type fetchResult struct {
+	entry    *cacheEntry
+	checksum string
+	// source the module is loaded from, one of the model.WasmModuleSource* values.
+	source string
 }
 
 // cacheEntry contains information about a Wasm module cache entry.
@@ -104,7 +152,7 @@ type cacheEntry struct {
 	modulePath string
 	// Last time that this local Wasm module is referenced.
 	last time.Time
-	// set of URLs referencing this entry
+	// set of URLs referencing this entry, in the scope of the entry
 	referencingURLs sets.String
 }
 
@@ -134,6 +182,9 @@ func (o cacheOptions) sanitize() cacheOptions {
 	if o.HTTPRequestMaxRetries != 0 {
 		ret.HTTPRequestMaxRetries = o.HTTPRequestMaxRetries
 	}
+	ret.VerificationKeys = o.VerificationKeys
+	ret.MaxCacheSize = o.MaxCacheSize
+	ret.NodeCacheAddress = o.NodeCacheAddress
 
 	return ret
 }
@@ -148,12 +199,27 @@ func NewLocalFileCache(dir string, options Options) *LocalFileCache {
 
 	cacheOptions := cacheOptions{Options: options}
 	cache := &LocalFileCache{
-		httpFetcher:  NewHTTPFetcher(options.HTTPRequestTimeout, options.HTTPRequestMaxRetries),
-		modules:      make(map[moduleKey]*cacheEntry),
-		checksums:    make(map[string]*checksumEntry),
-		dir:          dir,
-		cacheOptions: cacheOptions.sanitize(),
-		stopChan:     make(chan struct{}),
+		httpFetcher:    NewHTTPFetcher(options.HTTPRequestTimeout, options.HTTPRequestMaxRetries),
+		modules:        make(map[moduleKey]*cacheEntry),
+		checksums:      make(map[string]*checksumEntry),
+		dir:            dir,
+		cacheOptions:   cacheOptions.sanitize(),
+		moduleStatuses: make(map[string]model.WasmModuleStatus),
+		stopChan:       make(chan struct{}),
+	}
+	if options.NodeCacheAddress != "" {
+		if len(options.VerificationKeys) > 0 {
+			// The agent could not verify the modules served by the node level cache are the signed ones.
+			wasmLog.Warnf("the Wasm node cache %s is not used, as the signatures of the modules are verified", options.NodeCacheAddress)
+		} else {
+			cache.nodeCache = newNodeCacheClient(options.NodeCacheAddress)
+		}
+	}
+	if len(options.VerificationKeys) > 0 {
+		cache.verificationKeys, cache.verificationKeysErr = loadVerificationKeys(options.VerificationKeys)
+		if cache.verificationKeysErr != nil {
+			wasmLog.Errorf("all Wasm modules will be rejected: %v", cache.verificationKeysErr)
+		}
 	}
 
 	go func() {
@@ -198,7 +264,17 @@ func getModulePath(baseDir string, mkey moduleKey) (string, error) {
 
 // Get returns path the local Wasm module file.
 func (c *LocalFileCache) Get(downloadURL string, opts GetOptions) (string, error) {
-	// Construct Wasm cache key with downloading URL and provided checksum of the module.
+	res, err := c.getOrFetch(c.newCacheKey(downloadURL, opts), opts)
+	c.recordFetch(downloadURL, opts.ResourceName, res, err)
+	if err != nil {
+		return "", err
+	}
+
+	return res.entry.modulePath, err
+}
+
+// newCacheKey constructs Wasm cache key with downloading URL and provided checksum of the module.
+func (c *LocalFileCache) newCacheKey(downloadURL string, opts GetOptions) cacheKey {
 	key := cacheKey{
 		downloadURL: downloadURL,
 		moduleKey: moduleKey{
@@ -208,47 +284,96 @@ func (c *LocalFileCache) Get(downloadURL string, opts GetOptions) (string, error
 		resourceName:    opts.ResourceName,
 		resourceVersion: opts.ResourceVersion,
 	}
-
-	entry, err := c.getOrFetch(key, opts)
-	if err != nil {
-		return "", err
+	if len(opts.PullSecret) > 0 {
+		sha := sha256.Sum256(opts.PullSecret)
+		key.pullSecretHash = hex.EncodeToString(sha[:])
+		if c.isolatePullSecrets {
+			// A module pulled with a secret is only shared with the requests having the same secret.
+			key.scope = key.pullSecretHash
+			key.name += scopeSeparator + key.scope
+		}
 	}
-
-	return entry.modulePath, err
+	return key
 }
 
-func (c *LocalFileCache) getOrFetch(key cacheKey, opts GetOptions) (*cacheEntry, error) {
+// getOrFetch returns the cache entry of the module and its checksum, fetching the module if it is not cached.
+func (c *LocalFileCache) getOrFetch(key cacheKey, opts GetOptions) (fetchResult, error) {
 	u, err := url.Parse(key.downloadURL)
 	if err != nil {
-		return nil, fmt.Errorf("fail to parse Wasm module fetch url: %s, error: %v", key.downloadURL, err)
+		return fetchResult{}, fmt.Errorf("fail to parse Wasm module fetch url: %s, error: %v", key.downloadURL, err)
 	}
 
 	// First check if the cache entry is already downloaded and policy does not require to pull always.
 	ce, checksum := c.getEntry(key, shouldIgnoreResourceVersion(opts.PullPolicy, u))
 	if ce != nil {
-		return ce, nil
+		return fetchResult{entry: ce, checksum: checksum, source: model.WasmModuleSourceCache}, nil
 	}
 	key.checksum = checksum
-	// Fetch the image now as it is not available in cache.
+
+	// Deduplicate the concurrent fetches of the same module.
+	v, err, shared := c.inflight.Do(key.fetchKey(), func() (any, error) {
+		return c.fetch(key, opts, u)
+	})
+	if err != nil {
+		return fetchResult{}, err
+	}
+	res := v.(fetchResult)
+	if shared {
+		// The entry was added for another request, record the resource version of this one.
+		key.checksum = res.checksum
+		c.mux.Lock()
+		if c.updateChecksum(key) {
+			res.entry.referencingURLs.Insert(key.checksumKey())
+		}
+		c.mux.Unlock()
+	}
+	return res, nil
+}
+
+// fetch fetches the module, from the node level cache if configured for OCI images or from its URL,
+// and adds it to the cache.
+func (c *LocalFileCache) fetch(key cacheKey, opts GetOptions, u *url.URL) (fetchResult, error) {
+	var err error
 	var b []byte         // Byte array of Wasm binary.
 	var dChecksum string // Hex-Encoded sha256 checksum of binary.
 	var binaryFetcher func() ([]byte, error)
+	var signatureVerifier func() error
 	insecure := c.allowInsecure(u.Host)
 
 	ctx, cancel := context.WithTimeout(context.Background(), opts.RequestTimeout)
 	defer cancel()
+	fetchedFromNode := false
+	// The node level cache only returns the digest of the image along with the binary, which the agent cannot
+	// check against the binary. The modules pinned with a checksum are always fetched from their URL.
+	if c.nodeCache != nil && u.Scheme == "oci" && opts.Checksum == "" {
+		b, dChecksum, err = c.nodeCache.fetch(ctx, key.downloadURL, opts)
+		if err == nil {
+			fetchedFromNode = true
+			wasmNodeCacheFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
+		} else {
+			wasmNodeCacheFetchCount.With(resultTag.Value(downloadFailure)).Increment()
+			wasmLog.Warnf("failed to fetch Wasm module %s from the node cache, fetching it from its URL: %v", key.downloadURL, err)
+		}
+	}
 	switch u.Scheme {
 	case "http", "https":
 		// Download the Wasm module with http fetcher.
 		b, err = c.httpFetcher.Fetch(ctx, key.downloadURL, insecure)
 		if err != nil {
 			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
-			return nil, err
+			return fetchResult{}, err
 		}
 
 		// Get sha256 checksum and check if it is the same as provided one.
 		sha := sha256.Sum256(b)
 		dChecksum = hex.EncodeToString(sha[:])
+		signatureVerifier = func() error {
+			sig, err := c.httpFetcher.Fetch(ctx, key.downloadURL+signatureSuffix, insecure)
+			if err != nil {
+				return fmt.Errorf("could not fetch signature: %v", err)
+			}
+			return verifySignature(c.verificationKeys, b, decodeSignature(sig))
+		}
 	case "oci":
 		imgFetcherOps := ImageFetcherOption{
 			Insecure: insecure,
@@ -258,43 +383,79 @@ func (c *LocalFileCache) getOrFetch(key cacheKey, opts GetOptions) (*cacheEntry,
 		}
 		wasmLog.Debugf("fetching oci image from %s with options: %v", key.downloadURL, imgFetcherOps)
 		fetcher := NewImageFetcher(ctx, imgFetcherOps)
-		binaryFetcher, dChecksum, err = fetcher.PrepareFetch(u.Host + u.Path)
-		if err != nil {
-			wasmRemoteFetchCount.With(resultTag.Value(manifestFailure)).Increment()
-			return nil, fmt.Errorf("could not fetch Wasm OCI image: %v", err)
+		if !fetchedFromNode {
+			binaryFetcher, dChecksum, err = fetcher.PrepareFetch(u.Host + u.Path)
+			if err != nil {
+				wasmRemoteFetchCount.With(resultTag.Value(manifestFailure)).Increment()
+				return fetchResult{}, fmt.Errorf("could not fetch Wasm OCI image: %v", err)
+			}
+		}
+		signatureVerifier = func() error {
+			sigs, err := fetcher.fetchSignatures(u.Host+u.Path, dChecksum)
+			if err != nil {
+				return err
+			}
+			return verifyImageSignatures(c.verificationKeys, sigs, dChecksum)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported Wasm module downloading URL scheme: %v", u.Scheme)
+		return fetchResult{}, fmt.Errorf("unsupported Wasm module downloading URL scheme: %v", u.Scheme)
 	}
 
 	if key.checksum == "" {
 		key.checksum = dChecksum
 		// check again if the cache is having the checksum.
 		if ce, _ := c.getEntry(key, true); ce != nil {
-			return ce, nil
+			return fetchResult{entry: ce, checksum: key.checksum, source: model.WasmModuleSourceCache}, nil
 		}
 	} else if dChecksum != key.checksum {
 		wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
-		return nil, fmt.Errorf("module downloaded from %v has checksum %v, which does not match: %v", key.downloadURL, dChecksum, key.checksum)
+		return fetchResult{}, fmt.Errorf("module downloaded from %v has checksum %v, which does not match: %v", key.downloadURL, dChecksum, key.checksum)
+	}
+
+	// Verify the signature before downloading the binary of OCI images.
+	if err := c.verifySignature(signatureVerifier); err != nil {
+		wasmRemoteFetchCount.With(resultTag.Value(signatureFailure)).Increment()
+		return fetchResult{}, fmt.Errorf("signature verification of Wasm module %s failed: %v", key.downloadURL, err)
 	}
 
 	if binaryFetcher != nil {
 		b, err = binaryFetcher()
 		if err != nil {
 			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
-			return nil, fmt.Errorf("could not fetch Wasm binary: %v", err)
+			return fetchResult{}, fmt.Errorf("could not fetch Wasm binary: %v", err)
 		}
 	}
 
 	if !isValidWasmBinary(b) {
 		wasmRemoteFetchCount.With(resultTag.Value(fetchFailure)).Increment()
-		return nil, fmt.Errorf("fetched Wasm binary from %s is invalid", key.downloadURL)
+		return fetchResult{}, fmt.Errorf("fetched Wasm binary from %s is invalid", key.downloadURL)
 	}
 
-	wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
+	if !fetchedFromNode {
+		wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
+	}
 
 	key.checksum = dChecksum
-	return c.addEntry(key, b)
+	ce, err := c.addEntry(key, b)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	source := model.WasmModuleSourceRemote
+	if fetchedFromNode {
+		source = model.WasmModuleSourceNodeCache
+	}
+	return fetchResult{entry: ce, checksum: dChecksum, source: source}, nil
+}
+
+// verifySignature runs the signature verifier of the module if the signatures must be verified.
+func (c *LocalFileCache) verifySignature(verifier func() error) error {
+	if len(c.VerificationKeys) == 0 {
+		return nil
+	}
+	if c.verificationKeysErr != nil {
+		return c.verificationKeysErr
+	}
+	return verifier()
 }
 
 // Cleanup closes background Wasm module purge routine.
@@ -306,11 +467,11 @@ func (c *LocalFileCache) updateChecksum(key cacheKey) bool {
 	// If OCI URL having a tag or just http/https URL, we need to update checksum.
 	needChecksumUpdate := !strings.HasPrefix(key.downloadURL, ociURLPrefix) || !strings.Contains(key.downloadURL, "@")
 	if needChecksumUpdate {
-		ce := c.checksums[key.downloadURL]
+		ce := c.checksums[key.checksumKey()]
 		if ce == nil {
 			ce = new(checksumEntry)
 			ce.resourceVersionByResource = make(map[string]string)
-			c.checksums[key.downloadURL] = ce
+			c.checksums[key.checksumKey()] = ce
 		}
 		ce.checksum = key.checksum
 		ce.resourceVersionByResource[key.resourceName] = key.resourceVersion
@@ -330,7 +491,7 @@ func (c *LocalFileCache) addEntry(key cacheKey, wasmModule []byte) (*cacheEntry,
 		// Update last touched time.
 		ce.last = time.Now()
 		if needChecksumUpdate {
-			ce.referencingURLs.Insert(key.downloadURL)
+			ce.referencingURLs.Insert(key.checksumKey())
 		}
 		return ce, nil
 	}
@@ -350,9 +511,10 @@ func (c *LocalFileCache) addEntry(key cacheKey, wasmModule []byte) (*cacheEntry,
 		referencingURLs: sets.New[string](),
 	}
 	if needChecksumUpdate {
-		ce.referencingURLs.Insert(key.downloadURL)
+		ce.referencingURLs.Insert(key.checksumKey())
 	}
 	c.modules[key.moduleKey] = &ce
+	c.evict(key.moduleKey)
 	wasmCacheEntries.Record(float64(len(c.modules)))
 	return &ce, nil
 }
@@ -381,7 +543,7 @@ func (c *LocalFileCache) getEntry(key cacheKey, ignoreResourceVersion bool) (*ca
 	if len(key.checksum) == 0 {
 		// If no checksum, try the checksum cache.
 		// If the image was pulled before, there should be a checksum of the most recently pulled image.
-		if ce, found := c.checksums[key.downloadURL]; found {
+		if ce, found := c.checksums[key.checksumKey()]; found {
 			if ignoreResourceVersion || key.resourceVersion == ce.resourceVersionByResource[key.resourceName] {
 				// update checksum
 				key.checksum = ce.checksum
@@ -414,15 +576,7 @@ func (c *LocalFileCache) purge() {
 					continue
 				}
 				// The module has not be touched for expiry duration, delete it from the map as well as the local dir.
-				if err := os.Remove(m.modulePath); err != nil {
-					wasmLog.Errorf("failed to purge Wasm module %v: %v", m.modulePath, err)
-				} else {
-					for downloadURL := range m.referencingURLs {
-						delete(c.checksums, downloadURL)
-					}
-					delete(c.modules, k)
-					wasmLog.Debugf("successfully removed stale Wasm module %v", m.modulePath)
-				}
+				c.removeEntry(k, m)
 			}
 			wasmCacheEntries.Record(float64(len(c.modules)))
 			c.mux.Unlock()
@@ -433,6 +587,51 @@ func (c *LocalFileCache) purge() {
 	}
 }
 
+// removeEntry deletes the module from the map as well as the local dir, and returns true on success.
+// The caller must hold the cache lock.
+func (c *LocalFileCache) removeEntry(k moduleKey, m *cacheEntry) bool {
+	if err := os.Remove(m.modulePath); err != nil {
+		wasmLog.Errorf("failed to purge Wasm module %v: %v", m.modulePath, err)
+		return false
+	}
+	for checksumKey := range m.referencingURLs {
+		delete(c.checksums, checksumKey)
+	}
+	delete(c.modules, k)
+	wasmLog.Debugf("successfully removed Wasm module %v", m.modulePath)
+	return true
+}
+
+// evict removes the least recently used modules, except the added one, until the modules fit in MaxCacheSize.
+// The caller must hold the cache lock.
+func (c *LocalFileCache) evict(added moduleKey) {
+	if c.MaxCacheSize <= 0 {
+		return
+	}
+	sizes := make(map[moduleKey]int64, len(c.modules))
+	var total int64
+	for k, m := range c.modules {
+		if fi, err := os.Stat(m.modulePath); err == nil {
+			sizes[k] = fi.Size()
+			total += fi.Size()
+		}
+	}
+	for total > c.MaxCacheSize {
+		var lruKey moduleKey
+		var lru *cacheEntry
+		for k, m := range c.modules {
+			if k != added && (lru == nil || m.last.Before(lru.last)) {
+				lruKey, lru = k, m
+			}
+		}
+		if lru == nil || !c.removeEntry(lruKey, lru) {
+			return
+		}
+		wasmCacheEvictionCount.Increment()
+		total -= sizes[lruKey]
+	}
+}
+
 // Expired returns true if the module has not been touched for Wasm module Expiry.
 func (ce *cacheEntry) expired(expiry time.Duration) bool {
 	now := time.Now()
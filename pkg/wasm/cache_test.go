@@ -15,8 +15,12 @@
 package wasm
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -37,6 +41,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 
 	extensions "istio.io/api/extensions/v1alpha1"
@@ -958,3 +963,195 @@ func generateModulePath(t *testing.T, baseDir, resourceName, filename string) st
 	}
 	return filepath.Join(moduleDir, filename)
 }
+
+func TestWasmCacheSignatureVerification(t *testing.T) {
+	signer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherSigner, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	binary := append(wasmHeader, []byte("data")...)
+
+	// Set up a http server serving the signed, unsigned and wrongly signed modules.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/signed.wasm.sig":
+			w.Write([]byte(base64.StdEncoding.EncodeToString(sign(t, signer, binary))))
+		case "/wrongly-signed.wasm.sig":
+			w.Write([]byte(base64.StdEncoding.EncodeToString(sign(t, otherSigner, binary))))
+		case "/signed.wasm", "/unsigned.wasm", "/wrongly-signed.wasm":
+			w.Write(binary)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// Set up a registry with the same image, signed by cosign in one of two repositories.
+	tos := httptest.NewServer(registry.New())
+	defer tos.Close()
+	ou, err := url.Parse(tos.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerImageDigest, _ := setupOCIRegistry(t, ou.Host)
+	img, err := crane.Pull(fmt.Sprintf("%s/test/valid/docker:v0.1.0", ou.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, fmt.Sprintf("%s/test/unsigned:v0.1.0", ou.Host)); err != nil {
+		t.Fatal(err)
+	}
+	payload := cosignPayload(dockerImageDigest)
+	sigLayer := static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json")
+	sigImg, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       sigLayer,
+		Annotations: map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sign(t, signer, payload))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(sigImg, fmt.Sprintf("%s/test/valid/docker:sha256-%s.sig", ou.Host, dockerImageDigest)); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := writeVerificationKey(t, signer.Public())
+	cases := []struct {
+		name    string
+		url     string
+		keys    []string
+		wantErr string
+	}{
+		{name: "http signed", url: ts.URL + "/signed.wasm", keys: []string{keyPath}},
+		{name: "http unsigned", url: ts.URL + "/unsigned.wasm", keys: []string{keyPath}, wantErr: "could not fetch signature"},
+		{name: "http wrongly signed", url: ts.URL + "/wrongly-signed.wasm", keys: []string{keyPath}, wantErr: "not verified"},
+		{name: "http unsigned without keys", url: ts.URL + "/unsigned.wasm"},
+		{name: "oci signed", url: fmt.Sprintf("oci://%s/test/valid/docker:v0.1.0", ou.Host), keys: []string{keyPath}},
+		{name: "oci unsigned", url: fmt.Sprintf("oci://%s/test/unsigned:v0.1.0", ou.Host), keys: []string{keyPath}, wantErr: "could not fetch signatures"},
+		{
+			name:    "invalid keys reject all modules",
+			url:     ts.URL + "/signed.wasm",
+			keys:    []string{filepath.Join(t.TempDir(), "missing.pub")},
+			wantErr: "failed to read Wasm verification key",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.VerificationKeys = c.keys
+			cache := NewLocalFileCache(t.TempDir(), options)
+			defer close(cache.stopChan)
+
+			_, err := cache.Get(c.url, GetOptions{
+				ResourceName:   "namespace.resource",
+				RequestTimeout: time.Second * 10,
+			})
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("failed to download Wasm module: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v, want error containing %q", err, c.wantErr)
+			}
+			if len(cache.modules) != 0 {
+				t.Fatalf("rejected Wasm module was cached")
+			}
+		})
+	}
+}
+
+func TestWasmCacheEviction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Modules of 108 bytes, different per path.
+		w.Write(append(append(wasmHeader, []byte(r.URL.Path)...), make([]byte, 100-len(r.URL.Path))...))
+	}))
+	defer ts.Close()
+
+	options := defaultOptions()
+	options.MaxCacheSize = 250
+	cache := NewLocalFileCache(t.TempDir(), options)
+	defer close(cache.stopChan)
+
+	get := func(path string) string {
+		t.Helper()
+		p, err := cache.Get(ts.URL+path, GetOptions{ResourceName: "namespace.resource", RequestTimeout: time.Second * 10})
+		if err != nil {
+			t.Fatalf("failed to download Wasm module: %v", err)
+		}
+		return p
+	}
+	first := get("/first")
+	second := get("/second")
+	// Touch the first module, so the second one is the least recently used.
+	get("/first")
+	third := get("/third")
+
+	for p, wantExist := range map[string]bool{first: true, second: false, third: true} {
+		if _, err := os.Stat(p); (err == nil) != wantExist {
+			t.Errorf("module %v exists: %v, want %v", p, err == nil, wantExist)
+		}
+	}
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	if len(cache.modules) != 2 {
+		t.Errorf("got %d cached modules, want 2", len(cache.modules))
+	}
+	if _, found := cache.checksums[ts.URL+"/second"]; found {
+		t.Errorf("checksum of the evicted module is still cached")
+	}
+}
+
+func TestWasmCacheDeduplicatesFetches(t *testing.T) {
+	numRequest := int32(0)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequest, 1)
+		<-release
+		w.Write(append(wasmHeader, []byte("data")...))
+	}))
+	defer ts.Close()
+
+	cache := NewLocalFileCache(t.TempDir(), defaultOptions())
+	defer close(cache.stopChan)
+
+	const concurrency = 5
+	paths := make(chan string, concurrency)
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func(i int) {
+			p, err := cache.Get(ts.URL, GetOptions{
+				ResourceName:    fmt.Sprintf("namespace.resource-%d", i),
+				ResourceVersion: "1",
+				RequestTimeout:  time.Second * 10,
+			})
+			paths <- p
+			errs <- err
+		}(i)
+	}
+	// Wait for the first fetch to reach the server, and the others to join it.
+	for atomic.LoadInt32(&numRequest) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	want := ""
+	for i := 0; i < concurrency; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("failed to download Wasm module: %v", err)
+		}
+		p := <-paths
+		if want == "" {
+			want = p
+		} else if p != want {
+			t.Errorf("got module path %v, want %v", p, want)
+		}
+	}
+	if got := atomic.LoadInt32(&numRequest); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	if got := len(cache.checksums[ts.URL].resourceVersionByResource); got != concurrency {
+		t.Errorf("got resource versions of %d resources, want %d", got, concurrency)
+	}
+}
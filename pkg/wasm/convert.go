@@ -80,20 +80,25 @@ func MaybeConvertWasmExtensionConfig(resources []*anypb.Any, cache Cache) error
 				return
 			}
 
+			downloadURL := wasmConfig.GetConfig().GetVmConfig().GetCode().GetRemote().GetHttpUri().GetUri()
 			newExtensionConfig, err := convertWasmConfigFromRemoteToLocal(extConfig, wasmConfig, cache)
 			if err != nil {
 				if !wasmConfig.GetConfig().GetFailOpen() {
+					recordConversion(cache, extConfig.GetName(), downloadURL, err, false)
 					convertErrs[i] = err
 					return
 				}
 				// Use NOOP filter because the download failed.
+				convertErr := err
 				newExtensionConfig, err = createAllowAllFilter(extConfig.GetName())
 				if err != nil {
 					// If the fallback is failing, send the Nack regardless of fail_open.
 					err = fmt.Errorf("failed to create allow-all filter as a fallback of %s Wasm Module: %w", extConfig.GetName(), err)
+					recordConversion(cache, extConfig.GetName(), downloadURL, err, false)
 					convertErrs[i] = err
 					return
 				}
+				recordConversion(cache, extConfig.GetName(), downloadURL, convertErr, true)
 			}
 
 			resources[i] = newExtensionConfig
@@ -108,6 +113,14 @@ func MaybeConvertWasmExtensionConfig(resources []*anypb.Any, cache Cache) error
 	return err
 }
 
+// recordConversion records the failure of the conversion of the extension config of a resource,
+// if the cache reports the status of the modules.
+func recordConversion(cache Cache, resourceName, downloadURL string, err error, allowAll bool) {
+	if r, ok := cache.(conversionRecorder); ok {
+		r.recordConversion(resourceName, downloadURL, err, allowAll)
+	}
+}
+
 // tryUnmarshal returns the typed extension config and wasm config by unmarsharling `resource`,
 // if `resource` is a wasm config loading a wasm module from the remote site.
 // It returns `nil` for both the typed extension config and wasm config if it is not for the remote wasm or has an error.
@@ -41,7 +41,6 @@ import (
 // The spec is here https://github.com/solo-io/wasm/blob/master/spec/README.md.
 // Basically, this supports fetching and unpackaging three types of container images containing a Wasm binary.
 type ImageFetcherOption struct {
-	// TODO(mathetake) Add signature verification stuff.
 	PullSecret []byte
 	Insecure   bool
 }
@@ -91,27 +90,8 @@ func NewImageFetcher(ctx context.Context, opt ImageFetcherOption) *ImageFetcher
 // Wasm binary is not fetched immediately, but returned by `binaryFetcher` function, which is returned by PrepareFetch.
 // By this way, we can have another chance to check cache with `actualDigest` without downloading the OCI image.
 func (o *ImageFetcher) PrepareFetch(url string) (binaryFetcher func() ([]byte, error), actualDigest string, err error) {
-	ref, err := name.ParseReference(url)
-	if err != nil {
-		err = fmt.Errorf("could not parse url in image reference: %v", err)
-		return
-	}
-	wasmLog.Infof("fetching image %s from registry %s with tag %s", ref.Context().RepositoryStr(),
-		ref.Context().RegistryStr(), ref.Identifier())
-
-	// fallback to http based request, inspired by [helm](https://github.com/helm/helm/blob/12f1bc0acdeb675a8c50a78462ed3917fb7b2e37/pkg/registry/client.go#L594)
-	// only deal with https fallback instead of attributing all other type of errors to URL parsing error
-	desc, err := remote.Get(ref, o.fetchOpts...)
-	if err != nil && strings.Contains(err.Error(), "server gave HTTP response") {
-		wasmLog.Infof("fetching image with plain text from %s", url)
-		ref, err = name.ParseReference(url, name.Insecure)
-		if err == nil {
-			desc, err = remote.Get(ref, o.fetchOpts...)
-		}
-	}
-
+	desc, err := o.getDescriptor(url)
 	if err != nil {
-		err = fmt.Errorf("could not fetch manifest: %v", err)
 		return
 	}
 
@@ -165,6 +145,75 @@ func (o *ImageFetcher) PrepareFetch(url string) (binaryFetcher func() ([]byte, e
 	return
 }
 
+// getDescriptor fetches the descriptor of the image referenced by url.
+func (o *ImageFetcher) getDescriptor(url string) (*remote.Descriptor, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url in image reference: %v", err)
+	}
+	wasmLog.Infof("fetching image %s from registry %s with tag %s", ref.Context().RepositoryStr(),
+		ref.Context().RegistryStr(), ref.Identifier())
+
+	// fallback to http based request, inspired by [helm](https://github.com/helm/helm/blob/12f1bc0acdeb675a8c50a78462ed3917fb7b2e37/pkg/registry/client.go#L594)
+	// only deal with https fallback instead of attributing all other type of errors to URL parsing error
+	desc, err := remote.Get(ref, o.fetchOpts...)
+	if err != nil && strings.Contains(err.Error(), "server gave HTTP response") {
+		wasmLog.Infof("fetching image with plain text from %s", url)
+		ref, err = name.ParseReference(url, name.Insecure)
+		if err == nil {
+			desc, err = remote.Get(ref, o.fetchOpts...)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch manifest: %v", err)
+	}
+	return desc, nil
+}
+
+// fetchSignatures fetches the signatures attached by cosign to the image referenced by url, with the manifest digest.
+// They are stored as the layers of the image tagged `sha256-<digest>.sig` in the same repository.
+func (o *ImageFetcher) fetchSignatures(url, digest string) ([]signature, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url in image reference: %v", err)
+	}
+	desc, err := o.getDescriptor(ref.Context().Name() + ":sha256-" + digest + signatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch signatures: %v", err)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch signatures: %v", err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve signatures manifest: %v", err)
+	}
+	sigs := make([]signature, 0, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		sig, found := l.Annotations[cosignSignatureAnnotation]
+		if !found {
+			continue
+		}
+		layer, err := img.LayerByDigest(l.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch signed payload: %v", err)
+		}
+		r, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch signed payload: %v", err)
+		}
+		payload, err := io.ReadAll(io.LimitReader(r, maxSignatureSize))
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read signed payload: %v", err)
+		}
+		sigs = append(sigs, signature{payload: payload, signature: decodeSignature([]byte(sig))})
+	}
+	return sigs, nil
+}
+
 // extractDockerImage extracts the Wasm binary from the
 // *compat* variant Wasm image with the standard Docker media type: application/vnd.docker.image.rootfs.diff.tar.gzip.
 // https://github.com/solo-io/wasm/blob/master/spec/spec-compat.md#specification
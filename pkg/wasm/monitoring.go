@@ -23,6 +23,7 @@ const (
 	downloadFailure  = "download_failure"
 	manifestFailure  = "manifest_failure"
 	checksumMismatch = "checksum_mismatched"
+	signatureFailure = "signature_verification_failure"
 
 	// For Wasm conversion metric.
 	conversionSuccess   = "success"
@@ -50,7 +51,18 @@ var (
 
 	wasmRemoteFetchCount = monitoring.NewSum(
 		"wasm_remote_fetch_count",
-		"number of Wasm remote fetches and results, including success, download failure, and checksum mismatch.",
+		"number of Wasm remote fetches and results, including success, download failure, checksum mismatch, and signature verification failure.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	wasmCacheEvictionCount = monitoring.NewSum(
+		"wasm_cache_eviction_count",
+		"number of Wasm modules removed from the cache to fit in its disk budget.",
+	)
+
+	wasmNodeCacheFetchCount = monitoring.NewSum(
+		"wasm_node_cache_fetch_count",
+		"number of Wasm module fetches from the node level cache and results, including success and download failure.",
 		monitoring.WithLabels(resultTag),
 	)
 
@@ -72,6 +84,8 @@ func init() {
 		wasmCacheEntries,
 		wasmCacheLookupCount,
 		wasmRemoteFetchCount,
+		wasmCacheEvictionCount,
+		wasmNodeCacheFetchCount,
 		wasmConfigConversionCount,
 		wasmConfigConversionDuration,
 	)
@@ -0,0 +1,218 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/uds"
+)
+
+// This file implements the node level cache of Wasm OCI images, shared by the istio-agents of a node over a UDS,
+// so a module is pulled from its registry once per node rather than once per pod. Modules downloaded with
+// HTTP/HTTPS are not served, as the node level cache would then send arbitrary requests from the node network.
+// The agents cannot check the modules served match their digest, so the modules pinned with a checksum or whose
+// signatures are verified are never fetched from the node level cache.
+// The node level cache is a LocalFileCache: concurrent fetches of a module are deduplicated, the modules are
+// purged after ModuleExpiry, and the least recently used ones are removed beyond MaxCacheSize.
+
+const (
+	nodeCacheFetchPath = "/v1/fetch"
+	// nodeCacheChecksumHeader is the header of the fetch response holding the manifest digest of the image.
+	nodeCacheChecksumHeader = "X-Wasm-Checksum"
+	// maxNodeCacheRequestSize limits the size of the fetch requests.
+	maxNodeCacheRequestSize = 64 * 1024
+
+	// maxModuleSize limits the size of the modules; in reality it must be much smaller.
+	maxModuleSize = 1024 * 1024 * 256
+)
+
+// nodeCacheRequest is the body of the fetch request sent by the agents to the node level cache.
+type nodeCacheRequest struct {
+	URL             string                `json:"url"`
+	Checksum        string                `json:"checksum,omitempty"`
+	ResourceName    string                `json:"resourceName,omitempty"`
+	ResourceVersion string                `json:"resourceVersion,omitempty"`
+	RequestTimeout  time.Duration         `json:"requestTimeout,omitempty"`
+	PullSecret      []byte                `json:"pullSecret,omitempty"`
+	PullPolicy      extensions.PullPolicy `json:"pullPolicy,omitempty"`
+}
+
+// NodeCacheServer serves the Wasm modules of the node level cache.
+type NodeCacheServer struct {
+	cache  *LocalFileCache
+	server *http.Server
+}
+
+// NewNodeCacheServer creates the node level cache, storing the modules in dir.
+func NewNodeCacheServer(dir string, options Options) *NodeCacheServer {
+	// The node level cache fetches the modules from their URL. The agents verifying the signatures of the
+	// modules, or pinning their checksum, do not use it.
+	options.NodeCacheAddress = ""
+	options.VerificationKeys = nil
+	cache := NewLocalFileCache(dir, options)
+	cache.isolatePullSecrets = true
+	s := &NodeCacheServer{cache: cache}
+	mux := http.NewServeMux()
+	mux.HandleFunc(nodeCacheFetchPath, s.handleFetch)
+	s.server = &http.Server{
+		Handler: mux,
+	}
+	return s
+}
+
+// Start starts serving the node level cache on the UDS address, until stop is closed.
+func (s *NodeCacheServer) Start(address string, stop <-chan struct{}) error {
+	wasmLog.Infof("starting Wasm node cache server on %s", address)
+	l, err := uds.NewListener(address)
+	if err != nil {
+		return fmt.Errorf("failed to create UDS listener: %v", err)
+	}
+	go func() {
+		if err := s.server.Serve(l); network.IsUnexpectedListenerError(err) {
+			wasmLog.Errorf("error running Wasm node cache server: %v", err)
+		}
+	}()
+	go func() {
+		<-stop
+		if err := s.server.Close(); err != nil {
+			wasmLog.Errorf("Wasm node cache server terminated with error: %v", err)
+		}
+		s.cache.Cleanup()
+	}()
+	return nil
+}
+
+func (s *NodeCacheServer) handleFetch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := nodeCacheRequest{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNodeCacheRequestSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !strings.HasPrefix(req.URL, ociURLPrefix) {
+		http.Error(w, "only OCI images are served", http.StatusBadRequest)
+		return
+	}
+	opts := GetOptions{
+		Checksum:        req.Checksum,
+		ResourceName:    req.ResourceName,
+		ResourceVersion: req.ResourceVersion,
+		RequestTimeout:  req.RequestTimeout,
+		PullSecret:      req.PullSecret,
+		PullPolicy:      req.PullPolicy,
+	}
+	res, err := s.getOrFetch(req.URL, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	// The module may have been purged in the meantime, the agent fetches it from its URL then.
+	b, err := os.ReadFile(res.entry.modulePath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read Wasm module: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set(nodeCacheChecksumHeader, res.checksum)
+	w.Header().Set("Content-Type", "application/wasm")
+	_, _ = w.Write(b)
+}
+
+// Prewarm fetches the OCI image of a module ahead of the requests of the agents, so the first pod of the node
+// loading the module does not wait for its registry.
+func (s *NodeCacheServer) Prewarm(downloadURL string, opts GetOptions) error {
+	if !strings.HasPrefix(downloadURL, ociURLPrefix) {
+		return fmt.Errorf("only OCI images are served, got %s", downloadURL)
+	}
+	_, err := s.getOrFetch(downloadURL, opts)
+	return err
+}
+
+func (s *NodeCacheServer) getOrFetch(downloadURL string, opts GetOptions) (fetchResult, error) {
+	if opts.RequestTimeout == 0 {
+		opts.RequestTimeout = s.cache.HTTPRequestTimeout
+	}
+	return s.cache.getOrFetch(s.cache.newCacheKey(downloadURL, opts), opts)
+}
+
+// nodeCacheClient fetches the modules from the node level cache.
+type nodeCacheClient struct {
+	client *http.Client
+}
+
+func newNodeCacheClient(address string) *nodeCacheClient {
+	return &nodeCacheClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", address)
+				},
+			},
+		},
+	}
+}
+
+// fetch returns the binary of the module and its checksum.
+func (n *nodeCacheClient) fetch(ctx context.Context, downloadURL string, opts GetOptions) ([]byte, string, error) {
+	body, err := json.Marshal(nodeCacheRequest{
+		URL:             downloadURL,
+		Checksum:        opts.Checksum,
+		ResourceName:    opts.ResourceName,
+		ResourceVersion: opts.ResourceVersion,
+		RequestTimeout:  opts.RequestTimeout,
+		PullSecret:      opts.PullSecret,
+		PullPolicy:      opts.PullPolicy,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	// The host is ignored, requests are sent to the UDS.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://wasm-node-cache"+nodeCacheFetchPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxModuleSize))
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status code %v: %s", resp.StatusCode, bytes.TrimSpace(b))
+	}
+	checksum := resp.Header.Get(nodeCacheChecksumHeader)
+	if checksum == "" {
+		return nil, "", fmt.Errorf("missing %s header", nodeCacheChecksumHeader)
+	}
+	return b, checksum, nil
+}
@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestNodeCache(t *testing.T) {
+	registryRequests := int32(0)
+	reg := registry.New()
+	tos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&registryRequests, 1)
+		reg.ServeHTTP(w, r)
+	}))
+	defer tos.Close()
+	ou, err := url.Parse(tos.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerImageDigest, _ := setupOCIRegistry(t, ou.Host)
+	imageURL := fmt.Sprintf("oci://%s/test/valid/docker:v0.1.0", ou.Host)
+
+	// Keep the socket path short, within the UDS path limit.
+	sockDir, err := os.MkdirTemp("", "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sockDir)
+	address := filepath.Join(sockDir, "wasm.sock")
+	server := NewNodeCacheServer(t.TempDir(), defaultOptions())
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := server.Start(address, stop); err != nil {
+		t.Fatal(err)
+	}
+
+	agentOptions := defaultOptions()
+	agentOptions.NodeCacheAddress = address
+	getOptions := GetOptions{ResourceName: "namespace.resource", RequestTimeout: time.Second * 10}
+	wantFileName := fmt.Sprintf("%s.wasm", dockerImageDigest)
+
+	t.Run("modules are pulled once per node", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			cache := NewLocalFileCache(t.TempDir(), agentOptions)
+			defer close(cache.stopChan)
+			got, err := cache.Get(imageURL, getOptions)
+			if err != nil {
+				t.Fatalf("failed to download Wasm module: %v", err)
+			}
+			if filepath.Base(got) != wantFileName {
+				t.Errorf("got module %v, want %v", got, wantFileName)
+			}
+			if i == 0 {
+				atomic.StoreInt32(&registryRequests, 0)
+			}
+		}
+		if got := atomic.LoadInt32(&registryRequests); got != 0 {
+			t.Errorf("got %d registry requests for the second agent, want 0", got)
+		}
+	})
+
+	t.Run("modules pulled with a secret are not shared", func(t *testing.T) {
+		cache := NewLocalFileCache(t.TempDir(), agentOptions)
+		defer close(cache.stopChan)
+		opts := getOptions
+		opts.PullSecret = []byte(`{"auths":{}}`)
+		atomic.StoreInt32(&registryRequests, 0)
+		if _, err := cache.Get(imageURL, opts); err != nil {
+			t.Fatalf("failed to download Wasm module: %v", err)
+		}
+		if got := atomic.LoadInt32(&registryRequests); got == 0 {
+			t.Errorf("got no registry request for a module pulled with a secret")
+		}
+		server.cache.mux.Lock()
+		defer server.cache.mux.Unlock()
+		if got := len(server.cache.modules); got != 2 {
+			t.Errorf("got %d modules in the node cache, want 2", got)
+		}
+	})
+
+	t.Run("modules pinned with a checksum are pulled from the registry", func(t *testing.T) {
+		cache := NewLocalFileCache(t.TempDir(), agentOptions)
+		defer close(cache.stopChan)
+		opts := getOptions
+		opts.Checksum = dockerImageDigest
+		atomic.StoreInt32(&registryRequests, 0)
+		if _, err := cache.Get(imageURL, opts); err != nil {
+			t.Fatalf("failed to download Wasm module: %v", err)
+		}
+		if got := atomic.LoadInt32(&registryRequests); got == 0 {
+			t.Errorf("got no registry request for a module pinned with a checksum")
+		}
+	})
+
+	t.Run("node cache is not used when the signatures are verified", func(t *testing.T) {
+		options := agentOptions
+		options.VerificationKeys = []string{"key.pem"}
+		cache := NewLocalFileCache(t.TempDir(), options)
+		defer close(cache.stopChan)
+		if cache.nodeCache != nil {
+			t.Errorf("got node cache client, want none")
+		}
+	})
+
+	t.Run("http modules are not served", func(t *testing.T) {
+		_, _, err := newNodeCacheClient(address).fetch(context.Background(), "http://127.0.0.1/module.wasm", getOptions)
+		if err == nil || !strings.Contains(err.Error(), "only OCI images are served") {
+			t.Errorf("got error %v, want only OCI images error", err)
+		}
+	})
+
+	t.Run("fetch from the registry if the node cache is unavailable", func(t *testing.T) {
+		options := defaultOptions()
+		options.NodeCacheAddress = filepath.Join(sockDir, "missing.sock")
+		cache := NewLocalFileCache(t.TempDir(), options)
+		defer close(cache.stopChan)
+		got, err := cache.Get(imageURL, getOptions)
+		if err != nil {
+			t.Fatalf("failed to download Wasm module: %v", err)
+		}
+		if filepath.Base(got) != wantFileName {
+			t.Errorf("got module %v, want %v", got, wantFileName)
+		}
+	})
+}
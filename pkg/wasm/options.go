@@ -35,6 +35,16 @@ type Options struct {
 	InsecureRegistries    sets.String
 	HTTPRequestTimeout    time.Duration
 	HTTPRequestMaxRetries int
+	// VerificationKeys are the paths of PEM encoded public keys. If set, the signature of every
+	// Wasm module must be verified by one of the keys before the module is used.
+	VerificationKeys []string
+	// MaxCacheSize is the disk budget of the cache in bytes. The least recently used modules are
+	// removed when it is exceeded. No limit if 0.
+	MaxCacheSize int64
+	// NodeCacheAddress is the UDS address of the node level cache. If set, the OCI images are fetched
+	// from the node level cache first, and from their registry if it fails. The modules pinned with a
+	// checksum, and all the modules if VerificationKeys is set, are always fetched from their registry.
+	NodeCacheAddress string
 }
 
 func defaultOptions() Options {
@@ -0,0 +1,154 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// This file implements the verification of Wasm module signatures, compatible with the key based signatures of
+// cosign (https://github.com/sigstore/cosign).
+// Modules downloaded with HTTP/HTTPS are verified with a detached signature of the Wasm binary served at the module URL
+// suffixed with ".sig", for example the output of `cosign sign-blob`.
+// Modules pulled from OCI registries are verified with the signatures attached to the image by `cosign sign`,
+// stored in the `sha256-<digest>.sig` tag of the image repository.
+
+const (
+	// signatureSuffix is the suffix of the detached signature URL of a module, and of the cosign signature tag.
+	signatureSuffix = ".sig"
+
+	// cosignSignatureAnnotation is the layer annotation holding the base64 encoded signature of the layer.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// maxSignatureSize limits the size of the signatures and signed payloads.
+	maxSignatureSize = 64 * 1024
+)
+
+// signature is a signature of a payload.
+type signature struct {
+	payload   []byte
+	signature []byte
+}
+
+// simpleSigningPayload is the part of the payload signed by cosign identifying the signed image.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// loadVerificationKeys reads the PEM encoded public keys in the files.
+func loadVerificationKeys(paths []string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Wasm verification key: %v", err)
+		}
+		for {
+			var block *pem.Block
+			block, b = pem.Decode(b)
+			if block == nil {
+				break
+			}
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse Wasm verification key %s: %v", p, err)
+			}
+			switch key.(type) {
+			case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+			default:
+				return nil, fmt.Errorf("unsupported type %T of Wasm verification key %s", key, p)
+			}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no Wasm verification key found")
+	}
+	return keys, nil
+}
+
+// verifySignature returns nil if one of the keys verifies the signature of the payload.
+// ECDSA and RSA (PKCS #1 v1.5) signatures are computed over the SHA-256 digest of the payload, like cosign does.
+func verifySignature(keys []crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	for _, k := range keys {
+		switch key := k.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, digest[:], sig) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, payload, sig) {
+				return nil
+			}
+		}
+	}
+	return errors.New("the signature is not verified by any of the configured keys")
+}
+
+// verifyImageSignatures returns nil if one of the cosign signatures is verified by the keys and signs
+// the image with the manifest digest, hex encoded.
+func verifyImageSignatures(keys []crypto.PublicKey, sigs []signature, digest string) error {
+	if len(sigs) == 0 {
+		return errors.New("no signature found")
+	}
+	var lastErr error
+	for _, s := range sigs {
+		if err := verifySignature(keys, s.payload, s.signature); err != nil {
+			lastErr = err
+			continue
+		}
+		p := simpleSigningPayload{}
+		if err := json.Unmarshal(s.payload, &p); err != nil {
+			lastErr = fmt.Errorf("invalid signed payload: %v", err)
+			continue
+		}
+		if p.Critical.Image.DockerManifestDigest != sha256SchemePrefix+digest {
+			lastErr = fmt.Errorf("the signature is for the image %s", p.Critical.Image.DockerManifestDigest)
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// decodeSignature decodes a base64 encoded signature, as written by cosign. Other signatures are returned as is.
+func decodeSignature(b []byte) []byte {
+	trimmed := bytes.TrimSpace(b)
+	if sig, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil {
+		return sig
+	}
+	return b
+}
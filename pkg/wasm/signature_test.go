@@ -0,0 +1,177 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeVerificationKey writes the PEM encoded public key in a file, and returns its path.
+func writeVerificationKey(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign signs the payload the way cosign does.
+func sign(t *testing.T, signer crypto.Signer, payload []byte) []byte {
+	t.Helper()
+	var sig []byte
+	var err error
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		sig, err = signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(payload)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestVerifySignature(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	payload := append(wasmHeader, []byte("data")...)
+
+	cases := []struct {
+		name    string
+		signer  crypto.Signer
+		keys    []crypto.Signer
+		tamper  bool
+		wantErr bool
+	}{
+		{name: "ecdsa", signer: ecKey, keys: []crypto.Signer{ecKey}},
+		{name: "rsa", signer: rsaKey, keys: []crypto.Signer{rsaKey}},
+		{name: "ed25519", signer: edKey, keys: []crypto.Signer{edKey}},
+		{name: "second key", signer: ecKey, keys: []crypto.Signer{otherKey, ecKey}},
+		{name: "unknown key", signer: otherKey, keys: []crypto.Signer{ecKey, rsaKey, edKey}, wantErr: true},
+		{name: "tampered payload", signer: ecKey, keys: []crypto.Signer{ecKey}, tamper: true, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var paths []string
+			for _, k := range c.keys {
+				paths = append(paths, writeVerificationKey(t, k.Public()))
+			}
+			keys, err := loadVerificationKeys(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig := sign(t, c.signer, payload)
+			p := payload
+			if c.tamper {
+				p = append(append([]byte{}, payload...), 0)
+			}
+			// Signatures written by cosign are base64 encoded.
+			encoded := []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+			if err := verifySignature(keys, p, decodeSignature(encoded)); (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %v", err, c.wantErr)
+			}
+			if err := verifySignature(keys, p, decodeSignature(sig)); (err != nil) != c.wantErr {
+				t.Errorf("got error %v with raw signature, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadVerificationKeys(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	invalid := filepath.Join(t.TempDir(), "invalid.pub")
+	if err := os.WriteFile(invalid, []byte("not a key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{name: "valid", paths: []string{writeVerificationKey(t, ecKey.Public())}},
+		{name: "missing file", paths: []string{filepath.Join(t.TempDir(), "missing.pub")}, wantErr: true},
+		{name: "no PEM block", paths: []string{invalid}, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := loadVerificationKeys(c.paths); (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyImageSignatures(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys, err := loadVerificationKeys([]string{writeVerificationKey(t, ecKey.Public())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("manifest")))
+	payload := cosignPayload(digest)
+	otherPayload := cosignPayload(fmt.Sprintf("%x", sha256.Sum256([]byte("other"))))
+
+	cases := []struct {
+		name    string
+		sigs    []signature
+		wantErr bool
+	}{
+		{name: "no signature", wantErr: true},
+		{name: "valid", sigs: []signature{{payload: payload, signature: sign(t, ecKey, payload)}}},
+		{
+			name: "one valid signature",
+			sigs: []signature{
+				{payload: payload, signature: []byte("invalid")},
+				{payload: payload, signature: sign(t, ecKey, payload)},
+			},
+		},
+		{name: "invalid signature", sigs: []signature{{payload: payload, signature: []byte("invalid")}}, wantErr: true},
+		{name: "other image", sigs: []signature{{payload: otherPayload, signature: sign(t, ecKey, otherPayload)}}, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := verifyImageSignatures(keys, c.sigs, digest); (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+// cosignPayload returns the simple signing payload signed by cosign for the image with the digest.
+func cosignPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"test"},"image":{"docker-manifest-digest":"sha256:%s"},`+
+		`"type":"cosign container image signature"},"optional":null}`, digest))
+}
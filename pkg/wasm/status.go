@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+)
+
+// The agent reports the status of the Wasm modules to istiod with a request of the v3.WasmModuleStatusType type,
+// holding the JSON encoded statuses in the details of its error detail. The code of the error detail is Internal
+// if any module could not be loaded.
+
+// StatusReporter is implemented by the caches reporting the status of the Wasm modules they fetch.
+type StatusReporter interface {
+	// ModuleStatuses returns the status of the module of each resource, sorted by resource name.
+	ModuleStatuses() []model.WasmModuleStatus
+}
+
+// conversionRecorder is implemented by the caches recording the result of the extension config conversions.
+type conversionRecorder interface {
+	recordConversion(resourceName, downloadURL string, err error, allowAll bool)
+}
+
+var (
+	_ StatusReporter     = &LocalFileCache{}
+	_ conversionRecorder = &LocalFileCache{}
+)
+
+// ModuleStatuses implements StatusReporter.
+func (c *LocalFileCache) ModuleStatuses() []model.WasmModuleStatus {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	out := make([]model.WasmModuleStatus, 0, len(c.moduleStatuses))
+	for _, s := range c.moduleStatuses {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ResourceName < out[j].ResourceName
+	})
+	return out
+}
+
+// recordFetch records the result of the fetch of the module of a resource.
+func (c *LocalFileCache) recordFetch(downloadURL, resourceName string, res fetchResult, err error) {
+	if resourceName == "" {
+		return
+	}
+	status := model.WasmModuleStatus{
+		ResourceName: resourceName,
+		URL:          downloadURL,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Digest = res.checksum
+		status.Source = res.source
+		if fi, err := os.Stat(res.entry.modulePath); err == nil {
+			status.Size = fi.Size()
+		}
+	}
+	c.statusMux.Lock()
+	c.moduleStatuses[resourceName] = status
+	c.statusMux.Unlock()
+}
+
+// recordConversion records the failure of the conversion of the extension config of a resource, for the errors
+// of the module fetch as well as of the extension config itself. Successful fetches are recorded by recordFetch.
+func (c *LocalFileCache) recordConversion(resourceName, downloadURL string, err error, allowAll bool) {
+	if resourceName == "" || err == nil {
+		return
+	}
+	c.statusMux.Lock()
+	c.moduleStatuses[resourceName] = model.WasmModuleStatus{
+		ResourceName: resourceName,
+		URL:          downloadURL,
+		Error:        err.Error(),
+		AllowAll:     allowAll,
+	}
+	c.statusMux.Unlock()
+}
+
+// ModuleStatusDetail encodes the status of the modules in the error detail of the request reporting them.
+func ModuleStatusDetail(statuses []model.WasmModuleStatus) (*google_rpc.Status, error) {
+	b, err := json.Marshal(statuses)
+	if err != nil {
+		return nil, err
+	}
+	failed := 0
+	for _, s := range statuses {
+		if s.Error != "" {
+			failed++
+		}
+	}
+	detail := &google_rpc.Status{
+		Code:    int32(codes.OK),
+		Message: fmt.Sprintf("%d/%d Wasm modules loaded", len(statuses)-failed, len(statuses)),
+		Details: []*anypb.Any{protoconv.MessageToAny(wrapperspb.String(string(b)))},
+	}
+	if failed > 0 {
+		detail.Code = int32(codes.Internal)
+	}
+	return detail, nil
+}
+
+// ModuleStatusFromDetail decodes the status of the modules reported in the error detail of a request.
+func ModuleStatusFromDetail(detail *google_rpc.Status) ([]model.WasmModuleStatus, error) {
+	if len(detail.GetDetails()) != 1 {
+		return nil, fmt.Errorf("expected 1 detail, got %d", len(detail.GetDetails()))
+	}
+	s := &wrapperspb.StringValue{}
+	if err := detail.Details[0].UnmarshalTo(s); err != nil {
+		return nil, err
+	}
+	var statuses []model.WasmModuleStatus
+	if err := json.Unmarshal([]byte(s.Value), &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
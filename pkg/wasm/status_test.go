@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestModuleStatuses(t *testing.T) {
+	binary := append(wasmHeader, []byte("data")...)
+	sha := sha256.Sum256(binary)
+	digest := hex.EncodeToString(sha[:])
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/module.wasm" {
+			_, _ = w.Write(binary)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+	options := defaultOptions()
+	options.HTTPRequestMaxRetries = 1
+	cache := NewLocalFileCache(t.TempDir(), options)
+	defer close(cache.stopChan)
+
+	opts := GetOptions{RequestTimeout: time.Second * 10}
+	get := func(downloadURL, resourceName string) {
+		opts.ResourceName = resourceName
+		_, _ = cache.Get(downloadURL, opts)
+	}
+	get(ts.URL+"/module.wasm", "namespace.remote")
+	get(ts.URL+"/module.wasm", "namespace.cached")
+	get(ts.URL+"/missing.wasm", "namespace.missing")
+	errInvalid := errors.New("invalid extension config")
+	cache.recordConversion("namespace.allow", ts.URL+"/invalid.wasm", errInvalid, true)
+
+	got := cache.ModuleStatuses()
+	if len(got) != 4 {
+		t.Fatalf("got %d module statuses, want 4: %v", len(got), got)
+	}
+	// The statuses are sorted by resource name.
+	assert.Equal(t, got[0], model.WasmModuleStatus{
+		ResourceName: "namespace.allow",
+		URL:          ts.URL + "/invalid.wasm",
+		Error:        errInvalid.Error(),
+		AllowAll:     true,
+	})
+	assert.Equal(t, got[1], model.WasmModuleStatus{
+		ResourceName: "namespace.cached",
+		URL:          ts.URL + "/module.wasm",
+		Digest:       digest,
+		Size:         int64(len(binary)),
+		Source:       model.WasmModuleSourceCache,
+	})
+	if got[2].ResourceName != "namespace.missing" || got[2].Error == "" || got[2].Digest != "" {
+		t.Errorf("got status %+v, want an error", got[2])
+	}
+	assert.Equal(t, got[3], model.WasmModuleStatus{
+		ResourceName: "namespace.remote",
+		URL:          ts.URL + "/module.wasm",
+		Digest:       digest,
+		Size:         int64(len(binary)),
+		Source:       model.WasmModuleSourceRemote,
+	})
+}
+
+func TestModuleStatusDetail(t *testing.T) {
+	cases := []struct {
+		name     string
+		statuses []model.WasmModuleStatus
+		wantCode codes.Code
+	}{
+		{
+			name:     "loaded",
+			statuses: []model.WasmModuleStatus{{ResourceName: "namespace.name", Digest: "digest", Source: model.WasmModuleSourceRemote}},
+			wantCode: codes.OK,
+		},
+		{
+			name: "failed",
+			statuses: []model.WasmModuleStatus{
+				{ResourceName: "namespace.loaded", Digest: "digest", Source: model.WasmModuleSourceNodeCache},
+				{ResourceName: "namespace.failed", Error: "error", AllowAll: true},
+			},
+			wantCode: codes.Internal,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			detail, err := ModuleStatusDetail(c.statuses)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if codes.Code(detail.Code) != c.wantCode {
+				t.Errorf("got code %v, want %v", codes.Code(detail.Code), c.wantCode)
+			}
+			got, err := ModuleStatusFromDetail(detail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, got, c.statuses)
+		})
+	}
+
+	t.Run("invalid detail", func(t *testing.T) {
+		detail, _ := ModuleStatusDetail(nil)
+		detail.Details = []*anypb.Any{protoconv.MessageToAny(detail)}
+		if _, err := ModuleStatusFromDetail(detail); err == nil {
+			t.Error("expected an error for an invalid detail")
+		}
+	})
+}
@@ -22,10 +22,16 @@ import (
 	"istio.io/istio/security/pkg/credentialfetcher/plugin"
 )
 
-func NewCredFetcher(credtype, trustdomain, jwtPath, identityProvider string) (security.CredFetcher, error) {
+func NewCredFetcher(credtype, trustdomain, jwtPath, identityProvider string, metadata plugin.MetadataConfig) (security.CredFetcher, error) {
 	switch credtype {
 	case security.GCE:
 		return plugin.CreateGCEPlugin(trustdomain, jwtPath, identityProvider), nil
+	case security.Metadata:
+		p, err := plugin.CreateMetadataPlugin(metadata, jwtPath, identityProvider)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
 	case security.JWT, "":
 		// If unset, also default to JWT for backwards compatibility
 		if jwtPath == "" {
@@ -27,6 +27,7 @@ func TestNewCredFetcher(t *testing.T) {
 		trustdomain      string
 		jwtPath          string
 		identityProvider string
+		metadata         plugin.MetadataConfig
 		expectedErr      string
 		expectedToken    string
 		expectedIdp      string
@@ -49,6 +50,16 @@ func TestNewCredFetcher(t *testing.T) {
 			expectedToken:    "test_token",
 			expectedIdp:      "fakeIDP",
 		},
+		"metadata test": {
+			fetcherType:      security.Metadata,
+			identityProvider: "fakeIDP",
+			metadata:         plugin.MetadataConfig{Address: "unix:///var/run/agent.sock", Path: "/token"},
+			expectedIdp:      "fakeIDP",
+		},
+		"metadata without address": {
+			fetcherType: security.Metadata,
+			expectedErr: "metadata credential fetcher address is unset",
+		},
 		"invalid test": {
 			fetcherType:      "foo",
 			trustdomain:      "",
@@ -67,7 +78,7 @@ func TestNewCredFetcher(t *testing.T) {
 		t.Run(id, func(t *testing.T) {
 			t.Parallel()
 			cf, err := NewCredFetcher(
-				tc.fetcherType, tc.trustdomain, tc.jwtPath, tc.identityProvider)
+				tc.fetcherType, tc.trustdomain, tc.jwtPath, tc.identityProvider, tc.metadata)
 			if cf != nil {
 				defer cf.Stop()
 			}
@@ -0,0 +1,230 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is the generic metadata endpoint plugin of credentialfetcher.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/util"
+	"istio.io/pkg/log"
+)
+
+var metadatacredLog = log.RegisterScope("metadatacred", "Metadata endpoint credential fetcher for istio agent")
+
+const (
+	// unixAddressPrefix marks a metadata address as a unix domain socket path.
+	unixAddressPrefix = "unix://"
+
+	// metadataTokenGracePeriod is how long before expiry a cached token is refreshed.
+	metadataTokenGracePeriod = time.Minute
+
+	// metadataDefaultTokenLifetime is how long a token is cached if its expiry cannot be determined.
+	metadataDefaultTokenLifetime = 5 * time.Minute
+
+	// maxMetadataResponseSize bounds the size of the response read from the metadata endpoint.
+	maxMetadataResponseSize = 1 << 20
+)
+
+// MetadataConfig configures the metadata endpoint credential fetcher.
+type MetadataConfig struct {
+	// Address of the metadata endpoint. This is either an HTTP(S) URL such as "http://169.254.169.254",
+	// or a unix domain socket such as "unix:///var/run/attestation/agent.sock".
+	Address string
+	// Path, including any query string, of the token resource on the metadata endpoint.
+	Path string
+	// Headers are added to every request to the metadata endpoint.
+	Headers map[string]string
+	// TokenField is the dot separated path of the token in a JSON response, for example "data.token".
+	// If empty, the whole response body is the token.
+	TokenField string
+	// ExpiresInField is the dot separated path of the token lifetime, in seconds, in a JSON response.
+	// It requires TokenField to be set. If empty, or not present in the response, the expiry is read
+	// from the token if it is a JWT.
+	ExpiresInField string
+	// Timeout of a request to the metadata endpoint.
+	Timeout time.Duration
+}
+
+// MetadataPlugin fetches workload identity tokens from a local HTTP or unix domain socket metadata endpoint,
+// such as an instance metadata service or a TPM attestation agent. Tokens are cached until shortly before they expire.
+type MetadataPlugin struct {
+	cfg    MetadataConfig
+	client *http.Client
+	url    string
+
+	// The location to save the identity token, if set.
+	jwtPath string
+
+	// identity provider
+	identityProvider string
+
+	tokenMutex sync.Mutex
+	tokenCache string
+	expiry     time.Time
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+var _ security.CredFetcher = &MetadataPlugin{}
+
+// CreateMetadataPlugin creates a metadata endpoint credential fetcher plugin.
+func CreateMetadataPlugin(cfg MetadataConfig, jwtPath, identityProvider string) (*MetadataPlugin, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("metadata credential fetcher address is unset")
+	}
+	if cfg.ExpiresInField != "" && cfg.TokenField == "" {
+		return nil, fmt.Errorf("metadata credential fetcher expires in field requires a token field")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	base := strings.TrimSuffix(cfg.Address, "/")
+	if socket, ok := strings.CutPrefix(cfg.Address, unixAddressPrefix); ok {
+		dialer := &net.Dialer{}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		// The host is ignored when dialing a unix domain socket, but is required to build a valid URL.
+		base = "http://localhost"
+	} else if !strings.HasPrefix(cfg.Address, "http://") && !strings.HasPrefix(cfg.Address, "https://") {
+		return nil, fmt.Errorf("metadata credential fetcher address %q must be an http, https or unix address", cfg.Address)
+	}
+	path := cfg.Path
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return &MetadataPlugin{
+		cfg:              cfg,
+		client:           &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		url:              base + path,
+		jwtPath:          jwtPath,
+		identityProvider: identityProvider,
+		now:              time.Now,
+	}, nil
+}
+
+// GetPlatformCredential returns the cached token, fetching a new one from the metadata endpoint if the
+// cached token is missing or about to expire. If jwtPath is set, fetched tokens are also written there.
+func (p *MetadataPlugin) GetPlatformCredential() (string, error) {
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+
+	now := p.now()
+	if p.tokenCache != "" && now.Before(p.expiry.Add(-metadataTokenGracePeriod)) {
+		return p.tokenCache, nil
+	}
+	token, expiry, err := p.fetch(now)
+	if err != nil {
+		metadatacredLog.Errorf("failed to get identity token from metadata endpoint: %v", err)
+		return "", err
+	}
+	p.tokenCache = token
+	p.expiry = expiry
+	metadatacredLog.Debugf("got identity token of length %d, expiring at %v", len(token), expiry)
+	if p.jwtPath != "" {
+		if err := os.WriteFile(p.jwtPath, []byte(token), 0o640); err != nil {
+			metadatacredLog.Errorf("encountered error when writing identity token: %v", err)
+			return "", err
+		}
+	}
+	return token, nil
+}
+
+func (p *MetadataPlugin) fetch(now time.Time) (string, time.Time, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataResponseSize))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if p.cfg.TokenField == "" {
+		token := strings.TrimSpace(string(body))
+		if token == "" {
+			return "", time.Time{}, fmt.Errorf("empty token")
+		}
+		return token, tokenExpiry(token, now), nil
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse response as JSON: %v", err)
+	}
+	token, ok := jsonField(doc, p.cfg.TokenField).(string)
+	if !ok || token == "" {
+		return "", time.Time{}, fmt.Errorf("response has no string field %q", p.cfg.TokenField)
+	}
+	if p.cfg.ExpiresInField != "" {
+		if expiresIn, ok := jsonField(doc, p.cfg.ExpiresInField).(float64); ok {
+			return token, now.Add(time.Duration(expiresIn * float64(time.Second))), nil
+		}
+	}
+	return token, tokenExpiry(token, now), nil
+}
+
+// tokenExpiry returns the expiry of a JWT, or a default lifetime for other tokens.
+func tokenExpiry(token string, now time.Time) time.Time {
+	if exp, err := util.GetExp(token); err == nil && !exp.IsZero() {
+		return exp
+	}
+	return now.Add(metadataDefaultTokenLifetime)
+}
+
+// jsonField returns the value at the dot separated path in a decoded JSON document, or nil.
+func jsonField(doc any, path string) any {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return nil
+		}
+		doc = m[key]
+	}
+	return doc
+}
+
+// GetIdentityProvider returns the name of the identity provider that can authenticate the workload credential.
+func (p *MetadataPlugin) GetIdentityProvider() string {
+	return p.identityProvider
+}
+
+func (p *MetadataPlugin) Stop() {
+	p.client.CloseIdleConnections()
+}
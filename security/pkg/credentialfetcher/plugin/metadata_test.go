@@ -0,0 +1,236 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCreateMetadataPlugin(t *testing.T) {
+	testCases := map[string]struct {
+		cfg         MetadataConfig
+		expectedURL string
+		expectedErr string
+	}{
+		"http": {
+			cfg:         MetadataConfig{Address: "http://169.254.169.254/", Path: "token"},
+			expectedURL: "http://169.254.169.254/token",
+		},
+		"unix": {
+			cfg:         MetadataConfig{Address: "unix:///var/run/agent.sock", Path: "/token?aud=istio"},
+			expectedURL: "http://localhost/token?aud=istio",
+		},
+		"no address": {
+			cfg:         MetadataConfig{Path: "/token"},
+			expectedErr: "metadata credential fetcher address is unset",
+		},
+		"unsupported scheme": {
+			cfg:         MetadataConfig{Address: "tcp://127.0.0.1:80"},
+			expectedErr: "must be an http, https or unix address",
+		},
+		"expires in without token field": {
+			cfg:         MetadataConfig{Address: "http://127.0.0.1", ExpiresInField: "expires_in"},
+			expectedErr: "requires a token field",
+		},
+	}
+	for id, tc := range testCases {
+		t.Run(id, func(t *testing.T) {
+			p, err := CreateMetadataPlugin(tc.cfg, "", "")
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("CreateMetadataPlugin() returns err: %v, want: %v", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.url != tc.expectedURL {
+				t.Errorf("url is %s, want %s", p.url, tc.expectedURL)
+			}
+		})
+	}
+}
+
+func TestMetadataPlugin(t *testing.T) {
+	jwtExp := time.Date(2020, time.April, 5, 10, 13, 54, 0, time.FixedZone("PDT", -int((7*time.Hour).Seconds())))
+	testCases := map[string]struct {
+		cfg            MetadataConfig
+		response       string
+		status         int
+		now            time.Time
+		expectedToken  string
+		expectedExpiry time.Time
+		expectedErr    string
+	}{
+		"raw jwt": {
+			response:       thirdPartyJwt + "\n",
+			now:            jwtExp.Add(-time.Hour),
+			expectedToken:  thirdPartyJwt,
+			expectedExpiry: jwtExp,
+		},
+		"raw opaque token": {
+			response:       "opaque-token",
+			now:            jwtExp,
+			expectedToken:  "opaque-token",
+			expectedExpiry: jwtExp.Add(metadataDefaultTokenLifetime),
+		},
+		"json fields": {
+			cfg:            MetadataConfig{TokenField: "data.token", ExpiresInField: "data.expires_in"},
+			response:       `{"data": {"token": "opaque-token", "expires_in": 600}}`,
+			now:            jwtExp,
+			expectedToken:  "opaque-token",
+			expectedExpiry: jwtExp.Add(10 * time.Minute),
+		},
+		"json without expires in": {
+			cfg:            MetadataConfig{TokenField: "access_token", ExpiresInField: "expires_in"},
+			response:       fmt.Sprintf(`{"access_token": %q}`, thirdPartyJwt),
+			now:            jwtExp.Add(-time.Hour),
+			expectedToken:  thirdPartyJwt,
+			expectedExpiry: jwtExp,
+		},
+		"json missing token": {
+			cfg:         MetadataConfig{TokenField: "access_token"},
+			response:    `{"token": "opaque-token"}`,
+			expectedErr: `response has no string field "access_token"`,
+		},
+		"invalid json": {
+			cfg:         MetadataConfig{TokenField: "access_token"},
+			response:    "opaque-token",
+			expectedErr: "failed to parse response as JSON",
+		},
+		"empty token": {
+			response:    "\n",
+			expectedErr: "empty token",
+		},
+		"error status": {
+			response:    "not attested",
+			status:      http.StatusForbidden,
+			expectedErr: "unexpected status 403: not attested",
+		},
+	}
+	for id, tc := range testCases {
+		t.Run(id, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/token" || r.Header.Get("Metadata-Flavor") != "Istio" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				_, _ = w.Write([]byte(tc.response))
+			}))
+			defer srv.Close()
+
+			cfg := tc.cfg
+			cfg.Address = srv.URL
+			cfg.Path = "/token"
+			cfg.Headers = map[string]string{"Metadata-Flavor": "Istio"}
+			p, err := CreateMetadataPlugin(cfg, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Stop()
+
+			token, expiry, err := p.fetch(tc.now)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("fetch() returns err: %v, want: %v", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != tc.expectedToken {
+				t.Errorf("fetch() returns token: %s, want: %s", token, tc.expectedToken)
+			}
+			if !expiry.Equal(tc.expectedExpiry) {
+				t.Errorf("fetch() returns expiry: %v, want: %v", expiry, tc.expectedExpiry)
+			}
+		})
+	}
+}
+
+func TestMetadataPluginCache(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		fmt.Fprintf(w, `{"token": "%s%d", "expires_in": 600}`, fakeTokenPrefix, n)
+	})}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+
+	jwtPath := filepath.Join(dir, "token")
+	p, err := CreateMetadataPlugin(MetadataConfig{
+		Address:        unixAddressPrefix + socket,
+		Path:           "/token",
+		TokenField:     "token",
+		ExpiresInField: "expires_in",
+	}, jwtPath, "fakeIDP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if idp := p.GetIdentityProvider(); idp != "fakeIDP" {
+		t.Errorf("GetIdentityProvider() returns %s, want fakeIDP", idp)
+	}
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	verify := func(want string) {
+		t.Helper()
+		token, err := p.GetPlatformCredential()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != want {
+			t.Errorf("GetPlatformCredential() returns token: %s, want: %s", token, want)
+		}
+		jwtFile, err := os.ReadFile(jwtPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(jwtFile) != want {
+			t.Errorf("%s has token %s, want %s", jwtPath, jwtFile, want)
+		}
+	}
+
+	verify(fakeTokenPrefix + "1")
+	// The cached token is returned until it is about to expire.
+	now = now.Add(8 * time.Minute)
+	verify(fakeTokenPrefix + "1")
+	now = now.Add(time.Minute + time.Second)
+	verify(fakeTokenPrefix + "2")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("metadata endpoint called %d times, want 2", n)
+	}
+}
@@ -19,6 +19,9 @@ import "istio.io/pkg/monitoring"
 // RequestType specifies the type of request we are monitoring. Current supported are CSR and TokenExchange
 var RequestType = monitoring.MustCreateLabel("request_type")
 
+// Method is the full gRPC method name of the request we are monitoring.
+var Method = monitoring.MustCreateLabel("method")
+
 const (
 	TokenExchange = "token_exchange"
 	CSR           = "csr"
@@ -29,12 +32,19 @@ var NumOutgoingRetries = monitoring.NewSum(
 	"Number of outgoing retry requests (e.g. to a token exchange server, CA, etc.)",
 	monitoring.WithLabels(RequestType))
 
+var NumRetryAfterHints = monitoring.NewSum(
+	"num_outgoing_retry_after_hints",
+	"Number of failed outgoing requests where the server asked to retry after a delay (e.g. a throttling CA)",
+	monitoring.WithLabels(Method))
+
 func init() {
 	monitoring.MustRegister(
 		NumOutgoingRetries,
+		NumRetryAfterHints,
 	)
 }
 
 func Reset() {
 	NumOutgoingRetries.Record(0)
+	NumRetryAfterHints.Record(0)
 }
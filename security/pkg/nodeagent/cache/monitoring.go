@@ -52,8 +52,22 @@ var (
 		"The time remaining, in seconds, before the certificate chain will expire. "+
 			"A negative value indicates the cert is expired.",
 		monitoring.WithLabelKeys("resource_name"))
+
+	certRenewalLifetime = monitoring.NewDistribution(
+		"cert_renewal_remaining_lifetime_seconds",
+		"The time remaining, in seconds, before the workload certificate expires when its renewal is started.",
+		lifetimeBuckets)
+
+	certRenewalFailureLifetime = monitoring.NewDistribution(
+		"cert_renewal_failure_remaining_lifetime_seconds",
+		"The time remaining, in seconds, before the workload certificate expires when an attempt to renew it fails. "+
+			"A negative value indicates the cert already expired.",
+		lifetimeBuckets)
 )
 
+// lifetimeBuckets covers certificate lifetimes from under a minute up to a day.
+var lifetimeBuckets = []float64{0, 30, 60, 120, 300, 600, 900, 1800, 3600, 7200, 21600, 43200, 86400}
+
 func init() {
 	monitoring.MustRegister(
 		outgoingLatency,
@@ -61,5 +75,7 @@ func init() {
 		numFailedOutgoingRequests,
 		numFileWatcherFailures,
 		numFileSecretFailures,
+		certRenewalLifetime,
+		certRenewalFailureLifetime,
 	)
 }
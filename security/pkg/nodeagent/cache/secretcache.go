@@ -21,6 +21,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -124,6 +125,8 @@ type secretCache struct {
 	mu       sync.RWMutex
 	workload *security.SecretItem
 	certRoot []byte
+	// renewing holds the expiration time of the workload certificate currently being rotated, if any.
+	renewing time.Time
 }
 
 // GetRoot returns cached root cert and cert expiration time. This method is thread safe.
@@ -153,6 +156,27 @@ func (s *secretCache) SetWorkload(value *security.SecretItem) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.workload = value
+	if value != nil {
+		// A new certificate completes any in progress rotation.
+		s.renewing = time.Time{}
+	}
+}
+
+// GetRenewing returns the expiration time of the workload certificate being rotated, or the zero
+// time if no rotation is in progress. This method is thread safe.
+func (s *secretCache) GetRenewing() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.renewing
+}
+
+// StartRenewal clears the cached workload certificate, recording its expiration time until a
+// replacement is stored. This method is thread safe.
+func (s *secretCache) StartRenewal(expire time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workload = nil
+	s.renewing = expire
 }
 
 var _ security.SecretManager = &SecretManagerClient{}
@@ -297,6 +321,9 @@ func (sc *SecretManagerClient) GenerateSecret(resourceName string) (secret *secu
 	// send request to CA to get new workload certificate
 	ns, err = sc.generateNewSecret(resourceName)
 	if err != nil {
+		if expire := sc.cache.GetRenewing(); !expire.IsZero() {
+			certRenewalFailureLifetime.Record(time.Until(expire).Seconds())
+		}
 		return nil, fmt.Errorf("failed to generate workload certificate: %v", err)
 	}
 
@@ -637,6 +664,11 @@ func (sc *SecretManagerClient) generateNewSecret(resourceName string) (*security
 func (sc *SecretManagerClient) rotateTime(secret security.SecretItem) time.Duration {
 	secretLifeTime := secret.ExpireTime.Sub(secret.CreatedTime)
 	gracePeriod := time.Duration((sc.configOptions.SecretRotationGracePeriodRatio) * float64(secretLifeTime))
+	if jitter := sc.configOptions.SecretRotationJitterRatio; jitter > 0 {
+		// Rotate a random amount earlier, so proxies that got certificates at the same time do not all
+		// renew at the same time.
+		gracePeriod += time.Duration(rand.Float64() * jitter * float64(secretLifeTime))
+	}
 	delay := time.Until(secret.ExpireTime.Add(-gracePeriod))
 	if delay < 0 {
 		delay = 0
@@ -657,8 +689,9 @@ func (sc *SecretManagerClient) registerSecret(item security.SecretItem) {
 	resourceLog(item.ResourceName).Debugf("scheduled certificate for rotation in %v", delay)
 	sc.queue.PushDelayed(func() error {
 		resourceLog(item.ResourceName).Debugf("rotating certificate")
+		certRenewalLifetime.Record(time.Until(item.ExpireTime).Seconds())
 		// Clear the cache so the next call generates a fresh certificate
-		sc.cache.SetWorkload(nil)
+		sc.cache.StartRenewal(item.ExpireTime)
 
 		sc.OnSecretUpdate(item.ResourceName)
 		return nil
@@ -248,6 +248,27 @@ func TestRotateTime(t *testing.T) {
 	}
 }
 
+func TestRotateTimeJitter(t *testing.T) {
+	now := time.Now()
+	sc := &SecretManagerClient{configOptions: &security.Options{
+		SecretRotationGracePeriodRatio: 0.25,
+		SecretRotationJitterRatio:      0.25,
+	}}
+	item := security.SecretItem{CreatedTime: now, ExpireTime: now.Add(time.Hour)}
+	seen := map[time.Duration]struct{}{}
+	for i := 0; i < 20; i++ {
+		got := sc.rotateTime(item)
+		// Jitter only ever moves rotation earlier: between 30 and 45 minutes from now.
+		if got > time.Minute*45+time.Second || got < time.Minute*30-time.Second {
+			t.Fatalf("rotation time %v out of the expected jitter range", got)
+		}
+		seen[got.Truncate(time.Second)] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected rotation times to be randomized, got %v", seen)
+	}
+}
+
 func TestRootCertificateExists(t *testing.T) {
 	testCases := map[string]struct {
 		certPath     string
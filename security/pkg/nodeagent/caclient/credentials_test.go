@@ -81,7 +81,7 @@ func TestGetTokenForXDS(t *testing.T) {
 	secOpts.CredFetcher = plugin.CreateTokenPlugin(jwtPath)
 	defer os.Remove(jwtPath)
 
-	mockCredFetcher, err := credentialfetcher.NewCredFetcher(security.Mock, "", "", "")
+	mockCredFetcher, err := credentialfetcher.NewCredFetcher(security.Mock, "", "", "", plugin.MetadataConfig{})
 	if err != nil {
 		t.Fatalf("failed to create mock credential fetcher: %v", err)
 	}
@@ -19,11 +19,13 @@ import (
 )
 
 const (
-	errorlabel = "error"
+	errorlabel  = "error"
+	reasonlabel = "reason"
 )
 
 var (
-	errorTag = monitoring.MustCreateLabel(errorlabel)
+	errorTag  = monitoring.MustCreateLabel(errorlabel)
+	reasonTag = monitoring.MustCreateLabel(reasonlabel)
 
 	csrCounts = monitoring.NewSum(
 		"citadel_server_csr_count",
@@ -51,6 +53,12 @@ var (
 		monitoring.WithLabels(errorTag),
 	)
 
+	policyDenialCounts = monitoring.NewSum(
+		"citadel_server_policy_denial_count",
+		"The number of CSRs denied by the certificate issuance policy.",
+		monitoring.WithLabels(reasonTag),
+	)
+
 	successCounts = monitoring.NewSum(
 		"citadel_server_success_cert_issuance_count",
 		"The number of certificates issuances that have succeeded.",
@@ -75,6 +83,7 @@ func init() {
 		csrParsingErrorCounts,
 		idExtractionErrorCounts,
 		certSignErrorCounts,
+		policyDenialCounts,
 		successCounts,
 		rootCertExpiryTimestamp,
 		certChainExpiryTimestamp,
@@ -89,6 +98,7 @@ type monitoringMetrics struct {
 	CSRError          monitoring.Metric
 	IDExtractionError monitoring.Metric
 	certSignErrors    monitoring.Metric
+	policyDenials     monitoring.Metric
 }
 
 // newMonitoringMetrics creates a new monitoringMetrics.
@@ -100,9 +110,14 @@ func newMonitoringMetrics() monitoringMetrics {
 		CSRError:          csrParsingErrorCounts,
 		IDExtractionError: idExtractionErrorCounts,
 		certSignErrors:    certSignErrorCounts,
+		policyDenials:     policyDenialCounts,
 	}
 }
 
 func (m *monitoringMetrics) GetCertSignError(err string) monitoring.Metric {
 	return m.certSignErrors.With(errorTag.Value(err))
 }
+
+func (m *monitoringMetrics) GetPolicyDenial(reason string) monitoring.Metric {
+	return m.policyDenials.With(reasonTag.Value(reason))
+}
@@ -0,0 +1,189 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
+)
+
+const (
+	policyDenyIdentity      = "identity"
+	policyDenyImpersonation = "impersonation"
+	policyDenyInvalid       = "invalid_identity"
+)
+
+// IssuancePolicy restricts the certificates the CA server will sign for authenticated callers.
+// It is evaluated after authentication, before the CSR is signed. An empty policy allows everything.
+//
+// Example:
+//
+//	rules:
+//	- namespaces: ["prod-*"]
+//	  maxTTL: 1h
+//	  denyServiceAccounts: ["default"]
+//	impersonation:
+//	  allowedCallers: ["istio-system/ztunnel"]
+type IssuancePolicy struct {
+	// Rules are matched in order against the namespace of each requested identity; the first match applies.
+	// Identities in namespaces that match no rule are allowed.
+	Rules []IssuanceRule `json:"rules,omitempty"`
+	// Impersonation restricts which callers may request certificates for another identity.
+	Impersonation *ImpersonationPolicy `json:"impersonation,omitempty"`
+}
+
+// IssuanceRule is the policy applied to identities in a set of namespaces.
+type IssuanceRule struct {
+	// Namespaces the rule applies to. Entries are glob patterns, as defined by path.Match.
+	Namespaces []string `json:"namespaces"`
+	// MaxTTL, if set, caps the TTL of issued certificates. Longer requests are shortened rather than denied,
+	// and requests that do not specify a TTL are issued the default TTL of the CA, capped the same way.
+	MaxTTL *metav1.Duration `json:"maxTTL,omitempty"`
+	// AllowServiceAccounts, if set, lists the service account patterns that may be issued a certificate.
+	AllowServiceAccounts []string `json:"allowServiceAccounts,omitempty"`
+	// DenyServiceAccounts lists the service account patterns that may never be issued a certificate.
+	// Deny takes precedence over allow.
+	DenyServiceAccounts []string `json:"denyServiceAccounts,omitempty"`
+}
+
+// ImpersonationPolicy restricts use of the security.ImpersonatedIdentity request metadata.
+type ImpersonationPolicy struct {
+	// AllowedCallers lists the callers, in "namespace/serviceaccount" form, that may impersonate.
+	// Entries are glob patterns, as defined by path.Match. If empty, no caller may impersonate.
+	AllowedCallers []string `json:"allowedCallers"`
+}
+
+// LoadIssuancePolicy reads an IssuancePolicy from a YAML or JSON file.
+func LoadIssuancePolicy(file string) (*IssuancePolicy, error) {
+	by, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuance policy: %v", err)
+	}
+	return ParseIssuancePolicy(by)
+}
+
+// ParseIssuancePolicy parses and validates an IssuancePolicy from YAML or JSON.
+func ParseIssuancePolicy(by []byte) (*IssuancePolicy, error) {
+	p := &IssuancePolicy{}
+	if err := yaml.UnmarshalStrict(by, p); err != nil {
+		return nil, fmt.Errorf("failed to parse issuance policy: %v", err)
+	}
+	for i, r := range p.Rules {
+		if len(r.Namespaces) == 0 {
+			return nil, fmt.Errorf("issuance policy rule %d: namespaces must be set", i)
+		}
+		if r.MaxTTL != nil && r.MaxTTL.Duration <= 0 {
+			return nil, fmt.Errorf("issuance policy rule %d: maxTTL must be positive", i)
+		}
+		for _, pattern := range concat(r.Namespaces, r.AllowServiceAccounts, r.DenyServiceAccounts) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("issuance policy rule %d: invalid pattern %q: %v", i, pattern, err)
+			}
+		}
+	}
+	if p.Impersonation != nil {
+		for _, pattern := range p.Impersonation.AllowedCallers {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("issuance policy impersonation: invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// authorizeImpersonation checks whether the caller may request a certificate for another identity.
+func (p *IssuancePolicy) authorizeImpersonation(caller security.KubernetesInfo) error {
+	if p == nil || p.Impersonation == nil {
+		return nil
+	}
+	callerSa := caller.PodNamespace + "/" + caller.PodServiceAccount
+	if matchAny(p.Impersonation.AllowedCallers, callerSa) {
+		return nil
+	}
+	return fmt.Errorf("caller %v is not allowed to impersonate by issuance policy", callerSa)
+}
+
+// authorize checks that all identities may be issued a certificate, and returns the TTL to issue,
+// capped by the most restrictive matching rule. A non-positive TTL requests the default TTL of the CA,
+// defaultTTL, which is capped the same way. The second return value is the denial reason.
+func (p *IssuancePolicy) authorize(identities []string, ttl, defaultTTL time.Duration) (time.Duration, string, error) {
+	if p == nil {
+		return ttl, "", nil
+	}
+	for _, id := range identities {
+		identity, err := spiffe.ParseIdentity(id)
+		if err != nil {
+			// Without a namespace and service account there is nothing to match rules against, so only
+			// allow such identities if no rules are configured.
+			if len(p.Rules) == 0 {
+				continue
+			}
+			return 0, policyDenyInvalid, fmt.Errorf("identity %q is not a spiffe identity", id)
+		}
+		rule := p.ruleFor(identity.Namespace)
+		if rule == nil {
+			continue
+		}
+		if matchAny(rule.DenyServiceAccounts, identity.ServiceAccount) {
+			return 0, policyDenyIdentity, fmt.Errorf("identity %v is denied by issuance policy", id)
+		}
+		if len(rule.AllowServiceAccounts) > 0 && !matchAny(rule.AllowServiceAccounts, identity.ServiceAccount) {
+			return 0, policyDenyIdentity, fmt.Errorf("identity %v is not allowed by issuance policy", id)
+		}
+		if rule.MaxTTL != nil && ttl <= 0 {
+			ttl = defaultTTL
+		}
+		if rule.MaxTTL != nil && (ttl <= 0 || ttl > rule.MaxTTL.Duration) {
+			serverCaLog.Debugf("capping certificate TTL for %v from %v to %v", id, ttl, rule.MaxTTL.Duration)
+			ttl = rule.MaxTTL.Duration
+		}
+	}
+	return ttl, "", nil
+}
+
+func (p *IssuancePolicy) ruleFor(namespace string) *IssuanceRule {
+	for i := range p.Rules {
+		if matchAny(p.Rules[i].Namespaces, namespace) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// matchAny returns true if s matches any of the (already validated) glob patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func concat(lists ...[]string) []string {
+	var res []string
+	for _, l := range lists {
+		res = append(res, l...)
+	}
+	return res
+}
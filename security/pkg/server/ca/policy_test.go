@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/security"
+)
+
+const testPolicy = `
+rules:
+- namespaces: ["prod-*"]
+  maxTTL: 1h
+  allowServiceAccounts: ["app-*"]
+  denyServiceAccounts: ["app-legacy"]
+- namespaces: ["dev"]
+  maxTTL: 15m
+impersonation:
+  allowedCallers: ["istio-system/ztunnel"]
+`
+
+func TestParseIssuancePolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{name: "empty", in: ""},
+		{name: "valid", in: testPolicy},
+		{name: "unknown field", in: "rules:\n- namespaces: [a]\n  ttl: 1h", wantErr: true},
+		{name: "no namespaces", in: "rules:\n- maxTTL: 1h", wantErr: true},
+		{name: "negative ttl", in: "rules:\n- namespaces: [a]\n  maxTTL: -1h", wantErr: true},
+		{name: "bad pattern", in: "rules:\n- namespaces: [\"[\"]", wantErr: true},
+		{name: "bad caller pattern", in: "impersonation:\n  allowedCallers: [\"[\"]", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseIssuancePolicy([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIssuancePolicyAuthorize(t *testing.T) {
+	policy, err := ParseIssuancePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name       string
+		policy     *IssuancePolicy
+		identities []string
+		ttl        time.Duration
+		defaultTTL time.Duration
+		wantTTL    time.Duration
+		wantReason string
+	}{
+		{
+			name:       "no policy",
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/other"},
+			ttl:        24 * time.Hour,
+			wantTTL:    24 * time.Hour,
+		},
+		{
+			name:       "unmatched namespace",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/other/sa/default"},
+			ttl:        24 * time.Hour,
+			wantTTL:    24 * time.Hour,
+		},
+		{
+			name:       "capped ttl",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/app-foo"},
+			ttl:        24 * time.Hour,
+			wantTTL:    time.Hour,
+		},
+		{
+			name:       "short ttl kept",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/app-foo"},
+			ttl:        time.Minute,
+			wantTTL:    time.Minute,
+		},
+		{
+			name:       "default ttl",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/dev/sa/default"},
+			wantTTL:    15 * time.Minute,
+		},
+		{
+			name:       "zero ttl uses the default ttl",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/app-foo"},
+			defaultTTL: 10 * time.Minute,
+			wantTTL:    10 * time.Minute,
+		},
+		{
+			name:       "zero ttl caps the default ttl",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/app-foo"},
+			defaultTTL: 24 * time.Hour,
+			wantTTL:    time.Hour,
+		},
+		{
+			name:       "zero ttl without max ttl",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/other/sa/default"},
+			defaultTTL: 24 * time.Hour,
+			wantTTL:    0,
+		},
+		{
+			name:       "most restrictive ttl",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/app-foo", "spiffe://cluster.local/ns/dev/sa/default"},
+			ttl:        24 * time.Hour,
+			wantTTL:    15 * time.Minute,
+		},
+		{
+			name:       "not allowed",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/default"},
+			ttl:        time.Hour,
+			wantReason: policyDenyIdentity,
+		},
+		{
+			name:       "denied",
+			policy:     policy,
+			identities: []string{"spiffe://cluster.local/ns/prod-a/sa/app-legacy"},
+			ttl:        time.Hour,
+			wantReason: policyDenyIdentity,
+		},
+		{
+			name:       "not spiffe",
+			policy:     policy,
+			identities: []string{"test-identity"},
+			ttl:        time.Hour,
+			wantReason: policyDenyInvalid,
+		},
+		{
+			name:       "not spiffe without rules",
+			policy:     &IssuancePolicy{},
+			identities: []string{"test-identity"},
+			ttl:        time.Hour,
+			wantTTL:    time.Hour,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, reason, err := tt.policy.authorize(tt.identities, tt.ttl, tt.defaultTTL)
+			if reason != tt.wantReason {
+				t.Fatalf("expected reason %q, got %q (%v)", tt.wantReason, reason, err)
+			}
+			if (err != nil) != (tt.wantReason != "") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && ttl != tt.wantTTL {
+				t.Fatalf("expected ttl %v, got %v", tt.wantTTL, ttl)
+			}
+		})
+	}
+}
+
+func TestIssuancePolicyAuthorizeImpersonation(t *testing.T) {
+	policy, err := ParseIssuancePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := security.KubernetesInfo{PodNamespace: "istio-system", PodServiceAccount: "ztunnel"}
+	denied := security.KubernetesInfo{PodNamespace: "istio-system", PodServiceAccount: "istiod"}
+	if err := policy.authorizeImpersonation(allowed); err != nil {
+		t.Fatalf("expected impersonation to be allowed: %v", err)
+	}
+	if err := policy.authorizeImpersonation(denied); err == nil {
+		t.Fatalf("expected impersonation to be denied")
+	}
+	if err := (&IssuancePolicy{}).authorizeImpersonation(denied); err != nil {
+		t.Fatalf("expected impersonation to be allowed without an impersonation policy: %v", err)
+	}
+	if err := (&IssuancePolicy{Impersonation: &ImpersonationPolicy{}}).authorizeImpersonation(allowed); err == nil {
+		t.Fatalf("expected impersonation to be denied with an empty allow list")
+	}
+}
@@ -54,8 +54,12 @@ type Server struct {
 	Authenticators []security.Authenticator
 	ca             CertificateAuthority
 	serverCertTTL  time.Duration
+	// defaultCertTTL is the TTL of the certificates issued when the request does not set a positive TTL.
+	defaultCertTTL time.Duration
 
 	nodeAuthorizer *NodeAuthorizer
+	// policy, if set, restricts the certificates issued to authenticated callers.
+	policy *IssuancePolicy
 }
 
 type SaNode struct {
@@ -97,6 +101,11 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 			return nil, status.Error(codes.Unauthenticated, "request impersonation authentication failure")
 
 		}
+		if err := s.policy.authorizeImpersonation(caller.KubernetesInfo); err != nil {
+			s.monitoring.GetPolicyDenial(policyDenyImpersonation).Increment()
+			serverCaLog.Warnf("impersonation denied: %v", err)
+			return nil, status.Error(codes.PermissionDenied, "request impersonation denied by issuance policy")
+		}
 		if err := s.nodeAuthorizer.authenticateImpersonation(caller.KubernetesInfo, impersonatedIdentity); err != nil {
 			s.monitoring.AuthnError.Increment()
 			// Return an opaque error (for security purposes) but log the full reason
@@ -106,12 +115,18 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		// Node is authorized to impersonate; overwrite the SAN to the impersonated identity.
 		sans = []string{impersonatedIdentity}
 	}
+	ttl, reason, err := s.policy.authorize(sans, time.Duration(request.ValidityDuration)*time.Second, s.defaultCertTTL)
+	if err != nil {
+		s.monitoring.GetPolicyDenial(reason).Increment()
+		serverCaLog.Warnf("certificate request denied: %v", err)
+		return nil, status.Error(codes.PermissionDenied, "request denied by issuance policy")
+	}
 	certSigner := crMetadata[security.CertSigner].GetStringValue()
 	serverCaLog.Debugf("cert signer from workload %s", certSigner)
 	_, _, certChainBytes, rootCertBytes := s.ca.GetCAKeyCertBundle().GetAll()
 	certOpts := ca.CertOpts{
 		SubjectIDs: sans,
-		TTL:        time.Duration(request.ValidityDuration) * time.Second,
+		TTL:        ttl,
 		ForCA:      false,
 		CertSigner: certSigner,
 	}
@@ -171,6 +186,7 @@ func (s *Server) Register(grpcServer *grpc.Server) {
 // New creates a new instance of `IstioCAServiceServer`
 func New(
 	ca CertificateAuthority,
+	defaultCertTTL time.Duration,
 	ttl time.Duration,
 	authenticators []security.Authenticator,
 	client kube.Client,
@@ -184,10 +200,20 @@ func New(
 	server := &Server{
 		Authenticators: authenticators,
 		serverCertTTL:  ttl,
+		defaultCertTTL: defaultCertTTL,
 		ca:             ca,
 		monitoring:     newMonitoringMetrics(),
 	}
 
+	if features.CAIssuancePolicyFile != "" {
+		policy, err := LoadIssuancePolicy(features.CAIssuancePolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		server.policy = policy
+		serverCaLog.Infof("loaded certificate issuance policy from %v", features.CAIssuancePolicyFile)
+	}
+
 	if len(features.CATrustedNodeAccounts) > 0 && client != nil {
 		// TODO: do we need some way to delayed readiness until this is synced? Probably
 		// Worst case is we deny some requests though which are retried
@@ -184,6 +184,7 @@ func TestCreateCertificate(t *testing.T) {
 	testCases := map[string]struct {
 		authenticators []security.Authenticator
 		ca             CertificateAuthority
+		policy         *IssuancePolicy
 		certChain      []string
 		code           codes.Code
 	}{
@@ -228,6 +229,15 @@ func TestCreateCertificate(t *testing.T) {
 			certChain: []string{"cert", "cert_chain", "root_cert"},
 			code:      codes.OK,
 		},
+		"Denied by issuance policy": {
+			authenticators: []security.Authenticator{&mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/prod/sa/default"}}},
+			ca: &mockca.FakeCA{
+				SignedCert:    []byte("cert"),
+				KeyCertBundle: util.NewKeyCertBundleFromPem(nil, nil, []byte("cert_chain"), []byte("root_cert")),
+			},
+			policy: &IssuancePolicy{Rules: []IssuanceRule{{Namespaces: []string{"prod"}, DenyServiceAccounts: []string{"default"}}}},
+			code:   codes.PermissionDenied,
+		},
 	}
 
 	for id, c := range testCases {
@@ -235,6 +245,7 @@ func TestCreateCertificate(t *testing.T) {
 			ca:             c.ca,
 			Authenticators: c.authenticators,
 			monitoring:     newMonitoringMetrics(),
+			policy:         c.policy,
 		}
 		request := &pb.IstioCertificateRequest{Csr: "dumb CSR"}
 
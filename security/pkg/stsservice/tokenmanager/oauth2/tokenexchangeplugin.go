@@ -0,0 +1,360 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/stsservice"
+	"istio.io/pkg/log"
+)
+
+const (
+	httpTimeOutInSec = 5
+	maxRequestRetry  = 5
+	maxResponseSize  = 1 << 20
+	// defaultTokenLifetime is used when the token endpoint does not return expires_in.
+	defaultTokenLifetime = time.Hour
+	// defaultGracePeriod is how long before expiry a cached token is refreshed.
+	defaultGracePeriod = 5 * time.Minute
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	accessToken            = "access token"
+
+	// ClientSecretBasic sends the client credentials in the HTTP basic authorization header.
+	ClientSecretBasic = "client_secret_basic"
+	// ClientSecretPost sends the client credentials in the request body.
+	ClientSecretPost = "client_secret_post"
+	// ClientAuthNone does not authenticate the client.
+	ClientAuthNone = "none"
+)
+
+var pluginLog = log.RegisterScope("oauth2token", "OAuth 2.0 token manager plugin debugging")
+
+// Config configures token exchange against an OAuth 2.0 token endpoint.
+type Config struct {
+	// TokenEndpoint is the URL of the OAuth 2.0 token endpoint.
+	TokenEndpoint string
+	// ClientID and ClientSecret authenticate the client to the token endpoint, if set.
+	ClientID     string
+	ClientSecret string
+	// ClientAuthMethod is one of client_secret_basic (the default), client_secret_post or none.
+	ClientAuthMethod string
+	// Scopes are requested when the STS request does not specify a scope.
+	Scopes []string
+	// Audiences are requested when the STS request does not specify an audience.
+	Audiences []string
+	// CACertPath is the PEM file of the roots used to verify the token endpoint. The system roots are used if unset.
+	CACertPath string
+	// EnableCache caches the exchanged tokens until they are about to expire.
+	EnableCache bool
+}
+
+// Plugin supports RFC 8693 token exchange with any OAuth 2.0 authorization server.
+type Plugin struct {
+	httpClient *http.Client
+	config     Config
+	// tokens is the cache for exchanged tokens.
+	// map key is the requested scope and audiences, map value is tokenInfo.
+	tokens sync.Map
+}
+
+// CreateTokenManagerPlugin creates a plugin that exchanges tokens with an OAuth 2.0 authorization server.
+func CreateTokenManagerPlugin(config Config) (*Plugin, error) {
+	u, err := url.Parse(config.TokenEndpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OAuth 2.0 token endpoint %q", config.TokenEndpoint)
+	}
+	switch config.ClientAuthMethod {
+	case "":
+		config.ClientAuthMethod = ClientSecretBasic
+	case ClientSecretBasic, ClientSecretPost, ClientAuthNone:
+	default:
+		return nil, fmt.Errorf("unsupported OAuth 2.0 client authentication method %q", config.ClientAuthMethod)
+	}
+	if config.ClientAuthMethod != ClientAuthNone && config.ClientID == "" {
+		return nil, fmt.Errorf("OAuth 2.0 client authentication method %q requires a client ID", config.ClientAuthMethod)
+	}
+	caCertPool, err := x509.SystemCertPool()
+	if err != nil {
+		pluginLog.Errorf("Failed to get SystemCertPool: %v", err)
+		return nil, err
+	}
+	if config.CACertPath != "" {
+		caCert, err := os.ReadFile(config.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate %s: %v", config.CACertPath, err)
+		}
+		caCertPool = x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", config.CACertPath)
+		}
+	}
+	return &Plugin{
+		httpClient: &http.Client{
+			Timeout: httpTimeOutInSec * time.Second,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					RootCAs:    caCertPool,
+					MinVersion: tls.VersionTLS12,
+				},
+			},
+		},
+		config: config,
+	}, nil
+}
+
+type tokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"` // Expiration time in seconds
+	Scope           string `json:"scope"`
+}
+
+// ExchangeToken takes STS request parameters and exchanges the subject token at the token endpoint,
+// returns StsResponseParameters in JSON.
+func (p *Plugin) ExchangeToken(parameters security.StsRequestParameters) ([]byte, error) {
+	form := p.constructTokenRequest(parameters)
+	key := cacheKey(form)
+	if p.config.EnableCache {
+		if v, ok := p.tokens.Load(key); ok {
+			token := v.(stsservice.TokenInfo)
+			if remainingLife := time.Until(token.ExpireTime); remainingLife > defaultGracePeriod {
+				pluginLog.Debugf("use cached access token for %s with remaining lifetime %v", key, remainingLife)
+				return p.generateSTSResp(token.Token, token.TokenType, int64(remainingLife.Seconds()))
+			}
+		}
+	}
+
+	resp, err := p.fetchToken(form)
+	if err != nil {
+		return nil, err
+	}
+	lifetime := defaultTokenLifetime
+	if resp.ExpiresIn > 0 {
+		lifetime = time.Duration(resp.ExpiresIn) * time.Second
+	}
+	issuedTokenType := resp.IssuedTokenType
+	if issuedTokenType == "" {
+		issuedTokenType = accessTokenType
+	}
+	now := time.Now()
+	p.tokens.Store(key, stsservice.TokenInfo{
+		TokenType:  issuedTokenType,
+		IssueTime:  now,
+		ExpireTime: now.Add(lifetime),
+		Token:      resp.AccessToken,
+	})
+	return p.generateSTSResp(resp.AccessToken, issuedTokenType, int64(lifetime.Seconds()))
+}
+
+// constructTokenRequest returns the form of a token exchange request.
+// Example of a token exchange request:
+// POST /token
+// Content-Type: application/x-www-form-urlencoded
+// Authorization: Basic <client credentials>
+//
+//	grant_type=urn:ietf:params:oauth:grant-type:token-exchange
+//	&subject_token=<jwt token>
+//	&subject_token_type=urn:ietf:params:oauth:token-type:jwt
+//	&requested_token_type=urn:ietf:params:oauth:token-type:access_token
+//	&scope=<scopes>
+//	&audience=<audience>
+func (p *Plugin) constructTokenRequest(parameters security.StsRequestParameters) url.Values {
+	form := url.Values{}
+	form.Set("grant_type", tokenExchangeGrantType)
+	form.Set("subject_token", parameters.SubjectToken)
+	subjectTokenType := parameters.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = jwtTokenType
+	}
+	form.Set("subject_token_type", subjectTokenType)
+	requestedTokenType := parameters.RequestedTokenType
+	if requestedTokenType == "" {
+		requestedTokenType = accessTokenType
+	}
+	form.Set("requested_token_type", requestedTokenType)
+	if parameters.Scope != "" {
+		form.Set("scope", parameters.Scope)
+	} else if len(p.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.config.Scopes, " "))
+	}
+	if parameters.Audience != "" {
+		form.Set("audience", parameters.Audience)
+	} else {
+		for _, aud := range p.config.Audiences {
+			form.Add("audience", aud)
+		}
+	}
+	if parameters.Resource != "" {
+		form.Set("resource", parameters.Resource)
+	}
+	if parameters.ActorToken != "" {
+		form.Set("actor_token", parameters.ActorToken)
+		form.Set("actor_token_type", parameters.ActorTokenType)
+	}
+	if p.config.ClientAuthMethod == ClientSecretPost {
+		form.Set("client_id", p.config.ClientID)
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	return form
+}
+
+// cacheKey identifies the token requested by a form, so tokens of different scopes and audiences
+// are cached separately.
+func cacheKey(form url.Values) string {
+	audiences := append([]string{}, form["audience"]...)
+	sort.Strings(audiences)
+	return fmt.Sprintf("%s scope=%q audience=%q resource=%q", accessToken,
+		form.Get("scope"), strings.Join(audiences, " "), form.Get("resource"))
+}
+
+func (p *Plugin) fetchToken(form url.Values) (*tokenResponse, error) {
+	body, status, timeElapsed, err := p.sendRequestWithRetry(form.Encode())
+	if err != nil {
+		pluginLog.Errorf("Failed to exchange token (HTTP status %d, total time elapsed %s): %v",
+			status, timeElapsed.String(), err)
+		return nil, fmt.Errorf("failed to exchange token (HTTP status %d): %v", status, err)
+	}
+	respData := &tokenResponse{}
+	if err := json.Unmarshal(body, respData); err != nil {
+		pluginLog.Errorf("Failed to unmarshal token response data: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal token response data: %v", err)
+	}
+	if respData.AccessToken == "" {
+		pluginLog.Error("token response does not have access token")
+		return nil, errors.New("token response does not have access token")
+	}
+	pluginLog.WithLabels("latency", timeElapsed.String(), "ttl", respData.ExpiresIn).Infof("fetched access token")
+	return respData, nil
+}
+
+// sendRequestWithRetry sends the token request every 0.01 seconds until it receives a response or hits
+// the max retry number. If the response code is 4xx, it returns immediately without retry.
+func (p *Plugin) sendRequestWithRetry(form string) (body []byte, status int, elapsedTime time.Duration, err error) {
+	start := time.Now()
+	for i := 0; i < maxRequestRetry; i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, p.config.TokenEndpoint, strings.NewReader(form))
+		if err != nil {
+			return nil, 0, time.Since(start), fmt.Errorf("failed to create token request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		if p.config.ClientAuthMethod == ClientSecretBasic {
+			req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+		}
+		var resp *http.Response
+		resp, err = p.httpClient.Do(req)
+		if err != nil {
+			pluginLog.Errorf("failed to send out request: %v", err)
+			continue
+		}
+		status = resp.StatusCode
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+		if status == http.StatusOK {
+			return body, status, time.Since(start), nil
+		}
+		err = responseError(status, body)
+		if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+			return nil, status, time.Since(start), err
+		}
+	}
+	return nil, status, time.Since(start), err
+}
+
+// responseError converts an unsuccessful token response, which is an OAuth 2.0 error response if
+// returned by a compliant server, into an error.
+func responseError(status int, body []byte) error {
+	errResp := stsservice.StsErrorResponse{}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		if errResp.ErrorDescription != "" {
+			return fmt.Errorf("%s: %s", errResp.Error, errResp.ErrorDescription)
+		}
+		return errors.New(errResp.Error)
+	}
+	return fmt.Errorf("HTTP Status %d, body: %s", status, string(body))
+}
+
+func (p *Plugin) generateSTSResp(token, issuedTokenType string, expire int64) ([]byte, error) {
+	stsRespParam := stsservice.StsResponseParameters{
+		AccessToken:     token,
+		IssuedTokenType: issuedTokenType,
+		TokenType:       "Bearer",
+		ExpiresIn:       expire,
+	}
+	return json.MarshalIndent(stsRespParam, "", " ")
+}
+
+// DumpPluginStatus dumps all token status in JSON
+func (p *Plugin) DumpPluginStatus() ([]byte, error) {
+	tokenStatus := make([]stsservice.TokenInfo, 0)
+	p.tokens.Range(func(k any, v any) bool {
+		token := v.(stsservice.TokenInfo)
+		tokenStatus = append(tokenStatus, stsservice.TokenInfo{
+			TokenType: k.(string), IssueTime: token.IssueTime, ExpireTime: token.ExpireTime,
+		})
+		return true
+	})
+	sort.Slice(tokenStatus, func(i, j int) bool {
+		return tokenStatus[i].TokenType < tokenStatus[j].TokenType
+	})
+	td := stsservice.TokensDump{
+		Tokens: tokenStatus,
+	}
+	return json.MarshalIndent(td, "", " ")
+}
+
+// GetMetadata returns the metadata headers related to the token
+func (p *Plugin) GetMetadata(_ bool, _, token string) (map[string]string, error) {
+	if token == "" {
+		return nil, fmt.Errorf("empty token in plugin GetMetadata")
+	}
+	return map[string]string{
+		"authorization": "Bearer " + token,
+	}, nil
+}
+
+// ClearCache is only used for testing purposes.
+func (p *Plugin) ClearCache() {
+	p.tokens.Range(func(k any, _ any) bool {
+		p.tokens.Delete(k)
+		return true
+	})
+}
@@ -0,0 +1,296 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/stsservice"
+)
+
+const fakeSubjectToken = "fake-subject-token"
+
+type fakeTokenServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []url.Values
+	auth     []string
+	// failures is the number of requests answered with an internal error before succeeding.
+	failures  int
+	status    int
+	response  string
+	expiresIn int64
+}
+
+func newFakeTokenServer(t *testing.T) *fakeTokenServer {
+	s := &fakeTokenServer{expiresIn: 3600}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.requests = append(s.requests, r.PostForm)
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			_, _ = w.Write([]byte(s.response))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":      fmt.Sprintf("access-token-%d", len(s.requests)),
+			"issued_token_type": accessTokenType,
+			"token_type":        "Bearer",
+			"expires_in":        s.expiresIn,
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func exchange(t *testing.T, p *Plugin, params security.StsRequestParameters) stsservice.StsResponseParameters {
+	t.Helper()
+	params.SubjectToken = fakeSubjectToken
+	out, err := p.ExchangeToken(params)
+	if err != nil {
+		t.Fatalf("ExchangeToken() returns error: %v", err)
+	}
+	resp := stsservice.StsResponseParameters{}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCreateTokenManagerPlugin(t *testing.T) {
+	testCases := map[string]struct {
+		config      Config
+		expectedErr string
+	}{
+		"valid": {
+			config: Config{TokenEndpoint: "https://auth.example.com/token", ClientID: "istio"},
+		},
+		"no client auth": {
+			config: Config{TokenEndpoint: "https://auth.example.com/token", ClientAuthMethod: ClientAuthNone},
+		},
+		"invalid endpoint": {
+			config:      Config{TokenEndpoint: "auth.example.com/token", ClientID: "istio"},
+			expectedErr: "invalid OAuth 2.0 token endpoint",
+		},
+		"unsupported client auth": {
+			config:      Config{TokenEndpoint: "https://auth.example.com/token", ClientAuthMethod: "private_key_jwt"},
+			expectedErr: "unsupported OAuth 2.0 client authentication method",
+		},
+		"missing client id": {
+			config:      Config{TokenEndpoint: "https://auth.example.com/token"},
+			expectedErr: "requires a client ID",
+		},
+		"missing ca cert": {
+			config:      Config{TokenEndpoint: "https://auth.example.com/token", ClientID: "istio", CACertPath: "/does/not/exist"},
+			expectedErr: "failed to read CA certificate",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := CreateTokenManagerPlugin(tc.config)
+			if tc.expectedErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErr)) {
+				t.Fatalf("got error %v, want %q", err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestExchangeToken(t *testing.T) {
+	testCases := map[string]struct {
+		config       Config
+		params       security.StsRequestParameters
+		expectedForm url.Values
+		expectedAuth string
+	}{
+		"configured scopes and audiences with basic auth": {
+			config: Config{ClientID: "istio", ClientSecret: "secret", Scopes: []string{"read", "write"}, Audiences: []string{"a", "b"}},
+			expectedForm: url.Values{
+				"grant_type":           {tokenExchangeGrantType},
+				"subject_token":        {fakeSubjectToken},
+				"subject_token_type":   {jwtTokenType},
+				"requested_token_type": {accessTokenType},
+				"scope":                {"read write"},
+				"audience":             {"a", "b"},
+			},
+			expectedAuth: "Basic aXN0aW86c2VjcmV0",
+		},
+		"request parameters override configuration": {
+			config: Config{ClientID: "istio", ClientSecret: "secret", ClientAuthMethod: ClientSecretPost, Scopes: []string{"read"}, Audiences: []string{"a"}},
+			params: security.StsRequestParameters{
+				Scope:            "admin",
+				Audience:         "c",
+				Resource:         "https://api.example.com",
+				SubjectTokenType: "urn:ietf:params:oauth:token-type:id_token",
+			},
+			expectedForm: url.Values{
+				"grant_type":           {tokenExchangeGrantType},
+				"subject_token":        {fakeSubjectToken},
+				"subject_token_type":   {"urn:ietf:params:oauth:token-type:id_token"},
+				"requested_token_type": {accessTokenType},
+				"scope":                {"admin"},
+				"audience":             {"c"},
+				"resource":             {"https://api.example.com"},
+				"client_id":            {"istio"},
+				"client_secret":        {"secret"},
+			},
+		},
+		"no client auth": {
+			config: Config{ClientAuthMethod: ClientAuthNone},
+			expectedForm: url.Values{
+				"grant_type":           {tokenExchangeGrantType},
+				"subject_token":        {fakeSubjectToken},
+				"subject_token_type":   {jwtTokenType},
+				"requested_token_type": {accessTokenType},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := newFakeTokenServer(t)
+			tc.config.TokenEndpoint = server.URL
+			p, err := CreateTokenManagerPlugin(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := exchange(t, p, tc.params)
+			if resp.AccessToken != "access-token-1" || resp.TokenType != "Bearer" || resp.ExpiresIn != 3600 ||
+				resp.IssuedTokenType != accessTokenType {
+				t.Errorf("unexpected STS response: %+v", resp)
+			}
+			if !reflect.DeepEqual(server.requests[0], tc.expectedForm) {
+				t.Errorf("token request form is %v, want %v", server.requests[0], tc.expectedForm)
+			}
+			if server.auth[0] != tc.expectedAuth {
+				t.Errorf("authorization header is %q, want %q", server.auth[0], tc.expectedAuth)
+			}
+		})
+	}
+}
+
+func TestExchangeTokenCache(t *testing.T) {
+	server := newFakeTokenServer(t)
+	p, err := CreateTokenManagerPlugin(Config{TokenEndpoint: server.URL, ClientAuthMethod: ClientAuthNone, EnableCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := exchange(t, p, security.StsRequestParameters{Scope: "read"}).AccessToken; got != "access-token-1" {
+		t.Errorf("got token %s, want access-token-1", got)
+	}
+	if got := exchange(t, p, security.StsRequestParameters{Scope: "read"}).AccessToken; got != "access-token-1" {
+		t.Errorf("got token %s, want cached access-token-1", got)
+	}
+	// Tokens of a different scope are cached separately.
+	if got := exchange(t, p, security.StsRequestParameters{Scope: "write"}).AccessToken; got != "access-token-2" {
+		t.Errorf("got token %s, want access-token-2", got)
+	}
+
+	dump, err := p.DumpPluginStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := stsservice.TokensDump{}
+	if err := json.Unmarshal(dump, &td); err != nil {
+		t.Fatal(err)
+	}
+	if len(td.Tokens) != 2 || !strings.Contains(td.Tokens[0].TokenType, `scope="read"`) || td.Tokens[0].Token != "" {
+		t.Errorf("unexpected token dump: %s", dump)
+	}
+
+	// Tokens about to expire are not served from the cache.
+	server.mu.Lock()
+	server.expiresIn = 60
+	server.mu.Unlock()
+	p.ClearCache()
+	exchange(t, p, security.StsRequestParameters{})
+	if got := exchange(t, p, security.StsRequestParameters{}).AccessToken; got != "access-token-4" {
+		t.Errorf("got token %s, want access-token-4", got)
+	}
+}
+
+func TestExchangeTokenErrors(t *testing.T) {
+	server := newFakeTokenServer(t)
+	p, err := CreateTokenManagerPlugin(Config{TokenEndpoint: server.URL, ClientAuthMethod: ClientAuthNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Server errors are retried.
+	server.mu.Lock()
+	server.failures = 2
+	server.mu.Unlock()
+	if got := exchange(t, p, security.StsRequestParameters{}).AccessToken; got != "access-token-3" {
+		t.Errorf("got token %s, want access-token-3", got)
+	}
+
+	// Client errors are not retried, and OAuth 2.0 error responses are surfaced.
+	server.mu.Lock()
+	server.requests = nil
+	server.status = http.StatusBadRequest
+	server.response = `{"error": "invalid_grant", "error_description": "subject token expired"}`
+	server.mu.Unlock()
+	_, err = p.ExchangeToken(security.StsRequestParameters{SubjectToken: fakeSubjectToken})
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant: subject token expired") {
+		t.Errorf("got error %v, want invalid_grant", err)
+	}
+	if len(server.requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(server.requests))
+	}
+
+	server.mu.Lock()
+	server.status = http.StatusOK
+	server.response = `{"token_type": "Bearer"}`
+	server.mu.Unlock()
+	if _, err = p.ExchangeToken(security.StsRequestParameters{SubjectToken: fakeSubjectToken}); err == nil ||
+		!strings.Contains(err.Error(), "does not have access token") {
+		t.Errorf("got error %v, want missing access token", err)
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	p := &Plugin{}
+	if _, err := p.GetMetadata(false, "", ""); err == nil {
+		t.Error("expected error for empty token")
+	}
+	md, err := p.GetMetadata(true, "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(md, map[string]string{"authorization": "Bearer token"}) {
+		t.Errorf("unexpected metadata: %v", md)
+	}
+}
@@ -21,11 +21,14 @@ import (
 	"istio.io/istio/pkg/bootstrap/platform"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/stsservice/tokenmanager/google"
+	"istio.io/istio/security/pkg/stsservice/tokenmanager/oauth2"
 )
 
 const (
 	// GoogleTokenExchange is the name of the google token exchange service.
 	GoogleTokenExchange = "GoogleTokenExchange"
+	// OAuth2TokenExchange is the name of the generic OAuth 2.0 token exchange service.
+	OAuth2TokenExchange = "OAuth2TokenExchange"
 )
 
 // Plugin provides common interfaces for specific token exchange services.
@@ -43,6 +46,8 @@ type TokenManager struct {
 type Config struct {
 	CredFetcher security.CredFetcher
 	TrustDomain string
+	// OAuth2 configures the OAuth2TokenExchange token manager.
+	OAuth2 oauth2.Config
 }
 
 // GCPProjectInfo stores GCP project information, including project number,
@@ -96,6 +101,12 @@ func CreateTokenManager(tokenManagerType string, config Config) (security.TokenM
 		} else {
 			return nil, fmt.Errorf("%v token manager specified but failed to ready GCP project information", GoogleTokenExchange)
 		}
+	case OAuth2TokenExchange:
+		p, err := oauth2.CreateTokenManagerPlugin(config.OAuth2)
+		if err != nil {
+			return nil, err
+		}
+		tm.plugin = p
 	}
 	return tm, nil
 }
@@ -103,7 +103,7 @@ func FuzzCreateCertE2EUsingClientCertAuthenticator(data []byte) int {
 		SignedCert:    signedCert,
 		KeyCertBundle: kcb,
 	}
-	server, err := ca.New(mockCa, 1, []security.Authenticator{auth}, nil, nil)
+	server, err := ca.New(mockCa, 1, 1, []security.Authenticator{auth}, nil, nil)
 	if err != nil {
 		return 0
 	}
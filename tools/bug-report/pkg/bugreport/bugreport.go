@@ -318,6 +318,7 @@ func gatherInfo(runner *kubectlcmd.Runner, config *config.BugReportConfig, resou
 		case common.IsProxyContainer(params.ClusterVersion, container):
 			if !ambient.IsZtunnelPod(client, pod, namespace) {
 				getFromCluster(content.GetCoredumps, cp, filepath.Join(proxyDir, "cores"), &mandatoryWg)
+				getFromCluster(content.GetCrashBundles, cp, filepath.Join(proxyDir, "crash"), &mandatoryWg)
 				getFromCluster(content.GetNetstat, cp, proxyDir, &mandatoryWg)
 				getFromCluster(content.GetProxyInfo, cp, archive.ProxyOutputPath(tempDir, namespace, pod), &optionalWg)
 				getProxyLogs(runner, config, resources, p, namespace, pod, container, &optionalWg)
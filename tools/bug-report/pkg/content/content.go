@@ -16,6 +16,7 @@ package content
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,7 +24,9 @@ import (
 	"istio.io/istio/pkg/config/analysis/analyzers"
 	"istio.io/istio/pkg/config/analysis/diag"
 	"istio.io/istio/pkg/config/analysis/local"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/envoy"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/tools/bug-report/pkg/common"
 	"istio.io/istio/tools/bug-report/pkg/kubectlcmd"
@@ -322,6 +325,52 @@ func getCoredumpList(p *Params) ([]string, error) {
 	return cds, nil
 }
 
+// GetCrashBundles returns the Envoy crash bundles written by istio-agent for the given namespace/pod/container,
+// keyed by their path relative to the crash bundle directory.
+func GetCrashBundles(p *Params) (map[string]string, error) {
+	if p.Namespace == "" || p.Pod == "" {
+		return nil, fmt.Errorf("getCrashBundles requires namespace and pod")
+	}
+	files, err := getCrashBundleFileList(p)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string)
+	log.Infof("%s/%s/%s has %d crash bundle files", p.Namespace, p.Pod, p.Container, len(files))
+	for _, f := range files {
+		rel, err := filepath.Rel(constants.EnvoyCrashDir, f)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		outStr, err := p.Runner.Cat(p.Namespace, p.Pod, p.Container, f, p.DryRun)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		ret[rel] = outStr
+	}
+	return ret, nil
+}
+
+func getCrashBundleFileList(p *Params) ([]string, error) {
+	// Search the data directory, which always exists, rather than the crash bundle directory, which only exists
+	// when crash forensics are enabled. Bundles being written are in hidden directories and skipped.
+	out, err := p.Runner.Exec(p.Namespace, p.Pod, p.Container,
+		fmt.Sprintf("find %s -path %s/%s* -type f", constants.IstioDataDir, constants.EnvoyCrashDir, envoy.CrashBundlePrefix), p.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(out, "\n") {
+		if strings.TrimSpace(f) != "" {
+			files = append(files, strings.TrimSpace(f))
+		}
+	}
+	return files, nil
+}
+
 func getCRDList(p *Params) ([]string, error) {
 	crdStr, err := p.Runner.RunCmd("get customresourcedefinitions --no-headers", "", p.KubeConfig, p.KubeContext, p.DryRun)
 	if err != nil {
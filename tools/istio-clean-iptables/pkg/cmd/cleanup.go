@@ -90,7 +90,25 @@ func removeOldChains(cfg *config.Config, ext dep.Dependencies, cmd string) {
 	flushAndDeleteChains(ext, cmd, constants.NAT, chains)
 }
 
+// removeNftTables deletes the nftables tables programmed by the nftables backend, which hold all of its rules.
+func removeNftTables(ext dep.Dependencies) {
+	for _, family := range []string{constants.NftFamilyIPv4, constants.NftFamilyIPv6} {
+		for _, table := range builder.NftTables {
+			ext.RunQuietlyAndIgnore(constants.NFT, nil, "delete", "table", family, builder.NftTableName(table))
+		}
+	}
+}
+
 func (c *IptablesCleaner) Run() {
+	if common.ResolveFirewallBackend(c.cfg.FirewallBackend) == constants.NftablesBackend {
+		defer func() {
+			// Best effort since we don't know if the command exists
+			_ = c.ext.Run(constants.NFT, nil, "list", "ruleset")
+		}()
+		removeNftTables(c.ext)
+		return
+	}
+
 	defer func() {
 		for _, cmd := range []string{constants.IPTABLESSAVE, constants.IP6TABLESSAVE} {
 			// iptables-save is best efforts
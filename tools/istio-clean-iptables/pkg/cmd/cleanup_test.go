@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"bytes"
 	"io"
 	"path/filepath"
 	"strings"
@@ -109,6 +110,24 @@ func TestIptables(t *testing.T) {
 	}
 }
 
+func TestNftables(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.FirewallBackend = constants.NftablesBackend
+
+	ext := &DependenciesStub{}
+	cleaner := NewIptablesCleaner(cfg, ext)
+
+	cleaner.Run()
+
+	compareToGolden(t, "nftables", ext.ExecutedAll)
+
+	expectedExecutedNormally := []string{"nft list ruleset"}
+	if diff := cmp.Diff(ext.ExecutedNormally, expectedExecutedNormally); diff != "" {
+		t.Fatalf("Executed normally commands: got\n%v\nwant\n%vdiff %v",
+			ext.ExecutedNormally, expectedExecutedNormally, diff)
+	}
+}
+
 func compareToGolden(t *testing.T, name string, actual []string) {
 	t.Helper()
 	gotBytes := []byte(strings.Join(actual, "\n"))
@@ -135,6 +154,11 @@ func (s *DependenciesStub) RunQuietlyAndIgnore(cmd string, stdin io.ReadSeeker,
 	s.execute(true /*quietly*/, cmd, args...)
 }
 
+func (s *DependenciesStub) RunWithOutput(cmd string, stdin io.ReadSeeker, args ...string) (*bytes.Buffer, error) {
+	s.execute(false /*quietly*/, cmd, args...)
+	return &bytes.Buffer{}, nil
+}
+
 func (s *DependenciesStub) execute(quietly bool, cmd string, args ...string) {
 	cmdline := strings.Join(append([]string{cmd}, args...), " ")
 	s.ExecutedAll = append(s.ExecutedAll, cmdline)
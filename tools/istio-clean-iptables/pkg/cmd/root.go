@@ -66,6 +66,7 @@ func constructConfig() *config.Config {
 		OwnerGroupsExclude:      viper.GetString(constants.OwnerGroupsExclude.Name),
 		InboundInterceptionMode: viper.GetString(constants.IstioInboundInterceptionMode.Name),
 		InboundTProxyMark:       viper.GetString(constants.IstioInboundTproxyMark.Name),
+		FirewallBackend:         viper.GetString(constants.FirewallBackend),
 	}
 
 	// TODO: Make this more configurable, maybe with an allowlist of users to be captured for output instead of a denylist.
@@ -145,6 +146,11 @@ func bindFlags(cmd *cobra.Command, args []string) {
 		handleError(err)
 	}
 	viper.SetDefault(constants.IstioInboundTproxyMark.Name, constants.IstioInboundTproxyMark.DefaultValue)
+
+	if err := viper.BindPFlag(constants.FirewallBackend, cmd.Flags().Lookup(constants.FirewallBackend)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.FirewallBackend, constants.IptablesBackend)
 }
 
 // https://github.com/spf13/viper/issues/233.
@@ -165,6 +171,9 @@ func init() {
 		"The mode used to redirect inbound connections to Envoy, either \"REDIRECT\" or \"TPROXY\"")
 
 	rootCmd.Flags().StringP(constants.InboundTProxyMark, "t", "", "")
+
+	rootCmd.Flags().String(constants.FirewallBackend, constants.IptablesBackend,
+		"The backend whose rules are cleaned up, one of \"iptables\", \"nftables\" or \"auto\"")
 }
 
 func GetCommand() *cobra.Command {
@@ -36,6 +36,7 @@ type Config struct {
 	OwnerGroupsExclude      string   `json:"OUTBOUND_OWNER_GROUPS_EXCLUDE"`
 	InboundInterceptionMode string   `json:"INBOUND_INTERCEPTION_MODE"`
 	InboundTProxyMark       string   `json:"INBOUND_TPROXY_MARK"`
+	FirewallBackend         string   `json:"FIREWALL_BACKEND"`
 }
 
 func (c *Config) String() string {
@@ -56,9 +57,13 @@ func (c *Config) Print() {
 	fmt.Printf("DNS_SERVERS=%s,%s\n", c.DNSServersV4, c.DNSServersV6)
 	fmt.Printf("OUTBOUND_OWNER_GROUPS_INCLUDE=%s\n", c.OwnerGroupsInclude)
 	fmt.Printf("OUTBOUND_OWNER_GROUPS_EXCLUDE=%s\n", c.OwnerGroupsExclude)
+	fmt.Printf("FIREWALL_BACKEND=%s\n", c.FirewallBackend)
 	fmt.Println("")
 }
 
 func (c *Config) Validate() error {
+	if err := types.ValidateFirewallBackend(c.FirewallBackend); err != nil {
+		return err
+	}
 	return types.ValidateOwnerGroups(c.OwnerGroupsInclude, c.OwnerGroupsExclude)
 }
@@ -0,0 +1,394 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// maxJumpDepth bounds the nesting of the chains walked by Explain, to report loops instead of recursing forever.
+const maxJumpDepth = 16
+
+// Packet is the first packet of a connection, walked through the rules by Explain.
+type Packet struct {
+	// Inbound packets are received by the pod and traverse PREROUTING, other packets are sent by the pod and
+	// traverse OUTPUT.
+	Inbound  bool
+	Protocol string
+	Src      netip.Addr
+	SrcPort  uint16
+	Dst      netip.Addr
+	DstPort  uint16
+	// InInterface is the interface an inbound packet is received on, OutInterface the interface an outbound
+	// packet is routed to.
+	InInterface  string
+	OutInterface string
+	// UID and GID own the socket sending an outbound packet. Empty values match no owner, as for inbound packets.
+	UID string
+	GID string
+}
+
+func (p Packet) String() string {
+	direction := "outbound"
+	if p.Inbound {
+		direction = "inbound"
+	}
+	s := fmt.Sprintf("%s %s packet to %s", direction, strings.ToUpper(p.Protocol), netip.AddrPortFrom(p.Dst, p.DstPort))
+	if p.UID != "" {
+		s += " from UID " + p.UID
+	}
+	if p.GID != "" {
+		s += " GID " + p.GID
+	}
+	return s
+}
+
+// Verdict is the outcome of the traffic capture rules for a packet.
+type Verdict string
+
+const (
+	// VerdictRedirected means the packet is redirected to the proxy, or to the agent for DNS.
+	VerdictRedirected Verdict = "redirected"
+	// VerdictNotCaptured means the packet bypasses the proxy.
+	VerdictNotCaptured Verdict = "not captured"
+	// VerdictDropped means the packet is dropped.
+	VerdictDropped Verdict = "dropped"
+)
+
+// Step is a rule matched by a packet.
+type Step struct {
+	Table string
+	Chain string
+	// Rule holds the parameters of the rule, as passed to iptables after the chain.
+	Rule []string
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("-t %s -A %s %s", s.Table, s.Chain, strings.Join(s.Rule, " "))
+}
+
+// Explanation describes how a packet traverses the rules.
+type Explanation struct {
+	Packet Packet
+	// Steps lists the rules matched by the packet, in order.
+	Steps   []Step
+	Verdict Verdict
+	// Port is the port the packet is redirected to.
+	Port string
+	// Decision is the rule that decided the verdict, nil if no rule did.
+	Decision *Step
+}
+
+// hook is a built-in chain of a table traversed by a packet.
+type hook struct {
+	table string
+	chain string
+}
+
+var (
+	inboundHooks = []hook{
+		{constants.RAW, constants.PREROUTING},
+		{constants.MANGLE, constants.PREROUTING},
+		{constants.NAT, constants.PREROUTING},
+		{constants.MANGLE, constants.INPUT},
+		{constants.FILTER, constants.INPUT},
+	}
+	outboundHooks = []hook{
+		{constants.RAW, constants.OUTPUT},
+		{constants.MANGLE, constants.OUTPUT},
+		{constants.NAT, constants.OUTPUT},
+		{constants.FILTER, constants.OUTPUT},
+	}
+)
+
+// Explain walks the packet through the built-in chains of each table and the chains they jump to, as the kernel
+// would for the first packet of a new connection, and reports whether it is redirected, not captured or dropped
+// and the rule deciding it. The IPv4 or IPv6 rules are walked depending on the destination of the packet.
+func (rb *IptablesBuilder) Explain(p Packet) (*Explanation, error) {
+	rules := rb.rules.rulesv4
+	if p.Dst.Is6() {
+		rules = rb.rules.rulesv6
+	}
+	_, chains, err := resolveChains(rules)
+	if err != nil {
+		return nil, err
+	}
+	w := &walker{
+		packet: p,
+		chains: map[string][][]string{},
+		exp:    &Explanation{Packet: p, Verdict: VerdictNotCaptured},
+	}
+	for table, tableChains := range chains {
+		for _, c := range tableChains {
+			w.chains[table+":"+c.name] = c.rules
+		}
+	}
+	hooks := outboundHooks
+	if p.Inbound {
+		hooks = inboundHooks
+	}
+	for _, h := range hooks {
+		done, err := w.walk(h.table, h.chain, 0)
+		if errors.Is(err, errAccepted) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	return w.exp, nil
+}
+
+type walker struct {
+	packet Packet
+	// mark is the packet mark, set by the MARK target.
+	mark   uint32
+	chains map[string][][]string
+	exp    *Explanation
+}
+
+// walk traverses a chain. It returns true when the packet reached a final verdict, false when it returned from
+// the chain or was accepted by the table.
+func (w *walker) walk(table, chain string, depth int) (bool, error) {
+	if depth > maxJumpDepth {
+		return false, fmt.Errorf("too many nested jumps from chain %s in table %s", chain, table)
+	}
+	for _, rule := range w.chains[table+":"+chain] {
+		target, matched, err := w.match(rule)
+		if err != nil {
+			return false, fmt.Errorf("rule %q: %v", strings.Join(rule, " "), err)
+		}
+		if !matched {
+			continue
+		}
+		step := Step{Table: table, Chain: chain, Rule: rule}
+		w.exp.Steps = append(w.exp.Steps, step)
+		if len(target) == 0 {
+			continue
+		}
+		switch target[0] {
+		case constants.RETURN:
+			w.decide(step, VerdictNotCaptured, "")
+			return false, nil
+		case constants.ACCEPT:
+			w.decide(step, VerdictNotCaptured, "")
+			// Accepting a packet ends the traversal of the table, not of the chain only.
+			return false, errAccepted
+		case constants.DROP, "REJECT":
+			w.decide(step, VerdictDropped, "")
+			return true, nil
+		case constants.REDIRECT:
+			w.decide(step, VerdictRedirected, option(target, "--to-ports", "--to-port"))
+			return true, nil
+		case constants.TPROXY:
+			w.decide(step, VerdictRedirected, option(target, "--on-port"))
+			return true, nil
+		case constants.MARK:
+			if err := w.setMark(option(target, "--set-mark", "--set-xmark")); err != nil {
+				return false, fmt.Errorf("rule %q: %v", strings.Join(rule, " "), err)
+			}
+		case "CONNMARK", constants.CT, "NFLOG", "LOG":
+			// Non terminating targets not affecting the verdict.
+		default:
+			if _, ok := w.chains[table+":"+target[0]]; !ok {
+				return false, fmt.Errorf("rule %q: unsupported target %s", strings.Join(rule, " "), target[0])
+			}
+			done, err := w.walk(table, target[0], depth+1)
+			if done || err != nil {
+				return done, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// errAccepted unwinds the chains of a table when a packet is accepted.
+var errAccepted = errors.New("accepted")
+
+func (w *walker) decide(step Step, verdict Verdict, port string) {
+	w.exp.Decision = &step
+	w.exp.Verdict = verdict
+	w.exp.Port = port
+}
+
+func (w *walker) setMark(value string) error {
+	v, mask, err := parseMark(value)
+	if err != nil {
+		return err
+	}
+	w.mark = w.mark&^mask | v
+	return nil
+}
+
+// match evaluates the matches of a rule. It returns the target and its options if the packet matches.
+func (w *walker) match(rule []string) ([]string, bool, error) {
+	p := w.packet
+	for i := 0; i < len(rule); i++ {
+		negate := false
+		if rule[i] == "!" {
+			negate = true
+			i++
+			if i >= len(rule) {
+				return nil, false, fmt.Errorf("missing option after !")
+			}
+		}
+		opt := rule[i]
+		if opt == "-j" || opt == "-g" {
+			if i+1 >= len(rule) {
+				return nil, false, fmt.Errorf("missing target")
+			}
+			return rule[i+1:], true, nil
+		}
+		if opt == "--suppl-groups" {
+			continue
+		}
+		if i+1 >= len(rule) {
+			return nil, false, fmt.Errorf("missing value of %s", opt)
+		}
+		i++
+		value := rule[i]
+		var matched bool
+		var err error
+		switch opt {
+		case "-m":
+			switch value {
+			case "owner", "multiport", "tcp", "udp", "mark", "connmark", "conntrack", "comment":
+			default:
+				return nil, false, fmt.Errorf("unsupported match module %s", value)
+			}
+			continue
+		case "--comment":
+			continue
+		case "-p":
+			matched = value == "all" || strings.EqualFold(value, p.Protocol)
+		case "-s":
+			matched, err = addrMatches(value, p.Src)
+		case "-d":
+			matched, err = addrMatches(value, p.Dst)
+		case "-i":
+			matched = interfaceMatches(value, p.InInterface)
+		case "-o":
+			matched = interfaceMatches(value, p.OutInterface)
+		case "--dport", "--dports", "--destination-port", "--destination-ports":
+			matched, err = portMatches(value, p.DstPort)
+		case "--sport", "--sports", "--source-port", "--source-ports":
+			matched, err = portMatches(value, p.SrcPort)
+		case "--uid-owner":
+			matched = p.UID != "" && p.UID == value
+		case "--gid-owner":
+			matched = p.GID != "" && p.GID == value
+		case "--mark":
+			var v, mask uint32
+			v, mask, err = parseMark(value)
+			matched = w.mark&mask == v
+		case "--ctstate", "--state":
+			// The first packet of a connection is always new.
+			matched = false
+			for _, s := range strings.Split(value, ",") {
+				matched = matched || s == "NEW"
+			}
+		default:
+			return nil, false, fmt.Errorf("unsupported option %s", opt)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if matched == negate {
+			return nil, false, nil
+		}
+	}
+	return nil, true, nil
+}
+
+// option returns the value of the first of the given options of a target.
+func option(target []string, names ...string) string {
+	for i := 1; i+1 < len(target); i++ {
+		for _, n := range names {
+			if target[i] == n {
+				return target[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+func addrMatches(value string, addr netip.Addr) (bool, error) {
+	if !addr.IsValid() {
+		return false, nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		ip, ipErr := netip.ParseAddr(value)
+		if ipErr != nil {
+			return false, fmt.Errorf("invalid address %q: %v", value, err)
+		}
+		prefix = netip.PrefixFrom(ip, ip.BitLen())
+	}
+	return prefix.Contains(addr), nil
+}
+
+// interfaceMatches matches an interface name, a trailing + matching any suffix.
+func interfaceMatches(value, iface string) bool {
+	if strings.HasSuffix(value, "+") {
+		return strings.HasPrefix(iface, strings.TrimSuffix(value, "+"))
+	}
+	return value == iface
+}
+
+// portMatches matches a list of ports and port ranges.
+func portMatches(value string, port uint16) (bool, error) {
+	for _, r := range strings.Split(value, ",") {
+		lo, hi, isRange := strings.Cut(r, ":")
+		min, err := strconv.ParseUint(lo, 10, 16)
+		if err != nil {
+			return false, fmt.Errorf("invalid port %q", r)
+		}
+		max := min
+		if isRange {
+			if max, err = strconv.ParseUint(hi, 10, 16); err != nil {
+				return false, fmt.Errorf("invalid port range %q", r)
+			}
+		}
+		if uint64(port) >= min && uint64(port) <= max {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseMark parses a mark and its optional mask.
+func parseMark(value string) (uint32, uint32, error) {
+	v, m, hasMask := strings.Cut(value, "/")
+	mark, err := strconv.ParseUint(v, 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mark %q", value)
+	}
+	mask := uint64(0xffffffff)
+	if hasMask {
+		if mask, err = strconv.ParseUint(m, 0, 32); err != nil {
+			return 0, 0, fmt.Errorf("invalid mark mask %q", value)
+		}
+	}
+	return uint32(mark), uint32(mask), nil
+}
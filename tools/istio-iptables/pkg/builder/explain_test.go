@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"net/netip"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	iptableslog "istio.io/istio/tools/istio-iptables/pkg/log"
+)
+
+func TestExplainMatches(t *testing.T) {
+	iptables := NewIptablesBuilder(nil)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.MANGLE,
+		"-p", "tcp", "-m", "multiport", "--dports", "8000:8999,9443", "-j", constants.MARK, "--set-mark", "0x10/0xff")
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.NAT, "-p", "tcp", "-j", constants.ISTIOOUTPUT)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT,
+		"-m", "mark", "--mark", "16", "-j", constants.RETURN)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT,
+		"!", "-o", "eth+", "-j", constants.ACCEPT)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT,
+		"-d", "10.0.0.1", "-j", constants.DROP)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT,
+		"-j", constants.REDIRECT, "--to-ports", "15001")
+
+	cases := []struct {
+		name     string
+		port     uint16
+		dst      string
+		iface    string
+		verdict  Verdict
+		decision string
+	}{
+		{"port range marked", 8080, "10.0.0.5", "eth0", VerdictNotCaptured, "-t nat -A ISTIO_OUTPUT -m mark --mark 16 -j RETURN"},
+		{"port list marked", 9443, "10.0.0.5", "eth0", VerdictNotCaptured, "-t nat -A ISTIO_OUTPUT -m mark --mark 16 -j RETURN"},
+		{"negated interface", 80, "10.0.0.5", "net1", VerdictNotCaptured, "-t nat -A ISTIO_OUTPUT ! -o eth+ -j ACCEPT"},
+		{"dropped", 80, "10.0.0.1", "eth0", VerdictDropped, "-t nat -A ISTIO_OUTPUT -d 10.0.0.1 -j DROP"},
+		{"redirected", 80, "10.0.0.5", "eth0", VerdictRedirected, "-t nat -A ISTIO_OUTPUT -j REDIRECT --to-ports 15001"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exp, err := iptables.Explain(Packet{Protocol: "tcp", Dst: netip.MustParseAddr(tc.dst), DstPort: tc.port, OutInterface: tc.iface})
+			assert.NoError(t, err)
+			assert.Equal(t, exp.Verdict, tc.verdict)
+			assert.Equal(t, exp.Decision.String(), tc.decision)
+		})
+	}
+}
+
+func TestExplainUnsupported(t *testing.T) {
+	iptables := NewIptablesBuilder(nil)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.NAT, "-m", "statistic", "--probability", "0.5", "-j", constants.RETURN)
+	_, err := iptables.Explain(Packet{Protocol: "tcp", Dst: netip.MustParseAddr("10.0.0.5"), DstPort: 80})
+	assert.Error(t, err)
+
+	iptables = NewIptablesBuilder(nil)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.NAT, "-j", "ISTIO_MISSING")
+	_, err = iptables.Explain(Packet{Protocol: "tcp", Dst: netip.MustParseAddr("10.0.0.5"), DstPort: 80})
+	assert.Error(t, err)
+}
@@ -16,6 +16,7 @@ package builder
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"istio.io/istio/pkg/util/sets"
@@ -147,6 +148,56 @@ func (rb *IptablesBuilder) AppendRuleV6(command log.Command, chain string, table
 	return rb.appendInternal(&rb.rules.rulesv6, command, chain, table, params...)
 }
 
+// chainRules holds the rules of a chain in their final order, along with the commands producing them.
+type chainRules struct {
+	table string
+	name  string
+	rules [][]string
+	ops   [][]string
+}
+
+// resolveChains resolves the final order of the rules in each chain, as iptables would after running
+// the append and insert commands in order. It returns the tables and the chains of each table in the
+// order they are first used.
+func resolveChains(rules []*Rule) ([]string, map[string][]*chainRules, error) {
+	var tables []string
+	chains := map[string][]*chainRules{}
+	lookup := map[string]*chainRules{}
+	for _, r := range rules {
+		key := r.table + ":" + r.chain
+		c, ok := lookup[key]
+		if !ok {
+			if _, ok := chains[r.table]; !ok {
+				tables = append(tables, r.table)
+			}
+			c = &chainRules{table: r.table, name: r.chain}
+			lookup[key] = c
+			chains[r.table] = append(chains[r.table], c)
+		}
+		c.ops = append(c.ops, r.params)
+		switch r.params[0] {
+		case "-A":
+			c.rules = append(c.rules, r.params[2:])
+		case "-I":
+			pos, err := strconv.Atoi(r.params[2])
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid rule position %q: %v", r.params[2], err)
+			}
+			pos--
+			if pos < 0 {
+				pos = 0
+			}
+			if pos > len(c.rules) {
+				pos = len(c.rules)
+			}
+			c.rules = append(c.rules[:pos], append([][]string{r.params[3:]}, c.rules[pos:]...)...)
+		default:
+			return nil, nil, fmt.Errorf("unsupported rule operation %q", r.params[0])
+		}
+	}
+	return tables, chains, nil
+}
+
 func (rb *IptablesBuilder) buildRules(command string, rules []*Rule) [][]string {
 	output := make([][]string, 0)
 	chainTableLookupSet := sets.New[string]()
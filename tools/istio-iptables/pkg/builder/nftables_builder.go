@@ -0,0 +1,303 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// nftBaseChain describes how a built-in iptables chain of a table is hooked into netfilter.
+type nftBaseChain struct {
+	chainType string
+	hook      string
+	priority  int
+}
+
+// nftBaseChains maps a table and built-in chain to the equivalent nftables base chain.
+// The priorities match the ones of the iptables tables, so rules are evaluated in the same order.
+var nftBaseChains = map[string]nftBaseChain{
+	constants.NAT + ":" + constants.PREROUTING:    {"nat", "prerouting", -100},
+	constants.NAT + ":" + constants.OUTPUT:        {"nat", "output", -100},
+	constants.NAT + ":" + constants.INPUT:         {"nat", "input", 100},
+	constants.NAT + ":" + constants.POSTROUTING:   {"nat", "postrouting", 100},
+	constants.MANGLE + ":" + constants.PREROUTING: {"filter", "prerouting", -150},
+	constants.MANGLE + ":" + constants.INPUT:      {"filter", "input", -150},
+	constants.MANGLE + ":" + constants.FORWARD:    {"filter", "forward", -150},
+	// A route chain reroutes packets whose mark changed, like the iptables mangle OUTPUT chain.
+	constants.MANGLE + ":" + constants.OUTPUT:      {"route", "output", -150},
+	constants.MANGLE + ":" + constants.POSTROUTING: {"filter", "postrouting", -150},
+	constants.RAW + ":" + constants.PREROUTING:     {"filter", "prerouting", -300},
+	constants.RAW + ":" + constants.OUTPUT:         {"filter", "output", -300},
+	constants.FILTER + ":" + constants.INPUT:       {"filter", "input", 0},
+	constants.FILTER + ":" + constants.FORWARD:     {"filter", "forward", 0},
+	constants.FILTER + ":" + constants.OUTPUT:      {"filter", "output", 0},
+}
+
+// NftTableName returns the name of the nftables table holding the rules of an iptables table.
+// Each iptables table gets its own nftables table, so that chains with the same name in different
+// iptables tables do not collide, and so that all Istio rules can be removed by deleting the tables.
+func NftTableName(table string) string {
+	return constants.NftTablePrefix + table
+}
+
+// NftTables lists the iptables tables that may be programmed by the nftables backend.
+var NftTables = []string{constants.NAT, constants.MANGLE, constants.RAW, constants.FILTER}
+
+// BuildV4Nft returns an nft script programming the IPv4 rules, for use with `nft -f`.
+func (rb *IptablesBuilder) BuildV4Nft() (string, error) {
+	return buildNft(constants.NftFamilyIPv4, rb.rules.rulesv4)
+}
+
+// BuildV6Nft returns an nft script programming the IPv6 rules, for use with `nft -f`.
+func (rb *IptablesBuilder) BuildV6Nft() (string, error) {
+	return buildNft(constants.NftFamilyIPv6, rb.rules.rulesv6)
+}
+
+// buildNft translates the iptables rules into an nft script. The script replaces any tables from a
+// previous run atomically: the tables are created, deleted and recreated within a single transaction.
+func buildNft(family string, rules []*Rule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	tables, chains, err := resolveChains(rules)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, table := range tables {
+		name := NftTableName(table)
+		fmt.Fprintf(&b, "table %s %s\n", family, name)
+		fmt.Fprintf(&b, "delete table %s %s\n", family, name)
+		fmt.Fprintf(&b, "table %s %s {\n", family, name)
+		for _, c := range chains[table] {
+			fmt.Fprintf(&b, "\tchain %s {\n", c.name)
+			if _, builtin := constants.BuiltInChainsMap[c.name]; builtin {
+				base, ok := nftBaseChains[table+":"+c.name]
+				if !ok {
+					return "", fmt.Errorf("chain %s is not supported in table %s", c.name, table)
+				}
+				fmt.Fprintf(&b, "\t\ttype %s hook %s priority %d; policy accept;\n", base.chainType, base.hook, base.priority)
+			}
+			fmt.Fprintf(&b, "\t}\n")
+		}
+		fmt.Fprintf(&b, "}\n")
+		for _, c := range chains[table] {
+			for _, params := range c.rules {
+				rule, err := translateNftRule(family, params)
+				if err != nil {
+					return "", fmt.Errorf("failed to translate rule %q in chain %s of table %s: %v",
+						strings.Join(params, " "), c.name, table, err)
+				}
+				fmt.Fprintf(&b, "add rule %s %s %s %s\n", family, name, c.name, rule)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// translateNftRule translates the matches and target of an iptables rule into an nftables rule.
+func translateNftRule(family string, params []string) (string, error) {
+	addr := "ip"
+	if family == constants.NftFamilyIPv6 {
+		addr = "ip6"
+	}
+	var out []string
+	negate := false
+	proto := ""
+	module := ""
+	op := func() string {
+		if negate {
+			return "!= "
+		}
+		return ""
+	}
+	next := func(i int) (string, error) {
+		if i+1 >= len(params) {
+			return "", fmt.Errorf("missing value for %s", params[i])
+		}
+		return params[i+1], nil
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if p == "!" {
+			negate = true
+			continue
+		}
+		if p == "-j" {
+			if negate {
+				return "", fmt.Errorf("cannot negate a target")
+			}
+			target, err := translateNftTarget(params[i+1:])
+			if err != nil {
+				return "", err
+			}
+			out = append(out, target...)
+			return strings.Join(out, " "), nil
+		}
+		v, err := next(i)
+		if err != nil {
+			return "", err
+		}
+		i++
+		switch p {
+		case "-p":
+			proto = v
+			out = append(out, "meta l4proto "+op()+v)
+		case "-s":
+			out = append(out, addr+" saddr "+op()+v)
+		case "-d":
+			out = append(out, addr+" daddr "+op()+v)
+		case "-i":
+			out = append(out, "iifname "+op()+strconv.Quote(v))
+		case "-o":
+			out = append(out, "oifname "+op()+strconv.Quote(v))
+		case "-m":
+			module = v
+			if negate {
+				return "", fmt.Errorf("cannot negate module %s", v)
+			}
+		case "--dport", "--dports", "--sport", "--sports":
+			if proto == "" {
+				return "", fmt.Errorf("%s requires a protocol", p)
+			}
+			field := "dport"
+			if strings.HasPrefix(p, "--sport") {
+				field = "sport"
+			}
+			out = append(out, proto+" "+field+" "+op()+nftSet(v))
+		case "--uid-owner":
+			out = append(out, "meta skuid "+op()+v)
+		case "--gid-owner":
+			out = append(out, "meta skgid "+op()+v)
+		case "--ctstate":
+			out = append(out, "ct state "+op()+strings.ToLower(v))
+		case "--mark":
+			switch module {
+			case "mark":
+				out = append(out, "meta mark "+op()+v)
+			case "connmark":
+				out = append(out, "ct mark "+op()+v)
+			default:
+				return "", fmt.Errorf("--mark requires the mark or connmark module")
+			}
+		default:
+			return "", fmt.Errorf("unsupported option %s", p)
+		}
+		negate = false
+	}
+	return "", fmt.Errorf("rule has no target")
+}
+
+// translateNftTarget translates an iptables target and its options into nftables statements.
+func translateNftTarget(params []string) ([]string, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing target")
+	}
+	target := params[0]
+	opts := map[string]string{}
+	for i := 1; i < len(params); i++ {
+		switch params[i] {
+		case "--save-mark", "--restore-mark":
+			opts[params[i]] = ""
+		default:
+			if i+1 >= len(params) {
+				return nil, fmt.Errorf("missing value for %s", params[i])
+			}
+			opts[params[i]] = params[i+1]
+			i++
+		}
+	}
+	switch target {
+	case constants.RETURN:
+		return []string{"return"}, nil
+	case constants.ACCEPT:
+		return []string{"accept"}, nil
+	case constants.DROP:
+		return []string{"drop"}, nil
+	case constants.REDIRECT:
+		port, ok := opts["--to-ports"]
+		if !ok {
+			port, ok = opts["--to-port"]
+		}
+		if !ok {
+			return nil, fmt.Errorf("REDIRECT requires --to-ports")
+		}
+		return []string{"redirect to :" + port}, nil
+	case constants.MARK:
+		mark, ok := opts["--set-mark"]
+		if !ok {
+			return nil, fmt.Errorf("MARK requires --set-mark")
+		}
+		return []string{"meta mark set " + mark}, nil
+	case "CONNMARK":
+		if _, ok := opts["--save-mark"]; ok {
+			return []string{"ct mark set meta mark"}, nil
+		}
+		if _, ok := opts["--restore-mark"]; ok {
+			return []string{"meta mark set ct mark"}, nil
+		}
+		return nil, fmt.Errorf("CONNMARK requires --save-mark or --restore-mark")
+	case constants.TPROXY:
+		port, ok := opts["--on-port"]
+		if !ok {
+			return nil, fmt.Errorf("TPROXY requires --on-port")
+		}
+		var res []string
+		if mark, ok := opts["--tproxy-mark"]; ok {
+			mark, mask, hasMask := strings.Cut(mark, "/")
+			if hasMask && mask != "0xffffffff" {
+				return nil, fmt.Errorf("TPROXY mark mask %s is not supported", mask)
+			}
+			res = append(res, "meta mark set "+mark)
+		}
+		return append(res, "tproxy to :"+port), nil
+	case constants.CT:
+		zone, ok := opts["--zone"]
+		if !ok {
+			return nil, fmt.Errorf("CT requires --zone")
+		}
+		return []string{"ct zone set " + zone}, nil
+	case "NFLOG":
+		res := []string{"log"}
+		if prefix, ok := opts["--nflog-prefix"]; ok {
+			res = append(res, "prefix "+prefix)
+		}
+		if group, ok := opts["--nflog-group"]; ok {
+			res = append(res, "group "+group)
+		}
+		if size, ok := opts["--nflog-size"]; ok {
+			res = append(res, "snaplen "+size)
+		}
+		return res, nil
+	}
+	if len(opts) > 0 {
+		return nil, fmt.Errorf("unsupported target %s", target)
+	}
+	// Any other target is a user defined chain.
+	return []string{"jump " + target}, nil
+}
+
+// nftSet converts a comma separated iptables port list into an nftables anonymous set.
+func nftSet(v string) string {
+	if !strings.Contains(v, ",") {
+		return v
+	}
+	return "{ " + strings.Join(strings.Split(v, ","), ", ") + " }"
+}
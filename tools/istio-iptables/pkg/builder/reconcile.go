@@ -0,0 +1,403 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// istioChainPrefix is the prefix of the chains owned by Istio. Such chains that are not desired anymore are
+// removed on reconcile, along with the rules jumping to them.
+const istioChainPrefix = "ISTIO_"
+
+// Drift describes how the programmed rules of a chain differ from the desired rules.
+type Drift struct {
+	Table string
+	Chain string
+	// Missing lists the desired rules that are not programmed.
+	Missing []string
+	// Stale lists the programmed rules that are not desired.
+	Stale []string
+	// StaleChain is set when the whole chain is not desired.
+	StaleChain bool
+}
+
+func (d Drift) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "chain %s in table %s", d.Chain, d.Table)
+	switch {
+	case d.StaleChain:
+		b.WriteString(" is stale")
+	case len(d.Missing) == 0 && len(d.Stale) == 0:
+		b.WriteString(" has its rules out of order")
+	}
+	for _, r := range d.Missing {
+		fmt.Fprintf(&b, "\n  missing: %s", r)
+	}
+	for _, r := range d.Stale {
+		fmt.Fprintf(&b, "\n  stale: %s", r)
+	}
+	return b.String()
+}
+
+// Reconciliation holds the drift between the programmed and the desired rules, and the commands fixing it.
+type Reconciliation struct {
+	Drift []Drift
+	// Commands fixes the drift with iptables commands.
+	Commands [][]string
+	// Restore fixes the drift with iptables-restore input, to be applied with --noflush.
+	Restore string
+}
+
+// ReconcileV4 compares the IPv4 rules to the given iptables-save output.
+func (rb *IptablesBuilder) ReconcileV4(current string) (*Reconciliation, error) {
+	return reconcile(constants.IPTABLES, rb.rules.rulesv4, current)
+}
+
+// ReconcileV6 compares the IPv6 rules to the given ip6tables-save output.
+func (rb *IptablesBuilder) ReconcileV6(current string) (*Reconciliation, error) {
+	return reconcile(constants.IP6TABLES, rb.rules.rulesv6, current)
+}
+
+// savedTable holds the chains of a table in iptables-save output, in order.
+type savedTable struct {
+	chains []string
+	rules  map[string][][]string
+}
+
+// parseIptablesSave parses the output of iptables-save into the rules of each chain of each table.
+func parseIptablesSave(data string) map[string]*savedTable {
+	tables := map[string]*savedTable{}
+	var table *savedTable
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || line == "COMMIT":
+		case strings.HasPrefix(line, "*"):
+			table = &savedTable{rules: map[string][][]string{}}
+			tables[strings.TrimSpace(line[1:])] = table
+		case table == nil:
+		case strings.HasPrefix(line, ":"):
+			chain, _, _ := strings.Cut(line[1:], " ")
+			table.chains = append(table.chains, chain)
+			table.rules[chain] = nil
+		case strings.HasPrefix(line, "-A "):
+			params := splitRule(line)
+			if len(params) < 2 {
+				continue
+			}
+			table.rules[params[1]] = append(table.rules[params[1]], params[2:])
+		}
+	}
+	return tables
+}
+
+// splitRule splits a rule into its parameters. Quoted parameters, such as log prefixes, are kept quoted.
+func splitRule(line string) []string {
+	var params []string
+	var cur strings.Builder
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(line):
+			cur.WriteByte(c)
+			i++
+			cur.WriteByte(line[i])
+		case c == '"':
+			quoted = !quoted
+			cur.WriteByte(c)
+		case c == ' ' && !quoted:
+			if cur.Len() > 0 {
+				params = append(params, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if cur.Len() > 0 {
+		params = append(params, cur.String())
+	}
+	return params
+}
+
+// implicitModules are the match modules iptables-save adds for protocol options, e.g. `-m tcp` for `--dport`.
+var implicitModules = map[string]bool{constants.TCP: true, constants.UDP: true}
+
+// canonicalRule returns a representation of the rule that is independent of the way iptables-save prints it:
+// matches are sorted, implicit match modules are dropped, and numeric values, masks and defaults are normalized.
+func canonicalRule(params []string) string {
+	var matches, target []string
+	module := ""
+	for i := 0; i < len(params); i++ {
+		negate := ""
+		if params[i] == "!" && i+1 < len(params) {
+			negate = "! "
+			i++
+		}
+		opt := params[i]
+		var values []string
+		for i+1 < len(params) && !strings.HasPrefix(params[i+1], "-") && params[i+1] != "!" {
+			i++
+			values = append(values, normalizeValue(opt, params[i]))
+		}
+		switch {
+		case opt == "-m" || opt == "--match":
+			if len(values) > 0 {
+				module = values[0]
+			}
+			if implicitModules[module] {
+				module = ""
+			}
+		case opt == "-j" || opt == "--jump":
+			target = append(target, "-j "+strings.Join(values, " "))
+			target = append(target, canonicalTargetOptions(strings.Join(values, " "), params[i+1:])...)
+			i = len(params)
+		default:
+			prefix := ""
+			if module != "" && strings.HasPrefix(opt, "--") {
+				prefix = module + ":"
+			}
+			matches = append(matches, strings.TrimSpace(negate+prefix+opt+" "+strings.Join(values, " ")))
+		}
+	}
+	sort.Strings(matches)
+	return strings.Join(append(matches, target...), " ")
+}
+
+// canonicalTargetOptions normalizes the options of a target, dropping the ones iptables-save prints with their
+// default value.
+func canonicalTargetOptions(target string, params []string) []string {
+	var opts []string
+	for i := 0; i < len(params); i++ {
+		opt := params[i]
+		var values []string
+		for i+1 < len(params) && !strings.HasPrefix(params[i+1], "-") {
+			i++
+			values = append(values, normalizeValue(opt, params[i]))
+		}
+		value := strings.Join(values, " ")
+		switch {
+		case target == constants.MARK && opt == "--set-xmark":
+			opt = "--set-mark"
+		case target == "CONNMARK" && (opt == "--nfmask" || opt == "--ctmask") && value == normalizeValue("", "0xffffffff"):
+			continue
+		case target == constants.TPROXY && opt == "--on-ip" && (value == "0.0.0.0" || value == "::"):
+			continue
+		case opt == "--to-port":
+			opt = "--to-ports"
+		}
+		opts = append(opts, strings.TrimSpace(opt+" "+value))
+	}
+	sort.Strings(opts)
+	return opts
+}
+
+// normalizeValue normalizes numeric values to decimal, drops full masks and adds the implicit prefix length
+// of single addresses.
+func normalizeValue(opt string, v string) string {
+	if value, mask, ok := strings.Cut(v, "/"); ok && (opt != "-s" && opt != "-d") {
+		if m, err := strconv.ParseUint(mask, 0, 32); err == nil {
+			if m == 0xffffffff {
+				return normalizeValue(opt, value)
+			}
+			return normalizeValue(opt, value) + "/" + strconv.FormatUint(m, 10)
+		}
+	}
+	switch opt {
+	case "-s", "-d", "--source", "--destination":
+		if !strings.Contains(v, "/") {
+			if strings.Contains(v, ":") {
+				return v + "/128"
+			}
+			return v + "/32"
+		}
+		return v
+	case "--ctstate", "--state":
+		states := strings.Split(strings.ToUpper(v), ",")
+		sort.Strings(states)
+		return strings.Join(states, ",")
+	}
+	if n, err := strconv.ParseUint(v, 0, 64); err == nil {
+		return strconv.FormatUint(n, 10)
+	}
+	return v
+}
+
+// ruleTarget returns the target of a rule.
+func ruleTarget(params []string) string {
+	if idx := indexOf("-j", params); idx >= 0 && idx+1 < len(params) {
+		return params[idx+1]
+	}
+	return ""
+}
+
+// reconcile computes the drift between the desired rules and the programmed rules in the iptables-save output,
+// along with the commands replacing the drifted rules. Only the rules Istio owns are considered: all the rules of
+// the desired chains that are not built-in, and in built-in chains the desired rules and the rules jumping to Istio
+// chains. Rules added by others to built-in chains are left untouched.
+func reconcile(command string, rules []*Rule, current string) (*Reconciliation, error) {
+	tables, chains, err := resolveChains(rules)
+	if err != nil {
+		return nil, err
+	}
+	saved := parseIptablesSave(current)
+	for _, table := range []string{constants.NAT, constants.MANGLE, constants.RAW, constants.FILTER} {
+		if _, ok := chains[table]; !ok && saved[table] != nil {
+			tables = append(tables, table)
+		}
+	}
+
+	res := &Reconciliation{}
+	var restore strings.Builder
+	for _, table := range tables {
+		desired := map[string]bool{}
+		for _, c := range chains[table] {
+			desired[c.name] = true
+		}
+		st := saved[table]
+		if st == nil {
+			st = &savedTable{rules: map[string][][]string{}}
+		}
+		owned := func(params []string) bool {
+			target := ruleTarget(params)
+			return desired[target] || strings.HasPrefix(target, istioChainPrefix)
+		}
+
+		// Commands are grouped in phases, so that chains exist before rules jump to them, and stale chains are
+		// not referenced anymore when they are deleted.
+		var create, flush, remove, add, del [][]string
+		for _, c := range chains[table] {
+			_, builtin := constants.BuiltInChainsMap[c.name]
+			programmed, exists := st.rules[c.name]
+			var want []string
+			wantSet := map[string]int{}
+			for _, r := range c.rules {
+				canonical := canonicalRule(r)
+				want = append(want, canonical)
+				wantSet[canonical]++
+			}
+			var have []string
+			var haveRules [][]string
+			for _, r := range programmed {
+				canonical := canonicalRule(r)
+				if builtin && wantSet[canonical] == 0 && !owned(r) {
+					continue
+				}
+				have = append(have, canonical)
+				haveRules = append(haveRules, r)
+			}
+			if exists && equal(have, want) {
+				continue
+			}
+
+			drift := Drift{Table: table, Chain: c.name}
+			remaining := map[string]int{}
+			for k, v := range wantSet {
+				remaining[k] = v
+			}
+			for i, h := range have {
+				if remaining[h] > 0 {
+					remaining[h]--
+				} else {
+					drift.Stale = append(drift.Stale, strings.Join(haveRules[i], " "))
+				}
+			}
+			haveSet := map[string]int{}
+			for _, h := range have {
+				haveSet[h]++
+			}
+			for i, w := range want {
+				if haveSet[w] > 0 {
+					haveSet[w]--
+				} else {
+					drift.Missing = append(drift.Missing, strings.Join(c.rules[i], " "))
+				}
+			}
+			res.Drift = append(res.Drift, drift)
+
+			switch {
+			case builtin:
+				for _, r := range haveRules {
+					remove = append(remove, append([]string{"-D", c.name}, r...))
+				}
+			case exists:
+				flush = append(flush, []string{"-F", c.name})
+			default:
+				create = append(create, []string{"-N", c.name})
+			}
+			add = append(add, c.ops...)
+		}
+		for _, chain := range st.chains {
+			if desired[chain] || !strings.HasPrefix(chain, istioChainPrefix) {
+				continue
+			}
+			drift := Drift{Table: table, Chain: chain, StaleChain: true}
+			for _, r := range st.rules[chain] {
+				drift.Stale = append(drift.Stale, strings.Join(r, " "))
+			}
+			res.Drift = append(res.Drift, drift)
+			flush = append(flush, []string{"-F", chain})
+			del = append(del, []string{"-X", chain})
+		}
+		// Rules of built-in chains that are not desired, and jump to stale chains.
+		for _, chain := range st.chains {
+			if _, builtin := constants.BuiltInChainsMap[chain]; !builtin || desired[chain] {
+				continue
+			}
+			var stale []string
+			for _, r := range st.rules[chain] {
+				if owned(r) {
+					stale = append(stale, strings.Join(r, " "))
+					remove = append(remove, append([]string{"-D", chain}, r...))
+				}
+			}
+			if len(stale) > 0 {
+				res.Drift = append(res.Drift, Drift{Table: table, Chain: chain, Stale: stale})
+			}
+		}
+
+		ops := append(append(append(append(create, flush...), remove...), add...), del...)
+		if len(ops) == 0 {
+			continue
+		}
+		fmt.Fprintf(&restore, "* %s\n", table)
+		for _, op := range ops {
+			res.Commands = append(res.Commands, append([]string{command, "-t", table}, op...))
+			fmt.Fprintln(&restore, strings.Join(op, " "))
+		}
+		fmt.Fprintln(&restore, "COMMIT")
+	}
+	res.Restore = restore.String()
+	return res, nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
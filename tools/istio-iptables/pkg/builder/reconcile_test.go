@@ -0,0 +1,234 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	iptableslog "istio.io/istio/tools/istio-iptables/pkg/log"
+)
+
+func reconcileTestBuilder() *IptablesBuilder {
+	iptables := NewIptablesBuilder(nil)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.PREROUTING, constants.NAT, "-p", "tcp", "-j", constants.ISTIOINBOUND)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOINBOUND, constants.NAT,
+		"-p", "tcp", "--dport", "15008", "-j", constants.RETURN)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOINBOUND, constants.NAT,
+		"-p", "tcp", "-m", "owner", "!", "--uid-owner", "1337", "-j", constants.RETURN)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.MANGLE,
+		"-p", "tcp", "-m", "connmark", "--mark", "1337", "-j", "CONNMARK", "--restore-mark")
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOTPROXY, constants.MANGLE,
+		"!", "-d", "127.0.0.1/32", "-p", "tcp", "-j", constants.TPROXY, "--tproxy-mark", "1337/0xffffffff", "--on-port", "15006")
+	return iptables
+}
+
+// programmed is the iptables-save output after programming the rules of reconcileTestBuilder, and a foreign rule.
+const programmed = `# Generated by iptables-save v1.8.7 on Tue Oct 18 10:00:00 2026
+*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+:ISTIO_INBOUND - [0:0]
+-A PREROUTING -s 10.0.0.1/32 -j ACCEPT
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-A ISTIO_INBOUND -p tcp -m tcp --dport 15008 -j RETURN
+-A ISTIO_INBOUND -p tcp -m owner ! --uid-owner 1337 -j RETURN
+COMMIT
+*mangle
+:PREROUTING ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:ISTIO_TPROXY - [0:0]
+-A OUTPUT -p tcp -m connmark --mark 0x539 -j CONNMARK --restore-mark --nfmask 0xffffffff --ctmask 0xffffffff
+-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15006 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff
+COMMIT
+`
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name     string
+		current  string
+		drift    []Drift
+		commands [][]string
+		restore  string
+	}{
+		{
+			name:    "in sync",
+			current: programmed,
+		},
+		{
+			name:    "empty",
+			current: "",
+			drift: []Drift{
+				{Table: "nat", Chain: "PREROUTING", Missing: []string{"-p tcp -j ISTIO_INBOUND"}},
+				{Table: "nat", Chain: "ISTIO_INBOUND", Missing: []string{
+					"-p tcp --dport 15008 -j RETURN",
+					"-p tcp -m owner ! --uid-owner 1337 -j RETURN",
+				}},
+				{Table: "mangle", Chain: "OUTPUT", Missing: []string{"-p tcp -m connmark --mark 1337 -j CONNMARK --restore-mark"}},
+				{Table: "mangle", Chain: "ISTIO_TPROXY", Missing: []string{
+					"! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15006",
+				}},
+			},
+			commands: [][]string{
+				{"iptables", "-t", "nat", "-N", "ISTIO_INBOUND"},
+				{"iptables", "-t", "nat", "-A", "PREROUTING", "-p", "tcp", "-j", "ISTIO_INBOUND"},
+				{"iptables", "-t", "nat", "-A", "ISTIO_INBOUND", "-p", "tcp", "--dport", "15008", "-j", "RETURN"},
+				{"iptables", "-t", "nat", "-A", "ISTIO_INBOUND", "-p", "tcp", "-m", "owner", "!", "--uid-owner", "1337", "-j", "RETURN"},
+				{"iptables", "-t", "mangle", "-N", "ISTIO_TPROXY"},
+				{"iptables", "-t", "mangle", "-A", "OUTPUT", "-p", "tcp", "-m", "connmark", "--mark", "1337", "-j", "CONNMARK", "--restore-mark"},
+				{
+					"iptables", "-t", "mangle", "-A", "ISTIO_TPROXY", "!", "-d", "127.0.0.1/32", "-p", "tcp",
+					"-j", "TPROXY", "--tproxy-mark", "1337/0xffffffff", "--on-port", "15006",
+				},
+			},
+			restore: `* nat
+-N ISTIO_INBOUND
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-A ISTIO_INBOUND -p tcp --dport 15008 -j RETURN
+-A ISTIO_INBOUND -p tcp -m owner ! --uid-owner 1337 -j RETURN
+COMMIT
+* mangle
+-N ISTIO_TPROXY
+-A OUTPUT -p tcp -m connmark --mark 1337 -j CONNMARK --restore-mark
+-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15006
+COMMIT
+`,
+		},
+		{
+			name: "duplicated and stale rules",
+			current: `*nat
+:PREROUTING ACCEPT [0:0]
+:ISTIO_INBOUND - [0:0]
+:ISTIO_REDIRECT - [0:0]
+-A PREROUTING -s 10.0.0.1/32 -j ACCEPT
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-A PREROUTING -p udp -j ISTIO_REDIRECT
+-A ISTIO_INBOUND -p tcp -m tcp --dport 15008 -j RETURN
+-A ISTIO_INBOUND -p tcp -m owner ! --uid-owner 1337 -j RETURN
+-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001
+COMMIT
+*mangle
+:OUTPUT ACCEPT [0:0]
+:ISTIO_TPROXY - [0:0]
+-A OUTPUT -p tcp -m connmark --mark 0x539 -j CONNMARK --restore-mark --nfmask 0xffffffff --ctmask 0xffffffff
+-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15001 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff
+COMMIT
+`,
+			drift: []Drift{
+				{Table: "nat", Chain: "PREROUTING", Stale: []string{"-p tcp -j ISTIO_INBOUND", "-p udp -j ISTIO_REDIRECT"}},
+				{Table: "nat", Chain: "ISTIO_REDIRECT", Stale: []string{"-p tcp -j REDIRECT --to-ports 15001"}, StaleChain: true},
+				{
+					Table: "mangle", Chain: "ISTIO_TPROXY",
+					Missing: []string{"! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15006"},
+					Stale:   []string{"! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15001 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff"},
+				},
+			},
+			commands: [][]string{
+				{"iptables", "-t", "nat", "-F", "ISTIO_REDIRECT"},
+				{"iptables", "-t", "nat", "-D", "PREROUTING", "-p", "tcp", "-j", "ISTIO_INBOUND"},
+				{"iptables", "-t", "nat", "-D", "PREROUTING", "-p", "tcp", "-j", "ISTIO_INBOUND"},
+				{"iptables", "-t", "nat", "-D", "PREROUTING", "-p", "udp", "-j", "ISTIO_REDIRECT"},
+				{"iptables", "-t", "nat", "-A", "PREROUTING", "-p", "tcp", "-j", "ISTIO_INBOUND"},
+				{"iptables", "-t", "nat", "-X", "ISTIO_REDIRECT"},
+				{"iptables", "-t", "mangle", "-F", "ISTIO_TPROXY"},
+				{
+					"iptables", "-t", "mangle", "-A", "ISTIO_TPROXY", "!", "-d", "127.0.0.1/32", "-p", "tcp",
+					"-j", "TPROXY", "--tproxy-mark", "1337/0xffffffff", "--on-port", "15006",
+				},
+			},
+			restore: `* nat
+-F ISTIO_REDIRECT
+-D PREROUTING -p tcp -j ISTIO_INBOUND
+-D PREROUTING -p tcp -j ISTIO_INBOUND
+-D PREROUTING -p udp -j ISTIO_REDIRECT
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-X ISTIO_REDIRECT
+COMMIT
+* mangle
+-F ISTIO_TPROXY
+-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15006
+COMMIT
+`,
+		},
+		{
+			name: "out of order",
+			current: `*nat
+:PREROUTING ACCEPT [0:0]
+:ISTIO_INBOUND - [0:0]
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-A ISTIO_INBOUND -p tcp -m owner ! --uid-owner 1337 -j RETURN
+-A ISTIO_INBOUND -p tcp -m tcp --dport 15008 -j RETURN
+COMMIT
+*mangle
+:OUTPUT ACCEPT [0:0]
+:ISTIO_TPROXY - [0:0]
+-A OUTPUT -p tcp -m connmark --mark 0x539 -j CONNMARK --restore-mark --nfmask 0xffffffff --ctmask 0xffffffff
+-A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15006 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff
+COMMIT
+`,
+			drift: []Drift{{Table: "nat", Chain: "ISTIO_INBOUND"}},
+			commands: [][]string{
+				{"iptables", "-t", "nat", "-F", "ISTIO_INBOUND"},
+				{"iptables", "-t", "nat", "-A", "ISTIO_INBOUND", "-p", "tcp", "--dport", "15008", "-j", "RETURN"},
+				{"iptables", "-t", "nat", "-A", "ISTIO_INBOUND", "-p", "tcp", "-m", "owner", "!", "--uid-owner", "1337", "-j", "RETURN"},
+			},
+			restore: `* nat
+-F ISTIO_INBOUND
+-A ISTIO_INBOUND -p tcp --dport 15008 -j RETURN
+-A ISTIO_INBOUND -p tcp -m owner ! --uid-owner 1337 -j RETURN
+COMMIT
+`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := reconcileTestBuilder().ReconcileV4(tt.current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Drift, tt.drift) {
+				t.Errorf("unexpected drift:\n%#v\nwant\n%#v", res.Drift, tt.drift)
+			}
+			if !reflect.DeepEqual(res.Commands, tt.commands) {
+				t.Errorf("unexpected commands:\n%v\nwant\n%v", res.Commands, tt.commands)
+			}
+			if res.Restore != tt.restore {
+				t.Errorf("unexpected restore input:\n%s\nwant\n%s", res.Restore, tt.restore)
+			}
+		})
+	}
+}
+
+func TestCanonicalRule(t *testing.T) {
+	cases := []struct {
+		desired string
+		saved   string
+	}{
+		{"-p tcp --dport 15008 -j RETURN", "-p tcp -m tcp --dport 15008 -j RETURN"},
+		{"-o lo -s 127.0.0.6 -j RETURN", "-s 127.0.0.6/32 -o lo -j RETURN"},
+		{"-m conntrack --ctstate RELATED,ESTABLISHED -j ISTIO_DIVERT", "-m conntrack --ctstate ESTABLISHED,RELATED -j ISTIO_DIVERT"},
+		{"-j MARK --set-mark 1337", "-j MARK --set-xmark 0x539/0xffffffff"},
+		{"-p tcp -j REDIRECT --to-port 15001", "-p tcp -j REDIRECT --to-ports 15001"},
+	}
+	for _, tt := range cases {
+		if got, want := canonicalRule(splitRule(tt.desired)), canonicalRule(splitRule(tt.saved)); got != want {
+			t.Errorf("canonical rule of %q is %q, want %q from %q", tt.desired, got, want, tt.saved)
+		}
+	}
+}
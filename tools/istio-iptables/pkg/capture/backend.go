@@ -0,0 +1,58 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	"istio.io/pkg/log"
+)
+
+// mock exec.LookPath and filepath.EvalSymlinks to make the backend detection testable
+var (
+	lookPath     = exec.LookPath
+	evalSymlinks = filepath.EvalSymlinks
+)
+
+// ResolveFirewallBackend returns the backend used to program the rules. An empty backend defaults to
+// iptables. The auto backend selects nftables when the nft binary is available and iptables is either
+// missing, or is the iptables-nft shim whose rule sets conflict with native nftables tooling.
+func ResolveFirewallBackend(backend string) string {
+	switch backend {
+	case "":
+		return constants.IptablesBackend
+	case constants.AutoBackend:
+	default:
+		return backend
+	}
+	if _, err := lookPath(constants.NFT); err != nil {
+		log.Infof("nft binary not found, using the %s backend", constants.IptablesBackend)
+		return constants.IptablesBackend
+	}
+	iptables, err := lookPath(constants.IPTABLES)
+	if err != nil {
+		log.Infof("iptables binary not found, using the %s backend", constants.NftablesBackend)
+		return constants.NftablesBackend
+	}
+	if target, err := evalSymlinks(iptables); err == nil && strings.Contains(filepath.Base(target), "nft") {
+		log.Infof("iptables is the nftables shim %s, using the %s backend", target, constants.NftablesBackend)
+		return constants.NftablesBackend
+	}
+	log.Infof("using the %s backend", constants.IptablesBackend)
+	return constants.IptablesBackend
+}
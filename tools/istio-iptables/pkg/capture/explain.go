@@ -0,0 +1,36 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"fmt"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// Explain builds the traffic capture rules from the configuration, without programming them, and walks the packet
+// through them to explain whether it is redirected to the proxy, and by which rule.
+func Explain(cfg *config.Config, p builder.Packet) (exp *builder.Explanation, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("failed to build the traffic capture rules: %v", e)
+		}
+	}()
+	configurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+	configurator.buildRules()
+	return configurator.iptables.Explain(p)
+}
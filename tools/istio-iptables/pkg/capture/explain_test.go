@@ -0,0 +1,155 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"net/netip"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+)
+
+func TestExplain(t *testing.T) {
+	outbound := func(dst string, port uint16, uid string) builder.Packet {
+		p := builder.Packet{Protocol: "tcp", Dst: netip.MustParseAddr(dst), DstPort: port, OutInterface: "eth0", UID: uid, GID: uid}
+		if p.Dst.IsLoopback() {
+			p.OutInterface = "lo"
+		}
+		return p
+	}
+	inbound := func(port uint16) builder.Packet {
+		return builder.Packet{
+			Inbound: true, Protocol: "tcp", Src: netip.MustParseAddr("10.0.0.9"),
+			Dst: netip.MustParseAddr("10.0.0.2"), DstPort: port, InInterface: "eth0",
+		}
+	}
+	cases := []struct {
+		name     string
+		config   func(cfg *config.Config)
+		packet   builder.Packet
+		verdict  builder.Verdict
+		port     string
+		decision string
+	}{
+		{
+			name:     "outbound redirected",
+			packet:   outbound("10.0.0.5", 8080, "1000"),
+			verdict:  builder.VerdictRedirected,
+			port:     "15001",
+			decision: "-t nat -A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001",
+		},
+		{
+			name:     "outbound from the proxy",
+			packet:   outbound("10.0.0.5", 8080, "1337"),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN",
+		},
+		{
+			name:     "outbound to localhost",
+			packet:   outbound("127.0.0.1", 8080, "1000"),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_OUTPUT -o lo -m owner ! --uid-owner 1337 -j RETURN",
+		},
+		{
+			name:     "outbound excluded port",
+			config:   func(cfg *config.Config) { cfg.OutboundPortsExclude = "3306" },
+			packet:   outbound("10.0.0.5", 3306, "1000"),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_OUTPUT -p tcp --dport 3306 -j RETURN",
+		},
+		{
+			name:     "outbound excluded range",
+			config:   func(cfg *config.Config) { cfg.OutboundIPRangesExclude = "10.0.0.0/24" },
+			packet:   outbound("10.0.0.5", 8080, "1000"),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_OUTPUT -d 10.0.0.0/24 -j RETURN",
+		},
+		{
+			name:     "outbound outside included ranges",
+			config:   func(cfg *config.Config) { cfg.OutboundIPRangesInclude = "10.96.0.0/12" },
+			packet:   outbound("10.0.0.5", 8080, "1000"),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_OUTPUT -j RETURN",
+		},
+		{
+			name: "outbound dns",
+			config: func(cfg *config.Config) {
+				cfg.RedirectDNS = true
+				cfg.CaptureAllDNS = true
+			},
+			packet: builder.Packet{
+				Protocol: "udp", Dst: netip.MustParseAddr("10.96.0.10"), DstPort: 53, OutInterface: "eth0", UID: "1000", GID: "1000",
+			},
+			verdict:  builder.VerdictRedirected,
+			port:     "15053",
+			decision: "-t nat -A OUTPUT -p udp --dport 53 -j REDIRECT --to-port 15053",
+		},
+		{
+			name:     "inbound redirected",
+			packet:   inbound(8080),
+			verdict:  builder.VerdictRedirected,
+			port:     "15006",
+			decision: "-t nat -A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-ports 15006",
+		},
+		{
+			name:     "inbound excluded port",
+			packet:   inbound(15020),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_INBOUND -p tcp --dport 15020 -j RETURN",
+		},
+		{
+			name:     "inbound tunnel port",
+			packet:   inbound(15008),
+			verdict:  builder.VerdictNotCaptured,
+			decision: "-t nat -A ISTIO_INBOUND -p tcp --dport 15008 -j RETURN",
+		},
+		{
+			name:    "inbound not included",
+			config:  func(cfg *config.Config) { cfg.InboundPortsInclude = "9090" },
+			packet:  inbound(8080),
+			verdict: builder.VerdictNotCaptured,
+		},
+		{
+			name:     "inbound tproxy",
+			config:   func(cfg *config.Config) { cfg.InboundInterceptionMode = "TPROXY" },
+			packet:   inbound(8080),
+			verdict:  builder.VerdictRedirected,
+			port:     "15006",
+			decision: "-t mangle -A ISTIO_TPROXY ! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15006",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			cfg.InboundPortsInclude = "*"
+			cfg.InboundPortsExclude = "15020,15021,15090"
+			cfg.OutboundIPRangesInclude = "*"
+			if tc.config != nil {
+				tc.config(cfg)
+			}
+			exp, err := Explain(cfg, tc.packet)
+			assert.NoError(t, err)
+			assert.Equal(t, exp.Verdict, tc.verdict)
+			assert.Equal(t, exp.Port, tc.port)
+			decision := ""
+			if exp.Decision != nil {
+				decision = exp.Decision.String()
+			}
+			assert.Equal(t, decision, tc.decision)
+		})
+	}
+}
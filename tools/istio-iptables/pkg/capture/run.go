@@ -15,13 +15,10 @@ package capture
 
 import (
 	"fmt"
-	"net"
 	"net/netip"
 	"os"
 	"strings"
 
-	"github.com/vishvananda/netlink"
-
 	"istio.io/istio/tools/istio-iptables/pkg/builder"
 	"istio.io/istio/tools/istio-iptables/pkg/config"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
@@ -53,6 +50,8 @@ type IptablesConfigurator struct {
 	// TODO(abhide): Fix dep.Dependencies with better interface
 	ext dep.Dependencies
 	cfg *config.Config
+	// drift holds the chains that drifted from the desired rules, found by the last reconcile.
+	drift []builder.Drift
 }
 
 func NewIptablesConfigurator(cfg *config.Config, ext dep.Dependencies) *IptablesConfigurator {
@@ -241,52 +240,40 @@ func (cfg *IptablesConfigurator) shortCircuitExcludeInterfaces() {
 	}
 }
 
-func ignoreExists(err error) error {
-	if err == nil {
-		return nil
-	}
-	if strings.Contains(strings.ToLower(err.Error()), "file exists") {
-		return nil
-	}
-	return err
-}
-
-// configureIPv6Addresses sets up a new IP address on local interface. This is used as the source IP
-// for inbound traffic to distinguish traffic we want to capture vs traffic we do not. This is needed
-// for IPv6 but not IPv4, as IPv4 defaults to `netmask 255.0.0.0`, which allows binding to addresses
-// in the 127.x.y.z range, while IPv6 defaults to `prefixlen 128` which allows binding only to ::1.
-// Equivalent to `ip -6 addr add "::6/128" dev lo`
-func configureIPv6Addresses(cfg *config.Config) error {
-	if !cfg.EnableInboundIPv6 {
-		return nil
+func (cfg *IptablesConfigurator) Run() error {
+	backend := ResolveFirewallBackend(cfg.cfg.FirewallBackend)
+	if cfg.cfg.Check && backend == constants.NftablesBackend {
+		return fmt.Errorf("--%s is not supported by the %s backend", constants.Check, constants.NftablesBackend)
 	}
-	link, err := netlink.LinkByName("lo")
-	if err != nil {
-		return fmt.Errorf("failed to find 'lo' link: %v", err)
-	}
-	// Setup a new IP address on local interface. This is used as the source IP for inbound traffic
-	// to distinguish traffic we want to capture vs traffic we do not.
-	// Equivalent to `ip -6 addr add "::6/128" dev lo`
-	address := &net.IPNet{IP: net.ParseIP("::6"), Mask: net.CIDRMask(128, 128)}
-	addr := &netlink.Addr{IPNet: address}
-
-	err = netlink.AddrAdd(link, addr)
-	if ignoreExists(err) != nil {
-		return fmt.Errorf("failed to add IPv6 inbound address: %v", err)
-	}
-	log.Infof("Added ::6 address")
-	return nil
-}
-
-func (cfg *IptablesConfigurator) Run() {
 	defer func() {
 		// Best effort since we don't know if the commands exist
+		if backend == constants.NftablesBackend {
+			_ = cfg.ext.Run(constants.NFT, nil, "list", "ruleset")
+			return
+		}
 		_ = cfg.ext.Run(constants.IPTABLESSAVE, nil)
 		if cfg.cfg.EnableInboundIPv6 {
 			_ = cfg.ext.Run(constants.IP6TABLESSAVE, nil)
 		}
 	}()
 
+	cfg.logConfig()
+	cfg.buildRules()
+	if backend == constants.NftablesBackend {
+		// The nft script replaces the tables atomically, so it is already idempotent.
+		cfg.executeNftCommands()
+		return nil
+	}
+	if cfg.cfg.Reconcile || cfg.cfg.Check {
+		cfg.reconcile()
+		return nil
+	}
+	cfg.executeCommands()
+	return nil
+}
+
+// buildRules builds the traffic capture rules from the configuration, without programming them.
+func (cfg *IptablesConfigurator) buildRules() {
 	// Since OUTBOUND_IP_RANGES_EXCLUDE could carry ipv4 and ipv6 ranges
 	// need to split them in different arrays one for ipv4 and one for ipv6
 	// in order to not to fail
@@ -305,7 +292,6 @@ func (cfg *IptablesConfigurator) Run() {
 	}
 
 	redirectDNS := cfg.cfg.RedirectDNS
-	cfg.logConfig()
 
 	cfg.shortCircuitExcludeInterfaces()
 
@@ -542,7 +528,6 @@ func (cfg *IptablesConfigurator) Run() {
 		cfg.iptables.InsertRule(iptableslog.UndefinedCommand, constants.ISTIOINBOUND, constants.MANGLE, 3,
 			"-p", constants.TCP, "-i", "lo", "-m", "mark", "!", "--mark", outboundMark, "-j", constants.RETURN)
 	}
-	cfg.executeCommands()
 }
 
 type UDPRuleApplier struct {
@@ -766,3 +751,71 @@ func (cfg *IptablesConfigurator) executeCommands() {
 
 	}
 }
+
+// reconcile compares the desired rules to the programmed rules, and applies only the missing or stale ones.
+// In check mode, the drift is only recorded.
+func (cfg *IptablesConfigurator) reconcile() {
+	cfg.drift = nil
+	cfg.reconcileRules(constants.IPTABLESSAVE, constants.IPTABLESRESTORE, cfg.iptables.ReconcileV4)
+	if cfg.cfg.EnableInboundIPv6 {
+		cfg.reconcileRules(constants.IP6TABLESSAVE, constants.IP6TABLESRESTORE, cfg.iptables.ReconcileV6)
+	}
+}
+
+func (cfg *IptablesConfigurator) reconcileRules(save, restore string,
+	reconcile func(current string) (*builder.Reconciliation, error),
+) {
+	current, err := cfg.ext.RunWithOutput(save, nil)
+	if err != nil {
+		panic(fmt.Errorf("failed to read the programmed rules with %s: %v", save, err))
+	}
+	res, err := reconcile(current.String())
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range res.Drift {
+		log.Infof("Drift from the desired rules: %v", d)
+	}
+	cfg.drift = append(cfg.drift, res.Drift...)
+	if cfg.cfg.Check || len(res.Drift) == 0 {
+		return
+	}
+	if cfg.cfg.RestoreFormat {
+		log.Infof("Running %s with the following input:\n%v", restore, strings.TrimSpace(res.Restore))
+		// --noflush to only replace the drifted rules
+		cfg.ext.RunOrFail(restore, strings.NewReader(res.Restore), "--noflush")
+	} else {
+		cfg.executeIptablesCommands(res.Commands)
+	}
+}
+
+// Drift returns the chains that drifted from the desired rules, found by Run in reconcile or check mode.
+func (cfg *IptablesConfigurator) Drift() []builder.Drift {
+	return cfg.drift
+}
+
+// executeNftCommands programs the IPv4 and IPv6 rules in a single atomic nft transaction.
+func (cfg *IptablesConfigurator) executeNftCommands() {
+	data, err := cfg.BuildNft()
+	if err != nil {
+		panic(err)
+	}
+	if data == "" {
+		return
+	}
+	log.Infof("Running %s with the following input:\n%v", constants.NFT, strings.TrimSpace(data))
+	cfg.ext.RunOrFail(constants.NFT, strings.NewReader(data), "-f", "-")
+}
+
+// BuildNft returns the nft script programming all the rules built by Run.
+func (cfg *IptablesConfigurator) BuildNft() (string, error) {
+	v4, err := cfg.iptables.BuildV4Nft()
+	if err != nil {
+		return "", err
+	}
+	v6, err := cfg.iptables.BuildV6Nft()
+	if err != nil {
+		return "", err
+	}
+	return v4 + v6, nil
+}
@@ -15,7 +15,10 @@
 package capture
 
 import (
+	"bytes"
+	"io"
 	"net/netip"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -41,8 +44,11 @@ func constructTestConfig() *config.Config {
 	}
 }
 
-func TestIptables(t *testing.T) {
-	cases := []struct {
+func getCommonTestCases() []struct {
+	name   string
+	config func(cfg *config.Config)
+} {
+	return []struct {
 		name   string
 		config func(cfg *config.Config)
 	}{
@@ -264,12 +270,17 @@ func TestIptables(t *testing.T) {
 			},
 		},
 	}
-	for _, tt := range cases {
+}
+
+func TestIptables(t *testing.T) {
+	for _, tt := range getCommonTestCases() {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := constructTestConfig()
 			tt.config(cfg)
 			iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
-			iptConfigurator.Run()
+			if err := iptConfigurator.Run(); err != nil {
+				t.Fatal(err)
+			}
 			v4Rules := iptConfigurator.iptables.BuildV4()
 			v6Rules := iptConfigurator.iptables.BuildV6()
 			allRules := append(v4Rules, v6Rules...)
@@ -279,6 +290,189 @@ func TestIptables(t *testing.T) {
 	}
 }
 
+func TestNftables(t *testing.T) {
+	for _, tt := range getCommonTestCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			tt.config(cfg)
+			cfg.FirewallBackend = constants.NftablesBackend
+			iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+			if err := iptConfigurator.Run(); err != nil {
+				t.Fatal(err)
+			}
+			actual, err := iptConfigurator.BuildNft()
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareToGolden(t, filepath.Join("nftables", tt.name), strings.Split(strings.TrimSpace(actual), "\n"))
+		})
+	}
+}
+
+func TestResolveFirewallBackend(t *testing.T) {
+	cases := []struct {
+		name     string
+		backend  string
+		binaries map[string]string
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: constants.IptablesBackend,
+		},
+		{
+			name:     "explicit nftables",
+			backend:  constants.NftablesBackend,
+			expected: constants.NftablesBackend,
+		},
+		{
+			name:     "auto without nft",
+			backend:  constants.AutoBackend,
+			binaries: map[string]string{"iptables": "/usr/sbin/xtables-nft-multi"},
+			expected: constants.IptablesBackend,
+		},
+		{
+			name:     "auto without iptables",
+			backend:  constants.AutoBackend,
+			binaries: map[string]string{"nft": "/usr/sbin/nft"},
+			expected: constants.NftablesBackend,
+		},
+		{
+			name:     "auto with iptables-nft",
+			backend:  constants.AutoBackend,
+			binaries: map[string]string{"nft": "/usr/sbin/nft", "iptables": "/usr/sbin/xtables-nft-multi"},
+			expected: constants.NftablesBackend,
+		},
+		{
+			name:     "auto with iptables-legacy",
+			backend:  constants.AutoBackend,
+			binaries: map[string]string{"nft": "/usr/sbin/nft", "iptables": "/usr/sbin/xtables-legacy-multi"},
+			expected: constants.IptablesBackend,
+		},
+	}
+	defer func() {
+		lookPath = exec.LookPath
+		evalSymlinks = filepath.EvalSymlinks
+	}()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			lookPath = func(file string) (string, error) {
+				if _, ok := tt.binaries[file]; ok {
+					return "/usr/sbin/" + file, nil
+				}
+				return "", exec.ErrNotFound
+			}
+			evalSymlinks = func(path string) (string, error) {
+				return tt.binaries[filepath.Base(path)], nil
+			}
+			if got := ResolveFirewallBackend(tt.backend); got != tt.expected {
+				t.Errorf("expected backend %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckWithAutoNftablesBackend(t *testing.T) {
+	defer func() {
+		lookPath = exec.LookPath
+	}()
+	// Without iptables, the auto backend resolves to nftables which cannot check the rules.
+	lookPath = func(file string) (string, error) {
+		if file == constants.NFT {
+			return "/usr/sbin/nft", nil
+		}
+		return "", exec.ErrNotFound
+	}
+	cfg := constructTestConfig()
+	cfg.FirewallBackend = constants.AutoBackend
+	cfg.Check = true
+	iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+	if err := iptConfigurator.Run(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// reconcileStub serves the programmed rules, and records the commands applying rules.
+type reconcileStub struct {
+	dep.StdoutStubDependencies
+	programmed string
+	applied    []string
+}
+
+func (s *reconcileStub) RunOrFail(cmd string, stdin io.ReadSeeker, args ...string) {
+	cmdline := strings.Join(append([]string{cmd}, args...), " ")
+	if stdin != nil {
+		data, _ := io.ReadAll(stdin)
+		cmdline += "\n" + string(data)
+	}
+	s.applied = append(s.applied, cmdline)
+}
+
+func (s *reconcileStub) RunWithOutput(cmd string, stdin io.ReadSeeker, args ...string) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(s.programmed), nil
+}
+
+// toIptablesSave converts iptables-restore input to the iptables-save output after applying it.
+func toIptablesSave(restore string) string {
+	var lines []string
+	for _, line := range strings.Split(restore, "\n") {
+		switch {
+		case strings.HasPrefix(line, "* "):
+			lines = append(lines, "*"+strings.TrimPrefix(line, "* "))
+		case strings.HasPrefix(line, "-N "):
+			lines = append(lines, ":"+strings.TrimPrefix(line, "-N ")+" - [0:0]")
+		default:
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestReconcile(t *testing.T) {
+	iptConfigurator := NewIptablesConfigurator(constructTestConfig(), &dep.StdoutStubDependencies{})
+	if err := iptConfigurator.Run(); err != nil {
+		t.Fatal(err)
+	}
+	programmed := toIptablesSave(iptConfigurator.iptables.BuildV4Restore())
+	// Drop the last rule of the ISTIO_OUTPUT chain.
+	idx := strings.LastIndex(programmed, "-A ISTIO_OUTPUT")
+	drifted := programmed[:idx] + programmed[idx+strings.Index(programmed[idx:], "\n")+1:]
+
+	cases := []struct {
+		name       string
+		programmed string
+		check      bool
+		drift      int
+		applied    int
+	}{
+		{name: "in sync", programmed: programmed},
+		{name: "in sync check", programmed: programmed, check: true},
+		{name: "drift", programmed: drifted, drift: 1, applied: 1},
+		{name: "drift check", programmed: drifted, check: true, drift: 1},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			cfg.Reconcile = !tt.check
+			cfg.Check = tt.check
+			ext := &reconcileStub{programmed: tt.programmed}
+			iptConfigurator := NewIptablesConfigurator(cfg, ext)
+			if err := iptConfigurator.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(iptConfigurator.Drift()); got != tt.drift {
+				t.Errorf("got %d drifted chains, want %d: %v", got, tt.drift, iptConfigurator.Drift())
+			}
+			if len(ext.applied) != tt.applied {
+				t.Fatalf("got %d applied commands, want %d: %v", len(ext.applied), tt.applied, ext.applied)
+			}
+			if tt.applied > 0 && !strings.HasPrefix(ext.applied[0], "iptables-restore --noflush\n* nat\n-F ISTIO_OUTPUT\n") {
+				t.Errorf("unexpected applied rules: %s", ext.applied[0])
+			}
+		})
+	}
+}
+
 func TestSeparateV4V6(t *testing.T) {
 	mkIPList := func(ips ...string) []netip.Prefix {
 		ret := []netip.Prefix{}
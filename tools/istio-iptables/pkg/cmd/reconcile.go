@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// recoverableDependencies panics when a command that must succeed fails, instead of exiting the process.
+type recoverableDependencies struct {
+	dep.Dependencies
+}
+
+func (r recoverableDependencies) RunOrFail(cmd string, stdin io.ReadSeeker, args ...string) {
+	if err := r.Run(cmd, stdin, args...); err != nil {
+		panic(fmt.Errorf("failed to execute: %s %s, %v", cmd, strings.Join(args, " "), err))
+	}
+}
+
+// ConstructConfig returns the traffic capture configuration set with viper, for callers programming the capture
+// without iptables, such as the eBPF redirection of the CNI plugin. It must run in the network namespace of the pod.
+func ConstructConfig() (cfg *config.Config, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("failed to construct the traffic capture config: %v", e)
+		}
+	}()
+	bindFlags(rootCmd, nil)
+	cfg = constructConfig()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ReconcileCapture reconciles the traffic capture rules configured with viper in the current network namespace,
+// then checks them again. It returns the chains that drifted from the desired rules and were repaired, and an
+// error if the rules still drift after the repair. Unlike the root command, failures are returned instead of
+// exiting, so that long running processes such as the CNI repair controller can use it.
+func ReconcileCapture() (drift []builder.Drift, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("failed to reconcile the traffic capture rules: %v", e)
+		}
+	}()
+	bindFlags(rootCmd, nil)
+	cfg := constructConfig()
+	cfg.Reconcile = true
+	cfg.Check = false
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var ext dep.Dependencies
+	if cfg.DryRun {
+		ext = &dep.StdoutStubDependencies{}
+	} else {
+		ext = recoverableDependencies{&dep.RealDependencies{
+			CNIMode:          cfg.CNIMode,
+			HostNSEnterExec:  cfg.HostNSEnterExec,
+			NetworkNamespace: cfg.NetworkNamespace,
+		}}
+	}
+
+	iptConfigurator := capture.NewIptablesConfigurator(cfg, ext)
+	if err := iptConfigurator.Run(); err != nil {
+		return nil, err
+	}
+	if err := capture.ConfigureRoutes(cfg, ext); err != nil {
+		return nil, fmt.Errorf("failed to configure routes: %v", err)
+	}
+	if capture.ResolveFirewallBackend(cfg.FirewallBackend) == constants.NftablesBackend {
+		// The nftables backend replaces the tables atomically, there is no drift to check.
+		return nil, nil
+	}
+
+	check := *cfg
+	check.Reconcile = false
+	check.Check = true
+	verifier := capture.NewIptablesConfigurator(&check, ext)
+	if err := verifier.Run(); err != nil {
+		return nil, err
+	}
+	if remaining := verifier.Drift(); len(remaining) > 0 {
+		return iptConfigurator.Drift(), fmt.Errorf("%d chains still drift from the desired rules after reconciling: %v",
+			len(remaining), remaining)
+	}
+	return iptConfigurator.Drift(), nil
+}
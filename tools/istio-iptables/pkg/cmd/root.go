@@ -60,6 +60,8 @@ var rootCmd = &cobra.Command{
 	PreRun: bindFlags,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := constructConfig()
+		// Resolve the auto backend first, so the configuration is validated against the backend in use.
+		cfg.FirewallBackend = capture.ResolveFirewallBackend(cfg.FirewallBackend)
 		if err := cfg.Validate(); err != nil {
 			handleErrorWithCode(err, 1)
 		}
@@ -76,7 +78,15 @@ var rootCmd = &cobra.Command{
 
 		iptConfigurator := capture.NewIptablesConfigurator(cfg, ext)
 		if !cfg.SkipRuleApply {
-			iptConfigurator.Run()
+			if err := iptConfigurator.Run(); err != nil {
+				handleErrorWithCode(err, 1)
+			}
+			if cfg.Check {
+				if drift := iptConfigurator.Drift(); len(drift) > 0 {
+					handleErrorWithCode(fmt.Errorf("%d chains drifted from the desired rules", len(drift)), constants.DriftErrorCode)
+				}
+				return
+			}
 			if err := capture.ConfigureRoutes(cfg, ext); err != nil {
 				log.Errorf("failed to configure routes: ")
 				handleErrorWithCode(err, 1)
@@ -154,6 +164,9 @@ func constructConfig() *config.Config {
 		NetworkNamespace:        viper.GetString(constants.NetworkNamespace),
 		CNIMode:                 viper.GetBool(constants.CNIMode),
 		HostNSEnterExec:         viper.GetBool(constants.HostNSEnterExec),
+		FirewallBackend:         viper.GetString(constants.FirewallBackend),
+		Reconcile:               viper.GetBool(constants.Reconcile),
+		Check:                   viper.GetBool(constants.Check),
 	}
 
 	// TODO: Make this more configurable, maybe with an allowlist of users to be captured for output instead of a denylist.
@@ -185,12 +198,17 @@ func constructConfig() *config.Config {
 	// case where reading /etc/resolv.conf could fail.
 	// If capture all DNS option is enabled, we don't need to read from the dns resolve conf. All
 	// traffic to port 53 will be captured.
+	// The DNS servers can be set explicitly, e.g. in CNI mode where /etc/resolv.conf is the one of the node.
 	if cfg.RedirectDNS && !cfg.CaptureAllDNS {
-		dnsConfig, err := dns.ClientConfigFromFile("/etc/resolv.conf")
-		if err != nil {
-			panic(fmt.Sprintf("failed to load /etc/resolv.conf: %v", err))
+		if servers := viper.GetString(constants.DNSServers); servers != "" {
+			cfg.DNSServersV4, cfg.DNSServersV6 = netutil.IPsSplitV4V6(strings.Split(servers, ","))
+		} else {
+			dnsConfig, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+			if err != nil {
+				panic(fmt.Sprintf("failed to load /etc/resolv.conf: %v", err))
+			}
+			cfg.DNSServersV4, cfg.DNSServersV6 = netutil.IPsSplitV4V6(dnsConfig.Servers)
 		}
-		cfg.DNSServersV4, cfg.DNSServersV6 = netutil.IPsSplitV4V6(dnsConfig.Servers)
 	}
 	return cfg
 }
@@ -394,6 +412,11 @@ func bindFlags(cmd *cobra.Command, args []string) {
 	}
 	viper.SetDefault(constants.CaptureAllDNS, false)
 
+	if err := viper.BindPFlag(constants.DNSServers, cmd.Flags().Lookup(constants.DNSServers)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.DNSServers, "")
+
 	if err := viper.BindPFlag(constants.NetworkNamespace, cmd.Flags().Lookup(constants.NetworkNamespace)); err != nil {
 		handleError(err)
 	}
@@ -408,6 +431,21 @@ func bindFlags(cmd *cobra.Command, args []string) {
 		handleError(err)
 	}
 	viper.SetDefault(constants.HostNSEnterExec, false)
+
+	if err := viper.BindPFlag(constants.FirewallBackend, cmd.Flags().Lookup(constants.FirewallBackend)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.FirewallBackend, constants.IptablesBackend)
+
+	if err := viper.BindPFlag(constants.Reconcile, cmd.Flags().Lookup(constants.Reconcile)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Reconcile, false)
+
+	if err := viper.BindPFlag(constants.Check, cmd.Flags().Lookup(constants.Check)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Check, false)
 }
 
 // https://github.com/spf13/viper/issues/233.
@@ -489,11 +527,26 @@ func bindCmdlineFlags(rootCmd *cobra.Command) {
 	rootCmd.Flags().Bool(constants.CaptureAllDNS, false,
 		"Instead of only capturing DNS traffic to DNS server IP, capture all DNS traffic at port 53. This setting is only effective when redirect dns is enabled.")
 
+	rootCmd.Flags().String(constants.DNSServers, "",
+		"Comma separated list of the DNS server IPs whose traffic is captured, instead of the nameservers of /etc/resolv.conf. "+
+			"This setting is only effective when redirect dns is enabled and capture all dns is not.")
+
 	rootCmd.Flags().String(constants.NetworkNamespace, "", "The network namespace that iptables rules should be applied to.")
 
 	rootCmd.Flags().Bool(constants.CNIMode, false, "Whether to run as CNI plugin.")
 
 	rootCmd.Flags().Bool(constants.HostNSEnterExec, false, "Instead of using the internal go netns, use the nsenter command for switching network namespaces.")
+
+	rootCmd.Flags().String(constants.FirewallBackend, constants.IptablesBackend,
+		"The backend used to program the rules, one of \"iptables\", \"nftables\" or \"auto\". "+
+			"\"auto\" uses nftables when the nft binary is available and iptables is missing or is the iptables-nft shim")
+
+	rootCmd.Flags().Bool(constants.Reconcile, false,
+		"Compare the desired rules to the programmed rules, and only apply the missing or stale ones instead of appending all the rules")
+
+	rootCmd.Flags().Bool(constants.Check, false,
+		fmt.Sprintf("Only check whether the programmed rules drifted from the desired rules, without applying them. "+
+			"Exits with code %d on drift", constants.DriftErrorCode))
 }
 
 func GetCommand() *cobra.Command {
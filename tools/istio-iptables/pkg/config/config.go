@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
 	"istio.io/pkg/log"
 )
 
@@ -61,6 +62,9 @@ type Config struct {
 	CNIMode                 bool          `json:"CNI_MODE"`
 	HostNSEnterExec         bool          `json:"HOST_NSENTER_EXEC"`
 	TraceLogging            bool          `json:"IPTABLES_TRACE_LOGGING"`
+	FirewallBackend         string        `json:"FIREWALL_BACKEND"`
+	Reconcile               bool          `json:"RECONCILE"`
+	Check                   bool          `json:"CHECK"`
 }
 
 func (c *Config) String() string {
@@ -99,9 +103,18 @@ func (c *Config) Print() {
 	b.WriteString(fmt.Sprintf("CNI_MODE=%s\n", strconv.FormatBool(c.CNIMode)))
 	b.WriteString(fmt.Sprintf("HOST_NSENTER_EXEC=%s\n", strconv.FormatBool(c.HostNSEnterExec)))
 	b.WriteString(fmt.Sprintf("EXCLUDE_INTERFACES=%s\n", c.ExcludeInterfaces))
+	b.WriteString(fmt.Sprintf("FIREWALL_BACKEND=%s\n", c.FirewallBackend))
+	b.WriteString(fmt.Sprintf("RECONCILE=%s\n", strconv.FormatBool(c.Reconcile)))
+	b.WriteString(fmt.Sprintf("CHECK=%s\n", strconv.FormatBool(c.Check)))
 	log.Infof("Istio iptables variables:\n%s", b.String())
 }
 
 func (c *Config) Validate() error {
+	if err := ValidateFirewallBackend(c.FirewallBackend); err != nil {
+		return err
+	}
+	if c.Check && c.FirewallBackend == constants.NftablesBackend {
+		return fmt.Errorf("--%s is not supported by the %s backend", constants.Check, constants.NftablesBackend)
+	}
 	return ValidateOwnerGroups(c.OwnerGroupsInclude, c.OwnerGroupsExclude)
 }
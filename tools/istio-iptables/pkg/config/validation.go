@@ -16,6 +16,8 @@ package config
 
 import (
 	"fmt"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
 )
 
 const (
@@ -41,3 +43,12 @@ func ValidateOwnerGroups(include, exclude string) error {
 	}
 	return nil
 }
+
+func ValidateFirewallBackend(backend string) error {
+	switch backend {
+	case "", constants.IptablesBackend, constants.NftablesBackend, constants.AutoBackend:
+		return nil
+	}
+	return fmt.Errorf("invalid firewall backend %q, must be one of %s, %s or %s",
+		backend, constants.IptablesBackend, constants.NftablesBackend, constants.AutoBackend)
+}
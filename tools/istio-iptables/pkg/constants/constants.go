@@ -105,9 +105,23 @@ const (
 	RedirectDNS               = "redirect-dns"
 	DropInvalid               = "drop-invalid"
 	CaptureAllDNS             = "capture-all-dns"
+	DNSServers                = "dns-servers"
 	NetworkNamespace          = "network-namespace"
 	CNIMode                   = "cni-mode"
 	HostNSEnterExec           = "host-nsenter-exec"
+	FirewallBackend           = "firewall-backend"
+	Reconcile                 = "reconcile"
+	Check                     = "check"
+)
+
+// Backends used to program the traffic capture rules
+const (
+	// IptablesBackend programs the rules with the iptables binaries.
+	IptablesBackend = "iptables"
+	// NftablesBackend programs the rules natively with the nft binary.
+	NftablesBackend = "nftables"
+	// AutoBackend selects nftables on hosts where iptables is missing or is the iptables-nft shim.
+	AutoBackend = "auto"
 )
 
 // Environment variables that deliberately have no equivalent command-line flags.
@@ -154,6 +168,16 @@ const (
 	NSENTER          = "nsenter"
 )
 
+// Constants for nftables commands
+const (
+	NFT = "nft"
+	// NftFamilyIPv4 and NftFamilyIPv6 are the nftables address families of the IPv4 and IPv6 rules.
+	NftFamilyIPv4 = "ip"
+	NftFamilyIPv6 = "ip6"
+	// NftTablePrefix is prepended to the iptables table names to name the nftables tables.
+	NftTablePrefix = "istio-"
+)
+
 // Constants for syscall
 const (
 	// sys/socket.h
@@ -168,6 +192,8 @@ const (
 const (
 	ValidationContainerName = "istio-validation"
 	ValidationErrorCode     = 126
+	// DriftErrorCode is the exit code of a check finding rules that drifted from the desired rules.
+	DriftErrorCode = 125
 )
 
 // DNS ports
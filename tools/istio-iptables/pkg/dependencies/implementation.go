@@ -131,9 +131,9 @@ func exitCode(err error) (int, bool) {
 func (r *RealDependencies) RunOrFail(cmd string, stdin io.ReadSeeker, args ...string) {
 	var err error
 	if XTablesCmds.Contains(cmd) {
-		err = r.executeXTables(cmd, false, stdin, args...)
+		_, err = r.executeXTables(cmd, false, stdin, args...)
 	} else {
-		err = r.execute(cmd, false, stdin, args...)
+		_, err = r.execute(cmd, false, stdin, args...)
 	}
 	if err != nil {
 		log.Errorf("Failed to execute: %s %s, %v", cmd, strings.Join(args, " "), err)
@@ -144,9 +144,9 @@ func (r *RealDependencies) RunOrFail(cmd string, stdin io.ReadSeeker, args ...st
 // Run runs a command
 func (r *RealDependencies) Run(cmd string, stdin io.ReadSeeker, args ...string) (err error) {
 	if XTablesCmds.Contains(cmd) {
-		err = r.executeXTables(cmd, false, stdin, args...)
+		_, err = r.executeXTables(cmd, false, stdin, args...)
 	} else {
-		err = r.execute(cmd, false, stdin, args...)
+		_, err = r.execute(cmd, false, stdin, args...)
 	}
 	return err
 }
@@ -154,8 +154,16 @@ func (r *RealDependencies) Run(cmd string, stdin io.ReadSeeker, args ...string)
 // RunQuietlyAndIgnore runs a command quietly and ignores errors
 func (r *RealDependencies) RunQuietlyAndIgnore(cmd string, stdin io.ReadSeeker, args ...string) {
 	if XTablesCmds.Contains(cmd) {
-		_ = r.executeXTables(cmd, true, stdin, args...)
+		_, _ = r.executeXTables(cmd, true, stdin, args...)
 	} else {
-		_ = r.execute(cmd, true, stdin, args...)
+		_, _ = r.execute(cmd, true, stdin, args...)
 	}
 }
+
+// RunWithOutput runs a command and returns its standard output
+func (r *RealDependencies) RunWithOutput(cmd string, stdin io.ReadSeeker, args ...string) (*bytes.Buffer, error) {
+	if XTablesCmds.Contains(cmd) {
+		return r.executeXTables(cmd, false, stdin, args...)
+	}
+	return r.execute(cmd, false, stdin, args...)
+}
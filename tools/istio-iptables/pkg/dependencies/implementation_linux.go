@@ -31,7 +31,7 @@ import (
 	"istio.io/pkg/log"
 )
 
-func (r *RealDependencies) execute(cmd string, ignoreErrors bool, stdin io.Reader, args ...string) error {
+func (r *RealDependencies) execute(cmd string, ignoreErrors bool, stdin io.Reader, args ...string) (*bytes.Buffer, error) {
 	if r.CNIMode && r.HostNSEnterExec {
 		originalCmd := cmd
 		cmd = constants.NSENTER
@@ -60,7 +60,7 @@ func (r *RealDependencies) execute(cmd string, ignoreErrors bool, stdin io.Reade
 	if r.CNIMode && !r.HostNSEnterExec {
 		nsContainer, err = ns.GetNS(r.NetworkNamespace)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		err = nsContainer.Do(func(ns.NetNS) error {
@@ -79,10 +79,10 @@ func (r *RealDependencies) execute(cmd string, ignoreErrors bool, stdin io.Reade
 		log.Errorf("Command error output: \n%v", stderr.String())
 	}
 
-	return err
+	return stdout, err
 }
 
-func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin io.ReadSeeker, args ...string) error {
+func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin io.ReadSeeker, args ...string) (*bytes.Buffer, error) {
 	if r.CNIMode && r.HostNSEnterExec {
 		originalCmd := cmd
 		cmd = constants.NSENTER
@@ -97,7 +97,7 @@ func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin i
 	if r.CNIMode && !r.HostNSEnterExec {
 		nsContainer, err = ns.GetNS(r.NetworkNamespace)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer nsContainer.Close()
 	}
@@ -145,7 +145,7 @@ func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin i
 		return err
 	})
 	if backoffError != nil {
-		return fmt.Errorf("timed out trying to acquire XTables lock: %v", err)
+		return nil, fmt.Errorf("timed out trying to acquire XTables lock: %v", err)
 	}
 
 	if len(stdout.String()) != 0 {
@@ -164,5 +164,5 @@ func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin i
 		log.Errorf("Command error output: %v", stderrStr)
 	}
 
-	return err
+	return stdout, err
 }
@@ -18,6 +18,7 @@
 package dependencies
 
 import (
+	"bytes"
 	"errors"
 	"io"
 )
@@ -25,10 +26,10 @@ import (
 // ErrNotImplemented is returned when a requested feature is not implemented.
 var ErrNotImplemented = errors.New("not implemented")
 
-func (r *RealDependencies) execute(cmd string, ignoreErrors bool, stdin io.Reader, args ...string) error {
-	return ErrNotImplemented
+func (r *RealDependencies) execute(cmd string, ignoreErrors bool, stdin io.Reader, args ...string) (*bytes.Buffer, error) {
+	return nil, ErrNotImplemented
 }
 
-func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin io.Reader, args ...string) error {
-	return ErrNotImplemented
+func (r *RealDependencies) executeXTables(cmd string, ignoreErrors bool, stdin io.Reader, args ...string) (*bytes.Buffer, error) {
+	return nil, ErrNotImplemented
 }
@@ -14,7 +14,10 @@
 
 package dependencies
 
-import "io"
+import (
+	"bytes"
+	"io"
+)
 
 // Dependencies is used as abstraction for the commands used from the operating system
 type Dependencies interface {
@@ -24,4 +27,6 @@ type Dependencies interface {
 	Run(cmd string, stdin io.ReadSeeker, args ...string) error
 	// RunQuietlyAndIgnore runs a command quietly and ignores errors
 	RunQuietlyAndIgnore(cmd string, stdin io.ReadSeeker, args ...string)
+	// RunWithOutput runs a command and returns its standard output
+	RunWithOutput(cmd string, stdin io.ReadSeeker, args ...string) (*bytes.Buffer, error)
 }
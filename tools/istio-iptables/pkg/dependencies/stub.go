@@ -15,6 +15,7 @@
 package dependencies
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -60,3 +61,9 @@ func (s *StdoutStubDependencies) Run(cmd string, stdin io.ReadSeeker, args ...st
 func (s *StdoutStubDependencies) RunQuietlyAndIgnore(cmd string, stdin io.ReadSeeker, args ...string) {
 	s.RunOrFail(cmd, stdin, args...)
 }
+
+// RunWithOutput runs a command and returns an empty output
+func (s *StdoutStubDependencies) RunWithOutput(cmd string, stdin io.ReadSeeker, args ...string) (*bytes.Buffer, error) {
+	s.RunOrFail(cmd, stdin, args...)
+	return &bytes.Buffer{}, nil
+}